@@ -0,0 +1,93 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// batchFlags are the flags used by the batch commands to configure
+// the batch materialization request.
+type batchFlags struct {
+	format        batchFormat
+	formatOptions []string
+	callbackURL   string
+	listener      string
+	email         string
+	accountID     string
+	tags          []string
+}
+
+// addBatchFlags adds the flags configuring batch materialization to a batch command.
+func addBatchFlags(fs *pflag.FlagSet, flags *batchFlags) {
+	fs.Var(&flags.format, "format", `output format, one of "TIF", "TILE_STREAM", "TMS", "VECTOR", or "VECTOR_TILE"`)
+	fs.StringArrayVar(&flags.formatOptions, "format-option", []string{}, "key/value pairs (comma seperated) of options for the output format")
+	fs.StringVar(&flags.callbackURL, "callback-url", "", "URL that RDA will POST to when the job completes")
+	fs.StringVar(&flags.listener, "listener", "", "URL RDA can reach an \"rda job listen\" listener at, e.g. http://myhost:8080, to call back when the job completes")
+	fs.StringVar(&flags.email, "email", "", "email address that RDA will notify when the job completes")
+	fs.StringVar(&flags.accountID, "account-id", "", "GBDX account id to charge the job to")
+	fs.StringArrayVar(&flags.tags, "tag", []string{}, "tag to record the job with locally, for use with \"rda job list\"; repeat to add several")
+}
+
+// options returns the batch options described by the flags.
+func (flags *batchFlags) options() ([]rda.BatchOption, error) {
+	var options []rda.BatchOption
+	for _, kv := range flags.formatOptions {
+		s := strings.SplitN(kv, ",", 2)
+		if len(s) != 2 {
+			return nil, errors.Errorf("--format-option = %q is not of the form \"key,value\"", kv)
+		}
+		options = append(options, rda.WithFormatOption(strings.TrimSpace(s[0]), strings.TrimSpace(s[1])))
+	}
+	if flags.listener != "" {
+		if flags.callbackURL != "" {
+			return nil, errors.New("--listener and --callback-url cannot be set at the same time")
+		}
+		callbackURL, err := listenerCallbackURL(flags.listener)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, rda.WithCallbackURL(callbackURL))
+	}
+	if flags.callbackURL != "" {
+		u, err := url.Parse(flags.callbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.Errorf("--callback-url = %q is not an http(s) URL", flags.callbackURL)
+		}
+		options = append(options, rda.WithCallbackURL(flags.callbackURL))
+	}
+	if flags.email != "" {
+		if _, err := mail.ParseAddress(flags.email); err != nil {
+			return nil, errors.Wrapf(err, "--email = %q is not an email address", flags.email)
+		}
+		options = append(options, rda.WithEmailAddress(flags.email))
+	}
+	if flags.accountID != "" {
+		options = append(options, rda.WithAccountID(flags.accountID))
+	}
+	return options, nil
+}
@@ -27,6 +27,7 @@ import (
 	"net/http"
 
 	"github.com/DigitalGlobe/rdatools/rda/pkg/gbdx"
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/spf13/viper"
 	"golang.org/x/oauth2"
@@ -90,8 +91,9 @@ func newTokenSource(ctx context.Context) (oauth2.TokenSource, func() error, erro
 		return nil, nil, err
 	}
 
+	_, tokenURL, _ := config.Endpoints()
 	oauth2Conf := &oauth2.Config{
-		Endpoint: oauth2.Endpoint{TokenURL: gbdx.TokenEndpoint},
+		Endpoint: oauth2.Endpoint{TokenURL: tokenURL},
 	}
 
 	// Configure the token source.
@@ -107,3 +109,44 @@ func newTokenSource(ctx context.Context) (oauth2.TokenSource, func() error, erro
 
 	return ts, updateConfig, nil
 }
+
+// newRDAClient returns a rda.Client that uses client to access the
+// RDA API configured for the active profile.
+func newRDAClient(client *retryablehttp.Client) (*rda.Client, error) {
+	config, err := newConfigFromRDADir()
+	if err != nil {
+		return nil, err
+	}
+	rdaURL, _, _ := config.Endpoints()
+	endpoints, err := rda.NewEndpoints(rdaURL)
+	if err != nil {
+		return nil, err
+	}
+	return rda.NewClient(client, endpoints, rda.WithTemplateOptions(rda.MaxRequestsPerSecond(config.MaxRequestsPerSecond()))), nil
+}
+
+// newS3Accessor returns a gbdx.S3Accessor that fetches its AWS
+// credentials from the GBDX endpoint configured for the active
+// profile, and talks to the profile's S3 endpoint if it has one.
+func newS3Accessor(client *retryablehttp.Client, options ...gbdx.S3AccessorOption) (*gbdx.S3Accessor, error) {
+	config, err := newConfigFromRDADir()
+	if err != nil {
+		return nil, err
+	}
+	_, _, s3CredsURL := config.Endpoints()
+	options = append(options, gbdx.WithProviderOptions(gbdx.WithCredentialsEndpoint(s3CredsURL)))
+	if s3URL := config.S3Endpoint(); s3URL != "" {
+		options = append(options, gbdx.WithS3Endpoint(s3URL))
+	}
+	return gbdx.NewS3Accessor(client, options...)
+}
+
+// newCatalog returns a gbdx.Catalog that searches the GBDX catalog
+// configured for the active profile.
+func newCatalog(client *retryablehttp.Client) (*gbdx.Catalog, error) {
+	config, err := newConfigFromRDADir()
+	if err != nil {
+		return nil, err
+	}
+	return gbdx.NewCatalog(client, gbdx.WithCatalogEndpoint(config.CatalogEndpoint())), nil
+}
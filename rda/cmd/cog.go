@@ -0,0 +1,83 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// cogFlags are the flags used by the realize commands to write a COG
+// rather than a VRT.
+type cogFlags struct {
+	cog       bool
+	keepTiles bool
+	blockSize int
+}
+
+// addCOGFlags adds the flags controlling COG output to a realize command.
+func addCOGFlags(fs *pflag.FlagSet, flags *cogFlags) {
+	fs.BoolVar(&flags.cog, "cog", false, "assemble the realized tiles into a Cloud Optimized GeoTIFF with overviews rather than writing a VRT")
+	fs.BoolVar(&flags.keepTiles, "keep-tiles", false, "keep the realized tiles after assembling them into a COG")
+	fs.IntVar(&flags.blockSize, "cog-blocksize", 256, "width and height of the COG's internal tiles; must be a multiple of 16")
+}
+
+// writeCOG assembles the realized tiles into a COG at cogPath,
+// removing tileDir afterwards unless we've been asked to keep it.  If
+// realization was cancelled or didn't retrieve all numTiles tiles, the
+// COG isn't written so a rerun can pick up where we left off.
+func writeCOG(ctx context.Context, flags cogFlags, cogPath, tileDir string, md *rda.Metadata, tiles []rda.TileInfo, numTiles int, rpcs *rda.RPCs, cutline *rda.Geometry) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	default:
+	}
+	if len(tiles) < numTiles {
+		return errors.Errorf("only %d of %d tiles were realized, not writing a COG; rerun the command to retrieve the rest", len(tiles), numTiles)
+	}
+
+	tStart := time.Now()
+	options := []rda.COGOption{rda.WithCOGBlockSize(flags.blockSize)}
+	if rpcs != nil {
+		options = append(options, rda.WithCOGRPCs(rpcs))
+	}
+	if cutline != nil {
+		options = append(options, rda.WithCOGCutline(cutline))
+	}
+	if err := rda.WriteCOG(cogPath, md, tiles, options...); err != nil {
+		return err
+	}
+	fmt.Printf("Writing COG %s took %s\n", cogPath, time.Since(tStart))
+
+	if flags.keepTiles {
+		return nil
+	}
+	if err := os.RemoveAll(tileDir); err != nil {
+		return errors.Wrap(err, "failed removing tiles after writing COG")
+	}
+	return errors.Wrap(os.Remove(rda.ManifestPath(tileDir)), "failed removing tile manifest after writing COG")
+}
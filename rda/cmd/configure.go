@@ -29,17 +29,89 @@ import (
 	"path/filepath"
 
 	"github.com/BurntSushi/toml"
+	"github.com/DigitalGlobe/rdatools/rda/pkg/gbdx"
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/oauth2"
 )
 
-// Config holds the authorization info needed to access RDA.
+// Config holds the authorization info needed to access RDA, along
+// with where to find RDA and GBDX if not at their default locations.
 type Config struct {
 	Username string        `mapstructure:"gbdx_username" toml:"gbdx_username"`
 	Password string        `mapstructure:"gbdx_password" toml:"gbdx_password"`
 	Token    *oauth2.Token `mapstructure:"gbdx_token" toml:"gbdx_token,omitempty"`
+
+	RDAURL     string `mapstructure:"rda_url" toml:"rda_url,omitempty"`
+	TokenURL   string `mapstructure:"gbdx_token_url" toml:"gbdx_token_url,omitempty"`
+	S3CredsURL string `mapstructure:"gbdx_s3creds_url" toml:"gbdx_s3creds_url,omitempty"`
+	S3URL      string `mapstructure:"s3_url" toml:"s3_url,omitempty"`
+	CatalogURL string `mapstructure:"gbdx_catalog_url" toml:"gbdx_catalog_url,omitempty"`
+
+	MaxRPS float64 `mapstructure:"max_rps" toml:"max_rps,omitempty"`
+}
+
+// endpointKeys are the viper keys that override the endpoints found in a profile.
+var endpointKeys = []string{"rda_url", "gbdx_token_url", "gbdx_s3creds_url"}
+
+// endpointsOverridden returns true if any endpoint was set via the
+// command line or environment rather than the profile.
+func endpointsOverridden() bool {
+	for _, key := range endpointKeys {
+		if viper.GetString(key) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Endpoints returns the RDA, GBDX token, and GBDX S3 credential
+// endpoints to use.  Command line flags take precedence, followed by
+// environment variables, the profile, and lastly the production
+// defaults.
+func (c *Config) Endpoints() (rdaURL, tokenURL, s3CredsURL string) {
+	pick := func(key, profileVal, defaultVal string) string {
+		if val := viper.GetString(key); val != "" {
+			return val
+		}
+		if profileVal != "" {
+			return profileVal
+		}
+		return defaultVal
+	}
+	return pick("rda_url", c.RDAURL, rda.DefaultBaseURL),
+		pick("gbdx_token_url", c.TokenURL, gbdx.TokenEndpoint),
+		pick("gbdx_s3creds_url", c.S3CredsURL, gbdx.S3CredentialsEndpoint)
+}
+
+// S3Endpoint returns the S3 API to fetch RDA batch artifacts from,
+// with the same precedence as Endpoints.  An empty string means AWS.
+func (c *Config) S3Endpoint() string {
+	if val := viper.GetString("s3_url"); val != "" {
+		return val
+	}
+	return c.S3URL
+}
+
+// CatalogEndpoint returns the GBDX catalog search endpoint, with the
+// same precedence as Endpoints.  An empty string means the default.
+func (c *Config) CatalogEndpoint() string {
+	if val := viper.GetString("gbdx_catalog_url"); val != "" {
+		return val
+	}
+	return c.CatalogURL
+}
+
+// MaxRequestsPerSecond returns the cap on how many tile requests are
+// made to RDA each second, with the same precedence as Endpoints.
+// Zero means no cap.
+func (c *Config) MaxRequestsPerSecond() float64 {
+	if val := viper.GetFloat64("max_rps"); val > 0 {
+		return val
+	}
+	return c.MaxRPS
 }
 
 // configureCmd represents the configure command
@@ -53,24 +125,36 @@ var configureCmd = &cobra.Command{
 			return err
 		}
 
-		// Get the configuration overrides from the user via the command line.
+		// Get the configuration overrides from the user via the
+		// command line.  Blank input keeps the current value; for
+		// values with a default, "-" clears the value to go back to it.
 		var configVars = []struct {
 			prompt   string
 			val      *string
 			isSecret bool
+			fallback string // What an unset value means, if it can be unset.
 		}{
-			{"GBDX Email", &config.Username, false},
-			{"GBDX Password", &config.Password, true},
+			{"GBDX Email", &config.Username, false, ""},
+			{"GBDX Password", &config.Password, true, ""},
+			{"RDA URL", &config.RDAURL, false, "the default"},
+			{"GBDX Token URL", &config.TokenURL, false, "the default"},
+			{"GBDX S3 Credentials URL", &config.S3CredsURL, false, "the default"},
+			{"S3 URL", &config.S3URL, false, "AWS"},
+			{"GBDX Catalog Search URL", &config.CatalogURL, false, "the default"},
 		}
 		for _, configVar := range configVars {
 			// Pretty print the prompt for this variable.
 			fmt.Printf(configVar.prompt)
-			if val := *configVar.val; len(val) > 0 {
-				if configVar.isSecret {
-					fmt.Printf(" [%s]", secretString(val[max(0, len(val)-10):]))
-				} else {
-					fmt.Printf(" [%s]", val)
-				}
+			val := *configVar.val
+			switch {
+			case len(val) > 0 && configVar.isSecret:
+				fmt.Printf(" [%s]", secretString(val[max(0, len(val)-10):]))
+			case len(val) > 0 && configVar.fallback != "":
+				fmt.Printf(" [%s] (- for %s)", val, configVar.fallback)
+			case len(val) > 0:
+				fmt.Printf(" [%s]", val)
+			case configVar.fallback != "":
+				fmt.Printf(" (blank for %s)", configVar.fallback)
 			}
 			fmt.Printf(": ")
 
@@ -82,7 +166,10 @@ var configureCmd = &cobra.Command{
 				}
 				return fmt.Errorf("your input is bogus: %v", err)
 			}
-			if len(s) > 0 {
+			switch {
+			case s == "-" && configVar.fallback != "":
+				*configVar.val = ""
+			case len(s) > 0:
 				*configVar.val = s
 			}
 		}
@@ -104,6 +191,11 @@ func newConfig() (Config, error) {
 		config.Token = nil
 	}
 
+	// A cached token is only good for the endpoint that issued it.
+	if endpointsOverridden() {
+		config.Token = nil
+	}
+
 	// We expect these to have been set at this point, otherwise the config will be unusable.
 	if config.Username == "" {
 		return Config{}, errors.New("no username found to use for authorization")
@@ -129,9 +221,9 @@ func newConfigFromRDADir() (Config, error) {
 //
 // Note that we only update the profile associated with the "profile"
 // variable in viper.  We do not write a profile if GBDX_USERNAME or
-// GBDX_PASSWORD are set.
+// GBDX_PASSWORD are set, or if any endpoints were overridden.
 func writeConfig(config *Config, ts oauth2.TokenSource) error {
-	if viper.IsSet("gbdx_username") || viper.IsSet("gbdx_password") {
+	if viper.IsSet("gbdx_username") || viper.IsSet("gbdx_password") || endpointsOverridden() {
 		return nil
 	}
 
@@ -0,0 +1,62 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// cutlineFlags are the flags used by the realize and batch commands to
+// crop to an arbitrary geometry.
+type cutlineFlags struct {
+	path string
+	srs  string
+}
+
+// addCutlineFlags adds the flags controlling cutlines to a realize or
+// batch command.
+func addCutlineFlags(fs *pflag.FlagSet, flags *cutlineFlags) {
+	fs.StringVar(&flags.path, "cutline", "", "crop to the polygon or multipolygon in a WKT or GeoJSON file, fetching only the tiles that intersect it")
+	fs.StringVar(&flags.srs, "cutline-srs", "", `coordinate reference system of the cutline, e.g. "EPSG:4326", overriding any in the file; otherwise WKT is taken to be in the image's and GeoJSON in EPSG:4326`)
+}
+
+// readCutline returns the cutline we were given, reprojected into the
+// image's coordinate reference system.
+func readCutline(flags cutlineFlags, md *rda.Metadata) (*rda.Geometry, error) {
+	imageSRS := md.ImageGeoreferencing.SpatialReferenceSystemCode
+	if imageSRS == "" {
+		return nil, errors.New("--cutline can't be used with imagery that isn't georeferenced")
+	}
+	g, err := rda.ReadGeometry(flags.path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case flags.srs != "":
+		g.SRS = flags.srs
+	case g.SRS == "":
+		g.SRS = imageSRS
+	}
+	g, err = g.Transform(imageSRS)
+	return g, errors.Wrap(err, "failed reprojecting the cutline into the image's coordinate reference system")
+}
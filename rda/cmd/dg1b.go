@@ -73,6 +73,11 @@ cavis), and part number to get (starting at 1), in that order. Use the
 			}
 		}()
 
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
 		// Parse the args.
 		catID, bandName := args[0], args[1]
 		partNum, err := strconv.Atoi(args[2])
@@ -86,12 +91,12 @@ cavis), and part number to get (starting at 1), in that order. Use the
 		bandName = strings.ToLower(bandName)
 
 		// Go find the rda image id associated with this part.
-		parts, err := rda.PartSummary(client, catID)
+		parts, err := api.PartSummary(catID)
 		if err != nil {
 			return err
 		}
 
-		var images []rda.ImageMetadata
+		var images []rda.StripImage
 		switch bandName {
 		case "pan":
 			images = parts.PanImages
@@ -110,7 +115,7 @@ cavis), and part number to get (starting at 1), in that order. Use the
 		imageMD := images[partNum]
 
 		// Get the metadata.
-		template := rda.NewTemplate(dg1bTemplateID, client,
+		template := api.NewTemplate(dg1bTemplateID,
 			rda.AddParameter("imageId", imageMD.ImageID),
 			rda.AddParameter("bucketName", imageMD.TileBucketName))
 		md, err := template.Metadata()
@@ -139,7 +144,12 @@ var dg1bPartsCmd = &cobra.Command{
 			}
 		}()
 
-		parts, err := rda.PartSummary(client, args[0])
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
+		parts, err := api.PartSummary(args[0])
 		if err != nil {
 			return err
 		}
@@ -156,7 +166,7 @@ var dg1bPartsCmd = &cobra.Command{
 		}{}
 		for _, bandType := range []struct {
 			bs    **BandSummary
-			parts []rda.ImageMetadata
+			parts []rda.StripImage
 		}{
 			{&summary.Cavis, parts.CavisImages},
 			{&summary.Pan, parts.PanImages},
@@ -183,10 +193,23 @@ var dg1bRealizeCmd = &cobra.Command{
 
 You must provide the catalog id, band (e.g. pan, vnir, swir, or
 cavis), part number to get (starting at 1), and output directory. Use the
-"dg1b parts" command to figure out valid bands and part numbers.`,
+"dg1b parts" command to figure out valid bands and part numbers.
+
+With --calibrate radiance or --calibrate reflectance, the realized DNs
+are converted to top of atmosphere radiance or reflectance using the
+part's factory metadata, and written as Float32 tiles under
+tiles/radiance or tiles/reflectance, with the VRT or COG, named e.g.
+MUL_P001_reflectance, made from them rather than the DN tiles.
+Rerunning the command over a part realized without it calibrates the
+DN tiles already realized.`,
 
 	Args: cobra.ExactArgs(4),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		calibration := rda.CalibrationType(strings.ToLower(dg1bFlags.calibrate))
+		if calibration != "" && calibration != rda.Radiance && calibration != rda.Reflectance {
+			return errors.Errorf("--calibrate must be %s or %s, not %q", rda.Radiance, rda.Reflectance, dg1bFlags.calibrate)
+		}
+
 		// The http client.
 		ctx := context.Background()
 		client, writeConfig, err := newClient(ctx)
@@ -199,6 +222,11 @@ cavis), part number to get (starting at 1), and output directory. Use the
 			}
 		}()
 
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
 		// Parse the args.
 		catID, bandName := args[0], args[1]
 		partNum, err := strconv.Atoi(args[2])
@@ -213,12 +241,12 @@ cavis), part number to get (starting at 1), and output directory. Use the
 		outDir := args[3]
 
 		// Go find the rda image id associated with this part, building the metadata prefix while we're at it.
-		parts, err := rda.PartSummary(client, catID)
+		parts, err := api.PartSummary(catID)
 		if err != nil {
 			return err
 		}
 
-		var images []rda.ImageMetadata
+		var images []rda.StripImage
 		var partPrefix string
 		switch bandName {
 		case "pan":
@@ -241,16 +269,18 @@ cavis), part number to get (starting at 1), and output directory. Use the
 		}
 
 		// Download the metadata and extract the relevent files to outDir.
-		rpcs, err := rda.PartMetadata(client, catID, partPrefix, outDir)
+		rpcs, err := api.PartMetadata(catID, partPrefix, outDir)
 		if err != nil {
 			return err
 		}
 
 		// Get the RDA metadata.
 		imageMD := images[partNum]
-		template := rda.NewTemplate(dg1bTemplateID, client,
+		template := api.NewTemplate(dg1bTemplateID,
 			rda.AddParameter("imageId", imageMD.ImageID),
-			rda.AddParameter("bucketName", imageMD.TileBucketName))
+			rda.AddParameter("bucketName", imageMD.TileBucketName),
+			rda.NumParallel(int(dg1bFlags.maxconcurr)),
+			rda.VerifyTiles(dg1bFlags.verify))
 		md, err := template.Metadata()
 		if err != nil {
 			return err
@@ -278,13 +308,31 @@ cavis), part number to get (starting at 1), and output directory. Use the
 			return err
 		}
 
+		// Calibrate the tiles, if asked to, and assemble the calibrated tiles rather than the DNs.
+		outName := partPrefix
+		if calibration != "" {
+			if md, tiles, err = calibrate1B(ctx, calibration, md, tiles, outDir, partPrefix, tileDir); err != nil {
+				return err
+			}
+			if len(tiles) < 1 {
+				return nil
+			}
+			outName = partPrefix + "_" + string(calibration)
+		}
+
+		// Assemble the tiles into a COG, if asked to, carrying the RPCs along.
+		if dg1bFlags.cog.cog {
+			cogPath := filepath.Join(outDir, outName+".tif")
+			return writeCOG(ctx, dg1bFlags.cog, cogPath, tileDir, md, tiles, md.ImageMetadata.NumXTiles*md.ImageMetadata.NumYTiles, rpcs, nil)
+		}
+
 		// Build VRT struct and write it to disk.
 		vrt, err := rda.NewVRT(md, tiles, rpcs)
 		if err != nil {
 			return err
 		}
 
-		vrtPath := filepath.Join(outDir, partPrefix+".vrt")
+		vrtPath := filepath.Join(outDir, outName+".vrt")
 		f, err := os.Create(vrtPath)
 		if err != nil {
 			return errors.Wrap(err, "failed creating VRT for downloaded tiles")
@@ -304,9 +352,60 @@ cavis), part number to get (starting at 1), and output directory. Use the
 	},
 }
 
+// calibrate1B converts the realized DNs of the 1B part partPrefix to
+// calibration, using the part's factory metadata extracted to outDir,
+// and returns the calibrated tiles, which are written to a directory
+// in tileDir named for calibration.
+func calibrate1B(ctx context.Context, calibration rda.CalibrationType, md *rda.Metadata, tiles []rda.TileInfo, outDir, partPrefix, tileDir string) (*rda.Metadata, []rda.TileInfo, error) {
+	// The part's XML has its IMD too, but isn't always delivered.
+	imdPath := filepath.Join(outDir, partPrefix+".XML")
+	if _, err := os.Stat(imdPath); err != nil {
+		imdPath = filepath.Join(outDir, partPrefix+".IMD")
+	}
+	f, err := os.Open(imdPath)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed opening the part's factory metadata to calibrate with")
+	}
+	defer f.Close()
+	imd, err := rda.ParseIMD(f)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed parsing %s", imdPath)
+	}
+
+	bar := pb.StartNew(len(tiles))
+	c, err := rda.NewCalibrator(imd, calibration, rda.WithCalibrationProgressFunc(bar.Increment))
+	if err != nil {
+		bar.Finish()
+		return nil, nil, err
+	}
+
+	tStart := time.Now()
+	calibratedMD, calibrated, err := c.CalibrateTiles(ctx, md, tiles, filepath.Join(tileDir, string(calibration)))
+	if err != nil {
+		bar.Finish()
+		return nil, nil, err
+	}
+	bar.FinishPrint(fmt.Sprintf("Calibrating tiles to %s took %s", calibration, time.Since(tStart)))
+	return calibratedMD, calibrated, nil
+}
+
+var dg1bFlags struct {
+	maxconcurr uint64
+	verify     bool
+	calibrate  string
+
+	cog cogFlags
+}
+
 func init() {
 	rootCmd.AddCommand(dg1bCmd)
 	dg1bCmd.AddCommand(dg1bMetadataCmd)
 	dg1bCmd.AddCommand(dg1bPartsCmd)
 	dg1bCmd.AddCommand(dg1bRealizeCmd)
+
+	// Local flags specific to realizing tiles.
+	dg1bRealizeCmd.Flags().Uint64Var(&dg1bFlags.maxconcurr, "maxconcurrency", 0, "set the most concurrent requests to allow, which adapts downward when RDA is struggling; by default, 4 * num CPUs is used")
+	dg1bRealizeCmd.Flags().BoolVar(&dg1bFlags.verify, "verify", false, "check the checksum of every previously realized tile against the manifest, fetching any that don't match again")
+	dg1bRealizeCmd.Flags().StringVar(&dg1bFlags.calibrate, "calibrate", "", "convert the realized DNs to top of atmosphere \"radiance\" or \"reflectance\", written as Float32 tiles")
+	addCOGFlags(dg1bRealizeCmd.Flags(), &dg1bFlags.cog)
 }
@@ -0,0 +1,51 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDG1BRealize(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "rda-cmd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	if _, err := runCommand(t, "dg1b", "realize", testCatalogID, "vnir", "1", outDir); err != nil {
+		t.Fatalf("dg1b realize failed: %v", err)
+	}
+	checkRealized(t, filepath.Join(outDir, "MUL_P001.vrt"), filepath.Join(outDir, "tiles"), 4)
+
+	// Calibrating reuses the DN tiles just realized.
+	if _, err := runCommand(t, "dg1b", "realize", testCatalogID, "vnir", "1", outDir, "--calibrate", "reflectance"); err != nil {
+		t.Fatalf("dg1b realize --calibrate failed: %v", err)
+	}
+	checkRealized(t, filepath.Join(outDir, "MUL_P001_reflectance.vrt"), filepath.Join(outDir, "tiles", "reflectance"), 4)
+
+	if _, err := runCommand(t, "dg1b", "realize", testCatalogID, "vnir", "1", outDir, "--calibrate", "brightness"); err == nil {
+		t.Fatal("expected an unknown calibration to be refused")
+	}
+}
@@ -79,21 +79,31 @@ var dgstripRealizeCmd = &cobra.Command{
 			}
 		}()
 
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
 		// Get the metadata and figure out what RDA tiles need to be downloaded.
 		catID, vrtPath := args[0], args[1]
-		template := rda.NewTemplate(dgstripTemplateID, client, dgstripTemplateOptions(catID)...)
+		if err := checkDGStrip(api, catID); err != nil {
+			return err
+		}
+		template := api.NewTemplate(dgstripTemplateID, append(dgstripTemplateOptions(catID), rda.NumParallel(int(dgstripFlags.maxconcurr)), rda.VerifyTiles(dgstripFlags.verify))...)
 		md, err := template.Metadata()
 		if err != nil {
 			return err
 		}
-		tileWindow, err := processSubWindows(&dgstripFlags.srcWin, &dgstripFlags.projWin, md)
+		cutline, tileWindow, err := processSubWindows(&dgstripFlags.window, md)
 		if err != nil {
 			return err
 		}
 		rda.WithWindow(*tileWindow)(template)
+		rda.WithCutline(cutline)(template)
 
 		// Get the tiles.
-		bar := pb.StartNew(tileWindow.NumXTiles * tileWindow.NumYTiles)
+		numTiles := template.NumTiles()
+		bar := pb.StartNew(numTiles)
 		rda.WithProgressFunc(bar.Increment)(template)
 
 		tileDir := vrtPath[:len(vrtPath)-len(path.Ext(vrtPath))]
@@ -105,7 +115,7 @@ var dgstripRealizeCmd = &cobra.Command{
 
 		select {
 		case <-ctx.Done():
-			bar.FinishPrint(fmt.Sprintf("Completed %d of %d tiles before cancellation; rerun the command to pick up where you left off.", len(tiles), tileWindow.NumXTiles*tileWindow.NumYTiles))
+			bar.FinishPrint(fmt.Sprintf("Completed %d of %d tiles before cancellation; rerun the command to pick up where you left off.", len(tiles), numTiles))
 		default:
 			bar.FinishPrint(fmt.Sprintf("Tile retrieval took %s", time.Since(tStart)))
 		}
@@ -113,11 +123,21 @@ var dgstripRealizeCmd = &cobra.Command{
 			return err
 		}
 
+		// Assemble the tiles into a COG, if asked to, written where the VRT would have gone.
+		if dgstripFlags.cog.cog {
+			return writeCOG(ctx, dgstripFlags.cog, vrtPath, tileDir, md, tiles, numTiles, nil, cutline)
+		}
+
 		// Build VRT struct and write it to disk.
 		vrt, err := rda.NewVRT(md, tiles, nil)
 		if err != nil {
 			return err
 		}
+		if cutline != nil {
+			if err := vrt.AddCutlineMask(md, tiles, cutline); err != nil {
+				return err
+			}
+		}
 
 		f, err := os.Create(vrtPath)
 		if err != nil {
@@ -155,29 +175,48 @@ var dgstripBatchCmd = &cobra.Command{
 			}
 		}()
 
-		// Get the metadata and figure out what RDA tiles need to be downloaded.
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
+		batchOptions, err := dgstripFlags.batch.options()
+		if err != nil {
+			return err
+		}
+
+		// Get the metadata and make sure it can be output in the requested format.
 		catID := args[0]
-		template := rda.NewTemplate(dgstripTemplateID, client, dgstripTemplateOptions(catID)...)
+		if err := checkDGStrip(api, catID); err != nil {
+			return err
+		}
+		template := api.NewTemplate(dgstripTemplateID, dgstripTemplateOptions(catID)...)
+		md, err := template.Metadata()
+		if err != nil {
+			return err
+		}
+		format := rda.BatchFormat(dgstripFlags.batch.format)
+		if err := format.CheckMetadata(md); err != nil {
+			return err
+		}
 
-		// If we were given a subwindow, figure out its
-		// mapping to RDA tiles.
-		if (dgstripFlags.projWin != projectionWindow{} || dgstripFlags.srcWin != sourceWindow{}) {
-			md, err := template.Metadata()
-			if err != nil {
-				return err
-			}
-			tileWindow, err := processSubWindows(&dgstripFlags.srcWin, &dgstripFlags.projWin, md)
+		// If we were given a subwindow or cutline, figure out
+		// its mapping to RDA tiles.
+		if dgstripFlags.window.isSet() {
+			cutline, tileWindow, err := processSubWindows(&dgstripFlags.window, md)
 			if err != nil {
 				return err
 			}
 			rda.WithWindow(*tileWindow)(template)
+			rda.WithCutline(cutline)(template)
 		}
 
 		// Submit as a batch job.
-		resp, err := template.BatchRealize(ctx, rda.Tif)
+		resp, err := template.BatchRealize(ctx, format, batchOptions...)
 		if err != nil {
 			return err
 		}
+		recordJob(resp, dgstripFlags.batch.tags)
 
 		return json.NewEncoder(os.Stdout).Encode(resp)
 	},
@@ -200,9 +239,17 @@ var dgstripMetadataCmd = &cobra.Command{
 			}
 		}()
 
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
 		// Get the metadata.
 		catID := args[0]
-		template := rda.NewTemplate(dgstripTemplateID, client, dgstripTemplateOptions(catID)...)
+		if err := checkDGStrip(api, catID); err != nil {
+			return err
+		}
+		template := api.NewTemplate(dgstripTemplateID, dgstripTemplateOptions(catID)...)
 		md, err := template.Metadata()
 		if err != nil {
 			return err
@@ -212,6 +259,30 @@ var dgstripMetadataCmd = &cobra.Command{
 	},
 }
 
+// checkDGStrip returns an error if the strip doesn't offer the band
+// type and correction asked for, rather than leaving RDA to fail the
+// request for its metadata.
+func checkDGStrip(api *rda.Client, catalogID string) error {
+	strip, err := api.StripMetadata(catalogID)
+	if err != nil {
+		return err
+	}
+
+	var correctionType string
+	switch {
+	case dgstripFlags.acomp && dgstripFlags.toa:
+		// RDA falls back to TOA reflectance when Acomp isn't available.
+		correctionType = "TOAReflectance"
+	case dgstripFlags.acomp:
+		correctionType = "Acomp"
+	case dgstripFlags.toa:
+		correctionType = "TOAReflectance"
+	default:
+		correctionType = "DN"
+	}
+	return errors.Wrap(strip.CheckParameters(dgstripFlags.bt.String(), correctionType), "invalid --bandtype, --acomp, or --toa")
+}
+
 func dgstripTemplateOptions(catalogID string) []rda.TemplateOption {
 	options := []rda.TemplateOption{
 		rda.AddParameter("catalogId", catalogID),
@@ -253,10 +324,14 @@ var dgstripFlags struct {
 	bands bandCombo
 	dra   bool
 
-	srcWin  sourceWindow
-	projWin projectionWindow
+	window windowFlags
+	order  mosaicOrder
 
 	maxconcurr uint64
+	verify     bool
+
+	cog   cogFlags
+	batch batchFlags
 }
 
 func init() {
@@ -264,6 +339,7 @@ func init() {
 	dgstripCmd.AddCommand(dgstripRealizeCmd)
 	dgstripCmd.AddCommand(dgstripMetadataCmd)
 	dgstripCmd.AddCommand(dgstripBatchCmd)
+	dgstripCmd.AddCommand(dgstripMosaicCmd)
 
 	// Control what is fed to the DigitalGlobeStrip template in RDA.
 	dgstripCmd.PersistentFlags().Var(&dgstripFlags.crs, "crs", "coordinate reference system to use, either \"UTM\" or \"EPSG:<code>\"")
@@ -275,11 +351,19 @@ func init() {
 	dgstripCmd.PersistentFlags().BoolVar(&dgstripFlags.dra, "dra", false, "apply a DRA (aka convert to 8 bit in a pretty way)")
 
 	// Local flags specific to realizing tiles.
-	dgstripRealizeCmd.Flags().Uint64Var(&dgstripFlags.maxconcurr, "maxconcurrency", 0, "set how many concurrent requests to allow; by default, 4 * num CPUs is used")
-	dgstripRealizeCmd.Flags().Var(&dgstripFlags.srcWin, "srcwin", "realize a subwindow in pixel space, specified via comma seperated integers xoff,yoff,xsize,ysize")
-	dgstripRealizeCmd.Flags().Var(&dgstripFlags.projWin, "projwin", "realize a subwindow in projected space, specified via comma seperated floats ulx,uly,lrx,lry")
+	dgstripRealizeCmd.Flags().Uint64Var(&dgstripFlags.maxconcurr, "maxconcurrency", 0, "set the most concurrent requests to allow, which adapts downward when RDA is struggling; by default, 4 * num CPUs is used")
+	dgstripRealizeCmd.Flags().BoolVar(&dgstripFlags.verify, "verify", false, "check the checksum of every previously realized tile against the manifest, fetching any that don't match again")
+	addWindowFlags(dgstripRealizeCmd.Flags(), &dgstripFlags.window, "realize")
+	addCOGFlags(dgstripRealizeCmd.Flags(), &dgstripFlags.cog)
 
 	// Local flags specific to batch requesting tiles.
-	dgstripBatchCmd.Flags().Var(&dgstripFlags.srcWin, "srcwin", "batch realize a subwindow in pixel space, specified via comma seperated integers xoff,yoff,xsize,ysize")
-	dgstripBatchCmd.Flags().Var(&dgstripFlags.projWin, "projwin", "batch realize a subwindow in projected space, specified via comma seperated floats ulx,uly,lrx,lry")
+	addWindowFlags(dgstripBatchCmd.Flags(), &dgstripFlags.window, "batch realize")
+	addBatchFlags(dgstripBatchCmd.Flags(), &dgstripFlags.batch)
+
+	// Local flags specific to mosaicking strips.
+	dgstripMosaicCmd.Flags().Var(&dgstripFlags.window.bbox, "bbox", "area to mosaic, specified via comma seperated floats minlon,minlat,maxlon,maxlat")
+	dgstripMosaicCmd.Flags().Var(&dgstripFlags.order, "order", `which strip shows where strips overlap: "recent" for the most recently acquired, "off-nadir" for the least off nadir, or "given" for the first given`)
+	dgstripMosaicCmd.Flags().Uint64Var(&dgstripFlags.maxconcurr, "maxconcurrency", 0, "set the most concurrent requests to allow across all strips, which adapts downward when RDA is struggling; by default, 4 * num CPUs is used")
+	dgstripMosaicCmd.Flags().BoolVar(&dgstripFlags.verify, "verify", false, "check the checksum of every previously realized tile against the manifest, fetching any that don't match again")
+	addCOGFlags(dgstripMosaicCmd.Flags(), &dgstripFlags.cog)
 }
@@ -0,0 +1,79 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rdatest"
+)
+
+// testCatalogID is a strip the fake RDA serves.
+const testCatalogID = "1040010038952900"
+
+// checkRealized checks that vrtPath exists and the tiles it
+// references were written to tileDir.
+func checkRealized(t *testing.T, vrtPath, tileDir string, wantTiles int) {
+	t.Helper()
+	vrt, err := ioutil.ReadFile(vrtPath)
+	if err != nil {
+		t.Fatalf("no VRT written: %v", err)
+	}
+	tiles, err := filepath.Glob(filepath.Join(tileDir, "*.tif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tiles) != wantTiles {
+		t.Fatalf("got %d tiles in %s, want %d", len(tiles), tileDir, wantTiles)
+	}
+	for _, tile := range tiles {
+		if !strings.Contains(string(vrt), filepath.Base(tile)) {
+			t.Errorf("VRT doesn't reference tile %s", filepath.Base(tile))
+		}
+	}
+}
+
+func TestDGStripRealize(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "rda-cmd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	vrtPath := filepath.Join(outDir, "strip.vrt")
+	if _, err := runCommand(t, "dgstrip", "realize", testCatalogID, vrtPath); err != nil {
+		t.Fatalf("dgstrip realize failed: %v", err)
+	}
+	checkRealized(t, vrtPath, filepath.Join(outDir, "strip"), 4)
+
+	// Unavailable strips fail without writing anything.
+	vrtPath = filepath.Join(outDir, "unavailable.vrt")
+	if _, err := runCommand(t, "dgstrip", "realize", rdatest.UnavailableCatalogID, vrtPath); err == nil {
+		t.Fatal("expected realizing an unavailable strip to fail")
+	}
+	if _, err := os.Stat(vrtPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no VRT for an unavailable strip, got err %v", err)
+	}
+}
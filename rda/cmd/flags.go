@@ -28,6 +28,7 @@ import (
 
 	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
 	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
 )
 
 type sourceWindow struct {
@@ -96,26 +97,129 @@ func (p *projectionWindow) Type() string {
 	return "float,float,float,float"
 }
 
-func processSubWindows(srcWin *sourceWindow, projWin *projectionWindow, md *rda.Metadata) (*rda.TileWindow, error) {
-	if (*projWin != projectionWindow{} && *srcWin != sourceWindow{}) {
-		return nil, errors.New("--projwin and --srcwin cannot be set at the same time")
+// boundingBox is a box given as minx,miny,maxx,maxy, e.g. in longitude
+// and latitude.
+type boundingBox struct {
+	minX, minY, maxX, maxY float64
+}
+
+func (b *boundingBox) String() string {
+	return ""
+}
+
+func (b *boundingBox) Set(value string) error {
+	vals := strings.SplitN(value, ",", 4)
+	if len(vals) != 4 {
+		return fmt.Errorf("expected 4 values, but got %d", len(vals))
+	}
+	var err error
+	for i, v := range []*float64{&b.minX, &b.minY, &b.maxX, &b.maxY} {
+		if *v, err = strconv.ParseFloat(strings.TrimSpace(vals[i]), 64); err != nil {
+			return fmt.Errorf("failed setting %s = %s, err := %+v", []string{"minx", "miny", "maxx", "maxy"}[i], vals[i], err)
+		}
+	}
+	if b.minX >= b.maxX || b.minY >= b.maxY {
+		return errors.Errorf("%s has its minimums at or above its maximums", value)
+	}
+	return nil
+}
+
+func (b *boundingBox) Type() string {
+	return "float,float,float,float"
+}
+
+// windowFlags are the flags used by the realize and batch commands to
+// pick the part of an image to realize.
+type windowFlags struct {
+	srcWin     sourceWindow
+	projWin    projectionWindow
+	projWinSRS string
+	bbox       boundingBox
+	cutline    cutlineFlags
+}
+
+// addWindowFlags adds the flags picking a window to a realize or batch
+// command, whose action, e.g. "realize", prefixes their help.
+func addWindowFlags(fs *pflag.FlagSet, flags *windowFlags, action string) {
+	fs.Var(&flags.srcWin, "srcwin", action+" a subwindow in pixel space, specified via comma seperated integers xoff,yoff,xsize,ysize")
+	fs.Var(&flags.projWin, "projwin", action+" a subwindow in projected space, specified via comma seperated floats ulx,uly,lrx,lry")
+	fs.StringVar(&flags.projWinSRS, "projwin-srs", "", `coordinate reference system of --projwin, e.g. "EPSG:4326", if not the image's`)
+	fs.Var(&flags.bbox, "bbox", action+" a subwindow covering a geographic box, specified via comma seperated floats minlon,minlat,maxlon,maxlat")
+	addCutlineFlags(fs, &flags.cutline)
+}
+
+// isSet returns true if any of the window flags were given.
+func (w *windowFlags) isSet() bool {
+	return w.srcWin != sourceWindow{} || w.projWin != projectionWindow{} || w.projWinSRS != "" || w.bbox != boundingBox{} || w.cutline.path != ""
+}
+
+// processSubWindows returns the window of the image we were asked for,
+// along with the cutline, reprojected into the image's coordinate
+// reference system, if we were given one.  Windows given in another
+// coordinate reference system are expanded to cover all of the area
+// they were given for.
+func processSubWindows(w *windowFlags, md *rda.Metadata) (*rda.Geometry, *rda.TileWindow, error) {
+	given := 0
+	for _, set := range []bool{w.srcWin != sourceWindow{}, w.projWin != projectionWindow{}, w.bbox != boundingBox{}, w.cutline.path != ""} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		return nil, nil, errors.New("only one of --srcwin, --projwin, --bbox, and --cutline can be set at the same time")
+	}
+	if w.projWinSRS != "" && (w.projWin == projectionWindow{}) {
+		return nil, nil, errors.New("--projwin-srs is only used along with --projwin")
+	}
+
+	var cutline, area *rda.Geometry
+	switch {
+	case w.srcWin != sourceWindow{}:
+		tileWindow, err := md.Subset(w.srcWin.xOff, w.srcWin.yOff, w.srcWin.xSize, w.srcWin.ySize)
+		return nil, tileWindow, err
+	case w.cutline.path != "":
+		var err error
+		if cutline, err = readCutline(w.cutline, md); err != nil {
+			return nil, nil, err
+		}
+		area = cutline
+	case w.bbox != boundingBox{}:
+		area = rda.NewBoxGeometry(w.bbox.minX, w.bbox.minY, w.bbox.maxX, w.bbox.maxY, "EPSG:4326")
+	case w.projWin != projectionWindow{}:
+		p := w.projWin
+		area = rda.NewBoxGeometry(math.Min(p.ulx, p.lrx), math.Min(p.uly, p.lry), math.Max(p.ulx, p.lrx), math.Max(p.uly, p.lry), w.projWinSRS)
+	default:
+		tileWindow, err := md.Subset(0, 0, 0, 0)
+		return nil, tileWindow, err
 	}
 
-	// Convert projWin into a srcWin if we were given one.
-	if (*projWin != projectionWindow{}) {
-		igt, err := md.ImageGeoreferencing.Invert()
-		if err != nil {
-			return nil, err
+	if area.SRS != "" {
+		imageSRS := md.ImageGeoreferencing.SpatialReferenceSystemCode
+		if imageSRS == "" {
+			return nil, nil, errors.New("the image isn't georeferenced, so windows can't be reprojected into it")
 		}
-		xOff, yOff := igt.Apply(projWin.ulx, projWin.uly)
-		srcWin.xOff = int(math.Floor(xOff))
-		srcWin.yOff = int(math.Floor(yOff))
+		var err error
+		if area, err = area.Transform(imageSRS); err != nil {
+			return nil, nil, errors.Wrap(err, "failed reprojecting the window into the image's coordinate reference system")
+		}
+	}
 
-		xOffLR, yOffLR := igt.Apply(projWin.lrx, projWin.lry)
-		srcWin.xSize = int(math.Ceil(xOffLR - xOff))
-		srcWin.ySize = int(math.Ceil(yOffLR - yOff))
+	// The window is the area's bounding box in pixel space.
+	igt, err := md.ImageGeoreferencing.Invert()
+	if err != nil {
+		return nil, nil, err
 	}
-	return md.Subset(srcWin.xOff, srcWin.yOff, srcWin.xSize, srcWin.ySize)
+	minX, minY, maxX, maxY := area.Bounds()
+	pxMin, pyMin := math.Inf(1), math.Inf(1)
+	pxMax, pyMax := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{minX, minY}, {maxX, minY}, {maxX, maxY}, {minX, maxY}} {
+		px, py := igt.Apply(corner[0], corner[1])
+		pxMin, pxMax = math.Min(pxMin, px), math.Max(pxMax, px)
+		pyMin, pyMax = math.Min(pyMin, py), math.Max(pyMax, py)
+	}
+	xOff, yOff := int(math.Floor(pxMin)), int(math.Floor(pyMin))
+	tileWindow, err := md.Subset(xOff, yOff, int(math.Ceil(pxMax))-xOff, int(math.Ceil(pyMax))-yOff)
+	return cutline, tileWindow, err
 }
 
 type coordRefSys string
@@ -207,3 +311,111 @@ func (bc *bandCombo) Set(value string) error {
 func (bc *bandCombo) Type() string {
 	return "string"
 }
+
+type batchFormat rda.BatchFormat
+
+func (b *batchFormat) String() string {
+	return rda.BatchFormat(*b).String()
+}
+
+func (b *batchFormat) Set(value string) error {
+	return (*rda.BatchFormat)(b).UnmarshalText([]byte(value))
+}
+
+func (b *batchFormat) Type() string {
+	return "string"
+}
+
+type mosaicOrder rda.MosaicOrder
+
+func (o *mosaicOrder) String() string {
+	return rda.MosaicOrder(*o).String()
+}
+
+func (o *mosaicOrder) Set(value string) error {
+	return (*rda.MosaicOrder)(o).UnmarshalText([]byte(value))
+}
+
+func (o *mosaicOrder) Type() string {
+	return "string"
+}
+
+// zoomLevels is a list of zoom levels, given as comma separated levels
+// or ranges of levels, e.g. "10,12-14".
+type zoomLevels []int
+
+func (z *zoomLevels) String() string {
+	strs := make([]string, len(*z))
+	for i, level := range *z {
+		strs[i] = strconv.Itoa(level)
+	}
+	return strings.Join(strs, ",")
+}
+
+func (z *zoomLevels) Set(value string) error {
+	for _, r := range strings.Split(value, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		min, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil || min < 0 {
+			return errors.Errorf("invalid zoom level %q", r)
+		}
+		max := min
+		if len(bounds) == 2 {
+			if max, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil || max < min {
+				return errors.Errorf("invalid zoom level range %q", r)
+			}
+		}
+		for level := min; level <= max; level++ {
+			*z = append(*z, level)
+		}
+	}
+	return nil
+}
+
+func (z *zoomLevels) Type() string {
+	return "levels"
+}
+
+// byteSize is a number of bytes, optionally given with a unit, e.g.
+// "500MB" or "2GiB".
+type byteSize int64
+
+// byteUnits are the units a byteSize can be given in, longest first
+// so that suffixes match correctly.
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+func (b *byteSize) String() string {
+	if b == nil || *b == 0 {
+		return ""
+	}
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSize) Set(value string) error {
+	v := strings.ToUpper(strings.TrimSpace(value))
+	unit := int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(v, u.suffix) {
+			v, unit = strings.TrimSpace(strings.TrimSuffix(v, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return errors.Errorf("invalid size %q", value)
+	}
+	*b = byteSize(n * float64(unit))
+	return nil
+}
+
+func (b *byteSize) Type() string {
+	return "bytes"
+}
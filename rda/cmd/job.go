@@ -25,10 +25,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/DigitalGlobe/rdatools/rda/pkg/gbdx"
@@ -84,8 +88,14 @@ var statusCmd = &cobra.Command{
 			}
 		}()
 
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
 		// Fetch all the job statuses.
-		jobs, err := rda.FetchBatchStatus(ctx, client, args...)
+		jobs, err := api.FetchBatchStatus(ctx, args...)
+		updateJobStatuses(jobs)
 		if err != nil {
 			return err
 		}
@@ -117,7 +127,7 @@ objects associated with that job id.`,
 			}
 		}()
 
-		accessor, err := gbdx.NewS3Accessor(client)
+		accessor, err := newS3Accessor(client)
 		if err != nil {
 			return err
 		}
@@ -142,10 +152,17 @@ objects associated with that job id.`,
 
 // rmCmd represents the rm command
 var rmCmd = &cobra.Command{
-	Use:   "rm",
+	Use:   "rm <job id>",
 	Short: "remove S3 artifacts in your GBDX customer data bucket associated with the RDA batch job ID",
-	Args:  cobra.ExactArgs(1),
+	Long: `remove S3 artifacts in your GBDX customer data bucket associated with the RDA batch job ID
+
+How many artifacts would be deleted, and their total size, is shown
+first, and you are asked to confirm unless --yes is given.  Use
+--dry-run to only show what would be deleted.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := args[0]
+
 		ctx := context.Background()
 		client, writeConfig, err := newClient(ctx)
 		if err != nil {
@@ -157,16 +174,40 @@ var rmCmd = &cobra.Command{
 			}
 		}()
 
-		accessor, err := gbdx.NewS3Accessor(client)
+		accessor, err := newS3Accessor(client)
 		if err != nil {
 			return err
 		}
-		numDel, err := accessor.RDADeleteBatchJobArtifacts(ctx, args[0])
+		artifacts, err := accessor.RDABatchJobArtifacts(ctx, jobID)
 		if err != nil {
 			return err
 		}
-		log.Printf("deleted %d artifacts associated with %s\n", numDel, args[0])
-		return nil
+		if len(artifacts) == 0 {
+			fmt.Printf("no artifacts associated with %s to delete\n", jobID)
+			return nil
+		}
+
+		summary := summarizeArtifacts(jobID, artifacts)
+		if err := writeArtifactSummaries(os.Stdout, []artifactSummary{summary}); err != nil {
+			return err
+		}
+		if jobFlags.dryRun {
+			return nil
+		}
+		if !jobFlags.yes {
+			ok, err := confirm(fmt.Sprintf("delete %d artifacts (%s) associated with %s?", summary.numArtifacts, pb.Format(summary.size).To(pb.U_BYTES), jobID))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println("nothing deleted")
+				return nil
+			}
+		}
+
+		numDel, err := accessor.DeleteArtifacts(ctx, artifacts)
+		log.Printf("deleted %d artifacts associated with %s\n", numDel, jobID)
+		return err
 	},
 }
 
@@ -178,7 +219,13 @@ var downloadCmd = &cobra.Command{
  
 outdir will be created if it doesn't exist. If you specify the full path 
 (vs just the job id) to a file, it will only download that particular file 
-rather than the entire job contents.`,
+rather than the entire job contents.
+
+Files already in outdir are only skipped if their size matches S3's,
+along with their checksum if --verify is given; anything else, such as
+a file left partially written by an interrupted download, is
+downloaded again.  Artifacts are downloaded to temporary files that
+are renamed into place once verified.`,
 	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		outDir, jobID := args[0], args[1]
@@ -208,12 +255,20 @@ rather than the entire job contents.`,
 			}
 		}()
 
-		accessor, err := gbdx.NewS3Accessor(client)
+		accessor, err := newS3Accessor(client, gbdx.WithNumParallel(jobFlags.parallel))
 		if err != nil {
 			return err
 		}
 
-		numArtifacts, dlFunc, err := accessor.DownloadBatchJobArtifacts(ctx, outDir, jobID)
+		if jobFlags.dryRun {
+			artifacts, err := accessor.PlanBatchJobDownload(ctx, outDir, jobID, downloadOptions()...)
+			if err != nil {
+				return err
+			}
+			return writeArtifactPlan(os.Stdout, artifacts)
+		}
+
+		numArtifacts, dlFunc, err := accessor.DownloadBatchJobArtifacts(ctx, outDir, jobID, downloadOptions()...)
 		if err != nil {
 			return err
 		}
@@ -237,18 +292,40 @@ rather than the entire job contents.`,
 			return nil
 		}
 		bar.FinishPrint(fmt.Sprintf("S3 download of %d artifacts took %s", numArtifacts, time.Since(tStart)))
+		updateJob(jobID, func(r *rda.JobRecord) { r.AddDownload(outDir, numArtifacts) })
 		return nil
 	},
 }
 
 // watch represents the watch command
 var watchCmd = &cobra.Command{
-	Use:   "watch <outdir> <job id>",
-	Short: "watch RDA batch job id for completion, greedily downloading artifacts to the output directory as they arrive",
-	Long:  `download RDA batch job artifacts to the output directory; ourdir will be created if it doesn't exist`,
-	Args:  cobra.ExactArgs(2),
+	Use:   "watch <outdir> <job id>*",
+	Short: "watch RDA batch job ids for completion, greedily downloading artifacts to the output directory as they arrive",
+	Long: `watch RDA batch job ids for completion, greedily downloading artifacts to the output directory as they arrive
+
+outdir will be created if it doesn't exist.  When watching more than
+one job, each job's artifacts are downloaded to a directory named for
+it in outdir.  Job ids can be listed as arguments on the command line,
+or piped in from another source, e.g. "rda job list".  Jobs are polled
+every few seconds, backing off to once a minute while nothing new
+shows up, and up to four jobs' artifacts are downloaded at once in the
+background.  The command fails, naming them, if any jobs failed.`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		outDir, jobID := args[0], args[1]
+		outDir, jobIDs := args[0], args[1:]
+
+		// Read job ids from stdin (line seperated) if given none.
+		if len(jobIDs) == 0 {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				if id := strings.TrimSpace(scanner.Text()); id != "" {
+					jobIDs = append(jobIDs, id)
+				}
+			}
+			if len(jobIDs) == 0 {
+				return errors.New("no job ids given to watch")
+			}
+		}
 
 		// Setup our context to handle cancellation and listen for signals.
 		ctx, cancel := context.WithCancel(context.Background())
@@ -275,74 +352,222 @@ var watchCmd = &cobra.Command{
 			}
 		}()
 
-		accessor, err := gbdx.NewS3Accessor(client)
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
+		accessor, err := newS3Accessor(client, gbdx.WithNumParallel(jobFlags.parallel))
 		if err != nil {
 			return err
 		}
 
-		// Begin watching the job and downloading granules as they appear.
-		status := "processing"
-	dlLoop:
-		for {
-			numDL, dlFunc, err := accessor.DownloadBatchJobArtifacts(ctx, outDir, jobID)
+		// Begin watching the jobs and downloading artifacts as they appear.
+		w := newJobWatcher(api, accessor, outDir, jobIDs, downloadOptions()...)
+		w.watch(ctx)
+		return w.stop(ctx)
+	},
+}
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the RDA batch materialization jobs you've submitted",
+	Long: `list the RDA batch materialization jobs you've submitted
+
+Every job submitted via "rda template batch" or "rda dgstrip batch" is
+recorded under ~/.rda/jobs, along with its request, profile, tags, and
+last known status.  Jobs you status, watch, or download are recorded
+too.  The statuses listed are as of the last time they were fetched;
+use "rda job refresh" to update them.
+
+Filter the jobs with --status, --tag (repeat it to require several
+tags), --since, and --until.  The latter two take a date (2006-01-02),
+a timestamp (2006-01-02T15:04:05Z), or a duration before now (36h),
+and a date given to --until includes that whole day.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter := rda.JobFilter{Status: jobFlags.status, Tags: jobFlags.tags}
+		var err error
+		if filter.Since, err = parseTimeFlag("since", jobFlags.since); err != nil {
+			return err
+		}
+		if filter.Until, err = parseEndTimeFlag("until", jobFlags.until); err != nil {
+			return err
+		}
+
+		store, err := jobStore()
+		if err != nil {
+			return err
+		}
+		jobs, err := store.List(filter)
+		if err != nil {
+			return err
+		}
+
+		if jobFlags.json {
+			return json.NewEncoder(os.Stdout).Encode(jobs)
+		}
+		return writeJobTable(os.Stdout, jobs)
+	},
+}
+
+// showCmd represents the show command
+var showCmd = &cobra.Command{
+	Use:   "show <job id>",
+	Short: "show everything recorded locally about an RDA batch materialization job",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := jobStore()
+		if err != nil {
+			return err
+		}
+		job, err := store.Get(args[0])
+		if os.IsNotExist(err) {
+			return errors.Errorf("job %s isn't recorded locally; try \"rda job status %s\"", args[0], args[0])
+		}
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(os.Stdout).Encode(job)
+	},
+}
+
+// refreshCmd represents the refresh command
+var refreshCmd = &cobra.Command{
+	Use:   "refresh <job id>*",
+	Short: "update the status of locally recorded RDA batch materialization jobs",
+	Long: `update the status of locally recorded RDA batch materialization jobs
+
+With no job ids given, every recorded job that isn't yet complete or
+failed is refreshed, or every recorded job if --all is given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := jobStore()
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			jobs, err := store.List(rda.JobFilter{})
 			if err != nil {
 				return err
 			}
-
-			switch {
-			case numDL > 0:
-				bar := pb.StartNew(numDL)
-				tStart := time.Now()
-				gbdx.WithProgressFunc(bar.Increment)(accessor)
-				if err := dlFunc(); err != nil {
-					bar.FinishPrint("Failed downloading all artifacts; rerun the command to pick up where you left off.")
-					srcErr := errors.Cause(err)
-					if aerr, ok := srcErr.(awserr.Error); ok {
-						if origErr := aerr.OrigErr(); origErr != nil {
-							srcErr = origErr
-						}
-					}
-					if srcErr.Error() != "context canceled" {
-						return err
-					}
-					return nil
+			for _, job := range jobs {
+				if jobFlags.all || (job.Status.Status != "complete" && job.Status.Status != "failed") {
+					args = append(args, job.JobID)
 				}
-				bar.FinishPrint(fmt.Sprintf("S3 download of %d artifacts took %s", numDL, time.Since(tStart)))
+			}
+			if len(args) == 0 {
+				fmt.Println("no jobs need refreshing")
+				return nil
+			}
+		}
 
-			case status == "complete":
-				// We exit the loop here to ensure there is no more objects to download and the job status is set to complete.
-				break dlLoop
+		// Setup our context to handle cancellation and listen for signals.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
 
-			default:
-				jobs, err := rda.FetchBatchStatus(ctx, client, jobID)
-				if err != nil {
-					return err
-				}
-				if len(jobs) != 1 {
-					return errors.Errorf("no job found found for job id %s", jobID)
-				}
+		go func() {
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			select {
+			case s := <-sigs:
+				log.Printf("received a shutdown signal %s, winding down", s)
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
 
-				switch status = jobs[0].Status.Status; status {
-				case "complete":
-					continue dlLoop
-				case "processing":
-				default:
-					return errors.Errorf("job id %s has status %s, exiting", jobID, status)
-				}
+		client, writeConfig, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writeConfig(); err != nil {
+				log.Printf("on exit, received an error when writing configuration, err: %v", err)
+			}
+		}()
 
-				// If we are still processing but nothing was found to download, sleep for a while before checking again.
-				select {
-				case <-time.After(10 * time.Second):
-				case <-ctx.Done():
-					log.Printf("exited before downloading all artifacts; rerun the command to pick up where you left off.")
-					return nil
-				}
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
+		// Record what we could fetch even if some jobs failed.
+		jobs, fetchErr := api.FetchBatchStatus(ctx, args...)
+		updateJobStatuses(jobs)
+
+		var refreshed []*rda.JobRecord
+		for _, job := range jobs {
+			r, err := store.Get(job.JobID)
+			if err != nil {
+				return err
 			}
+			refreshed = append(refreshed, r)
 		}
-		return nil
+		sort.Slice(refreshed, func(i, j int) bool { return refreshed[i].SubmittedAt.Before(refreshed[j].SubmittedAt) })
+		if err := writeJobTable(os.Stdout, refreshed); err != nil {
+			return err
+		}
+		return fetchErr
 	},
 }
 
+var jobFlags struct {
+	status       string
+	tags         []string
+	since, until string
+	json         bool
+	all          bool
+	parallel     int
+
+	include, exclude []string
+	zoom             zoomLevels
+	maxBytes         byteSize
+	verify           bool
+	dryRun           bool
+
+	yes       bool
+	olderThan string
+
+	delete     bool
+	awsProfile string
+	destS3URL  string
+
+	addr        string
+	downloadDir string
+	exec        string
+}
+
+// downloadOptions returns the options choosing which artifacts to
+// download given by jobFlags.
+func downloadOptions() []gbdx.DownloadOption {
+	options := []gbdx.DownloadOption{gbdx.IncludeArtifacts(jobFlags.include...), gbdx.ExcludeArtifacts(jobFlags.exclude...), gbdx.VerifyArtifacts(jobFlags.verify)}
+	if len(jobFlags.zoom) > 0 {
+		options = append(options, gbdx.ZoomLevels(jobFlags.zoom...))
+	}
+	if jobFlags.maxBytes > 0 {
+		options = append(options, gbdx.MaxDownloadBytes(int64(jobFlags.maxBytes)))
+	}
+	return options
+}
+
+// writeArtifactPlan writes the artifacts that would be downloaded to
+// w, one per line, followed by their total size.
+func writeArtifactPlan(w io.Writer, artifacts []gbdx.Artifact) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SIZE\tLAST MODIFIED\tFILE")
+	var total int64
+	for _, a := range artifacts {
+		total += a.Size
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", pb.Format(a.Size).To(pb.U_BYTES), a.LastModified.Local().Format("2006-01-02 15:04"), a.File)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d artifacts, %s (%d bytes), would be downloaded\n", len(artifacts), pb.Format(total).To(pb.U_BYTES), total)
+	return err
+}
+
 func init() {
 	rootCmd.AddCommand(jobCmd)
 	jobCmd.AddCommand(statusCmd)
@@ -350,4 +575,54 @@ func init() {
 	jobCmd.AddCommand(downloadableCmd)
 	jobCmd.AddCommand(downloadCmd)
 	jobCmd.AddCommand(watchCmd)
+	jobCmd.AddCommand(listCmd)
+	jobCmd.AddCommand(showCmd)
+	jobCmd.AddCommand(refreshCmd)
+	jobCmd.AddCommand(pruneCmd)
+	jobCmd.AddCommand(syncCmd)
+	jobCmd.AddCommand(listenCmd)
+
+	// Local flags specific to listing jobs.
+	listCmd.Flags().StringVar(&jobFlags.status, "status", "", "only list jobs whose last known status is this, e.g. processing, complete, or failed")
+	listCmd.Flags().StringArrayVar(&jobFlags.tags, "tag", []string{}, "only list jobs with this tag; repeat to require several")
+	listCmd.Flags().StringVar(&jobFlags.since, "since", "", "only list jobs submitted at or after this date, timestamp, or duration ago")
+	listCmd.Flags().StringVar(&jobFlags.until, "until", "", "only list jobs submitted at or before this date, timestamp, or duration ago")
+	listCmd.Flags().BoolVar(&jobFlags.json, "json", false, "list the full job records as JSON rather than a table")
+
+	// Local flags specific to downloading artifacts.
+	for _, cmd := range []*cobra.Command{downloadCmd, watchCmd} {
+		cmd.Flags().IntVar(&jobFlags.parallel, "parallel", 0, "set how many artifacts to download at once; by default, 4 * num CPUs are")
+		cmd.Flags().StringArrayVar(&jobFlags.include, "include", []string{}, "only download artifacts matching this glob pattern, e.g. \"*.tif\" or \"tiles/*/*.tif\"; repeat to allow several")
+		cmd.Flags().StringArrayVar(&jobFlags.exclude, "exclude", []string{}, "skip artifacts matching this glob pattern; repeat to skip several")
+		cmd.Flags().Var(&jobFlags.zoom, "zoom", "only download these zoom levels of a TMS or vector tile job, e.g. 10,12-14")
+		cmd.Flags().BoolVar(&jobFlags.verify, "verify", false, "check the checksum of every previously downloaded artifact against S3, downloading any that don't match again")
+	}
+	downloadCmd.Flags().Var(&jobFlags.maxBytes, "max-bytes", "stop downloading before going over this many bytes, e.g. 500MB or 2GiB; rerun to download the next lot")
+	downloadCmd.Flags().BoolVar(&jobFlags.dryRun, "dry-run", false, "list what would be downloaded and its total size, without downloading anything")
+
+	// Local flags specific to deleting artifacts.
+	for _, cmd := range []*cobra.Command{rmCmd, pruneCmd} {
+		cmd.Flags().BoolVarP(&jobFlags.yes, "yes", "y", false, "delete without asking for confirmation")
+		cmd.Flags().BoolVar(&jobFlags.dryRun, "dry-run", false, "show what would be deleted, without deleting anything")
+	}
+	pruneCmd.Flags().StringVar(&jobFlags.olderThan, "older-than", "", "only prune jobs whose artifacts were all written longer ago than this, e.g. 30d or 36h (required)")
+	pruneCmd.Flags().StringVar(&jobFlags.status, "status", "", "only prune jobs whose status in RDA is this, e.g. complete or failed")
+
+	// Local flags specific to syncing artifacts.
+	syncCmd.Flags().IntVar(&jobFlags.parallel, "parallel", 0, "set how many artifacts to copy at once; by default, 4 * num CPUs are")
+	syncCmd.Flags().BoolVar(&jobFlags.verify, "verify", false, "check the checksum of every artifact already in a local destination against S3, copying any that don't match again")
+	syncCmd.Flags().BoolVar(&jobFlags.delete, "delete", false, "delete anything at the destination that isn't one of the job's artifacts")
+	syncCmd.Flags().BoolVarP(&jobFlags.yes, "yes", "y", false, "delete without asking for confirmation")
+	syncCmd.Flags().BoolVar(&jobFlags.dryRun, "dry-run", false, "show what would be copied and deleted, as JSON, without changing anything")
+	syncCmd.Flags().StringVar(&jobFlags.awsProfile, "aws-profile", "", "AWS profile whose credentials to access an S3 destination with")
+	syncCmd.Flags().StringVar(&jobFlags.destS3URL, "dest-s3-url", "", "S3 compatible API holding an S3 destination, rather than AWS's")
+
+	// Local flags specific to listening for callbacks.
+	listenCmd.Flags().StringVar(&jobFlags.addr, "addr", ":8080", "address to listen for callbacks on")
+	listenCmd.Flags().StringVar(&jobFlags.downloadDir, "download", "", "download the artifacts of each completed job to a directory named for it in this directory")
+	listenCmd.Flags().StringVar(&jobFlags.exec, "exec", "", "shell command to run for each finished job, after downloading its artifacts")
+	listenCmd.Flags().IntVar(&jobFlags.parallel, "parallel", 0, "set how many artifacts to download at once; by default, 4 * num CPUs are")
+
+	// Local flags specific to refreshing jobs.
+	refreshCmd.Flags().BoolVar(&jobFlags.all, "all", false, "refresh every recorded job, not just those still in progress")
 }
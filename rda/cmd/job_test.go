@@ -0,0 +1,182 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rdatest"
+)
+
+// submitTestJob submits a batch job for the test strip, returning its id.
+func submitTestJob(t *testing.T) string {
+	t.Helper()
+	out, err := runCommand(t, "dgstrip", "batch", testCatalogID)
+	if err != nil {
+		t.Fatalf("dgstrip batch failed: %v", err)
+	}
+	var resp rda.BatchResponse
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("dgstrip batch output %q isn't a batch response: %v", out, err)
+	}
+	if resp.JobID == "" {
+		t.Fatalf("dgstrip batch output %q has no job id", out)
+	}
+	return resp.JobID
+}
+
+// setWatchPolls shortens the poll intervals of job watch, returning a
+// function restoring them.
+func setWatchPolls(min, max time.Duration) func() {
+	oldMin, oldMax := minWatchPoll, maxWatchPoll
+	minWatchPoll, maxWatchPoll = min, max
+	return func() { minWatchPoll, maxWatchPoll = oldMin, oldMax }
+}
+
+func TestJobWatch(t *testing.T) {
+	defer setWatchPolls(10*time.Millisecond, 40*time.Millisecond)()
+	outDir, err := ioutil.TempDir("", "rda-cmd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	jobID := submitTestJob(t)
+	out, err := runCommand(t, "job", "watch", outDir, jobID)
+	if err != nil {
+		t.Fatalf("job watch failed: %v", err)
+	}
+	if !strings.Contains(out, jobID+": complete") {
+		t.Errorf("job watch summary %q doesn't report the job complete", out)
+	}
+
+	files, err := ioutil.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no artifacts downloaded to %s", outDir)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, jobID)); err == nil {
+		t.Errorf("a single watched job's artifacts should go directly in the output directory")
+	}
+}
+
+func TestJobWatchBackoff(t *testing.T) {
+	const min, max = 25 * time.Millisecond, 100 * time.Millisecond
+	defer setWatchPolls(min, max)()
+	outDir, err := ioutil.TempDir("", "rda-cmd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	ctx := context.Background()
+	client, _, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, err := newRDAClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessor, err := newS3Accessor(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing shows up while the status can't be fetched, so we
+	// back off, then poll quickly again once artifacts arrive.
+	jobID := submitTestJob(t)
+	testServer.AddFault(rdatest.Fault{Route: "/materialize/status/", Status: 400, Times: 2})
+	defer testServer.ClearFaults()
+
+	w := newJobWatcher(api, accessor, outDir, []string{jobID})
+	var waits []time.Duration
+	w.after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		return time.After(d)
+	}
+	w.watch(ctx)
+	if err := w.stop(ctx); err != nil {
+		t.Fatalf("watching the job failed: %v", err)
+	}
+
+	if len(waits) < 5 {
+		t.Fatalf("waited %v between polls, expected at least 4 waits after the first poll", waits)
+	}
+	if want := []time.Duration{2 * min, max, max}; !reflect.DeepEqual(waits[1:4], want) {
+		t.Errorf("waited %v between polls while nothing showed up, want %v", waits[1:4], want)
+	}
+	if waits[4] != min {
+		t.Errorf("waited %s after artifacts arrived, want %s", waits[4], min)
+	}
+}
+
+func TestJobWatchFailedJobs(t *testing.T) {
+	defer setWatchPolls(10*time.Millisecond, 40*time.Millisecond)()
+	outDir, err := ioutil.TempDir("", "rda-cmd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	okID, failedID := submitTestJob(t), submitTestJob(t)
+	testServer.FailJob(failedID, "ran out of tiles")
+
+	out, err := runCommand(t, "job", "watch", outDir, okID, failedID)
+	if err == nil {
+		t.Fatal("expected job watch to fail when a job fails")
+	}
+	if want := "1 of 2 jobs failed: " + failedID; err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+	for _, want := range []string{
+		okID + ": complete, ",
+		" downloaded to " + filepath.Join(outDir, okID),
+		failedID + ": failed, job " + failedID + " has status failed ran out of tiles",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("job watch summary %q doesn't contain %q", out, want)
+		}
+	}
+	files, err := ioutil.ReadDir(filepath.Join(outDir, okID))
+	if err != nil || len(files) == 0 {
+		t.Errorf("the complete job's artifacts weren't downloaded, err: %v", err)
+	}
+
+	// rda exits nonzero only if a job failed.
+	if code, out := execute(t, "job", "watch", outDir, okID, failedID); code != 1 || !strings.Contains(out, "1 of 2 jobs failed") {
+		t.Errorf("job watch exited %d with output %q, want 1 naming the failed job", code, out)
+	}
+	if code, out := execute(t, "job", "watch", filepath.Join(outDir, okID), okID); code != 0 {
+		t.Errorf("job watch exited %d with output %q, want 0", code, out)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// jobStore returns the store of batch jobs we've submitted, kept in
+// the RDA configuration directory.
+func jobStore() (*rda.JobStore, error) {
+	dir, err := rdaDir()
+	if err != nil {
+		return nil, err
+	}
+	return rda.NewJobStore(filepath.Join(dir, "jobs")), nil
+}
+
+// recordJob records a newly submitted batch job.  Failures are only
+// logged, as the job was submitted regardless.
+func recordJob(resp *rda.BatchResponse, tags []string) {
+	store, err := jobStore()
+	if err == nil {
+		err = store.Put(rda.NewJobRecord(resp, viper.GetString("profile"), tags...))
+	}
+	if err != nil {
+		log.Printf("failed recording job %s locally, err: %v", resp.JobID, err)
+	}
+}
+
+// updateJob applies update to the local record of the job, logging
+// rather than returning failures.  jobID may also be a path to an
+// artifact within the job, as "rda job download" accepts.
+func updateJob(jobID string, update func(*rda.JobRecord)) {
+	jobID = strings.SplitN(strings.Trim(jobID, "/"), "/", 2)[0]
+	store, err := jobStore()
+	if err == nil {
+		err = store.Update(jobID, update)
+	}
+	if err != nil {
+		log.Printf("failed updating the local record of job %s, err: %v", jobID, err)
+	}
+}
+
+// updateJobStatuses records the statuses RDA gave for jobs.
+func updateJobStatuses(jobs []*rda.BatchResponse) {
+	for _, job := range jobs {
+		job := job
+		updateJob(job.JobID, func(r *rda.JobRecord) { r.UpdateStatus(job) })
+	}
+}
+
+// parseTimeFlag parses a time given on the command line, either as a
+// date (2006-01-02), an RFC 3339 timestamp, or a duration (e.g. 36h or
+// 30d) before now.
+func parseTimeFlag(name, val string) (time.Time, error) {
+	if val == "" {
+		return time.Time{}, nil
+	}
+	if d, err := parseDuration(val); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, val, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("--%s = %q is not a date (2006-01-02), a timestamp (2006-01-02T15:04:05Z07:00), or a duration before now (36h or 30d)", name, val)
+}
+
+// parseEndTimeFlag is parseTimeFlag for the end of a range, where a
+// date means the end of that day, i.e. the start of the next.
+func parseEndTimeFlag(name, val string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", val, time.Local); err == nil {
+		return t.AddDate(0, 0, 1), nil
+	}
+	return parseTimeFlag(name, val)
+}
+
+// parseDuration parses a duration as time.ParseDuration does, but also
+// accepts a whole number of days, e.g. 30d.
+func parseDuration(val string) (time.Duration, error) {
+	if days := strings.TrimSuffix(val, "d"); days != val {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	return time.ParseDuration(val)
+}
+
+// writeJobTable writes a summary of jobs to w, one per line.
+func writeJobTable(w io.Writer, jobs []*rda.JobRecord) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB ID\tSTATUS\tSUBMITTED\tTEMPLATE\tTAGS")
+	for _, j := range jobs {
+		submitted := "-"
+		if !j.SubmittedAt.IsZero() {
+			submitted = j.SubmittedAt.Local().Format("2006-01-02 15:04")
+		}
+		status := j.Status.Status
+		if status == "" {
+			status = "-"
+		}
+		template := j.Request.ImageReference.TemplateID
+		if template == "" {
+			template = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", j.JobID, status, submitted, template, strings.Join(j.Tags, ","))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,59 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEndTimeFlag(t *testing.T) {
+	day := time.Date(2020, 1, 31, 0, 0, 0, 0, time.Local)
+	stamp := time.Date(2020, 1, 31, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		val   string
+		start time.Time
+		end   time.Time
+	}{
+		{val: "", start: time.Time{}, end: time.Time{}},
+		{val: "2020-01-31", start: day, end: day.AddDate(0, 0, 1)},
+		{val: "2020-01-31T12:30:00Z", start: stamp, end: stamp},
+	}
+	for _, tc := range tests {
+		start, err := parseTimeFlag("from", tc.val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !start.Equal(tc.start) {
+			t.Errorf("parseTimeFlag(%q) = %s, want %s", tc.val, start, tc.start)
+		}
+		end, err := parseEndTimeFlag("to", tc.val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !end.Equal(tc.end) {
+			t.Errorf("parseEndTimeFlag(%q) = %s, want %s", tc.val, end, tc.end)
+		}
+	}
+	if _, err := parseEndTimeFlag("to", "Jan 31"); err == nil {
+		t.Error("parseEndTimeFlag accepted a malformed date")
+	}
+}
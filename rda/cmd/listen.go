@@ -0,0 +1,273 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/gbdx"
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// callbackPath is where "rda job listen" receives callbacks.
+const callbackPath = "/rda/callback"
+
+// listenCmd represents the listen command
+var listenCmd = &cobra.Command{
+	Use:   "listen",
+	Short: "receive RDA's callbacks for finished batch jobs, recording their status and optionally downloading their artifacts",
+	Long: `receive RDA's callbacks for finished batch jobs, recording their status and optionally downloading their artifacts
+
+This runs an HTTP server at --addr receiving the callbacks RDA makes
+when batch jobs submitted with --listener pointing at it finish.  Each
+callback's job status is confirmed with RDA and recorded locally, as
+"rda job list" shows.  Then, if the job completed and --download is
+given, its artifacts are downloaded to a directory named for the job
+there, and if --exec is given, that command is run via the shell with
+RDA_JOB_ID, RDA_JOB_STATUS, and, if downloaded, RDA_JOB_DIR set in its
+environment, and the job's status as JSON on its stdin.  Once that's
+done, the job's record notes it, so callbacks RDA repeats for the same
+status are ignored; if downloading or the command fails, the next
+callback tries again.
+
+Callbacks must carry the token kept in ~/.rda/callback-token, which
+--listener adds to the callback URLs of jobs it submits.  RDA must be
+able to reach this server at the URL given to --listener.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Setup our context to handle cancellation and listen for signals.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+		token, err := callbackToken()
+		if err != nil {
+			return err
+		}
+
+		client, writeConfig, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writeConfig(); err != nil {
+				log.Printf("on exit, received an error when writing configuration, err: %v", err) // TODO, handle more gracefully.
+			}
+		}()
+
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+		l := callbackListener{api: api, downloadDir: jobFlags.downloadDir, exec: jobFlags.exec}
+		if l.downloadDir != "" {
+			if l.accessor, err = newS3Accessor(client, gbdx.WithNumParallel(jobFlags.parallel)); err != nil {
+				return err
+			}
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle(callbackPath, rda.NewCallbackHandler(func(reqCtx context.Context, resp *rda.BatchResponse) error {
+			return l.receive(reqCtx, ctx, resp)
+		}, rda.CallbackToken(token)))
+		srv := http.Server{Addr: jobFlags.addr, Handler: mux}
+
+		srvErr := make(chan error, 1)
+		go func() { srvErr <- srv.ListenAndServe() }()
+		log.Printf("listening for RDA callbacks on %s%s; submit batch jobs with --listener <URL RDA can reach this at>", jobFlags.addr, callbackPath)
+
+		select {
+		case err = <-srvErr:
+			err = errors.Wrap(err, "failed listening for callbacks")
+		case s := <-sigs:
+			log.Printf("received a shutdown signal %s, winding down", s)
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer shutdownCancel()
+			err = srv.Shutdown(shutdownCtx)
+		}
+
+		// Stop any downloads in progress; rerunning "rda job
+		// download" picks up where they left off.
+		cancel()
+		l.wg.Wait()
+		return err
+	},
+}
+
+// callbackListener handles the callbacks "rda job listen" receives.
+type callbackListener struct {
+	api         *rda.Client
+	accessor    *gbdx.S3Accessor
+	downloadDir string
+	exec        string
+
+	// Actions taken on finished jobs run one at a time, in the
+	// background.
+	mu sync.Mutex
+	wg sync.WaitGroup
+}
+
+// receive records the status of the job RDA called back about and,
+// if it's finished, starts acting on it under ctx.  The status is
+// fetched from RDA under reqCtx rather than trusted from the callback.
+func (l *callbackListener) receive(reqCtx, ctx context.Context, callback *rda.BatchResponse) error {
+	jobs, err := l.api.FetchBatchStatus(reqCtx, callback.JobID)
+	if err != nil || len(jobs) == 0 {
+		log.Printf("%s: failed confirming the status of the job called back about, err: %v", callback.JobID, err)
+		return errors.Errorf("failed confirming the status of job %s", callback.JobID)
+	}
+	job := jobs[0]
+	updateJobStatuses(jobs)
+	log.Printf("%s: called back, %s", job.JobID, job.Status.Status)
+
+	switch strings.ToLower(job.Status.Status) {
+	case "complete", "failed":
+	default:
+		return nil
+	}
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		if actedOn(job.JobID, job.Status.Status) {
+			log.Printf("%s: already acted on its %s status, ignoring", job.JobID, job.Status.Status)
+			return
+		}
+		if err := l.act(ctx, job); err != nil {
+			log.Printf("%s: %v", job.JobID, err)
+			return
+		}
+		updateJob(job.JobID, func(r *rda.JobRecord) { r.ActedOn = job.Status.Status })
+	}()
+	return nil
+}
+
+// actedOn returns true if the local record of the job shows that its
+// finished status was already acted on.
+func actedOn(jobID, status string) bool {
+	store, err := jobStore()
+	if err != nil {
+		return false
+	}
+	r, err := store.Get(jobID)
+	return err == nil && strings.EqualFold(r.ActedOn, status)
+}
+
+// act downloads the finished job's artifacts and runs the hook
+// command, as asked.
+func (l *callbackListener) act(ctx context.Context, job *rda.BatchResponse) error {
+	dir := ""
+	if l.downloadDir != "" && strings.EqualFold(job.Status.Status, "complete") {
+		dir = filepath.Join(l.downloadDir, job.JobID)
+		n, dlFunc, err := l.accessor.DownloadBatchJobArtifacts(ctx, dir, job.JobID)
+		if err == nil {
+			err = dlFunc()
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed downloading artifacts")
+		}
+		log.Printf("%s: downloaded %d artifacts to %s", job.JobID, n, dir)
+		updateJob(job.JobID, func(r *rda.JobRecord) { r.AddDownload(dir, n) })
+	}
+
+	if l.exec == "" {
+		return nil
+	}
+	b, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrap(err, "failed encoding the job for the hook command")
+	}
+	hook := exec.CommandContext(ctx, "sh", "-c", l.exec)
+	if runtime.GOOS == "windows" {
+		hook = exec.CommandContext(ctx, "cmd", "/C", l.exec)
+	}
+	hook.Env = append(os.Environ(), "RDA_JOB_ID="+job.JobID, "RDA_JOB_STATUS="+job.Status.Status)
+	if dir != "" {
+		hook.Env = append(hook.Env, "RDA_JOB_DIR="+dir)
+	}
+	hook.Stdin = strings.NewReader(string(b))
+	hook.Stdout, hook.Stderr = os.Stdout, os.Stderr
+	return errors.Wrap(hook.Run(), "hook command failed")
+}
+
+// callbackToken returns the token that callbacks to "rda job listen"
+// must carry, creating it if need be.
+func callbackToken() (string, error) {
+	dir, err := ensureRDADir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "callback-token")
+	if b, err := ioutil.ReadFile(path); err == nil && len(strings.TrimSpace(string(b))) > 0 {
+		return strings.TrimSpace(string(b)), nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed generating a callback token")
+	}
+	token := hex.EncodeToString(b)
+	if err := ioutil.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", errors.Wrap(err, "failed saving the callback token")
+	}
+	return token, nil
+}
+
+// listenerCallbackURL returns the callback URL pointing at the "rda
+// job listen" listener reachable at base.
+func listenerCallbackURL(base string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.Errorf("--listener = %q is not an http(s) URL", base)
+	}
+	token, err := callbackToken()
+	if err != nil {
+		return "", err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + callbackPath
+	u.RawQuery = url.Values{"token": {token}}.Encode()
+	return u.String(), nil
+}
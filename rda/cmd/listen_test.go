@@ -0,0 +1,88 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+)
+
+func TestListenActsOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rda-cmd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	client, _, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, err := newRDAClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran := filepath.Join(dir, "ran")
+	l := callbackListener{api: api, exec: "echo $RDA_JOB_STATUS >> " + ran}
+
+	jobID := submitTestJob(t)
+	testServer.FailJob(jobID, "ran out of tiles")
+
+	// RDA calls back twice about the job finishing, and again
+	// later.
+	callback := &rda.BatchResponse{JobID: jobID}
+	for i := 0; i < 2; i++ {
+		if err := l.receive(ctx, ctx, callback); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.wg.Wait()
+	if err := l.receive(ctx, ctx, callback); err != nil {
+		t.Fatal(err)
+	}
+	l.wg.Wait()
+
+	b, err := ioutil.ReadFile(ran)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(string(b)); len(got) != 1 || got[0] != "failed" {
+		t.Errorf("the hook command ran for %v, want it run once for the failed job", got)
+	}
+	store, err := jobStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := store.Get(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ActedOn != "failed" {
+		t.Errorf("the job's record shows %q was acted on, want failed", r.ActedOn)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rdatest"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// testServer is the fake RDA every command test runs against.
+var testServer *rdatest.Server
+
+// executeArgsEnv, when set, makes the test binary run rda with the
+// newline separated arguments it holds in place of the tests, so
+// tests can check how rda exits.
+const executeArgsEnv = "RDA_TEST_EXECUTE_ARGS"
+
+// TestMain points rda at a rdatest.Server via the same environment
+// variables a user would, with HOME in a scratch directory so the
+// tests never see or touch a real ~/.rda.
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv(executeArgsEnv); ok {
+		rootCmd.SetArgs(strings.Split(args, "\n"))
+		Execute()
+		os.Exit(0)
+	}
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	home, err := ioutil.TempDir("", "rda-cmd-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(home)
+
+	testServer = rdatest.NewServer()
+	defer testServer.Close()
+
+	env := map[string]string{
+		"HOME":             home,
+		"GBDX_USERNAME":    rdatest.Username,
+		"GBDX_PASSWORD":    rdatest.Password,
+		"RDA_URL":          testServer.RDAURL(),
+		"GBDX_TOKEN_URL":   testServer.TokenURL(),
+		"GBDX_S3CREDS_URL": testServer.S3CredentialsURL(),
+		"S3_URL":           testServer.S3URL(),
+		"GBDX_CATALOG_URL": testServer.CatalogURL(),
+	}
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return m.Run()
+}
+
+// runCommand executes rda with args, returning what it wrote to
+// stdout.  Flags left set by a previous run are reset first, since
+// the commands are package level and shared between tests.
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	resetFlags(rootCmd)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	var out bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(&out, r)
+		close(copied)
+	}()
+
+	rootCmd.SetArgs(args)
+	rootCmd.SilenceUsage = true
+	rootCmd.SilenceErrors = true
+	err = rootCmd.Execute()
+
+	w.Close()
+	<-copied
+	r.Close()
+	return out.String(), err
+}
+
+// execute runs rda with args in a separate process, as a user would,
+// returning its exit code and combined output.
+func execute(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), executeArgsEnv+"="+strings.Join(args, "\n"))
+	out, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), string(out)
+	}
+	if err != nil {
+		t.Fatalf("failed running rda: %v", err)
+	}
+	return 0, string(out)
+}
+
+// resetFlags returns the flags of cmd and its subcommands to their
+// defaults.  Slice and array flags all default to empty, and are
+// emptied rather than Set, which would append to what they hold.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			s.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+func TestResetFlags(t *testing.T) {
+	for _, tag := range []string{"first", "second"} {
+		if _, err := runCommand(t, "job", "list", "--tag", tag); err != nil {
+			t.Fatalf("job list failed: %v", err)
+		}
+		if len(jobFlags.tags) != 1 || jobFlags.tags[0] != tag {
+			t.Errorf("after passing --tag %s, got tags %q", tag, jobFlags.tags)
+		}
+	}
+
+	resetFlags(rootCmd)
+	if len(jobFlags.tags) != 0 {
+		t.Errorf("got tags %q after resetting flags, want none", jobFlags.tags)
+	}
+	if f := listCmd.Flags().Lookup("tag"); f.Changed {
+		t.Error("--tag is still marked changed after resetting flags")
+	}
+}
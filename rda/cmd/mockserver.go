@@ -0,0 +1,89 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rdatest"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var mockserverFlags struct {
+	addr     string
+	jobPolls int
+}
+
+// mockserverCmd represents the mockserver command
+var mockserverCmd = &cobra.Command{
+	Use:   "mockserver",
+	Short: "Run a local fake of the RDA and GBDX APIs",
+	Long: `Run a local fake of the RDA and GBDX APIs
+
+The fake serves synthetic imagery for any catalog id, finds a few
+acquisitions over that imagery in its catalog, accepts template
+uploads, and runs batch materialization jobs whose artifacts land in a
+fake S3 bucket.  A profile to use it is printed on startup; add it to
+~/.rda/credentials.toml and pass --profile to any other command.  The
+fake keeps everything in memory, so it all goes away on exit.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		l, err := net.Listen("tcp", mockserverFlags.addr)
+		if err != nil {
+			return errors.Wrapf(err, "failed listening on %s", mockserverFlags.addr)
+		}
+
+		s := rdatest.NewUnstartedServer(rdatest.WithJobPolls(mockserverFlags.jobPolls))
+		s.Listener.Close()
+		s.Listener = l
+		s.Start()
+		defer s.Close()
+
+		fmt.Printf(`Serving a fake RDA and GBDX at %s; use it via this profile:
+
+[mock]
+gbdx_username = %q
+gbdx_password = %q
+rda_url = %q
+gbdx_token_url = %q
+gbdx_s3creds_url = %q
+s3_url = %q
+gbdx_catalog_url = %q
+`, s.URL, rdatest.Username, rdatest.Password, s.RDAURL(), s.TokenURL(), s.S3CredentialsURL(), s.S3URL(), s.CatalogURL())
+
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received a shutdown signal %s, winding down", <-sigs)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mockserverCmd)
+	mockserverCmd.Flags().StringVar(&mockserverFlags.addr, "addr", "localhost:8080", "address to listen on")
+	mockserverCmd.Flags().IntVar(&mockserverFlags.jobPolls, "job-polls", 2, "number of status requests before a batch job completes")
+}
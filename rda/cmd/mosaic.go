@@ -0,0 +1,211 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	"github.com/cheggaaa/pb"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var dgstripMosaicCmd = &cobra.Command{
+	Use:   "mosaic <catalog-id>... <output-vrt>",
+	Short: "Realize the tiles of several DigitalGlobe strips covering an area and mosaic them together",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (dgstripFlags.window.bbox == boundingBox{}) {
+			return errors.New("--bbox must be given to say what area to mosaic")
+		}
+
+		// Setup our context to handle cancellation and listen for signals.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() {
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			select {
+			case s := <-sigs:
+				log.Printf("received a shutdown signal %s, winding down", s)
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		// The http client.
+		client, writeConfig, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writeConfig(); err != nil {
+				log.Printf("on exit, received an error when writing configuration, err: %v", err)
+			}
+		}()
+
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
+		// Get the metadata of each strip and figure out which of
+		// its tiles cover the area.
+		catIDs, vrtPath := args[:len(args)-1], args[len(args)-1]
+		tileDir := vrtPath[:len(vrtPath)-len(path.Ext(vrtPath))]
+		var strips []*mosaicStrip
+		numTiles := 0
+		for _, catID := range catIDs {
+			if err := checkDGStrip(api, catID); err != nil {
+				return err
+			}
+			template := api.NewTemplate(dgstripTemplateID, append(dgstripTemplateOptions(catID), rda.NumParallel(int(dgstripFlags.maxconcurr)), rda.VerifyTiles(dgstripFlags.verify))...)
+			md, err := template.Metadata()
+			if err != nil {
+				return errors.Wrapf(err, "failed fetching metadata of strip %s", catID)
+			}
+			_, tileWindow, err := processSubWindows(&dgstripFlags.window, md)
+			if err != nil {
+				log.Printf("skipping strip %s, as it doesn't cover the area: %v", catID, err)
+				continue
+			}
+			rda.WithWindow(*tileWindow)(template)
+			strips = append(strips, &mosaicStrip{catID: catID, template: template, md: md, tileDir: filepath.Join(tileDir, catID)})
+			numTiles += template.NumTiles()
+		}
+		if len(strips) == 0 {
+			return errors.New("none of the strips cover the area given by --bbox")
+		}
+
+		// Realize the strips concurrently, keeping within
+		// --maxconcurrency and the request rate cap between them.
+		templates := make([]*rda.Template, len(strips))
+		for i, s := range strips {
+			templates[i] = s.template
+		}
+		rda.ShareRateControl(templates...)
+		bar := pb.StartNew(numTiles)
+		tStart := time.Now()
+		var wg sync.WaitGroup
+		for _, s := range strips {
+			wg.Add(1)
+			go func(s *mosaicStrip) {
+				defer wg.Done()
+				rda.WithProgressFunc(bar.Increment)(s.template)
+				s.tiles, s.err = s.template.Realize(ctx, s.tileDir)
+			}(s)
+		}
+		wg.Wait()
+
+		var sources []rda.MosaicSource
+		realized := 0
+		for _, s := range strips {
+			if s.err != nil {
+				return errors.Wrapf(s.err, "failed realizing strip %s", s.catID)
+			}
+			realized += len(s.tiles)
+			if len(s.tiles) > 0 {
+				sources = append(sources, rda.MosaicSource{Metadata: s.md, Tiles: s.tiles})
+			}
+		}
+		select {
+		case <-ctx.Done():
+			bar.FinishPrint(fmt.Sprintf("Completed %d of %d tiles before cancellation; rerun the command to pick up where you left off.", realized, numTiles))
+			return nil
+		default:
+			bar.FinishPrint(fmt.Sprintf("Tile retrieval took %s", time.Since(tStart)))
+		}
+		if len(sources) == 0 {
+			return errors.New("no tiles were realized")
+		}
+
+		// Lay the strips out in order of priority.
+		if err := rda.SortMosaicSources(sources, rda.MosaicOrder(dgstripFlags.order)); err != nil {
+			return err
+		}
+		mosaic, err := rda.NewMosaic(sources)
+		if err != nil {
+			return errors.Wrap(err, "the strips can't be mosaicked; --crs and --gsd can put them all on the same grid")
+		}
+
+		// Assemble the tiles into a COG, if asked to, written where the VRT would have gone.
+		if dgstripFlags.cog.cog {
+			if realized < numTiles {
+				return errors.Errorf("only %d of %d tiles were realized, not writing a COG; rerun the command to retrieve the rest", realized, numTiles)
+			}
+			tStart := time.Now()
+			if err := rda.WriteMosaicCOG(vrtPath, mosaic, rda.WithCOGBlockSize(dgstripFlags.cog.blockSize)); err != nil {
+				return err
+			}
+			fmt.Printf("Writing COG %s took %s\n", vrtPath, time.Since(tStart))
+			if dgstripFlags.cog.keepTiles {
+				return nil
+			}
+			return errors.Wrap(os.RemoveAll(tileDir), "failed removing tiles after writing COG")
+		}
+
+		// Build VRT struct and write it to disk.
+		vrt, err := rda.NewMosaicVRT(mosaic)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Create(vrtPath)
+		if err != nil {
+			return errors.Wrap(err, "failed creating VRT for downloaded tiles")
+		}
+		defer f.Close()
+
+		if err := vrt.MakeRelative(filepath.Dir(vrtPath)); err != nil {
+			return err
+		}
+
+		enc := xml.NewEncoder(f)
+		enc.Indent("  ", "    ")
+		if err := enc.Encode(vrt); err != nil {
+			return errors.Wrap(err, "couldn't write our VRT to disk")
+		}
+		return nil
+	},
+}
+
+// mosaicStrip is one of the strips being mosaicked.
+type mosaicStrip struct {
+	catID    string
+	template *rda.Template
+	md       *rda.Metadata
+	tileDir  string
+
+	tiles []rda.TileInfo
+	err   error
+}
@@ -25,7 +25,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
@@ -48,7 +47,12 @@ var operatorCmd = &cobra.Command{
 			}
 		}()
 
-		return errors.Wrap(rda.OperatorInfo(client, os.Stdout, args...), "failed copying response body to stdout")
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
+		return errors.Wrap(api.OperatorInfo(os.Stdout, args...), "failed copying response body to stdout")
 	},
 }
 
@@ -0,0 +1,224 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/gbdx"
+	"github.com/cheggaaa/pb"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune --older-than <age>",
+	Short: "remove the S3 artifacts of every RDA batch job whose artifacts are older than the given age",
+	Long: `remove the S3 artifacts of every RDA batch job whose artifacts are older than the given age
+
+Every job found in your GBDX customer data bucket whose artifacts were
+all written longer ago than --older-than is pruned, optionally only
+those whose status in RDA is --status.  The jobs to prune are shown
+first, and you are asked to confirm unless --yes is given.  Use
+--dry-run to only show what would be pruned.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if jobFlags.olderThan == "" {
+			return errors.New("--older-than is required")
+		}
+		age, err := parseDuration(jobFlags.olderThan)
+		if err != nil {
+			return errors.Errorf("--older-than = %q is not a duration, e.g. 36h or 30d", jobFlags.olderThan)
+		}
+		cutoff := time.Now().Add(-age)
+
+		ctx := context.Background()
+		client, writeConfig, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writeConfig(); err != nil {
+				log.Printf("on exit, received an error when writing configuration, err: %v", err) // TODO, handle more gracefully.
+			}
+		}()
+
+		accessor, err := newS3Accessor(client)
+		if err != nil {
+			return err
+		}
+		jobIDs, err := accessor.RDABatchJobPrefixes(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Find the jobs whose artifacts are all old enough.
+		candidates := map[string][]gbdx.Artifact{}
+		var summaries []artifactSummary
+		for _, jobID := range jobIDs {
+			artifacts, err := accessor.RDABatchJobArtifacts(ctx, jobID+"/")
+			if err != nil {
+				return err
+			}
+			summary := summarizeArtifacts(jobID, artifacts)
+			if summary.numArtifacts == 0 || summary.lastWritten.After(cutoff) {
+				continue
+			}
+			candidates[jobID] = artifacts
+			summaries = append(summaries, summary)
+		}
+
+		// Of those, find the ones with the status asked for.
+		if jobFlags.status != "" && len(summaries) > 0 {
+			ids := make([]string, len(summaries))
+			for i, s := range summaries {
+				ids[i] = s.jobID
+			}
+			api, err := newRDAClient(client)
+			if err != nil {
+				return err
+			}
+			jobs, err := api.FetchBatchStatus(ctx, ids...)
+			if err != nil {
+				log.Printf("failed fetching the status of some jobs, which won't be pruned, err: %v", err)
+			}
+			updateJobStatuses(jobs)
+
+			statuses := map[string]string{}
+			for _, job := range jobs {
+				statuses[job.JobID] = job.Status.Status
+			}
+			matched := summaries[:0]
+			for _, s := range summaries {
+				if strings.EqualFold(statuses[s.jobID], jobFlags.status) {
+					s.status = statuses[s.jobID]
+					matched = append(matched, s)
+				}
+			}
+			summaries = matched
+		}
+
+		if len(summaries) == 0 {
+			fmt.Println("no jobs to prune")
+			return nil
+		}
+		if err := writeArtifactSummaries(os.Stdout, summaries); err != nil {
+			return err
+		}
+		if jobFlags.dryRun {
+			return nil
+		}
+		if !jobFlags.yes {
+			ok, err := confirm(fmt.Sprintf("delete the artifacts of these %d jobs?", len(summaries)))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println("nothing deleted")
+				return nil
+			}
+		}
+
+		var failed []string
+		for _, s := range summaries {
+			numDel, err := accessor.DeleteArtifacts(ctx, candidates[s.jobID])
+			if err != nil {
+				log.Printf("deleted %d of %d artifacts associated with %s, err: %v", numDel, s.numArtifacts, s.jobID, err)
+				failed = append(failed, s.jobID)
+				continue
+			}
+			log.Printf("deleted %d artifacts associated with %s", numDel, s.jobID)
+		}
+		if len(failed) > 0 {
+			return errors.Errorf("failed pruning %d of %d jobs: %s", len(failed), len(summaries), strings.Join(failed, ", "))
+		}
+		return nil
+	},
+}
+
+// artifactSummary summarizes a job's artifacts in S3.
+type artifactSummary struct {
+	jobID        string
+	status       string
+	numArtifacts int
+	size         int64
+	lastWritten  time.Time
+}
+
+// summarizeArtifacts returns a summary of the given artifacts of a job.
+func summarizeArtifacts(jobID string, artifacts []gbdx.Artifact) artifactSummary {
+	s := artifactSummary{jobID: jobID, numArtifacts: len(artifacts)}
+	for _, a := range artifacts {
+		s.size += a.Size
+		if a.LastModified.After(s.lastWritten) {
+			s.lastWritten = a.LastModified
+		}
+	}
+	return s
+}
+
+// writeArtifactSummaries writes summaries to w, one per line, followed
+// by their totals if there is more than one.
+func writeArtifactSummaries(w io.Writer, summaries []artifactSummary) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB ID\tSTATUS\tARTIFACTS\tSIZE\tLAST WRITTEN")
+	var (
+		numArtifacts int
+		size         int64
+	)
+	for _, s := range summaries {
+		numArtifacts += s.numArtifacts
+		size += s.size
+		status := s.status
+		if status == "" {
+			status = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", s.jobID, status, s.numArtifacts, pb.Format(s.size).To(pb.U_BYTES), s.lastWritten.Local().Format("2006-01-02 15:04"))
+	}
+	if len(summaries) > 1 {
+		fmt.Fprintf(tw, "%d jobs\t\t%d\t%s\t\n", len(summaries), numArtifacts, pb.Format(size).To(pb.U_BYTES))
+	}
+	return tw.Flush()
+}
+
+// confirm asks a yes or no question on stdin, returning true if it is
+// answered yes.
+func confirm(question string) (bool, error) {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, errors.Wrap(err, "failed reading confirmation")
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
@@ -51,6 +51,21 @@ refresh and caching for you.
 rda authorization supports "profiles" if you have more than one set of
 credentials.  By default, "default" is used if you don't specify a
 particual profile via the --profile flag.
+
+Each profile can also point rda at a different RDA API, GBDX token
+endpoint, and GBDX S3 credentials endpoint, e.g. a staging
+deployment or a local stand in.  These can be overridden for a
+single invocation with the --rda-url, --token-url, and --s3creds-url
+flags, or the 'RDA_URL', 'GBDX_TOKEN_URL', and 'GBDX_S3CREDS_URL'
+environment variables.  Likewise --s3-url or 'S3_URL' point rda at an
+S3 compatible API other than AWS for batch artifacts, and
+--catalog-url or 'GBDX_CATALOG_URL' at another GBDX catalog search
+endpoint.  'rda mockserver' runs a local stand in for all of these.
+
+Tile realization adapts how many requests it has in flight to how RDA
+is responding.  A profile's max_rps setting, or the --max-rps flag or
+'MAX_RPS' environment variable, additionally caps how many tile
+requests are made each second.
 `,
 	Version: fmt.Sprintf("%v, commit %v, built at %v", version, commit, date),
 	// RunE: func(cmd *cobra.Command, args []string) error {
@@ -73,12 +88,30 @@ func Execute() {
 func init() {
 	rootCmd.PersistentFlags().String("profile", "default", "RDA profile to use")
 	rootCmd.PersistentFlags().Bool("debug", false, "Debug RDA HTTP requests")
+	rootCmd.PersistentFlags().String("rda-url", "", "base URL of the RDA API, overriding the profile's")
+	rootCmd.PersistentFlags().String("token-url", "", "GBDX oauth2 token endpoint, overriding the profile's")
+	rootCmd.PersistentFlags().String("s3creds-url", "", "GBDX S3 credentials endpoint, overriding the profile's")
+	rootCmd.PersistentFlags().String("s3-url", "", "S3 compatible API holding batch artifacts, overriding the profile's")
+	rootCmd.PersistentFlags().String("catalog-url", "", "GBDX catalog search endpoint, overriding the profile's")
+	rootCmd.PersistentFlags().Float64("max-rps", 0, "most tile requests to make to RDA per second, overriding the profile's; 0 means no cap")
 
 	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
 	viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
+	viper.BindPFlag("rda_url", rootCmd.PersistentFlags().Lookup("rda-url"))
+	viper.BindPFlag("gbdx_token_url", rootCmd.PersistentFlags().Lookup("token-url"))
+	viper.BindPFlag("gbdx_s3creds_url", rootCmd.PersistentFlags().Lookup("s3creds-url"))
+	viper.BindPFlag("s3_url", rootCmd.PersistentFlags().Lookup("s3-url"))
+	viper.BindPFlag("gbdx_catalog_url", rootCmd.PersistentFlags().Lookup("catalog-url"))
+	viper.BindPFlag("max_rps", rootCmd.PersistentFlags().Lookup("max-rps"))
 
 	viper.BindEnv("gbdx_username")
 	viper.BindEnv("gbdx_password")
+	viper.BindEnv("rda_url")
+	viper.BindEnv("gbdx_token_url")
+	viper.BindEnv("gbdx_s3creds_url")
+	viper.BindEnv("s3_url")
+	viper.BindEnv("gbdx_catalog_url")
+	viper.BindEnv("max_rps")
 
 	cobra.OnInitialize(initConfig)
 }
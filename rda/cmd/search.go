@@ -0,0 +1,257 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/gbdx"
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var searchFlags struct {
+	bbox        boundingBox
+	geojson     string
+	from        string
+	to          string
+	sensors     []string
+	maxCloud    float64
+	maxOffNadir float64
+	format      string
+	checkRDA    bool
+}
+
+// maxAvailabilityChecks is how many RDA availability checks are made at once.
+const maxAvailabilityChecks = 8
+
+// searchResult is an acquisition found in the catalog, along with
+// whether RDA has it if that was checked.
+type searchResult struct {
+	gbdx.Acquisition
+	RDAAvailable *bool `json:"rdaAvailable,omitempty"`
+}
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search (--bbox minlon,minlat,maxlon,maxlat | --geojson <file>)",
+	Short: "Search the GBDX catalog for DG acquisitions over an area",
+	Long: `Search the GBDX catalog for DG acquisitions over an area
+
+The area is given either as a longitude and latitude box via --bbox,
+or as a GeoJSON polygon or multipolygon via --geojson.  Acquisitions
+whose footprints intersect it are written to stdout, most recent
+first, with their catalog ids, footprints, acquisition dates, sensors,
+cloud cover, and off nadir angles, either as a JSON array or, with
+--format geojson, as a GeoJSON FeatureCollection of their footprints.
+
+Not everything in the catalog is in RDA.  Pass --check-rda to ask RDA
+for each acquisition's strip info and record whether it has it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var area *rda.Geometry
+		switch bboxSet := cmd.Flags().Changed("bbox"); {
+		case bboxSet && searchFlags.geojson != "":
+			return errors.New("only one of --bbox and --geojson may be given")
+		case bboxSet:
+			b := searchFlags.bbox
+			area = rda.NewBoxGeometry(b.minX, b.minY, b.maxX, b.maxY, "EPSG:4326")
+		case searchFlags.geojson != "":
+			g, err := readSearchArea(searchFlags.geojson)
+			if err != nil {
+				return err
+			}
+			area = g
+		default:
+			return errors.New("an area to search is required, via --bbox or --geojson")
+		}
+
+		switch searchFlags.format {
+		case "json", "geojson":
+		default:
+			return errors.Errorf("--format = %q must be json or geojson", searchFlags.format)
+		}
+
+		from, err := parseTimeFlag("from", searchFlags.from)
+		if err != nil {
+			return err
+		}
+		to, err := parseEndTimeFlag("to", searchFlags.to)
+		if err != nil {
+			return err
+		}
+		options := []gbdx.SearchOption{gbdx.AcquiredBetween(from, to)}
+		var sensors []string
+		for _, s := range searchFlags.sensors {
+			sensors = append(sensors, strings.Split(s, ",")...)
+		}
+		if len(sensors) > 0 {
+			options = append(options, gbdx.Sensors(sensors...))
+		}
+		if cmd.Flags().Changed("max-cloud") {
+			options = append(options, gbdx.MaxCloudCover(searchFlags.maxCloud))
+		}
+		if cmd.Flags().Changed("max-off-nadir") {
+			options = append(options, gbdx.MaxOffNadir(searchFlags.maxOffNadir))
+		}
+
+		ctx := context.Background()
+		client, writeConfig, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writeConfig(); err != nil {
+				log.Printf("on exit, received an error when writing configuration, err: %v", err)
+			}
+		}()
+
+		catalog, err := newCatalog(client)
+		if err != nil {
+			return err
+		}
+		acqs, err := catalog.Search(ctx, area.WKT(), options...)
+		if err != nil {
+			return err
+		}
+
+		results := make([]searchResult, len(acqs))
+		for i, acq := range acqs {
+			results[i].Acquisition = acq
+		}
+		if searchFlags.checkRDA {
+			api, err := newRDAClient(client)
+			if err != nil {
+				return err
+			}
+			if err := checkRDAAvailability(ctx, api, results); err != nil {
+				return err
+			}
+		}
+
+		if searchFlags.format == "geojson" {
+			return writeSearchGeoJSON(results)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(results), "failed writing search results")
+	},
+}
+
+// readSearchArea reads the GeoJSON area to search from path,
+// reprojecting it to EPSG:4326 if it names another crs.
+func readSearchArea(path string) (*rda.Geometry, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading --geojson")
+	}
+	g, err := rda.ParseGeoJSON(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed parsing GeoJSON in %s", path)
+	}
+	if g.SRS == "EPSG:4326" {
+		return g, nil
+	}
+	g, err = g.Transform("EPSG:4326")
+	return g, errors.Wrapf(err, "failed reprojecting %s to EPSG:4326", path)
+}
+
+// checkRDAAvailability records in each result whether RDA has strip
+// info for it.
+func checkRDAAvailability(ctx context.Context, api *rda.Client, results []searchResult) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(results))
+	sem := make(chan struct{}, maxAvailabilityChecks)
+	for i := range results {
+		wg.Add(1)
+		go func(r *searchResult, err *error) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			available, aerr := api.StripAvailable(ctx, r.CatalogID)
+			if aerr != nil {
+				*err = errors.Wrapf(aerr, "failed checking RDA for %s", r.CatalogID)
+				return
+			}
+			r.RDAAvailable = &available
+		}(&results[i], &errs[i])
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSearchGeoJSON writes results to stdout as a GeoJSON
+// FeatureCollection of their footprints.
+func writeSearchGeoJSON(results []searchResult) error {
+	type feature struct {
+		Type       string          `json:"type"`
+		ID         string          `json:"id"`
+		Geometry   json.RawMessage `json:"geometry"`
+		Properties searchResult    `json:"properties"`
+	}
+	fc := struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}{Type: "FeatureCollection", Features: []feature{}}
+
+	for _, r := range results {
+		geom := json.RawMessage("null")
+		if g, err := rda.ParseWKT(r.FootprintWKT); err != nil {
+			log.Printf("%s has no usable footprint, err: %v", r.CatalogID, err)
+		} else {
+			geom = g.GeoJSON()
+		}
+		props := r
+		props.FootprintWKT = ""
+		fc.Features = append(fc.Features, feature{Type: "Feature", ID: r.CatalogID, Geometry: geom, Properties: props})
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(fc), "failed writing search results")
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().Var(&searchFlags.bbox, "bbox", "search a geographic box, specified via comma seperated floats minlon,minlat,maxlon,maxlat")
+	searchCmd.Flags().StringVar(&searchFlags.geojson, "geojson", "", "search the area of the GeoJSON polygon or multipolygon in this file")
+	searchCmd.Flags().StringVar(&searchFlags.from, "from", "", "only find acquisitions made at or after this date (2006-01-02), timestamp, or duration ago (e.g. 30d)")
+	searchCmd.Flags().StringVar(&searchFlags.to, "to", "", "only find acquisitions made at or before this date (2006-01-02, through the end of that day), timestamp, or duration ago (e.g. 30d)")
+	searchCmd.Flags().StringArrayVar(&searchFlags.sensors, "sensor", []string{}, "only find acquisitions from this sensor platform, e.g. WORLDVIEW02 or WORLDVIEW03_VNIR; repeat or comma seperate to allow several")
+	searchCmd.Flags().Float64Var(&searchFlags.maxCloud, "max-cloud", 0, "only find acquisitions with at most this percent cloud cover")
+	searchCmd.Flags().Float64Var(&searchFlags.maxOffNadir, "max-off-nadir", 0, "only find acquisitions imaged at most this many degrees off nadir")
+	searchCmd.Flags().StringVar(&searchFlags.format, "format", "json", "output format, json or geojson")
+	searchCmd.Flags().BoolVar(&searchFlags.checkRDA, "check-rda", false, "check whether RDA has each acquisition")
+}
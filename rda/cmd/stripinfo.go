@@ -22,8 +22,14 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
 	"github.com/pkg/errors"
@@ -39,9 +45,37 @@ var stripinfoCmd = &cobra.Command{
 	the underlying catalog id that is stored in RDA is composed
 	of.  This is different than metadata you get back from
 	realized graphs and templates in that those are specific to
-	the image you can pull out from those nodes.`,
+	the image you can pull out from those nodes.
+
+	With --summary, the strip's sensor, acquisition time, sun and
+	satellite angles, cloud cover, resolution, and the band types
+	and corrections RDA can realize it with are shown along with
+	its image parts, as a table or, with --json, as JSON.
+
+	With --parsed, the DG factory metadata of each of the strip's
+	image parts is parsed and written as JSON: band calibration
+	factors and effective bandwidths, sun and satellite angles,
+	footprints, tiling, and ephemeris and attitude summaries.  The
+	argument may also be a factory metadata zip, as written by
+	--zipfile, or a part's XML, IMD, TIL, EPH, or ATT file, in
+	either XML or the older key=value syntax.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if stripinfoFlags.parsed && (stripinfoFlags.summary || zipfile != "") {
+			return errors.New("--parsed can't be used with --summary or --zipfile")
+		}
+
+		// Local factory metadata files don't need RDA to parse.
+		if stripinfoFlags.parsed {
+			if _, err := os.Stat(args[0]); err == nil {
+				fms, err := parseFactoryFile(args[0])
+				if err != nil {
+					return err
+				}
+				return json.NewEncoder(os.Stdout).Encode(fms)
+			}
+		}
+
 		ctx := context.Background()
 		client, writeConfig, err := newClient(ctx)
 		if err != nil {
@@ -53,9 +87,33 @@ var stripinfoCmd = &cobra.Command{
 			}
 		}()
 
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
+		if stripinfoFlags.parsed {
+			fms, err := api.FactoryMetadata(args[0])
+			if err != nil {
+				return err
+			}
+			return json.NewEncoder(os.Stdout).Encode(fms)
+		}
+
+		if stripinfoFlags.summary {
+			strip, err := api.StripMetadata(args[0])
+			if err != nil {
+				return err
+			}
+			if stripinfoFlags.json {
+				return json.NewEncoder(os.Stdout).Encode(strip)
+			}
+			return writeStripTable(os.Stdout, strip)
+		}
+
 		// No zip file, so stream out json.
 		if zipfile == "" {
-			return errors.Wrap(rda.StripInfo(client, os.Stdout, args[0], false), "failed copying response body to stdout")
+			return errors.Wrap(api.StripInfo(os.Stdout, args[0], false), "failed copying response body to stdout")
 		}
 
 		f, err := os.Create(zipfile)
@@ -64,13 +122,102 @@ var stripinfoCmd = &cobra.Command{
 		}
 		defer f.Close()
 
-		return errors.Wrap(rda.StripInfo(client, f, args[0], true), "failed writing RDA strip information as zip file")
+		return errors.Wrap(api.StripInfo(f, args[0], true), "failed writing RDA strip information as zip file")
 	},
 }
 
+// writeStripTable writes a summary of strip to w, followed by a line
+// per image part.
+func writeStripTable(w io.Writer, strip *rda.Strip) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Catalog ID\t%s\n", strip.CatalogID)
+	fmt.Fprintf(tw, "Sensor\t%s\n", strip.Sensor)
+	fmt.Fprintf(tw, "Acquired\t%s\n", strip.AcquisitionDate.UTC().Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(tw, "Cloud cover\t%g%%\n", strip.CloudCover)
+	fmt.Fprintf(tw, "Sun azimuth/elevation\t%g/%g\n", strip.SunAzimuth, strip.SunElevation)
+	fmt.Fprintf(tw, "Satellite azimuth/elevation\t%g/%g\n", strip.SatAzimuth, strip.SatElevation)
+	fmt.Fprintf(tw, "GSD\t%gm\n", strip.GSD)
+	fmt.Fprintf(tw, "Band types\t%s\n", strings.Join(strip.BandTypes, ", "))
+	fmt.Fprintf(tw, "Correction types\t%s\n", strings.Join(strip.CorrectionTypes, ", "))
+	fmt.Fprintf(tw, "Part footprints\t%s\n", strip.PartFootprintsWKT)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "BAND\tPART\tIMAGE ID\tBANDS\tSIZE\tGSD")
+	for _, band := range []struct {
+		name  string
+		parts []rda.StripImage
+	}{
+		{"pan", strip.Parts.PanImages},
+		{"vnir", strip.Parts.VNIRImages},
+		{"swir", strip.Parts.SWIRImages},
+		{"cavis", strip.Parts.CavisImages},
+	} {
+		for i, part := range band.parts {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%dx%d\t%gm\n", band.name, i+1, part.ImageID, part.NumBands, part.ImageWidth, part.ImageHeight, part.GroundSampleDistanceMeters)
+		}
+	}
+	return tw.Flush()
+}
+
+// parseFactoryFile parses the factory metadata in the file at path,
+// which is either a zip of it or one of a part's files.
+func parseFactoryFile(path string) ([]*rda.FactoryMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed opening factory metadata")
+	}
+	defer f.Close()
+
+	ext := strings.ToUpper(filepath.Ext(path))
+	if ext == ".ZIP" {
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed reading factory metadata zip")
+		}
+		return rda.ParseFactoryMetadataZip(f, fi.Size())
+	}
+
+	fm := rda.FactoryMetadata{Prefix: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+	switch ext {
+	case ".XML":
+		var parsed *rda.FactoryMetadata
+		if parsed, err = rda.ParseFactoryXML(f); err == nil {
+			parsed.Prefix = fm.Prefix
+			fm = *parsed
+		}
+	case ".IMD":
+		fm.IMD, err = rda.ParseIMD(f)
+	case ".TIL":
+		fm.TIL, err = rda.ParseTIL(f)
+	case ".EPH":
+		fm.Ephemeris, err = rda.ParseEphemeris(f)
+	case ".ATT":
+		fm.Attitude, err = rda.ParseAttitude(f)
+	default:
+		return nil, errors.Errorf("%s isn't a factory metadata zip or an XML, IMD, TIL, EPH, or ATT file", path)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed parsing %s", path)
+	}
+	return []*rda.FactoryMetadata{&fm}, nil
+}
+
 var zipfile string
 
+var stripinfoFlags struct {
+	summary bool
+	json    bool
+	parsed  bool
+}
+
 func init() {
 	rootCmd.AddCommand(stripinfoCmd)
 	stripinfoCmd.Flags().StringVar(&zipfile, "zipfile", "", "write zipped metadata to the provided filepath")
+	stripinfoCmd.Flags().BoolVar(&stripinfoFlags.summary, "summary", false, "summarize the strip and its image parts as a table")
+	stripinfoCmd.Flags().BoolVar(&stripinfoFlags.json, "json", false, "with --summary, write the summary as JSON rather than a table")
+	stripinfoCmd.Flags().BoolVar(&stripinfoFlags.parsed, "parsed", false, "parse the DG factory metadata of each image part and write it as JSON")
 }
@@ -0,0 +1,407 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	"github.com/cheggaaa/pb"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// batchCmd represents the batch command
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Submit many RDA batch materialization jobs at once",
+}
+
+// batchSubmitCmd represents the batch submit command
+var batchSubmitCmd = &cobra.Command{
+	Use:   "submit <manifest.csv|manifest.json>",
+	Short: "Submit a batch materialization job for each row of a manifest",
+	Long: `Submit a batch materialization job for each row of a manifest
+
+Each row gives a catalogId, to realize a DigitalGlobe strip as "rda
+dgstrip batch" would, or a templateId and nodeId; template parameters,
+which for a catalogId override those "rda dgstrip batch" uses by
+default; an output format and its options, defaulting to --format and
+--format-option; and optionally a geometry to crop the output to, in
+the image's coordinate reference system, given as wkt, as a bbox of
+minx,miny,maxx,maxy, or as geojson.
+
+A CSV manifest has a header naming its columns: catalogId, templateId,
+nodeId, format, wkt, bbox, and geojson; format.<key> for format option
+<key>; and any others for template parameters.  A JSON manifest is an
+array of objects with the fields catalogId, templateId, nodeId,
+parameters, format, formatOptions, wkt, bbox, and geojson.
+
+Rows are submitted --parallel at a time, no more than --rate per
+second.  The outcome of each row, its job id or why it failed, is
+written to --results, by default next to the manifest, as JSON lines.
+Rows whose request was already submitted according to that file are
+skipped, wherever they now are in the manifest, so rerunning the
+command only submits new, changed, and failed rows.  Rows with the
+same request are only submitted once.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Setup our context to handle cancellation and listen for signals.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			select {
+			case <-sigs:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		if batchSubmitFlags.parallel < 1 {
+			return errors.Errorf("--parallel = %d must be at least 1", batchSubmitFlags.parallel)
+		}
+		options, err := batchSubmitFlags.batch.options()
+		if err != nil {
+			return err
+		}
+		rows, err := rda.ReadBatchManifest(args[0])
+		if err != nil {
+			return err
+		}
+		resultsPath := batchSubmitFlags.results
+		if resultsPath == "" {
+			resultsPath = strings.TrimSuffix(args[0], filepath.Ext(args[0])) + ".results.jsonl"
+		}
+		results, err := rda.OpenBatchResults(resultsPath)
+		if err != nil {
+			return err
+		}
+
+		client, writeConfig, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writeConfig(); err != nil {
+				log.Printf("on exit, received an error when writing configuration, err: %v", err)
+			}
+		}()
+
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
+		s := manifestSubmitter{
+			api:     api,
+			format:  rda.BatchFormat(batchSubmitFlags.batch.format),
+			options: options,
+			results: results,
+			dryRun:  batchSubmitFlags.dryRun,
+		}
+		if batchSubmitFlags.rate > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / batchSubmitFlags.rate))
+			defer ticker.Stop()
+			s.limiter = ticker.C
+		}
+		outcomes := s.submit(ctx, rows, batchSubmitFlags.parallel)
+
+		if s.dryRun {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(outcomes)
+		}
+		if err := results.Close(); err != nil {
+			return err
+		}
+		if err := writeSubmissionTable(os.Stdout, outcomes); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return errors.Errorf("interrupted before submitting every row; rerun the command to submit the rest")
+		}
+		failed := 0
+		for _, o := range outcomes {
+			if o.Error != "" {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return errors.Errorf("%d of %d rows failed; see %s", failed, len(rows), resultsPath)
+		}
+		return nil
+	},
+}
+
+// manifestOutcome is what became of a manifest row.
+type manifestOutcome struct {
+	rda.BatchSubmission
+
+	// Skipped is set if the row's request was submitted before.
+	Skipped bool `json:"skipped,omitempty"`
+
+	// Request is the request made for the row, given for dry runs.
+	Request *rda.BatchRequest `json:"request,omitempty"`
+}
+
+// manifestSubmitter submits the rows of a batch manifest.
+type manifestSubmitter struct {
+	api *rda.Client
+
+	// format and options are the output format and batch options
+	// rows start from.
+	format  rda.BatchFormat
+	options []rda.BatchOption
+
+	results *rda.BatchResults
+	dryRun  bool
+
+	// limiter, if set, paces submissions.
+	limiter <-chan time.Time
+}
+
+// submit submits rows, n at a time, returning what became of each
+// row that was gotten to before ctx was cancelled, in row order.
+func (s *manifestSubmitter) submit(ctx context.Context, rows []*rda.BatchManifestRow, n int) []manifestOutcome {
+	var bar *pb.ProgressBar
+	if !s.dryRun {
+		bar = pb.StartNew(len(rows))
+		defer bar.Finish()
+	}
+
+	rowsIn := make(chan *rda.BatchManifestRow)
+	go func() {
+		defer close(rowsIn)
+		for _, row := range rows {
+			select {
+			case rowsIn <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	outcomes := make([]*manifestOutcome, len(rows))
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range rowsIn {
+				o := s.submitRow(ctx, row)
+				if o == nil {
+					continue // Interrupted.
+				}
+				// Skipped rows are recorded too, under their
+				// current row number.
+				if !s.dryRun {
+					if err := s.results.Add(o.BatchSubmission); err != nil {
+						log.Printf("row %d: %v", row.Row, err)
+					}
+				}
+				outcomes[row.Row-1] = o
+				if bar != nil {
+					bar.Increment()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var done []manifestOutcome
+	for _, o := range outcomes {
+		if o != nil {
+			done = append(done, *o)
+		}
+	}
+	return done
+}
+
+// submitRow submits the request described by row unless it was
+// submitted before, returning what became of it, or nil if ctx was
+// cancelled first.
+func (s *manifestSubmitter) submitRow(ctx context.Context, row *rda.BatchManifestRow) *manifestOutcome {
+	o := manifestOutcome{BatchSubmission: rda.BatchSubmission{Row: row.Row}}
+	fail := func(err error) *manifestOutcome {
+		if ctx.Err() != nil {
+			return nil
+		}
+		o.Error = err.Error()
+		return &o
+	}
+
+	if err := row.Validate(); err != nil {
+		return fail(err)
+	}
+	template := s.template(row)
+	format, _ := row.OutputFormat(s.format)
+	options := append([]rda.BatchOption{}, s.options...)
+	for key, val := range row.FormatOptions {
+		options = append(options, rda.WithFormatOption(key, val))
+	}
+	if wkt, _ := row.CropWKT(); wkt != "" {
+		options = append(options, rda.WithCropGeometry(wkt))
+	}
+	req := template.BatchRequest(format, options...)
+	hash, err := req.Hash()
+	if err != nil {
+		return fail(err)
+	}
+	o.RequestHash = hash
+
+	if s.dryRun {
+		if prev, ok := s.results.Submitted(hash); ok {
+			o.JobID, o.SubmittedAt, o.Skipped = prev.JobID, prev.SubmittedAt, true
+		} else {
+			o.Request = req
+		}
+		return &o
+	}
+
+	// Once reserved, we must add the row's outcome to the results,
+	// which submit does, so other rows with the same request stop
+	// waiting on it.
+	prev, ok, err := s.results.Reserve(ctx, hash)
+	if err != nil {
+		return nil
+	}
+	if ok {
+		o.JobID, o.SubmittedAt, o.Skipped = prev.JobID, prev.SubmittedAt, true
+		return &o
+	}
+
+	if s.limiter != nil {
+		select {
+		case <-s.limiter:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	// Make sure the image can be output in the requested format
+	// before submitting it, as "rda dgstrip batch" does.
+	md, err := template.Metadata()
+	if err != nil {
+		return fail(err)
+	}
+	if err := format.CheckMetadata(md); err != nil {
+		return fail(err)
+	}
+	resp, err := template.SubmitBatch(ctx, req)
+	if err != nil {
+		return fail(err)
+	}
+	recordJob(resp, batchSubmitFlags.batch.tags)
+	now := time.Now()
+	o.JobID, o.SubmittedAt = resp.JobID, &now
+	return &o
+}
+
+// template returns the template realizing the row.
+func (s *manifestSubmitter) template(row *rda.BatchManifestRow) *rda.Template {
+	if row.CatalogID == "" {
+		var params []rda.TemplateOption
+		for key, val := range row.Parameters {
+			params = append(params, rda.AddParameter(key, val))
+		}
+		if row.NodeID != "" {
+			params = append(params, rda.AddParameter("nodeId", row.NodeID))
+		}
+		return s.api.NewTemplate(row.TemplateID, params...)
+	}
+
+	// Start from the parameters "rda dgstrip batch" uses when given
+	// no flags.
+	var (
+		crs   coordRefSys
+		bt    bandType
+		bands bandCombo
+	)
+	params := map[string]string{
+		"catalogId":      row.CatalogID,
+		"crs":            crs.String(),
+		"bands":          bt.String(),
+		"bandSelection":  bands.String(),
+		"correctionType": "DN",
+		"draType":        "None",
+	}
+	for key, val := range row.Parameters {
+		params[key] = val
+	}
+	var options []rda.TemplateOption
+	for key, val := range params {
+		options = append(options, rda.AddParameter(key, val))
+	}
+	return s.api.NewTemplate(dgstripTemplateID, options...)
+}
+
+// writeSubmissionTable writes what became of each manifest row to w.
+func writeSubmissionTable(w *os.File, outcomes []manifestOutcome) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROW\tJOB ID\tOUTCOME")
+	for _, o := range outcomes {
+		switch {
+		case o.Error != "":
+			fmt.Fprintf(tw, "%d\t-\tfailed: %s\n", o.Row, o.Error)
+		case o.Skipped:
+			submitted := "earlier"
+			if o.SubmittedAt != nil {
+				submitted = o.SubmittedAt.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(tw, "%d\t%s\tskipped, submitted %s\n", o.Row, o.JobID, submitted)
+		default:
+			fmt.Fprintf(tw, "%d\t%s\tsubmitted\n", o.Row, o.JobID)
+		}
+	}
+	return tw.Flush()
+}
+
+var batchSubmitFlags struct {
+	batch    batchFlags
+	parallel int
+	rate     float64
+	results  string
+	dryRun   bool
+}
+
+func init() {
+	rootCmd.AddCommand(batchCmd)
+	batchCmd.AddCommand(batchSubmitCmd)
+
+	addBatchFlags(batchSubmitCmd.Flags(), &batchSubmitFlags.batch)
+	batchSubmitCmd.Flags().IntVar(&batchSubmitFlags.parallel, "parallel", 4, "how many rows to submit at once")
+	batchSubmitCmd.Flags().Float64Var(&batchSubmitFlags.rate, "rate", 2, "most rows to submit per second; 0 for no limit")
+	batchSubmitCmd.Flags().StringVar(&batchSubmitFlags.results, "results", "", "file to record each row's job id or error in; by default, <manifest>.results.jsonl")
+	batchSubmitCmd.Flags().BoolVar(&batchSubmitFlags.dryRun, "dry-run", false, "print the request each row would make, as JSON, without submitting anything")
+}
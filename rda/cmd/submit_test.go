@@ -0,0 +1,112 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+)
+
+// batchSubmissions returns how many batch materialization requests the
+// test server has received.
+func batchSubmissions() int {
+	return testServer.Requests("/materialize") - testServer.Requests("/materialize/status")
+}
+
+func TestBatchSubmitRerun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rda-cmd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	manifest := filepath.Join(dir, "manifest.csv")
+
+	// submit submits a manifest of the given catalog ids, returning
+	// how many requests were made.
+	submit := func(catIDs ...string) int {
+		t.Helper()
+		if err := ioutil.WriteFile(manifest, []byte("catalogId\n"+strings.Join(catIDs, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		before := batchSubmissions()
+		if _, err := runCommand(t, "batch", "submit", manifest, "--rate", "0"); err != nil {
+			t.Fatalf("batch submit failed: %v", err)
+		}
+		return batchSubmissions() - before
+	}
+
+	const a, b, c = "1040010038952901", "1040010038952902", "1040010038952903"
+	if n := submit(a, b); n != 2 {
+		t.Fatalf("submitted %d requests for a new manifest, want 2", n)
+	}
+
+	// Reordering the rows and inserting one only submits the new row.
+	if n := submit(c, b, a); n != 1 {
+		t.Errorf("submitted %d requests after reordering and inserting a row, want 1", n)
+	}
+
+	// Dropping rows and restoring them later submits nothing.
+	if n := submit(c); n != 0 {
+		t.Errorf("submitted %d requests after dropping rows, want 0", n)
+	}
+	results, err := rda.OpenBatchResults(filepath.Join(dir, "manifest.results.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subs := results.Results(); len(subs) != 1 {
+		t.Errorf("got results for %d rows after dropping rows, want 1", len(subs))
+	}
+	if n := submit(a, b, c); n != 0 {
+		t.Errorf("submitted %d requests after restoring rows, want 0", n)
+	}
+
+	// Rows with the same request are only submitted once, even
+	// submitted at the same time.
+	const d = "1040010038952904"
+	if n := submit(d, d, d, d); n != 1 {
+		t.Errorf("submitted %d requests for 4 identical rows, want 1", n)
+	}
+
+	// The results list each row's job under its current row number.
+	results, err = rda.OpenBatchResults(filepath.Join(dir, "manifest.results.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs := results.Results()
+	if len(subs) != 4 {
+		t.Fatalf("got results for %d rows, want 4", len(subs))
+	}
+	for i, s := range subs {
+		if s.Row != i+1 || s.JobID == "" || s.JobID != subs[0].JobID {
+			t.Errorf("row %d's result is %+v, want row %d of the job for %s", i+1, s, i+1, d)
+		}
+	}
+
+	// The earlier rows' jobs are still remembered.
+	if n := submit(a, b, c); n != 0 {
+		t.Errorf("submitted %d requests after replacing every row and restoring them, want 0", n)
+	}
+}
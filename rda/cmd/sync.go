@@ -0,0 +1,180 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/gbdx"
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/cheggaaa/pb"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync <job id> <destination>",
+	Short: "copy an RDA batch job's new or changed artifacts to a local directory or another S3 location",
+	Long: `copy an RDA batch job's new or changed artifacts to a local directory or another S3 location
+
+The destination is either a local directory, which is created if it
+doesn't exist, or an s3://bucket/prefix URL.  Artifacts are laid out
+there as "rda job download" lays them out, and only those that are
+missing or whose size or checksum differs are copied.  The checksums of
+artifacts already in a local directory are only checked if --verify is
+given, as with "rda job download"; otherwise only their size is.
+
+S3 destinations are accessed with your own AWS credentials, found as
+the AWS CLI finds them (e.g. AWS_ACCESS_KEY_ID and friends, or
+~/.aws/credentials), rather than with GBDX's; use --aws-profile to pick
+a profile from your AWS configuration.
+
+With --delete, anything at the destination that isn't one of the job's
+artifacts is deleted, after asking you to confirm unless --yes is
+given.  Use --dry-run to only show what would be copied and deleted.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID, destArg := args[0], args[1]
+
+		// Setup our context to handle cancellation and listen for signals.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() {
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			select {
+			case s := <-sigs:
+				log.Printf("received a shutdown signal %s, winding down", s)
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		dest, err := syncDestination(destArg)
+		if err != nil {
+			return err
+		}
+
+		client, writeConfig, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writeConfig(); err != nil {
+				log.Printf("on exit, received an error when writing configuration, err: %v", err) // TODO, handle more gracefully.
+			}
+		}()
+
+		accessor, err := newS3Accessor(client, gbdx.WithNumParallel(jobFlags.parallel))
+		if err != nil {
+			return err
+		}
+
+		plan, err := accessor.PlanSync(ctx, jobID, dest, jobFlags.delete, jobFlags.verify)
+		if err != nil {
+			return err
+		}
+		if jobFlags.dryRun {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(plan)
+		}
+		if len(plan.Copy) == 0 && len(plan.Delete) == 0 {
+			fmt.Printf("%s is already in sync with %s\n", dest, jobID)
+			return nil
+		}
+		if len(plan.Delete) > 0 && !jobFlags.yes {
+			for _, p := range plan.Delete {
+				fmt.Println(p)
+			}
+			ok, err := confirm(fmt.Sprintf("delete these %d extraneous files from %s?", len(plan.Delete), dest))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println("nothing synced")
+				return nil
+			}
+		}
+
+		bar := pb.StartNew(len(plan.Copy))
+		gbdx.WithProgressFunc(bar.Increment)(accessor)
+		tStart := time.Now()
+		numDel, err := accessor.SyncBatchJobArtifacts(ctx, plan)
+		if err != nil {
+			bar.FinishPrint("Failed syncing all artifacts; rerun the command to pick up where you left off.")
+			return err
+		}
+		bar.FinishPrint(fmt.Sprintf("copied %d artifacts and deleted %d extraneous files in %s", len(plan.Copy), numDel, time.Since(tStart)))
+
+		if dir := localSyncDir(destArg); dir != "" {
+			updateJob(jobID, func(r *rda.JobRecord) { r.AddDownload(dir, len(plan.Copy)) })
+		}
+		return nil
+	},
+}
+
+// localSyncDir returns the local directory dest names, or "" if it is
+// an S3 URL.
+func localSyncDir(dest string) string {
+	if strings.HasPrefix(dest, "s3://") {
+		return ""
+	}
+	return dest
+}
+
+// syncDestination returns the destination to sync to named by dest,
+// with S3 destinations accessed via the AWS credentials chosen by
+// jobFlags.
+func syncDestination(dest string) (gbdx.SyncDestination, error) {
+	if dir := localSyncDir(dest); dir != "" {
+		return gbdx.LocalDestination(dir), nil
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Profile:           jobFlags.awsProfile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed loading AWS credentials for the destination")
+	}
+	cfg := aws.Config{}
+	if aws.StringValue(sess.Config.Region) == "" {
+		cfg.Region = aws.String("us-east-1")
+	}
+	if jobFlags.destS3URL != "" {
+		cfg.Endpoint = aws.String(jobFlags.destS3URL)
+		cfg.S3ForcePathStyle = aws.Bool(true)
+	}
+	return gbdx.NewS3Destination(sess, dest, &cfg)
+}
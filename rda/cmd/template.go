@@ -21,6 +21,7 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"encoding/xml"
@@ -34,6 +35,7 @@ import (
 	"strings"
 	"syscall"
 	"time"
+	"unicode"
 
 	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
 	"github.com/cheggaaa/pb"
@@ -66,7 +68,12 @@ var templateDescribeCmd = &cobra.Command{
 			}
 		}()
 
-		template := rda.NewTemplate(args[0], client)
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
+		template := api.NewTemplate(args[0])
 		g, err := template.Describe()
 		if err != nil {
 			return err
@@ -80,6 +87,17 @@ var templateUploadCmd = &cobra.Command{
 	Short: "upload uploads a RDA template to the RDA API, returning a template id for it",
 	Long: `upload uploads a RDA template to the RDA API, returning a template id for it
 
+The template is either RDA's JSON representation of a graph, or the
+compact text format below, where each line adds a node feeding the
+nodes it names to an operator along with its parameters:
+
+  ms = DigitalGlobeStrip(catalogId=$cat, bands=MS)
+  ortho = Orthorectify(ms, "Output Coordinate Reference System"=EPSG:4326)
+  default ortho
+
+$name becomes the template placeholder ${name}.  The default node is
+the one at the end of the longest path if not given.
+
 Edge ID fields are not required, and will be overwritten. 
 
 You can specifiy a "-" as the path and it will read the template from an input pipe`,
@@ -96,25 +114,26 @@ You can specifiy a "-" as the path and it will read the template from an input p
 			}
 		}()
 
-		var r io.Reader
-		switch file := args[0]; file {
-		case "-":
-			r = os.Stdin
-		default:
-			f, err := os.Open(file)
-			if err != nil {
-				return errors.Wrap(err, "couldn't open template file for uploading")
-			}
-			defer f.Close()
-			r = f
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
 		}
 
 		// We parse the graph in part to figure out if its valid rather than just passing it through.
-		g, err := rda.NewGraphFromAPI(r)
+		g, err := readGraph(args[0])
 		if err != nil {
 			return err
 		}
-		template := rda.NewTemplate(args[0], client)
+		if !templateFlags.skipValidation {
+			v, err := validateGraph(api, g)
+			if err != nil {
+				return err
+			}
+			if err := v.Err(); err != nil {
+				return errors.Wrap(err, "not uploading the template; fix it or use --skip-validation")
+			}
+		}
+		template := api.NewTemplate(args[0])
 		id, err := template.Upload(g)
 		if err != nil {
 			return err
@@ -126,6 +145,194 @@ You can specifiy a "-" as the path and it will read the template from an input p
 	},
 }
 
+var templateValidateCmd = &cobra.Command{
+	Use:   "validate <template id|template path>",
+	Short: "validate checks a RDA template against RDA's operator catalog",
+	Long: `validate checks a RDA template against RDA's operator catalog
+
+Every node's operator must exist, be given all of its required
+parameters and no unknown ones, and be fed as many sources as it
+takes, with edge indices running 1, 2, 3, and so on.  Problems are
+reported by node id.  The template placeholders found, e.g. ${name},
+are listed too, along with whether they must be provided via --kv.
+
+The argument is a template file, in either format "template upload"
+accepts, or "-" to read one from an input pipe.  Anything else is
+taken to be the id of a template already uploaded to RDA.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		client, writeConfig, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writeConfig(); err != nil {
+				log.Printf("on exit, received an error when writing configuration, err: %v", err)
+			}
+		}()
+
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
+		g, err := loadGraph(api, args[0])
+		if err != nil {
+			return err
+		}
+		v, err := validateGraph(api, g)
+		if err != nil {
+			return err
+		}
+
+		if err := json.NewEncoder(os.Stdout).Encode(v); err != nil {
+			return err
+		}
+		return v.Err()
+	},
+}
+
+var templateParamsCmd = &cobra.Command{
+	Use:   "params <template id>",
+	Short: "params lists the parameters a RDA template expects via --kv",
+	Long: `params lists the parameters a RDA template expects via --kv
+
+Every placeholder, e.g. ${name} or ${name:-default}, found in the
+parameters of the template's nodes is listed along with its default,
+whether it must be given, and the ids of the nodes that use it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		client, writeConfig, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writeConfig(); err != nil {
+				log.Printf("on exit, received an error when writing configuration, err: %v", err)
+			}
+		}()
+
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
+		g, err := api.NewTemplate(args[0]).Describe()
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(os.Stdout).Encode(g.Placeholders())
+	},
+}
+
+var templateRenderCmd = &cobra.Command{
+	Use:   "render <template id>",
+	Short: "render prints a RDA template with the given parameters substituted into it",
+	Long: `render prints a RDA template with the given parameters substituted into it
+
+The substitution is done locally, filling in each placeholder with the
+value given via --kv, or its default if none is.  It fails if there
+are placeholders without defaults that weren't given.  Use it to check
+what RDA will evaluate before requesting metadata or tiles.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		client, writeConfig, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writeConfig(); err != nil {
+				log.Printf("on exit, received an error when writing configuration, err: %v", err)
+			}
+		}()
+
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
+		params, err := templateParameters()
+		if err != nil {
+			return err
+		}
+		template := api.NewTemplate(args[0], params...)
+		checkTemplateParameters(template)
+		g, err := template.Render()
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(os.Stdout).Encode(g)
+	},
+}
+
+var templateGraphCmd = &cobra.Command{
+	Use:   "graph <template id|template path>",
+	Short: "graph draws a RDA template as a Graphviz or Mermaid graph",
+	Long: `graph draws a RDA template as a Graphviz or Mermaid graph
+
+Nodes are labeled with their id, operator, and parameters, and edges
+with the index they feed their destination at.  The default node is
+highlighted.  Use --format to pick between Graphviz's DOT language,
+e.g. "rda template graph <id> | dot -Tsvg > template.svg", and a
+Mermaid flowchart.
+
+The argument is a template file, "-" to read one from an input pipe,
+or the id of a template already uploaded to RDA.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var write func(*rda.Graph) error
+		switch templateFlags.graphFormat {
+		case "dot":
+			write = func(g *rda.Graph) error { return g.WriteDOT(os.Stdout) }
+		case "mermaid":
+			write = func(g *rda.Graph) error { return g.WriteMermaid(os.Stdout) }
+		default:
+			return errors.Errorf("--format = %q is not one of dot or mermaid", templateFlags.graphFormat)
+		}
+
+		gs, err := loadGraphs(context.Background(), args[0])
+		if err != nil {
+			return err
+		}
+		return write(gs[0])
+	},
+}
+
+var templateDiffCmd = &cobra.Command{
+	Use:   "diff <template id|template path> <template id|template path>",
+	Short: "diff reports how the structure of two RDA templates differs",
+	Long: `diff reports how the structure of two RDA templates differs
+
+Nodes are matched by id.  Each difference is printed on its own line,
+prefixed by "-" for what the second template removed, "+" for what it
+added, and "~" for what it changed: nodes, their operators and
+parameters, edges, and the default node.  Nothing is printed if the
+templates are the same.
+
+Either argument may be a template file, "-" to read one from an input
+pipe, or the id of a template already uploaded to RDA.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if args[0] == "-" && args[1] == "-" {
+			return errors.New("only one of the templates can be read from an input pipe")
+		}
+
+		gs, err := loadGraphs(context.Background(), args[0], args[1])
+		if err != nil {
+			return err
+		}
+		d, err := rda.DiffGraphs(gs[0], gs[1])
+		if err != nil {
+			return err
+		}
+		fmt.Print(d)
+		return nil
+	},
+}
+
 var templateMetadataCmd = &cobra.Command{
 	Use:   "metadata <template id>",
 	Short: "fetch RDA metadata for the given template",
@@ -149,24 +356,23 @@ multiple values.`,
 			}
 		}()
 
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
 		// Parse the args.
 		templateID := args[0]
 
 		// Deal with the flags.
-		var params []rda.TemplateOption
-		for _, kv := range templateFlags.keyvals {
-			s := strings.SplitN(kv, ",", 2)
-			if len(s) != 2 {
-				return errors.Errorf("--kv = %q is not of the form \"key,value\"", kv)
-			}
-			params = append(params, rda.AddParameter(strings.TrimSpace(s[0]), strings.TrimSpace(s[1])))
-		}
-		if templateFlags.nodeID != "" {
-			params = append(params, rda.AddParameter("nodeId", templateFlags.nodeID))
+		params, err := templateParameters()
+		if err != nil {
+			return err
 		}
 
 		// Get the metadata.
-		template := rda.NewTemplate(templateID, client, params...)
+		template := api.NewTemplate(templateID, params...)
+		checkTemplateParameters(template)
 		md, err := template.Metadata()
 		if err != nil {
 			return err
@@ -208,35 +414,37 @@ var templateRealizeCmd = &cobra.Command{
 			}
 		}()
 
-		// Parse the flags.
-		var params []rda.TemplateOption
-		for _, kv := range templateFlags.keyvals {
-			s := strings.SplitN(kv, ",", 2)
-			if len(s) != 2 {
-				return errors.Errorf("--kv = %q is not of the form \"key,value\"", kv)
-			}
-			params = append(params, rda.AddParameter(strings.TrimSpace(s[0]), strings.TrimSpace(s[1])))
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
 		}
-		if templateFlags.nodeID != "" {
-			params = append(params, rda.AddParameter("nodeId", templateFlags.nodeID))
+
+		// Parse the flags.
+		params, err := templateParameters()
+		if err != nil {
+			return err
 		}
+		params = append(params, rda.NumParallel(int(templateFlags.maxconcurr)), rda.VerifyTiles(templateFlags.verify))
 
 		// Get the metadata and figure out what RDA tiles need to be downloaded.
 		templateID, vrtPath := args[0], args[1]
-		template := rda.NewTemplate(templateID, client, params...)
+		template := api.NewTemplate(templateID, params...)
+		checkTemplateParameters(template)
 		md, err := template.Metadata()
 		if err != nil {
 			return err
 		}
 
-		tileWindow, err := processSubWindows(&templateFlags.srcWin, &templateFlags.projWin, md)
+		cutline, tileWindow, err := processSubWindows(&templateFlags.window, md)
 		if err != nil {
 			return err
 		}
 		rda.WithWindow(*tileWindow)(template)
+		rda.WithCutline(cutline)(template)
 
 		// Get the tiles.
-		bar := pb.StartNew(tileWindow.NumXTiles * tileWindow.NumYTiles)
+		numTiles := template.NumTiles()
+		bar := pb.StartNew(numTiles)
 		rda.WithProgressFunc(bar.Increment)(template)
 
 		tileDir := vrtPath[:len(vrtPath)-len(path.Ext(vrtPath))]
@@ -248,7 +456,7 @@ var templateRealizeCmd = &cobra.Command{
 
 		select {
 		case <-ctx.Done():
-			bar.FinishPrint(fmt.Sprintf("Completed %d of %d tiles before cancellation; rerun the command to pick up where you left off.", len(tiles), tileWindow.NumXTiles*tileWindow.NumYTiles))
+			bar.FinishPrint(fmt.Sprintf("Completed %d of %d tiles before cancellation; rerun the command to pick up where you left off.", len(tiles), numTiles))
 		default:
 			bar.FinishPrint(fmt.Sprintf("Tile retrieval took %s", time.Since(tStart)))
 		}
@@ -256,11 +464,21 @@ var templateRealizeCmd = &cobra.Command{
 			return err
 		}
 
+		// Assemble the tiles into a COG, if asked to, written where the VRT would have gone.
+		if templateFlags.cog.cog {
+			return writeCOG(ctx, templateFlags.cog, vrtPath, tileDir, md, tiles, numTiles, nil, cutline)
+		}
+
 		// Build VRT struct and write it to disk.
 		vrt, err := rda.NewVRT(md, tiles, nil)
 		if err != nil {
 			return err
 		}
+		if cutline != nil {
+			if err := vrt.AddCutlineMask(md, tiles, cutline); err != nil {
+				return err
+			}
+		}
 
 		f, err := os.Create(vrtPath)
 		if err != nil {
@@ -302,49 +520,55 @@ var templateBatchCmd = &cobra.Command{
 			}
 		}()
 
+		api, err := newRDAClient(client)
+		if err != nil {
+			return err
+		}
+
 		// Parse the flags.
-		var params []rda.TemplateOption
-		for _, kv := range templateFlags.keyvals {
-			s := strings.SplitN(kv, ",", 2)
-			if len(s) != 2 {
-				return errors.Errorf("--kv = %q is not of the form \"key,value\"", kv)
-			}
-			params = append(params, rda.AddParameter(strings.TrimSpace(s[0]), strings.TrimSpace(s[1])))
+		params, err := templateParameters()
+		if err != nil {
+			return err
 		}
-		if templateFlags.nodeID != "" {
-			params = append(params, rda.AddParameter("nodeId", templateFlags.nodeID))
+		batchOptions, err := templateFlags.batch.options()
+		if err != nil {
+			return err
 		}
 
 		// Get the metadata and figure out what RDA tiles need to be downloaded.
 		templateID := args[0]
-		template := rda.NewTemplate(templateID, client, params...)
+		template := api.NewTemplate(templateID, params...)
+		checkTemplateParameters(template)
 		md, err := template.Metadata()
 		if err != nil {
 			return err
 		}
 
-		if md.ImageGeoreferencing.SpatialReferenceSystemCode == "" {
-			return errors.New("rda batch materialization requires georeferenced imagery, but we found no EPSG code")
+		format := rda.BatchFormat(templateFlags.batch.format)
+		if err := format.CheckMetadata(md); err != nil {
+			return err
 		}
 
 		// mapping to RDA tiles.
-		if (templateFlags.projWin != projectionWindow{} || templateFlags.srcWin != sourceWindow{}) {
+		if templateFlags.window.isSet() {
 			md, err := template.Metadata()
 			if err != nil {
 				return err
 			}
-			tileWindow, err := processSubWindows(&templateFlags.srcWin, &templateFlags.projWin, md)
+			cutline, tileWindow, err := processSubWindows(&templateFlags.window, md)
 			if err != nil {
 				return err
 			}
 			rda.WithWindow(*tileWindow)(template)
+			rda.WithCutline(cutline)(template)
 		}
 
 		// Submit as a batch job.
-		resp, err := template.BatchRealize(ctx, rda.Tif)
+		resp, err := template.BatchRealize(ctx, format, batchOptions...)
 		if err != nil {
 			return err
 		}
+		recordJob(resp, templateFlags.batch.tags)
 
 		return json.NewEncoder(os.Stdout).Encode(resp)
 	},
@@ -355,20 +579,164 @@ var templateFlags struct {
 
 	nodeID string
 
-	srcWin  sourceWindow
-	projWin projectionWindow
+	window windowFlags
 
 	maxconcurr uint64
+	verify     bool
+
+	skipValidation bool
+
+	graphFormat string
+
+	cog   cogFlags
+	batch batchFlags
+}
+
+// readGraph reads a graph from file, or stdin if file is "-", in
+// either RDA's JSON format or the text format understood by
+// rda.NewGraphFromDSL.
+func readGraph(file string) (*rda.Graph, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't open template file")
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// RDA's JSON is an object, which the text format can't start with.
+	br := bufio.NewReader(r)
+	for {
+		c, _, err := br.ReadRune()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed reading template file")
+		}
+		if unicode.IsSpace(c) {
+			continue
+		}
+		br.UnreadRune()
+		if c == '{' {
+			return rda.NewGraphFromAPI(br)
+		}
+		return rda.NewGraphFromDSL(br)
+	}
+}
+
+// templateParameters returns the template options setting the
+// parameters given via --kv and --node.
+func templateParameters() ([]rda.TemplateOption, error) {
+	var params []rda.TemplateOption
+	for _, kv := range templateFlags.keyvals {
+		s := strings.SplitN(kv, ",", 2)
+		if len(s) != 2 {
+			return nil, errors.Errorf("--kv = %q is not of the form \"key,value\"", kv)
+		}
+		params = append(params, rda.AddParameter(strings.TrimSpace(s[0]), strings.TrimSpace(s[1])))
+	}
+	if templateFlags.nodeID != "" {
+		params = append(params, rda.AddParameter("nodeId", templateFlags.nodeID))
+	}
+	return params, nil
+}
+
+// checkTemplateParameters warns about parameters given to template
+// that it doesn't use, which are likely typos, and about required
+// ones that are missing.  We leave it to RDA to reject the request.
+func checkTemplateParameters(template *rda.Template) {
+	unknown, missing, err := template.CheckParameters()
+	if err != nil {
+		log.Printf("couldn't check the template parameters given, err: %v", err)
+		return
+	}
+	if len(unknown) > 0 {
+		log.Printf("warning: the template doesn't use the parameter(s) %s given via --kv", strings.Join(unknown, ", "))
+	}
+	if len(missing) > 0 {
+		log.Printf("warning: the template requires the parameter(s) %s, which weren't given via --kv", strings.Join(missing, ", "))
+	}
+}
+
+// loadGraph returns the graph found in the template file arg, or
+// stdin if arg is "-".  If no such file exists, arg is taken to be a
+// template id and the graph is fetched from RDA.
+func loadGraph(api *rda.Client, arg string) (*rda.Graph, error) {
+	if isLocalGraph(arg) {
+		return readGraph(arg)
+	}
+	return api.NewTemplate(arg).Describe()
+}
+
+// loadGraphs returns the graph for each of args as loadGraph does,
+// only creating a client, and so needing GBDX credentials, if one of
+// them isn't a local template file.
+func loadGraphs(ctx context.Context, args ...string) ([]*rda.Graph, error) {
+	var api *rda.Client
+	gs := make([]*rda.Graph, len(args))
+	for i, arg := range args {
+		if api == nil && !isLocalGraph(arg) {
+			client, writeConfig, err := newClient(ctx)
+			if err != nil {
+				return nil, err
+			}
+			defer func() {
+				if err := writeConfig(); err != nil {
+					log.Printf("on exit, received an error when writing configuration, err: %v", err)
+				}
+			}()
+
+			if api, err = newRDAClient(client); err != nil {
+				return nil, err
+			}
+		}
+
+		g, err := loadGraph(api, arg)
+		if err != nil {
+			return nil, err
+		}
+		gs[i] = g
+	}
+	return gs, nil
+}
+
+// isLocalGraph reports whether arg is a template file or "-", for stdin.
+func isLocalGraph(arg string) bool {
+	_, err := os.Stat(arg)
+	return arg == "-" || err == nil
+}
+
+// validateGraph validates g against the operators RDA currently provides.
+func validateGraph(api *rda.Client, g *rda.Graph) (*rda.GraphValidation, error) {
+	ops, err := api.Operators()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't fetch RDA's operator catalog to validate the template against")
+	}
+	return rda.ValidateGraph(g, ops), nil
 }
 
 func init() {
 	rootCmd.AddCommand(templateCmd)
 	templateCmd.AddCommand(templateDescribeCmd)
 	templateCmd.AddCommand(templateUploadCmd)
+	templateCmd.AddCommand(templateValidateCmd)
+	templateCmd.AddCommand(templateParamsCmd)
+	templateCmd.AddCommand(templateRenderCmd)
+	templateCmd.AddCommand(templateGraphCmd)
+	templateCmd.AddCommand(templateDiffCmd)
 	templateCmd.AddCommand(templateMetadataCmd)
 	templateCmd.AddCommand(templateRealizeCmd)
 	templateCmd.AddCommand(templateBatchCmd)
 
+	// Local flags specific to uploading templates.
+	templateUploadCmd.Flags().BoolVar(&templateFlags.skipValidation, "skip-validation", false, "upload the template without first validating it against RDA's operator catalog")
+
+	// Local flags specific to drawing templates.
+	templateGraphCmd.Flags().StringVar(&templateFlags.graphFormat, "format", "dot", "format to draw the template in, either dot or mermaid")
+
+	// Local flags specific to rendering templates.
+	templateRenderCmd.Flags().StringArrayVar(&templateFlags.keyvals, "kv", []string{}, "key/value pairs (comma seperated) for template subsitution")
+
 	// Local flags specific to getting template metadata.
 	templateMetadataCmd.Flags().StringArrayVar(&templateFlags.keyvals, "kv", []string{}, "key/value pairs (comma seperated) for template subsitution")
 	templateMetadataCmd.Flags().StringVar(&templateFlags.nodeID, "node", "", "node id to evaluate; if absent the default node is evaluated")
@@ -376,13 +744,14 @@ func init() {
 	// Local flags specific to getting template tile realization.
 	templateRealizeCmd.Flags().StringArrayVar(&templateFlags.keyvals, "kv", []string{}, "key/value pairs (comma seperated) for template subsitution")
 	templateRealizeCmd.Flags().StringVar(&templateFlags.nodeID, "node", "", "node id to evaluate; if absent the default node is evaluated")
-	templateRealizeCmd.Flags().Uint64Var(&templateFlags.maxconcurr, "maxconcurrency", 0, "set how many concurrent requests to allow; by default, 4 * num CPUs is used")
-	templateRealizeCmd.Flags().Var(&templateFlags.srcWin, "srcwin", "realize a subwindow in pixel space, specified via comma seperated integers xoff,yoff,xsize,ysize")
-	templateRealizeCmd.Flags().Var(&templateFlags.projWin, "projwin", "realize a subwindow in projected space, specified via comma seperated floats ulx,uly,lrx,lry")
+	templateRealizeCmd.Flags().Uint64Var(&templateFlags.maxconcurr, "maxconcurrency", 0, "set the most concurrent requests to allow, which adapts downward when RDA is struggling; by default, 4 * num CPUs is used")
+	templateRealizeCmd.Flags().BoolVar(&templateFlags.verify, "verify", false, "check the checksum of every previously realized tile against the manifest, fetching any that don't match again")
+	addWindowFlags(templateRealizeCmd.Flags(), &templateFlags.window, "realize")
+	addCOGFlags(templateRealizeCmd.Flags(), &templateFlags.cog)
 
 	// Local flags specific to RDA template batch realization.
 	templateBatchCmd.Flags().StringArrayVar(&templateFlags.keyvals, "kv", []string{}, "key/value pairs (comma seperated) for template subsitution")
 	templateBatchCmd.Flags().StringVar(&templateFlags.nodeID, "node", "", "node id to evaluate; if absent the default node is evaluated")
-	templateBatchCmd.Flags().Var(&templateFlags.srcWin, "srcwin", "batch realize a subwindow in pixel space, specified via comma seperated integers xoff,yoff,xsize,ysize")
-	templateBatchCmd.Flags().Var(&templateFlags.projWin, "projwin", "batch realize a subwindow in projected space, specified via comma seperated floats ulx,uly,lrx,lry")
+	addWindowFlags(templateBatchCmd.Flags(), &templateFlags.window, "batch realize")
+	addBatchFlags(templateBatchCmd.Flags(), &templateFlags.batch)
 }
@@ -0,0 +1,91 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rdatest"
+)
+
+func TestTemplateRealize(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "rda-cmd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	vrtPath := filepath.Join(outDir, "image.vrt")
+	if _, err := runCommand(t, "template", "realize", rdatest.IdahoReadTemplateID, vrtPath,
+		"--kv", "imageId,"+testCatalogID+"-mul-p001", "--kv", "bucketName,rdatest-tiles"); err != nil {
+		t.Fatalf("template realize failed: %v", err)
+	}
+	checkRealized(t, vrtPath, filepath.Join(outDir, "image"), 4)
+
+	// Templates RDA doesn't know are refused.
+	if _, err := runCommand(t, "template", "realize", "no-such-template", filepath.Join(outDir, "missing.vrt")); err == nil {
+		t.Fatal("expected realizing an unknown template to fail")
+	}
+}
+
+func TestTemplateGraphLocalFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rda-cmd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")
+	for path, gsd := range map[string]string{a: "${GSD:-}", b: "0.5"} {
+		g := `{"defaultNodeId": "IdahoRead", "edges": [], "nodes": [
+			{"id": "IdahoRead", "operator": "IdahoRead", "parameters": {"imageId": "${imageId}", "targetGSD": "` + gsd + `"}}]}`
+		if err := ioutil.WriteFile(path, []byte(g), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Local templates are read without asking GBDX for a token.
+	tokens := testServer.Requests("/oauth/token")
+	if _, err := runCommand(t, "template", "graph", a); err != nil {
+		t.Fatalf("template graph failed: %v", err)
+	}
+	out, err := runCommand(t, "template", "diff", a, b)
+	if err != nil {
+		t.Fatalf("template diff failed: %v", err)
+	}
+	if out == "" {
+		t.Error("template diff reported no differences")
+	}
+	if n := testServer.Requests("/oauth/token") - tokens; n != 0 {
+		t.Errorf("got %d token requests for local templates, want 0", n)
+	}
+
+	// Diffing against a template id still needs a client.
+	if _, err := runCommand(t, "template", "diff", a, rdatest.IdahoReadTemplateID); err != nil {
+		t.Fatalf("template diff failed: %v", err)
+	}
+	if n := testServer.Requests("/oauth/token") - tokens; n != 1 {
+		t.Errorf("got %d token requests after diffing against a template id, want 1", n)
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/gbdx"
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	"github.com/cheggaaa/pb"
+	"github.com/pkg/errors"
+)
+
+var (
+	// minWatchPoll and maxWatchPoll bound how long we wait between
+	// polls of the jobs being watched; we back off from the former
+	// to the latter while nothing new shows up.
+	minWatchPoll = 2 * time.Second
+	maxWatchPoll = time.Minute
+)
+
+const (
+	// maxStatusFailures is how many polls in a row can fail to
+	// fetch a job's status before we give up on it.
+	maxStatusFailures = 3
+
+	// maxWatchDownloads is how many jobs' artifacts are downloaded
+	// at once.
+	maxWatchDownloads = 4
+)
+
+// watchedJob tracks a batch job being watched.
+type watchedJob struct {
+	id     string
+	outDir string
+
+	status, message string
+	statusFailures  int
+
+	found       int // found is how many artifacts we've found to download.
+	downloading bool
+	done        bool
+	err         error
+
+	bar *pb.ProgressBar
+}
+
+func (j *watchedJob) finish(err error) {
+	j.done, j.err = true, err
+	if err != nil {
+		j.bar.Postfix(" failed")
+	} else {
+		j.bar.Postfix(" " + j.status)
+	}
+	j.bar.Finish()
+}
+
+// jobWatcher watches batch jobs, greedily downloading their artifacts
+// as they appear.
+type jobWatcher struct {
+	api      *rda.Client
+	accessor *gbdx.S3Accessor
+
+	// options choose which of each job's artifacts to download.
+	options []gbdx.DownloadOption
+
+	jobs  []*watchedJob
+	total *pb.ProgressBar
+
+	// mu guards found and the totals of the progress bars, which
+	// concurrent downloads update.
+	mu    sync.Mutex
+	found int
+
+	// after returns a channel that receives once it's time to poll
+	// again, normally time.After.
+	after func(time.Duration) <-chan time.Time
+
+	// pool displays the progress of every job along with the total;
+	// it is nil if the terminal can't display it, in which case we
+	// log progress instead.
+	pool *pb.Pool
+}
+
+// newJobWatcher returns a jobWatcher for the given jobs.  If there is
+// more than one, each job's artifacts are downloaded to a directory
+// named for it in outDir, otherwise directly to outDir.
+func newJobWatcher(api *rda.Client, accessor *gbdx.S3Accessor, outDir string, jobIDs []string, options ...gbdx.DownloadOption) *jobWatcher {
+	width := 0
+	for _, id := range jobIDs {
+		if len(id) > width {
+			width = len(id)
+		}
+	}
+
+	w := jobWatcher{api: api, accessor: accessor, options: options, after: time.After}
+	var bars []*pb.ProgressBar
+	for _, id := range jobIDs {
+		dir := outDir
+		if len(jobIDs) > 1 {
+			dir = filepath.Join(outDir, id)
+		}
+		j := &watchedJob{id: id, outDir: dir, status: "processing", bar: pb.New(0).Prefix(fmt.Sprintf("%-*s ", width, id)).Postfix(" processing")}
+		w.jobs = append(w.jobs, j)
+		bars = append(bars, j.bar)
+	}
+	w.total = pb.New(0).Prefix(fmt.Sprintf("%-*s ", width, "all jobs"))
+	if len(jobIDs) > 1 {
+		bars = append(bars, w.total)
+	}
+
+	pool, err := pb.StartPool(bars...)
+	if err == nil {
+		w.pool = pool
+	}
+	return &w
+}
+
+// logf logs progress if we aren't displaying it.
+func (w *jobWatcher) logf(format string, args ...interface{}) {
+	if w.pool == nil {
+		log.Printf(format, args...)
+	}
+}
+
+// downloadResult is how a job's download went.
+type downloadResult struct {
+	job *watchedJob
+	n   int
+	err error
+
+	// complete is whether the job was complete when the download
+	// started, in which case it got all the job's artifacts.
+	complete bool
+}
+
+// watch polls the jobs until they've all completed or failed and
+// their artifacts are downloaded, or until ctx is cancelled.  Each
+// job's artifacts are downloaded in the background, up to
+// maxWatchDownloads jobs at a time, while polling continues.
+func (w *jobWatcher) watch(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	// Each job has at most one download in flight, so results never
+	// block, even once we've stopped receiving them.
+	results := make(chan downloadResult, len(w.jobs))
+	sem := make(chan struct{}, maxWatchDownloads)
+
+	poll, found := minWatchPoll, false
+	next := w.after(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case r := <-results:
+			r.job.downloading = false
+			if r.n > 0 {
+				found = true
+				updateJob(r.job.id, func(rec *rda.JobRecord) { rec.AddDownload(r.job.outDir, r.n) })
+			}
+			switch {
+			case r.job.done || ctx.Err() != nil:
+			case r.err != nil:
+				r.job.finish(r.err)
+			case r.complete:
+				r.job.finish(nil)
+			}
+
+		case <-next:
+			w.poll(ctx)
+			for _, j := range w.jobs {
+				if j.done || j.downloading {
+					continue
+				}
+				switch j.status {
+				case "processing", "complete":
+					j.downloading = true
+					wg.Add(1)
+					go func(j *watchedJob, complete bool) {
+						defer wg.Done()
+						select {
+						case sem <- struct{}{}:
+						case <-ctx.Done():
+							results <- downloadResult{job: j, err: ctx.Err()}
+							return
+						}
+						n, err := w.download(ctx, j)
+						<-sem
+						results <- downloadResult{job: j, n: n, err: err, complete: complete}
+					}(j, j.status == "complete")
+				}
+			}
+
+			// Back off while nothing is showing up.
+			if found {
+				poll = minWatchPoll
+			} else {
+				poll *= 2
+				if poll > maxWatchPoll {
+					poll = maxWatchPoll
+				}
+			}
+			found = false
+			next = w.after(poll)
+		}
+
+		if w.finished() {
+			return
+		}
+	}
+}
+
+// finished returns true once every job is done.
+func (w *jobWatcher) finished() bool {
+	for _, j := range w.jobs {
+		if !j.done {
+			return false
+		}
+	}
+	return true
+}
+
+// poll fetches the statuses of the jobs that aren't done in bulk,
+// finishing those that failed or whose status can't be fetched.
+func (w *jobWatcher) poll(ctx context.Context) {
+	var active []string
+	for _, j := range w.jobs {
+		if !j.done {
+			active = append(active, j.id)
+		}
+	}
+	if len(active) == 0 {
+		return
+	}
+
+	// The artifacts of a job that is complete when polled are all
+	// in S3 by the time we list them to download.
+	resps, err := w.api.FetchBatchStatus(ctx, active...)
+	if ctx.Err() != nil {
+		return
+	}
+	updateJobStatuses(resps)
+	polled := make(map[string]*rda.BatchResponse, len(resps))
+	for _, resp := range resps {
+		polled[resp.JobID] = resp
+	}
+
+	for _, j := range w.jobs {
+		if j.done {
+			continue
+		}
+		resp, ok := polled[j.id]
+		if !ok {
+			j.statusFailures++
+			if j.statusFailures >= maxStatusFailures {
+				j.finish(errors.Errorf("couldn't fetch its status %d times in a row, last err: %v", j.statusFailures, err))
+			}
+			continue
+		}
+		j.statusFailures = 0
+		if resp.Status.Status != j.status {
+			w.logf("%s: %s", j.id, resp.Status.Status)
+		}
+		j.status, j.message = resp.Status.Status, resp.Status.StatusMessage
+		j.bar.Postfix(" " + j.status)
+
+		switch j.status {
+		case "processing", "complete":
+		default:
+			j.finish(errors.Errorf("job %s has status %s %s", j.id, j.status, j.message))
+		}
+	}
+}
+
+// download downloads any of j's artifacts that we don't have yet,
+// returning how many were downloaded.  It is run concurrently for
+// different jobs.
+func (w *jobWatcher) download(ctx context.Context, j *watchedJob) (int, error) {
+	options := append([]gbdx.DownloadOption{gbdx.DownloadProgressFunc(func() int {
+		w.total.Increment()
+		return j.bar.Increment()
+	})}, w.options...)
+	n, dlFunc, err := w.accessor.DownloadBatchJobArtifacts(ctx, j.outDir, j.id, options...)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	j.found += n
+	j.bar.SetTotal(j.found)
+	w.found += n
+	w.total.SetTotal(w.found)
+	w.mu.Unlock()
+
+	tStart := time.Now()
+	if err := dlFunc(); err != nil {
+		return 0, err
+	}
+	w.logf("%s: downloaded %d artifacts in %s", j.id, n, time.Since(tStart))
+	return n, nil
+}
+
+// stop stops displaying progress and summarizes how each job fared,
+// returning an error naming those that failed.
+func (w *jobWatcher) stop(ctx context.Context) error {
+	if w.pool != nil {
+		for _, j := range w.jobs {
+			j.bar.Finish()
+		}
+		w.total.Finish()
+		w.pool.Stop()
+	}
+
+	var failed []string
+	for _, j := range w.jobs {
+		switch {
+		case j.err != nil:
+			failed = append(failed, j.id)
+			fmt.Printf("%s: failed, %v\n", j.id, j.err)
+		case j.done:
+			fmt.Printf("%s: %s, %d artifacts downloaded to %s\n", j.id, j.status, j.found, j.outDir)
+		default:
+			fmt.Printf("%s: %s, %d artifacts downloaded to %s so far\n", j.id, j.status, j.found, j.outDir)
+		}
+	}
+
+	if ctx.Err() != nil {
+		log.Printf("exited before downloading all artifacts; rerun the command to pick up where you left off.")
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("%d of %d jobs failed: %s", len(failed), len(w.jobs), strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gbdx
+
+import (
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Artifact describes an RDA batch job artifact in S3.
+type Artifact struct {
+	Key string `json:"key"`
+
+	// Path is the artifact's path relative to its job, which is
+	// what download filters match against.
+	Path string `json:"path"`
+
+	// File is where the artifact is downloaded to, when planning a
+	// download.
+	File string `json:"file,omitempty"`
+
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// DownloadOption is a type to use for choosing which of a job's
+// artifacts are downloaded, and following their progress.
+type DownloadOption func(*downloadFilter)
+
+// IncludeArtifacts only downloads artifacts matching one of the given
+// glob patterns, as understood by path.Match.  Patterns containing a
+// "/" are matched against the artifact's path relative to its job,
+// e.g. "tiles/*/*.tif", and others against just its name, e.g.
+// "*.tif".
+func IncludeArtifacts(patterns ...string) DownloadOption {
+	return func(f *downloadFilter) {
+		f.include = append(f.include, patterns...)
+	}
+}
+
+// ExcludeArtifacts skips artifacts matching any of the given glob
+// patterns, which are matched as in IncludeArtifacts.  Exclusions win
+// over inclusions.
+func ExcludeArtifacts(patterns ...string) DownloadOption {
+	return func(f *downloadFilter) {
+		f.exclude = append(f.exclude, patterns...)
+	}
+}
+
+// ZoomLevels only downloads artifacts of the given zoom levels of a
+// TMS or vector tile layout, i.e. those whose path relative to their
+// job starts with one of the levels, as in "12/654/1583.png".
+func ZoomLevels(levels ...int) DownloadOption {
+	return func(f *downloadFilter) {
+		if f.zooms == nil {
+			f.zooms = map[int]bool{}
+		}
+		for _, z := range levels {
+			f.zooms[z] = true
+		}
+	}
+}
+
+// MaxDownloadBytes caps how many bytes of artifacts are downloaded,
+// stopping at the first artifact that would go over.  Since artifacts
+// that are already downloaded don't count, downloading again picks up
+// where the last download stopped.
+func MaxDownloadBytes(n int64) DownloadOption {
+	return func(f *downloadFilter) {
+		f.maxBytes = n
+	}
+}
+
+// VerifyArtifacts checks the checksum of each artifact already
+// downloaded against S3's, downloading again any that don't match.
+// Otherwise an artifact is taken to be downloaded if a file of its
+// size is where it would be downloaded to.
+func VerifyArtifacts(verify bool) DownloadOption {
+	return func(f *downloadFilter) {
+		f.verify = verify
+	}
+}
+
+// DownloadProgressFunc sets a function to be called whenever one of
+// the artifacts of this download finishes, along with the function
+// given to the S3Accessor via WithProgressFunc.  This lets concurrent
+// downloads through one S3Accessor track their progress separately.
+func DownloadProgressFunc(progressFunc func() int) DownloadOption {
+	return func(f *downloadFilter) {
+		f.progressFunc = progressFunc
+	}
+}
+
+// downloadFilter selects which artifacts to download.
+type downloadFilter struct {
+	include, exclude []string
+	zooms            map[int]bool
+	maxBytes         int64
+	verify           bool
+
+	progressFunc func() int
+}
+
+// validate returns an error if any of f's patterns are malformed.
+func (f *downloadFilter) validate() error {
+	for _, pattern := range append(append([]string{}, f.include...), f.exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid artifact pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// match returns true if the artifact at the path relative to its job
+// should be downloaded.
+func (f *downloadFilter) match(p string) bool {
+	if f.zooms != nil {
+		z, err := strconv.Atoi(strings.SplitN(p, "/", 2)[0])
+		if err != nil || !f.zooms[z] || !strings.Contains(p, "/") {
+			return false
+		}
+	}
+	if len(f.include) > 0 && !matchAny(f.include, p) {
+		return false
+	}
+	return !matchAny(f.exclude, p)
+}
+
+// matchAny returns true if p matches any of patterns.
+func matchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		name := p
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(p)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
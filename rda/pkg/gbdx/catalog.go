@@ -0,0 +1,241 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gbdx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+)
+
+// Acquisition describes a DG acquisition found in the GBDX catalog.
+type Acquisition struct {
+	CatalogID       string    `json:"catalogId"`
+	AcquisitionDate time.Time `json:"acquisitionDate"`
+	Sensor          string    `json:"sensor"`
+	CloudCover      float64   `json:"cloudCover"`
+	OffNadirAngle   float64   `json:"offNadirAngle"`
+
+	// FootprintWKT is the acquisition's footprint as WKT, in EPSG:4326.
+	FootprintWKT string `json:"footprintWkt,omitempty"`
+}
+
+// Catalog searches the GBDX catalog.
+type Catalog struct {
+	client   *retryablehttp.Client
+	endpoint string
+}
+
+// CatalogOption is a type to use for setting options on a Catalog.
+type CatalogOption func(*Catalog)
+
+// WithCatalogEndpoint sets the GBDX endpoint searches are posted to;
+// by default CatalogSearchEndpoint is used.
+func WithCatalogEndpoint(endpoint string) CatalogOption {
+	return func(c *Catalog) {
+		if endpoint != "" {
+			c.endpoint = endpoint
+		}
+	}
+}
+
+// NewCatalog returns a Catalog that searches GBDX using client.
+func NewCatalog(client *retryablehttp.Client, options ...CatalogOption) *Catalog {
+	c := &Catalog{
+		client:   client,
+		endpoint: CatalogSearchEndpoint,
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c
+}
+
+// searchRequest is the body of a GBDX catalog search.
+type searchRequest struct {
+	SearchAreaWKT string   `json:"searchAreaWkt"`
+	StartDate     string   `json:"startDate,omitempty"`
+	EndDate       string   `json:"endDate,omitempty"`
+	Filters       []string `json:"filters,omitempty"`
+	Types         []string `json:"types"`
+}
+
+// SearchOption is a type to use for narrowing a catalog search.
+type SearchOption func(*searchRequest)
+
+// AcquiredBetween limits a search to acquisitions made between from
+// and to.  A zero time leaves that end of the range open.
+func AcquiredBetween(from, to time.Time) SearchOption {
+	return func(r *searchRequest) {
+		if !from.IsZero() {
+			r.StartDate = from.UTC().Format(time.RFC3339)
+		}
+		if !to.IsZero() {
+			r.EndDate = to.UTC().Format(time.RFC3339)
+		}
+	}
+}
+
+// Sensors limits a search to acquisitions from the given sensor
+// platforms, e.g. WORLDVIEW02 or WORLDVIEW03_VNIR.
+func Sensors(sensors ...string) SearchOption {
+	return func(r *searchRequest) {
+		if len(sensors) == 0 {
+			return
+		}
+		terms := make([]string, len(sensors))
+		for i, sensor := range sensors {
+			terms[i] = fmt.Sprintf("sensorPlatformName = '%s'", strings.ToUpper(sensor))
+		}
+		r.Filters = append(r.Filters, "("+strings.Join(terms, " OR ")+")")
+	}
+}
+
+// MaxCloudCover limits a search to acquisitions with at most pct
+// percent cloud cover.
+func MaxCloudCover(pct float64) SearchOption {
+	return func(r *searchRequest) {
+		r.Filters = append(r.Filters, "cloudCover <= "+strconv.FormatFloat(pct, 'f', -1, 64))
+	}
+}
+
+// MaxOffNadir limits a search to acquisitions imaged at most deg
+// degrees off nadir.
+func MaxOffNadir(deg float64) SearchOption {
+	return func(r *searchRequest) {
+		r.Filters = append(r.Filters, "offNadirAngle <= "+strconv.FormatFloat(deg, 'f', -1, 64))
+	}
+}
+
+// Search returns the acquisitions whose footprints intersect areaWKT,
+// a POLYGON or MULTIPOLYGON in EPSG:4326, most recent first.
+func (c *Catalog) Search(ctx context.Context, areaWKT string, options ...SearchOption) ([]Acquisition, error) {
+	reqBody := searchRequest{
+		SearchAreaWKT: areaWKT,
+		Types:         []string{"DigitalGlobeAcquisition"},
+	}
+	for _, opt := range options {
+		opt(&reqBody)
+	}
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed forming request body for catalog search")
+	}
+
+	req, err := retryablehttp.NewRequest("POST", c.endpoint, body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed forming catalog search request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failure requesting %s", c.endpoint)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed searching the catalog at %s, HTTP Status: %s", c.endpoint, res.Status)
+	}
+
+	var resBody struct {
+		Results []struct {
+			Identifier string                 `json:"identifier"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&resBody); err != nil {
+		return nil, errors.Wrap(err, "catalog search response failed to decode as json")
+	}
+
+	acqs := make([]Acquisition, 0, len(resBody.Results))
+	for _, r := range resBody.Results {
+		acq, err := newAcquisition(r.Identifier, r.Properties)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading catalog record %s", r.Identifier)
+		}
+		acqs = append(acqs, acq)
+	}
+	sort.SliceStable(acqs, func(i, j int) bool {
+		return acqs[i].AcquisitionDate.After(acqs[j].AcquisitionDate)
+	})
+	return acqs, nil
+}
+
+// newAcquisition reads an Acquisition out of a catalog record's
+// properties.  The catalog isn't consistent about whether numbers are
+// sent as numbers or strings, so either is accepted.
+func newAcquisition(identifier string, props map[string]interface{}) (Acquisition, error) {
+	str := func(key string) string {
+		switch v := props[key].(type) {
+		case string:
+			return v
+		case nil:
+			return ""
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	num := func(key string) (float64, error) {
+		switch v := props[key].(type) {
+		case float64:
+			return v, nil
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			return f, errors.Wrapf(err, "%s is not a number", key)
+		case nil:
+			return 0, nil
+		default:
+			return 0, errors.Errorf("%s is not a number", key)
+		}
+	}
+
+	acq := Acquisition{
+		CatalogID:    str("catalogID"),
+		Sensor:       str("sensorPlatformName"),
+		FootprintWKT: str("footprintWkt"),
+	}
+	if acq.CatalogID == "" {
+		acq.CatalogID = identifier
+	}
+	if ts := str("timestamp"); ts != "" {
+		t, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			return Acquisition{}, errors.Wrap(err, "timestamp is not a RFC 3339 time")
+		}
+		acq.AcquisitionDate = t
+	}
+
+	var err error
+	if acq.CloudCover, err = num("cloudCover"); err != nil {
+		return Acquisition{}, err
+	}
+	if acq.OffNadirAngle, err = num("offNadirAngle"); err != nil {
+		return Acquisition{}, err
+	}
+	return acq, nil
+}
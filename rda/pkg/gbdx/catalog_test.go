@@ -0,0 +1,156 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gbdx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+)
+
+func TestCatalogSearch(t *testing.T) {
+	resp := `{
+  "stats": {"recordsReturned": 2},
+  "results": [
+    {
+      "identifier": "1030010000000000",
+      "type": ["DigitalGlobeAcquisition", "Acquisition"],
+      "properties": {
+        "catalogID": "1030010000000000",
+        "timestamp": "2016-05-01T17:21:40.000Z",
+        "sensorPlatformName": "WORLDVIEW02",
+        "cloudCover": 3,
+        "offNadirAngle": "21.5",
+        "footprintWkt": "POLYGON ((-93 36, -92 36, -92 37, -93 37, -93 36))"
+      }
+    },
+    {
+      "identifier": "1040010000000000",
+      "type": ["DigitalGlobeAcquisition", "Acquisition"],
+      "properties": {
+        "timestamp": "2018-02-28T17:21:40.000Z",
+        "sensorPlatformName": "WORLDVIEW03_VNIR",
+        "cloudCover": "0",
+        "offNadirAngle": 12.25,
+        "footprintWkt": "POLYGON ((-93 36, -92 36, -92 37, -93 37, -93 36))"
+      }
+    }
+  ]
+}`
+
+	var got searchRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "catalog searches must be POSTed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, resp)
+	}))
+	defer ts.Close()
+
+	catalog := NewCatalog(retryablehttp.NewClient(), WithCatalogEndpoint(ts.URL))
+	from := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	acqs, err := catalog.Search(context.Background(), "POLYGON ((-93 36, -92 36, -92 37, -93 37, -93 36))",
+		AcquiredBetween(from, time.Time{}),
+		Sensors("worldview02", "WORLDVIEW03_VNIR"),
+		MaxCloudCover(10),
+		MaxOffNadir(22.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantReq := searchRequest{
+		SearchAreaWKT: "POLYGON ((-93 36, -92 36, -92 37, -93 37, -93 36))",
+		StartDate:     "2016-01-01T00:00:00Z",
+		Filters: []string{
+			"(sensorPlatformName = 'WORLDVIEW02' OR sensorPlatformName = 'WORLDVIEW03_VNIR')",
+			"cloudCover <= 10",
+			"offNadirAngle <= 22.5",
+		},
+		Types: []string{"DigitalGlobeAcquisition"},
+	}
+	if !reflect.DeepEqual(got, wantReq) {
+		t.Errorf("search request is %+v, want %+v", got, wantReq)
+	}
+
+	// Most recent first, with the catalog id falling back to the record's identifier.
+	want := []Acquisition{
+		{
+			CatalogID:       "1040010000000000",
+			AcquisitionDate: time.Date(2018, 2, 28, 17, 21, 40, 0, time.UTC),
+			Sensor:          "WORLDVIEW03_VNIR",
+			CloudCover:      0,
+			OffNadirAngle:   12.25,
+			FootprintWKT:    "POLYGON ((-93 36, -92 36, -92 37, -93 37, -93 36))",
+		},
+		{
+			CatalogID:       "1030010000000000",
+			AcquisitionDate: time.Date(2016, 5, 1, 17, 21, 40, 0, time.UTC),
+			Sensor:          "WORLDVIEW02",
+			CloudCover:      3,
+			OffNadirAngle:   21.5,
+			FootprintWKT:    "POLYGON ((-93 36, -92 36, -92 37, -93 37, -93 36))",
+		},
+	}
+	if !reflect.DeepEqual(acqs, want) {
+		t.Errorf("search returned %+v, want %+v", acqs, want)
+	}
+}
+
+func TestCatalogSearchErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		resp   string
+	}{
+		{"bad status", http.StatusBadRequest, `{"error": "bad search area"}`},
+		{"bad json", http.StatusOK, `{"results": [`},
+		{"bad number", http.StatusOK, `{"results": [{"identifier": "id", "properties": {"cloudCover": "cloudy"}}]}`},
+		{"bad timestamp", http.StatusOK, `{"results": [{"identifier": "id", "properties": {"timestamp": "yesterday"}}]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				fmt.Fprintln(w, test.resp)
+			}))
+			defer ts.Close()
+
+			client := retryablehttp.NewClient()
+			client.RetryMax = 0
+			catalog := NewCatalog(client, WithCatalogEndpoint(ts.URL))
+			if _, err := catalog.Search(context.Background(), "POLYGON ((0 0, 1 0, 1 1, 0 0))"); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
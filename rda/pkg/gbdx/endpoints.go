@@ -21,7 +21,12 @@
 package gbdx
 
 var (
-	// TokenEndpoint is the GBDX endpoint for dealing with oauth2 token authorization.
-	TokenEndpoint         = "https://geobigdata.io/auth/v1/oauth/token"
-	s3CredentialsEndpoint = "https://geobigdata.io/s3creds/v1/prefix"
+	// TokenEndpoint is the default GBDX endpoint for dealing with oauth2 token authorization.
+	TokenEndpoint = "https://geobigdata.io/auth/v1/oauth/token"
+
+	// S3CredentialsEndpoint is the default GBDX endpoint for fetching temporary AWS credentials.
+	S3CredentialsEndpoint = "https://geobigdata.io/s3creds/v1/prefix"
+
+	// CatalogSearchEndpoint is the default GBDX endpoint for searching the catalog of DG acquisitions.
+	CatalogSearchEndpoint = "https://geobigdata.io/catalog/v2/search"
 )
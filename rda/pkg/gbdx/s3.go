@@ -22,13 +22,20 @@ package gbdx
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -57,7 +64,8 @@ func (c CustomerDataLocation) String() string {
 
 // Provider implements the aws/credentials.Provider interface.
 type Provider struct {
-	client *retryablehttp.Client
+	client   *retryablehttp.Client
+	endpoint string
 
 	credentials.Expiry
 	Value
@@ -73,24 +81,41 @@ type Value struct {
 }
 
 // NewProvider returns a configured Provider for getting AWS credentials from GBDX.
-func NewProvider(client *retryablehttp.Client) (*Provider, error) {
+func NewProvider(client *retryablehttp.Client, options ...ProviderOption) (*Provider, error) {
 	p := &Provider{
-		client: client,
-		Value:  Value{ProviderName: "GBDX"},
+		client:   client,
+		endpoint: S3CredentialsEndpoint,
+		Value:    Value{ProviderName: "GBDX"},
+	}
+	for _, opt := range options {
+		opt(p)
 	}
 	_, err := p.Retrieve()
 	return p, err
 }
 
+// ProviderOption is a type to use for setting options on a Provider.
+type ProviderOption func(*Provider)
+
+// WithCredentialsEndpoint sets the GBDX endpoint AWS credentials are
+// fetched from; by default S3CredentialsEndpoint is used.
+func WithCredentialsEndpoint(endpoint string) ProviderOption {
+	return func(p *Provider) {
+		if endpoint != "" {
+			p.endpoint = endpoint
+		}
+	}
+}
+
 // Retrieve returns AWS credentials to use from GBDX.
 func (g *Provider) Retrieve() (credentials.Value, error) {
-	res, err := g.client.Get(s3CredentialsEndpoint)
+	res, err := g.client.Get(g.endpoint)
 	if err != nil {
-		return credentials.Value(g.Value), errors.Wrapf(err, "failure requesting %s", s3CredentialsEndpoint)
+		return credentials.Value(g.Value), errors.Wrapf(err, "failure requesting %s", g.endpoint)
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return credentials.Value(g.Value), errors.Errorf("failed getting AWS access info from %s, HTTP Status: %s", s3CredentialsEndpoint, res.Status)
+		return credentials.Value(g.Value), errors.Errorf("failed getting AWS access info from %s, HTTP Status: %s", g.endpoint, res.Status)
 	}
 
 	if err := json.NewDecoder(res.Body).Decode(g); err != nil {
@@ -104,8 +129,8 @@ func (g *Provider) Retrieve() (credentials.Value, error) {
 
 // NewAWSSession returns a aws session.Session configured with GBDX
 // credentials for accessing your customer data bucket/location.
-func NewAWSSession(client *retryablehttp.Client) (*session.Session, *CustomerDataLocation, error) {
-	provider, err := NewProvider(client)
+func NewAWSSession(client *retryablehttp.Client, options ...ProviderOption) (*session.Session, *CustomerDataLocation, error) {
+	provider, err := NewProvider(client, options...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -125,36 +150,81 @@ type S3Accessor struct {
 	svc          s3iface.S3API
 	downloader   s3manageriface.DownloaderAPI
 	progressFunc func() int
+	progressMu   sync.Mutex
+	numParallel  int
+
+	providerOptions []ProviderOption
+	s3Endpoint      string
 }
 
 // NewS3Accessor returns a configured S3Accessor.
 func NewS3Accessor(client *retryablehttp.Client, options ...S3AccessorOption) (*S3Accessor, error) {
-	sess, cdl, err := NewAWSSession(client)
-	if err != nil {
-		return nil, err
-	}
 	a := &S3Accessor{
-		dataLoc:      *cdl,
-		svc:          s3.New(sess),
-		downloader:   s3manager.NewDownloader(sess),
 		progressFunc: func() int { return 0 },
+		numParallel:  4 * runtime.NumCPU(),
 	}
 	for _, opt := range options {
 		opt(a)
 	}
+
+	sess, cdl, err := NewAWSSession(client, a.providerOptions...)
+	if err != nil {
+		return nil, err
+	}
+	a.dataLoc = *cdl
+
+	var cfgs []*aws.Config
+	if a.s3Endpoint != "" {
+		cfgs = append(cfgs, &aws.Config{
+			Endpoint:         aws.String(a.s3Endpoint),
+			S3ForcePathStyle: aws.Bool(true),
+		})
+	}
+	a.svc = s3.New(sess, cfgs...)
+	a.downloader = s3manager.NewDownloaderWithClient(a.svc)
 	return a, nil
 }
 
 // S3AccessorOption is a type to use for setting options on an S3Accessor.
 type S3AccessorOption func(*S3Accessor)
 
-// WithProgressFunc sets a progress function to be called whenever an artifact finishes downloading from S3.
+// WithProgressFunc sets a progress function to be called whenever an
+// artifact finishes downloading from S3.  Calls to it are serialized,
+// even though artifacts download concurrently.
 func WithProgressFunc(progressFunc func() int) S3AccessorOption {
 	return func(a *S3Accessor) {
 		a.progressFunc = progressFunc
 	}
 }
 
+// WithNumParallel sets how many artifacts are downloaded from S3 at
+// once; by default, 4 * num CPUs are.
+func WithNumParallel(n int) S3AccessorOption {
+	return func(a *S3Accessor) {
+		if n > 0 {
+			a.numParallel = n
+		}
+	}
+}
+
+// WithProviderOptions sets options on the Provider used to fetch the
+// AWS credentials that the S3Accessor uses.  This only has an effect
+// when given to NewS3Accessor.
+func WithProviderOptions(options ...ProviderOption) S3AccessorOption {
+	return func(a *S3Accessor) {
+		a.providerOptions = append(a.providerOptions, options...)
+	}
+}
+
+// WithS3Endpoint points the S3Accessor at an S3 compatible API other
+// than AWS's, addressing buckets by path rather than by host name.
+// This only has an effect when given to NewS3Accessor.
+func WithS3Endpoint(endpoint string) S3AccessorOption {
+	return func(a *S3Accessor) {
+		a.s3Endpoint = endpoint
+	}
+}
+
 // RDABatchJobPrefixes returns all the RDA job ids that appear in your
 // GBDX customer data bucket under the "rda" prefix.
 func (a *S3Accessor) RDABatchJobPrefixes(ctx context.Context) ([]string, error) {
@@ -193,9 +263,9 @@ func (a *S3Accessor) RDABatchJobObjects(ctx context.Context, jobID string) ([]st
 
 	paths := []string{}
 	for _, obj := range objects {
-		splitPath := strings.Split(aws.StringValue(obj.Key), "/")
+		splitPath := strings.Split(aws.StringValue(obj.object.Key), "/")
 		if len(splitPath) < 3 {
-			return nil, errors.Errorf("expected the S3 path %q when split to have length of 3 or more", aws.StringValue(obj.Key))
+			return nil, errors.Errorf("expected the S3 path %q when split to have length of 3 or more", aws.StringValue(obj.object.Key))
 		}
 		// We're pulling off the GBDX account and rda prefixes before we return the path here.
 		paths = append(paths, path.Join(splitPath[2:]...))
@@ -203,87 +273,310 @@ func (a *S3Accessor) RDABatchJobObjects(ctx context.Context, jobID string) ([]st
 	return paths, nil
 }
 
+// RDABatchJobArtifacts returns the artifacts in S3 associated with
+// the given job id, which may also be a prefix of them as for
+// DownloadBatchJobArtifacts.
+func (a *S3Accessor) RDABatchJobArtifacts(ctx context.Context, jobID string) ([]Artifact, error) {
+	objects, err := a.listBatchJobArtifacts(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make([]Artifact, len(objects))
+	for i, obj := range objects {
+		p, err := artifactPath(aws.StringValue(obj.object.Key), jobID)
+		if err != nil {
+			return nil, err
+		}
+		artifacts[i] = Artifact{Key: aws.StringValue(obj.object.Key), Path: p, Size: obj.size, LastModified: obj.lastModified}
+	}
+	return artifacts, nil
+}
+
 // RDADeleteBatchJobArtifacts deletes all RDA batch job artifacts from
 // S3 associated with the given job id, returning the number deleted.
+// See DeleteArtifacts for how failures are reported.
 func (a *S3Accessor) RDADeleteBatchJobArtifacts(ctx context.Context, jobID string) (int, error) {
-	// List objects under this jobID.
-	objects, err := a.listBatchJobArtifacts(ctx, jobID)
+	artifacts, err := a.RDABatchJobArtifacts(ctx, jobID)
 	if err != nil {
 		return 0, err
 	}
+	return a.DeleteArtifacts(ctx, artifacts)
+}
+
+// DeleteArtifacts deletes the given artifacts from S3, returning the
+// number deleted.  If S3 fails to delete some of them, the error is a
+// *DeleteError listing those; if a request to S3 fails outright, no
+// more are attempted.  Either way, the number returned is of those
+// that were deleted.
+func (a *S3Accessor) DeleteArtifacts(ctx context.Context, artifacts []Artifact) (int, error) {
+	keys := make([]string, len(artifacts))
+	for i, artifact := range artifacts {
+		keys[i] = artifact.Key
+	}
+	return deleteObjects(ctx, a.svc, a.dataLoc.Bucket, keys)
+}
+
+// deleteObjects deletes the objects with the given keys from bucket,
+// reporting failures as DeleteArtifacts does.
+func deleteObjects(ctx context.Context, svc s3iface.S3API, bucket string, keys []string) (int, error) {
+	var (
+		numDel int
+		delErr DeleteError
+	)
 
 	// Delete them in batches of up to 1000 (an S3 api limit).
-	for i := 0; i < len(objects); i += 1000 {
+	for i := 0; i < len(keys); i += 1000 {
 		toDel := s3.DeleteObjectsInput{
-			Bucket: aws.String(a.dataLoc.Bucket),
+			Bucket: aws.String(bucket),
 			Delete: &s3.Delete{
 				Objects: []*s3.ObjectIdentifier{},
+				Quiet:   aws.Bool(true),
 			},
 		}
-		for j := i; j < i+1000 && j < len(objects); j++ {
-			toDel.Delete.Objects = append(toDel.Delete.Objects, &s3.ObjectIdentifier{Key: objects[j].Key})
+		for j := i; j < i+1000 && j < len(keys); j++ {
+			toDel.Delete.Objects = append(toDel.Delete.Objects, &s3.ObjectIdentifier{Key: aws.String(keys[j])})
+		}
+
+		out, err := svc.DeleteObjectsWithContext(ctx, &toDel)
+		if err != nil {
+			err = errors.Wrapf(err, "failed deleting artifacts from S3 after deleting %d of %d", numDel, len(keys))
+			if len(delErr.Failed) > 0 {
+				err = errors.WithMessage(err, delErr.Error())
+			}
+			return numDel, err
+		}
+
+		// In quiet mode, S3 only tells us about the objects it
+		// failed to delete.
+		numDel += len(toDel.Delete.Objects) - len(out.Errors)
+		for _, e := range out.Errors {
+			delErr.Failed = append(delErr.Failed, DeleteFailure{
+				Key:     aws.StringValue(e.Key),
+				Code:    aws.StringValue(e.Code),
+				Message: aws.StringValue(e.Message),
+			})
 		}
+	}
+	if len(delErr.Failed) > 0 {
+		return numDel, &delErr
+	}
+	return numDel, nil
+}
+
+// DeleteFailure describes an artifact S3 failed to delete.
+type DeleteFailure struct {
+	Key     string `json:"key"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// DeleteError is returned when S3 fails to delete some artifacts.
+type DeleteError struct {
+	Failed []DeleteFailure
+}
+
+// maxDeleteFailures is how many failures a DeleteError describes
+// before summarizing the rest.
+const maxDeleteFailures = 5
 
-		if _, err := a.svc.DeleteObjectsWithContext(ctx, &toDel); err != nil {
-			return 0, errors.Wrapf(err, "failed deleting artifacts associated with RDA job id %s from S3", jobID)
+func (e *DeleteError) Error() string {
+	msgs := []string{}
+	for i, f := range e.Failed {
+		if i == maxDeleteFailures {
+			msgs = append(msgs, fmt.Sprintf("and %d more", len(e.Failed)-maxDeleteFailures))
+			break
 		}
+		msgs = append(msgs, fmt.Sprintf("%s: %s %s", f.Key, f.Code, f.Message))
 	}
-	return len(objects), nil
+	return fmt.Sprintf("S3 failed to delete %d artifacts: %s", len(e.Failed), strings.Join(msgs, "; "))
 }
 
 // DownloadBatchJobArtifacts returns the count of objects that will be
 // downloaded and a function to run that initiates the download of the
 // RDA batch artifacts associated with the given jobID. If the file
-// already exists in outDir (taking the same name as in S3), it will
-// not be downloaded and won't be counted in the returned count.
+// already exists in outDir (taking the same name as in S3) and its
+// size matches the object in S3, along with its checksum if
+// VerifyArtifacts is given, it will not be downloaded and won't be
+// counted in the returned count; files that don't match, e.g. those
+// left partially written by an interrupted download, are downloaded
+// again.  Options restrict which artifacts are downloaded.
+//
+// Artifacts are downloaded concurrently (see WithNumParallel) to
+// temporary files in outDir, which are only renamed into place once
+// they've been verified against S3.
 //
 // We return in this style so that the user can instantiate a progress
 // bar if they like; you can provide a function via WithProgressFunc,
-// and it will be invokded on every successful download.
-func (a *S3Accessor) DownloadBatchJobArtifacts(ctx context.Context, outDir string, jobID string) (int, func() error, error) {
+// or DownloadProgressFunc for just this download, and it will be
+// invokded on every successful download.
+func (a *S3Accessor) DownloadBatchJobArtifacts(ctx context.Context, outDir string, jobID string, options ...DownloadOption) (int, func() error, error) {
+	toDL, err := a.planDownload(ctx, outDir, jobID, options...)
+	if err != nil {
+		return 0, nil, err
+	}
 	if err := os.MkdirAll(outDir, 0775); err != nil {
 		return 0, nil, err
 	}
+	var f downloadFilter
+	for _, opt := range options {
+		opt(&f)
+	}
+	return len(toDL), func() error { return a.downloadArtifacts(ctx, toDL, f.progressFunc) }, nil
+}
 
-	possibleDL, err := a.listBatchJobArtifacts(ctx, jobID)
+// PlanBatchJobDownload returns the artifacts that
+// DownloadBatchJobArtifacts would download given the same arguments,
+// without downloading anything.
+func (a *S3Accessor) PlanBatchJobDownload(ctx context.Context, outDir string, jobID string, options ...DownloadOption) ([]Artifact, error) {
+	toDL, err := a.planDownload(ctx, outDir, jobID, options...)
 	if err != nil {
-		return 0, nil, err
+		return nil, err
+	}
+	artifacts := make([]Artifact, len(toDL))
+	for i, dl := range toDL {
+		artifacts[i] = Artifact{
+			Key:          aws.StringValue(dl.object.Key),
+			Path:         dl.path,
+			File:         dl.file,
+			Size:         dl.size,
+			LastModified: dl.lastModified,
+		}
+	}
+	return artifacts, nil
+}
+
+// planDownload returns what to download of the given job's artifacts.
+func (a *S3Accessor) planDownload(ctx context.Context, outDir string, jobID string, options ...DownloadOption) ([]*downloadLocation, error) {
+	var f downloadFilter
+	for _, opt := range options {
+		opt(&f)
+	}
+	if err := f.validate(); err != nil {
+		return nil, err
 	}
 
 	// Filter out any we've already downloaded.
-	toDL := []downloadLocation{}
+	_, dls, err := a.locateArtifacts(ctx, outDir, jobID, &f, func(ctx context.Context, dl *downloadLocation) (bool, error) {
+		return a.localDone(ctx, dl, f.verify)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep within our byte budget, stopping at the first artifact
+	// that doesn't fit so that reruns pick up where we left off.
+	toDL := []*downloadLocation{}
+	var total int64
+	for _, dl := range dls {
+		if f.maxBytes > 0 && total+dl.size > f.maxBytes {
+			break
+		}
+		total += dl.size
+		toDL = append(toDL, dl)
+	}
+	return toDL, nil
+}
+
+// localDone returns true if dl's object has already been downloaded,
+// i.e. a file of its size is where it's downloaded to.  If verify is
+// true, the file's checksum must match too.
+func (a *S3Accessor) localDone(ctx context.Context, dl *downloadLocation, verify bool) (bool, error) {
+	fi, err := os.Stat(dl.file)
+	if err != nil || fi.Size() != dl.size {
+		return false, nil
+	}
+	if !verify {
+		return true, nil
+	}
+	if err := a.headArtifact(ctx, dl); err != nil {
+		return false, err
+	}
+	return dl.verify(dl.file) == nil, nil
+}
+
+// locateArtifacts returns where each of the given job's artifacts
+// selected by f is downloaded to in outDir, along with the size, ETag,
+// and modification time S3 lists for it, and then just those for which
+// done returns false.  Nothing is headed here, so planning costs no
+// more than the listing; objects are headed as they're fetched.
+func (a *S3Accessor) locateArtifacts(ctx context.Context, outDir string, jobID string, f *downloadFilter, done func(context.Context, *downloadLocation) (bool, error)) ([]*downloadLocation, []*downloadLocation, error) {
+	possibleDL, err := a.listBatchJobArtifacts(ctx, jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dls := []*downloadLocation{}
 	for _, obj := range possibleDL {
-		// Remove the jobID from the path we are going to
-		// write the output to.  This is in case the jobID is
-		// actually a nested S3 path.
-		paths := strings.Split(aws.StringValue(obj.Key), "/")
-		if len(paths) < 3 {
-			return 0, nil, errors.Errorf("cannot split s3 path %q into 3 or more components", aws.StringValue(obj.Key))
+		basePath, err := artifactPath(aws.StringValue(obj.object.Key), jobID)
+		if err != nil {
+			return nil, nil, err
 		}
-		basePath := strings.TrimPrefix(strings.Join(paths[2:], "/"), jobID)
-		if basePath == "" {
-			basePath = paths[len(paths)-1]
+		if !f.match(basePath) {
+			continue
 		}
 
 		// Form the file path, trying to handle Window's paths while we do it.
 		file := filepath.Join(outDir, filepath.Join(strings.Split(basePath, "/")...))
-		if _, err := os.Stat(file); !os.IsNotExist(err) {
-			continue
+		dls = append(dls, &downloadLocation{path: basePath, file: file, object: obj.object, size: obj.size, etag: obj.etag, lastModified: obj.lastModified})
+	}
+
+	// Find out whether each object has already been downloaded.
+	skip := make([]bool, len(dls))
+	if err := a.forEach(ctx, len(dls), func(ctx context.Context, i int) error {
+		var err error
+		skip[i], err = done(ctx, dls[i])
+		return err
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	toDo := []*downloadLocation{}
+	for i, dl := range dls {
+		if !skip[i] {
+			toDo = append(toDo, dl)
 		}
-		toDL = append(toDL, downloadLocation{file: file, object: obj})
 	}
+	return dls, toDo, nil
+}
+
+// artifactPath returns the path of the artifact with the given key
+// relative to its job.
+func artifactPath(key, jobID string) (string, error) {
+	// Remove the jobID from the path.  This is in case the jobID
+	// is actually a nested S3 path.
+	paths := strings.Split(key, "/")
+	if len(paths) < 3 {
+		return "", errors.Errorf("cannot split s3 path %q into 3 or more components", key)
+	}
+	basePath := strings.TrimPrefix(strings.TrimPrefix(strings.Join(paths[2:], "/"), jobID), "/")
+	if basePath == "" {
+		basePath = paths[len(paths)-1]
+	}
+	return basePath, nil
+}
 
-	return len(toDL), func() error { return a.downloadArtifacts(ctx, toDL) }, nil
+// batchArtifact is an object listed under an RDA batch job's prefix.
+type batchArtifact struct {
+	object       *s3.GetObjectInput
+	size         int64
+	etag         string
+	lastModified time.Time
 }
 
-func (a *S3Accessor) listBatchJobArtifacts(ctx context.Context, jobID string) ([]*s3.GetObjectInput, error) {
-	objects := []*s3.GetObjectInput{}
+func (a *S3Accessor) listBatchJobArtifacts(ctx context.Context, jobID string) ([]*batchArtifact, error) {
+	objects := []*batchArtifact{}
 	if err := a.svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
 		Bucket: &a.dataLoc.Bucket,
 		Prefix: aws.String(strings.Join([]string{a.dataLoc.Prefix, "rda", jobID}, "/")),
 	}, func(p *s3.ListObjectsV2Output, lastPage bool) bool {
 		for _, o := range p.Contents {
-			objects = append(objects, &s3.GetObjectInput{Bucket: &a.dataLoc.Bucket, Key: o.Key})
+			objects = append(objects, &batchArtifact{
+				object:       &s3.GetObjectInput{Bucket: &a.dataLoc.Bucket, Key: o.Key},
+				size:         aws.Int64Value(o.Size),
+				etag:         strings.Trim(aws.StringValue(o.ETag), `"`),
+				lastModified: aws.TimeValue(o.LastModified),
+			})
 		}
 		return true
 	}); err != nil {
@@ -292,46 +585,268 @@ func (a *S3Accessor) listBatchJobArtifacts(ctx context.Context, jobID string) ([
 	return objects, nil
 }
 
+// maxDownloadAttempts is how many times an artifact is downloaded
+// before giving up on it failing verification.
+const maxDownloadAttempts = 3
+
+// downloadLocation is an object to download, its path relative to its
+// job, and where to download it to, along with the size and ETag S3
+// reports for it.
 type downloadLocation struct {
+	path   string
 	file   string
 	object *s3.GetObjectInput
+
+	size         int64
+	etag         string
+	lastModified time.Time
+
+	// partSize is the size of the parts of an object uploaded in
+	// multiple parts, which its ETag is derived from.
+	partSize int64
+
+	// checkETag is whether etag can be checked against the
+	// downloaded file.
+	checkETag bool
+
+	// headed is whether headArtifact has filled in the above.
+	headed bool
 }
 
-func (a *S3Accessor) downloadArtifacts(ctx context.Context, dlLoc []downloadLocation) error {
-	for _, dl := range dlLoc {
-		obj, file := dl.object, dl.file
+// headArtifact fills in what S3 reports about dl's object, if it
+// hasn't already.
+func (a *S3Accessor) headArtifact(ctx context.Context, dl *downloadLocation) error {
+	if dl.headed {
+		return nil
+	}
+	dl.headed = true
+	out, err := a.svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: dl.object.Bucket, Key: dl.object.Key})
+	if err != nil {
+		return errors.Wrapf(err, "failed fetching the details of s3://%s/%s", aws.StringValue(dl.object.Bucket), aws.StringValue(dl.object.Key))
+	}
+	dl.size, dl.etag = aws.Int64Value(out.ContentLength), strings.Trim(aws.StringValue(out.ETag), `"`)
 
-		if err := a.downloadArtifact(ctx, file, obj); err != nil {
-			return err
-		}
-		a.progressFunc()
+	// The ETags of objects encrypted with KMS or customer keys
+	// aren't derived from their contents, so only their size can be
+	// checked.
+	if aws.StringValue(out.ServerSideEncryption) == s3.ServerSideEncryptionAwsKms || out.SSECustomerAlgorithm != nil {
+		return nil
+	}
+
+	numParts := multipartETagParts(dl.etag)
+	if numParts == 0 {
+		dl.checkETag = len(dl.etag) == 2*md5.Size
+		return nil
+	}
+
+	// A multipart ETag is the MD5 of its parts' MD5s, so we need
+	// to know the part size to check it; parts are all the same
+	// size but the last, so the first tells us.
+	out, err = a.svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: dl.object.Bucket, Key: dl.object.Key, PartNumber: aws.Int64(1)})
+	if err != nil {
+		return errors.Wrapf(err, "failed fetching the part size of s3://%s/%s", aws.StringValue(dl.object.Bucket), aws.StringValue(dl.object.Key))
 	}
+	dl.partSize = aws.Int64Value(out.ContentLength)
+	dl.checkETag = dl.partSize > 0
 	return nil
 }
 
-func (a *S3Accessor) downloadArtifact(ctx context.Context, file string, obj *s3.GetObjectInput) error {
-	baseDir, _ := filepath.Split(file)
-	if err := os.MkdirAll(baseDir, 0775); err != nil {
-		return errors.Wrap(err, "couldn't create directories to write downloaded artifact to")
+// multipartETagParts returns how many parts the object with the given
+// ETag was uploaded in, or 0 if its ETag is a plain MD5 of its
+// contents.
+func multipartETagParts(etag string) int {
+	idx := strings.LastIndex(etag, "-")
+	if idx < 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(etag[idx+1:])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// verify returns an error if file's size or checksum don't match what
+// S3 reported for dl's object.  Only the size is checked if the
+// object's ETag isn't a checksum of its contents.
+func (dl *downloadLocation) verify(file string) error {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return errors.Wrap(err, "failed verifying downloaded artifact")
+	}
+	if fi.Size() != dl.size {
+		return errors.Errorf("%s is %d bytes but s3://%s/%s is %d bytes", file, fi.Size(), aws.StringValue(dl.object.Bucket), aws.StringValue(dl.object.Key), dl.size)
+	}
+
+	if !dl.checkETag {
+		return nil
 	}
+	etag, err := fileETag(file, dl.partSize, multipartETagParts(dl.etag))
+	if err != nil {
+		return err
+	}
+	if etag == dl.etag {
+		return nil
+	}
+	return errors.Errorf("the checksum of %s (%s) doesn't match the ETag of s3://%s/%s (%s)", file, etag, aws.StringValue(dl.object.Bucket), aws.StringValue(dl.object.Key), dl.etag)
+}
 
-	fd, err := os.Create(file)
+// fileETag returns the ETag S3 would give file if it were uploaded in
+// numParts parts of partSize bytes, or in one part if numParts is 0.
+func fileETag(file string, partSize int64, numParts int) (string, error) {
+	fd, err := os.Open(file)
 	if err != nil {
-		return errors.Wrapf(err, "failed creating file to hold rda output from s3")
+		return "", errors.Wrap(err, "failed verifying downloaded artifact")
 	}
+	defer fd.Close()
 
-	// Delete the file we've created if we didn't download it successfully.
-	defer func() {
-		if err != nil {
-			if nerr := os.Remove(file); nerr != nil {
-				err = errors.WithMessagef(err, "failed removing partially downloaded file %s, err: %v", file, nerr)
+	if numParts == 0 {
+		h := md5.New()
+		if _, err := io.Copy(h, fd); err != nil {
+			return "", errors.Wrapf(err, "failed checksumming %s", file)
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	sums := md5.New()
+	for i := 0; i < numParts; i++ {
+		h := md5.New()
+		if _, err := io.CopyN(h, fd, partSize); err != nil && err != io.EOF {
+			return "", errors.Wrapf(err, "failed checksumming %s", file)
+		}
+		sums.Write(h.Sum(nil))
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(sums.Sum(nil)), numParts), nil
+}
+
+// forEach calls fn with each index up to n, from up to numParallel
+// goroutines at once.  It stops at, and returns, the first error.
+func (a *S3Accessor) forEach(ctx context.Context, n int, fn func(context.Context, int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	numParallel := a.numParallel
+	if numParallel < 1 {
+		numParallel = 1
+	}
+	if n < numParallel {
+		numParallel = n
+	}
+
+	idxs := make(chan int)
+	go func() {
+		defer close(idxs)
+		for i := 0; i < n; i++ {
+			select {
+			case idxs <- i:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
-	defer fd.Close()
 
-	if _, err = a.downloader.DownloadWithContext(ctx, fd, obj); err != nil {
-		return errors.Wrap(err, "failure downloading object from S3")
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < numParallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range idxs {
+				if err := fn(ctx, idx); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
 	}
-	return nil
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		// Our parent context was canceled before we got through
+		// everything.
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// downloadArtifacts downloads the artifacts at dlLoc, calling the
+// accessor's progress function, and progressFunc if it isn't nil, as
+// each finishes.
+func (a *S3Accessor) downloadArtifacts(ctx context.Context, dlLoc []*downloadLocation, progressFunc func() int) error {
+	return a.forEach(ctx, len(dlLoc), func(ctx context.Context, i int) error {
+		if err := a.downloadArtifact(ctx, dlLoc[i]); err != nil {
+			return err
+		}
+		a.progressMu.Lock()
+		defer a.progressMu.Unlock()
+		a.progressFunc()
+		if progressFunc != nil {
+			progressFunc()
+		}
+		return nil
+	})
+}
+
+// downloadArtifact downloads dl's object to a temporary file, which is
+// renamed into place once verified, retrying if it doesn't verify.
+func (a *S3Accessor) downloadArtifact(ctx context.Context, dl *downloadLocation) error {
+	if err := a.headArtifact(ctx, dl); err != nil {
+		return err
+	}
+	baseDir, base := filepath.Split(dl.file)
+	if err := os.MkdirAll(baseDir, 0775); err != nil {
+		return errors.Wrap(err, "couldn't create directories to write downloaded artifact to")
+	}
+
+	// Only download the version of the object we headed, so we
+	// fail rather than verify against the wrong one if it changes.
+	obj := *dl.object
+	if dl.etag != "" {
+		obj.IfMatch = aws.String(`"` + dl.etag + `"`)
+	}
+
+	var err error
+	for attempt := 0; attempt < maxDownloadAttempts; attempt++ {
+		var tmp string
+		if tmp, err = a.downloadTemp(ctx, baseDir, base, &obj); err != nil {
+			return err
+		}
+		if err = dl.verify(tmp); err != nil {
+			os.Remove(tmp)
+			continue
+		}
+		if err = os.Rename(tmp, dl.file); err != nil {
+			os.Remove(tmp)
+			return errors.Wrapf(err, "failed moving downloaded artifact into place at %s", dl.file)
+		}
+		return nil
+	}
+	return errors.WithMessagef(err, "giving up after downloading s3://%s/%s %d times", aws.StringValue(obj.Bucket), aws.StringValue(obj.Key), maxDownloadAttempts)
+}
+
+// downloadTemp downloads obj to a new temporary file in dir, returning
+// its path.  The file is removed if the download fails.
+func (a *S3Accessor) downloadTemp(ctx context.Context, dir, base string, obj *s3.GetObjectInput) (string, error) {
+	fd, err := ioutil.TempFile(dir, base+".*.part")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed creating file to hold rda output from s3")
+	}
+
+	_, err = a.downloader.DownloadWithContext(ctx, fd, obj)
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		if nerr := os.Remove(fd.Name()); nerr != nil {
+			return "", errors.WithMessagef(errors.Wrap(err, "failure downloading object from S3"), "failed removing partially downloaded file %s, err: %v", fd.Name(), nerr)
+		}
+		return "", errors.Wrap(err, "failure downloading object from S3")
+	}
+	return fd.Name(), nil
 }
@@ -21,15 +21,25 @@
 package gbdx
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -64,7 +74,7 @@ func TestProvider(t *testing.T) {
 		fmt.Fprintln(w, resp)
 	}))
 	defer ts.Close()
-	s3CredentialsEndpoint = ts.URL
+	S3CredentialsEndpoint = ts.URL
 
 	client := retryablehttp.NewClient()
 
@@ -111,7 +121,7 @@ func TestNewAWSSession(t *testing.T) {
 		fmt.Fprintln(w, resp)
 	}))
 	defer ts.Close()
-	s3CredentialsEndpoint = ts.URL
+	S3CredentialsEndpoint = ts.URL
 
 	client := retryablehttp.NewClient()
 	sess, loc, err := NewAWSSession(client)
@@ -140,6 +150,8 @@ type mockS3 struct {
 	s3iface.S3API
 	listFunc   func(aws.Context, *s3.ListObjectsV2Input, func(*s3.ListObjectsV2Output, bool) bool, ...request.Option) error
 	delObjects func(aws.Context, *s3.DeleteObjectsInput, ...request.Option) (*s3.DeleteObjectsOutput, error)
+	headFunc   func(aws.Context, *s3.HeadObjectInput, ...request.Option) (*s3.HeadObjectOutput, error)
+	getFunc    func(aws.Context, *s3.GetObjectInput, ...request.Option) (*s3.GetObjectOutput, error)
 }
 
 func (m mockS3) ListObjectsV2PagesWithContext(ctx aws.Context, in *s3.ListObjectsV2Input, f func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
@@ -150,6 +162,14 @@ func (m mockS3) DeleteObjectsWithContext(ctx aws.Context, in *s3.DeleteObjectsIn
 	return m.delObjects(ctx, in, opts...)
 }
 
+func (m mockS3) HeadObjectWithContext(ctx aws.Context, in *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	return m.headFunc(ctx, in, opts...)
+}
+
+func (m mockS3) GetObjectWithContext(ctx aws.Context, in *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	return m.getFunc(ctx, in, opts...)
+}
+
 func TestRDABatchJobPrefixes(t *testing.T) {
 	exp := []string{"2a2c79d0-acd4-4ea3-a9a4-c144f85708d3", "4840c2f2-b978-4f7c-81a0-dc2988ca4b15", "5e14dff5-dcce-4009-a4c7-9a96e8cdaf3a"}
 
@@ -187,31 +207,199 @@ func (m mockDownloader) DownloadWithContext(ctx aws.Context, w io.WriterAt, in *
 	return m.dlFunc(ctx, w, in, f...)
 }
 
-func TestDownloadBatchJobArtifacts(t *testing.T) {
-	m := mockS3{
-		listFunc: func(_ aws.Context, _ *s3.ListObjectsV2Input, f func(*s3.ListObjectsV2Output, bool) bool, _ ...request.Option) error {
-			f(&s3.ListObjectsV2Output{Contents: []*s3.Object{
-				&s3.Object{Key: aws.String("prefix/rda/jobid/granule_R0C0.tif")},
-				&s3.Object{Key: aws.String("prefix/rda/jobid/granule_R0C1.tif")},
-			}}, true)
-			f(&s3.ListObjectsV2Output{Contents: []*s3.Object{
-				&s3.Object{Key: aws.String("prefix/rda/jobid/granule_R1C0.tif")},
-				&s3.Object{Key: aws.String("prefix/rda/jobid/granule_R1C1.tif")},
-			}}, true)
+// fakeBucket is an in memory S3 bucket, served via mockS3 and
+// mockDownloader.
+type fakeBucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+
+	// partSize, if set, is the part size objects are treated as
+	// having been uploaded in.
+	partSize int64
+
+	// corrupt, if set, is how many more downloads to corrupt.
+	corrupt int
+
+	downloads int
+	heads     int
+
+	// metadata holds the user metadata of objects uploaded via
+	// uploader.
+	metadata map[string]map[string]*string
+}
+
+func (b *fakeBucket) etag(data []byte) string {
+	if b.partSize == 0 {
+		sum := md5.Sum(data)
+		return `"` + hex.EncodeToString(sum[:]) + `"`
+	}
+	sums := []byte{}
+	n := 0
+	for off := int64(0); off < int64(len(data)); off += b.partSize {
+		end := off + b.partSize
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		sum := md5.Sum(data[off:end])
+		sums = append(sums, sum[:]...)
+		n++
+	}
+	sum := md5.Sum(sums)
+	return fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), n)
+}
+
+func (b *fakeBucket) s3() mockS3 {
+	return mockS3{
+		listFunc: func(_ aws.Context, in *s3.ListObjectsV2Input, f func(*s3.ListObjectsV2Output, bool) bool, _ ...request.Option) error {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			keys := []string{}
+			for key := range b.objects {
+				if strings.HasPrefix(key, aws.StringValue(in.Prefix)) {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			out := s3.ListObjectsV2Output{}
+			for _, key := range keys {
+				out.Contents = append(out.Contents, &s3.Object{Key: aws.String(key), Size: aws.Int64(int64(len(b.objects[key]))), ETag: aws.String(b.etag(b.objects[key])), LastModified: aws.Time(time.Unix(0, 0))})
+			}
+			f(&out, true)
 			return nil
 		},
+		headFunc: func(_ aws.Context, in *s3.HeadObjectInput, _ ...request.Option) (*s3.HeadObjectOutput, error) {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.heads++
+			data, ok := b.objects[aws.StringValue(in.Key)]
+			if !ok {
+				return nil, errors.New("NoSuchKey")
+			}
+			size := int64(len(data))
+			if in.PartNumber != nil {
+				if b.partSize == 0 {
+					return nil, errors.New("not a multipart object")
+				}
+				if size > b.partSize {
+					size = b.partSize
+				}
+			}
+			return &s3.HeadObjectOutput{ContentLength: aws.Int64(size), ETag: aws.String(b.etag(data)), Metadata: b.metadata[aws.StringValue(in.Key)]}, nil
+		},
+		getFunc: func(_ aws.Context, in *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			data, ok := b.objects[aws.StringValue(in.Key)]
+			if !ok {
+				return nil, errors.New("NoSuchKey")
+			}
+			if in.IfMatch != nil && aws.StringValue(in.IfMatch) != b.etag(data) {
+				return nil, errors.New("PreconditionFailed")
+			}
+			return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(data)), ContentLength: aws.Int64(int64(len(data)))}, nil
+		},
 	}
+}
 
-	accessor := S3Accessor{
-		dataLoc: CustomerDataLocation{},
-		svc:     m,
-		downloader: mockDownloader{
-			dlFunc: func(aws.Context, io.WriterAt, *s3.GetObjectInput, ...func(*s3manager.Downloader)) (int64, error) {
-				return 0, nil
-			},
+type mockUploader struct {
+	s3manageriface.UploaderAPI
+	upFunc func(aws.Context, *s3manager.UploadInput, ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error)
+}
+
+func (m mockUploader) UploadWithContext(ctx aws.Context, in *s3manager.UploadInput, f ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	return m.upFunc(ctx, in, f...)
+}
+
+// uploader returns an uploader storing objects in b, as S3 would given
+// b's part size, regardless of the part size asked for.
+func (b *fakeBucket) uploader() mockUploader {
+	return mockUploader{
+		upFunc: func(_ aws.Context, in *s3manager.UploadInput, _ ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+			data, err := ioutil.ReadAll(in.Body)
+			if err != nil {
+				return nil, err
+			}
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.objects[aws.StringValue(in.Key)] = data
+			if b.metadata == nil {
+				b.metadata = map[string]map[string]*string{}
+			}
+			b.metadata[aws.StringValue(in.Key)] = in.Metadata
+			return &s3manager.UploadOutput{}, nil
 		},
+	}
+}
+
+// deleter returns the DeleteObjects implementation of a mockS3 for b,
+// which refuses to delete keys containing "locked" and fails whole
+// requests after failRequestsAfter of them, if that is set.
+func (b *fakeBucket) deleter(failRequestsAfter int) func(aws.Context, *s3.DeleteObjectsInput, ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	requests := 0
+	return func(_ aws.Context, in *s3.DeleteObjectsInput, _ ...request.Option) (*s3.DeleteObjectsOutput, error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		requests++
+		if failRequestsAfter > 0 && requests > failRequestsAfter {
+			return nil, errors.New("InternalError")
+		}
+		out := s3.DeleteObjectsOutput{}
+		for _, o := range in.Delete.Objects {
+			if strings.Contains(aws.StringValue(o.Key), "locked") {
+				out.Errors = append(out.Errors, &s3.Error{Key: o.Key, Code: aws.String("AccessDenied"), Message: aws.String("Access Denied")})
+				continue
+			}
+			delete(b.objects, aws.StringValue(o.Key))
+		}
+		return &out, nil
+	}
+}
+
+func (b *fakeBucket) downloader() mockDownloader {
+	return mockDownloader{
+		dlFunc: func(_ aws.Context, w io.WriterAt, in *s3.GetObjectInput, _ ...func(*s3manager.Downloader)) (int64, error) {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			data, ok := b.objects[aws.StringValue(in.Key)]
+			if !ok {
+				return 0, errors.New("NoSuchKey")
+			}
+			if in.IfMatch != nil && aws.StringValue(in.IfMatch) != b.etag(data) {
+				return 0, errors.New("PreconditionFailed")
+			}
+			b.downloads++
+			if b.corrupt > 0 {
+				b.corrupt--
+				data = append([]byte{}, data...)
+				data[0]++
+			}
+			n, err := w.WriteAt(data, 0)
+			return int64(n), err
+		},
+	}
+}
+
+func (b *fakeBucket) accessor(numParallel int) *S3Accessor {
+	return &S3Accessor{
+		dataLoc:      CustomerDataLocation{Bucket: "bucket", Prefix: "prefix"},
+		svc:          b.s3(),
+		downloader:   b.downloader(),
 		progressFunc: func() int { return 0 },
+		numParallel:  numParallel,
+	}
+}
+
+func TestDownloadBatchJobArtifacts(t *testing.T) {
+	b := fakeBucket{objects: map[string][]byte{}}
+	for r := 0; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			b.objects[fmt.Sprintf("prefix/rda/jobid/granule_R%dC%d.tif", r, c)] = []byte(fmt.Sprintf("tile %d,%d, some more bytes to make it longer", r, c))
+		}
 	}
+	accessor := b.accessor(3)
+
+	var progress int32
+	WithProgressFunc(func() int { return int(atomic.AddInt32(&progress, 1)) })(accessor)
 
 	tmpDir, err := ioutil.TempDir("", "TestDownloadBatchJobArtifacts-")
 	if err != nil {
@@ -219,24 +407,266 @@ func TestDownloadBatchJobArtifacts(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	dlCount, dlFunc, err := accessor.DownloadBatchJobArtifacts(context.Background(), tmpDir, "jobid")
+	var dlProgress int
+	dlCount, dlFunc, err := accessor.DownloadBatchJobArtifacts(context.Background(), tmpDir, "jobid",
+		DownloadProgressFunc(func() int { dlProgress++; return dlProgress }))
 	if err != nil {
 		t.Fatal(err)
 	}
-	if dlCount != 4 {
-		t.Fatalf("expected 4 objects to download, but got %d", dlCount)
+	if dlCount != 16 {
+		t.Fatalf("expected 16 objects to download, but got %d", dlCount)
 	}
 
 	if err := dlFunc(); err != nil {
 		t.Fatal(err)
 	}
+	if progress != 16 {
+		t.Fatalf("expected progress to be reported 16 times, but got %d", progress)
+	}
+	if dlProgress != 16 {
+		t.Fatalf("expected the download's progress to be reported 16 times, but got %d", dlProgress)
+	}
 
-	// Count files written.
+	// Check the files written, and that no temporary files are left behind.
 	files, err := ioutil.ReadDir(tmpDir)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(files) != 4 {
-		t.Fatalf("expected 4 objects written to disk, but got %d", len(files))
+	if len(files) != 16 {
+		t.Fatalf("expected 16 objects written to disk, but got %d", len(files))
+	}
+	for _, fi := range files {
+		data, err := ioutil.ReadFile(filepath.Join(tmpDir, fi.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp := b.objects["prefix/rda/jobid/"+fi.Name()]; string(data) != string(exp) {
+			t.Fatalf("%s holds %q, expected %q", fi.Name(), data, exp)
+		}
+	}
+
+	// Planning again needs nothing but the listing.
+	b.heads = 0
+	if dlCount, _, err = accessor.DownloadBatchJobArtifacts(context.Background(), tmpDir, "jobid"); err != nil {
+		t.Fatal(err)
+	}
+	if dlCount != 0 || b.heads != 0 {
+		t.Fatalf("expected nothing to download again without heading anything, but got %d to download after %d heads", dlCount, b.heads)
+	}
+
+	// Truncate one file and corrupt another.  Only the truncated one
+	// is downloaded again, unless we verify checksums.
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "granule_R0C0.tif"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	corrupted := append([]byte{}, b.objects["prefix/rda/jobid/granule_R1C1.tif"]...)
+	corrupted[0]++
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "granule_R1C1.tif"), corrupted, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b.heads = 0
+	if dlCount, dlFunc, err = accessor.DownloadBatchJobArtifacts(context.Background(), tmpDir, "jobid"); err != nil {
+		t.Fatal(err)
+	}
+	if dlCount != 1 || b.heads != 0 {
+		t.Fatalf("expected 1 object to download again without heading anything, but got %d after %d heads", dlCount, b.heads)
+	}
+	if err := dlFunc(); err != nil {
+		t.Fatal(err)
+	}
+	if b.heads != 1 {
+		t.Fatalf("expected only the object downloaded again to be headed, but got %d heads", b.heads)
+	}
+
+	dlCount, dlFunc, err = accessor.DownloadBatchJobArtifacts(context.Background(), tmpDir, "jobid", VerifyArtifacts(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dlCount != 1 {
+		t.Fatalf("expected 1 object to download again when verifying, but got %d", dlCount)
+	}
+	if err := dlFunc(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"granule_R0C0.tif", "granule_R1C1.tif"} {
+		data, err := ioutil.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp := b.objects["prefix/rda/jobid/"+name]; string(data) != string(exp) {
+			t.Fatalf("%s holds %q after downloading again, expected %q", name, data, exp)
+		}
+	}
+}
+
+func TestDownloadBatchJobArtifactsVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		partSize  int64
+		corrupt   int
+		downloads int
+		wantErr   bool
+	}{
+		{name: "clean", downloads: 1},
+		{name: "retried", corrupt: 2, downloads: 3},
+		{name: "gives up", corrupt: maxDownloadAttempts, downloads: maxDownloadAttempts, wantErr: true},
+		{name: "multipart", partSize: 5, downloads: 1},
+		{name: "multipart retried", partSize: 5, corrupt: 1, downloads: 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := fakeBucket{
+				objects:  map[string][]byte{"prefix/rda/jobid/out.tif": []byte("0123456789abcdefghijk")},
+				partSize: tc.partSize,
+				corrupt:  tc.corrupt,
+			}
+			accessor := b.accessor(1)
+
+			tmpDir, err := ioutil.TempDir("", "TestDownloadBatchJobArtifactsVerify-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tmpDir)
+
+			_, dlFunc, err := accessor.DownloadBatchJobArtifacts(context.Background(), tmpDir, "jobid")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = dlFunc()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("wanted an error: %t, but got %v", tc.wantErr, err)
+			}
+			if b.downloads != tc.downloads {
+				t.Fatalf("expected %d downloads, but got %d", tc.downloads, b.downloads)
+			}
+
+			files, err := ioutil.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tc.wantErr && len(files) != 0:
+				t.Fatalf("expected nothing left on disk after failing, but found %d files", len(files))
+			case !tc.wantErr && (len(files) != 1 || files[0].Name() != "out.tif"):
+				t.Fatalf("expected only out.tif on disk, but found %v", files)
+			}
+		})
+	}
+}
+
+func TestPlanBatchJobDownload(t *testing.T) {
+	b := fakeBucket{objects: map[string][]byte{
+		"prefix/rda/jobid/10/1/1.png": make([]byte, 10),
+		"prefix/rda/jobid/10/1/2.png": make([]byte, 10),
+		"prefix/rda/jobid/11/2/2.png": make([]byte, 20),
+		"prefix/rda/jobid/11/2/3.png": make([]byte, 20),
+		"prefix/rda/jobid/11/2/4.jpg": make([]byte, 20),
+		"prefix/rda/jobid/12/4/4.png": make([]byte, 40),
+		"prefix/rda/jobid/README.txt": make([]byte, 5),
+	}}
+	accessor := b.accessor(2)
+
+	tests := []struct {
+		name    string
+		options []DownloadOption
+		exp     []string
+	}{
+		{name: "everything", exp: []string{"10/1/1.png", "10/1/2.png", "11/2/2.png", "11/2/3.png", "11/2/4.jpg", "12/4/4.png", "README.txt"}},
+		{name: "include", options: []DownloadOption{IncludeArtifacts("*.jpg", "*.txt")}, exp: []string{"11/2/4.jpg", "README.txt"}},
+		{name: "exclude", options: []DownloadOption{ExcludeArtifacts("*.png")}, exp: []string{"11/2/4.jpg", "README.txt"}},
+		{name: "nested pattern", options: []DownloadOption{IncludeArtifacts("1?/*/2.png")}, exp: []string{"10/1/2.png", "11/2/2.png"}},
+		{name: "zoom", options: []DownloadOption{ZoomLevels(10, 12)}, exp: []string{"10/1/1.png", "10/1/2.png", "12/4/4.png"}},
+		{name: "zoom and exclude", options: []DownloadOption{ZoomLevels(11), ExcludeArtifacts("*.jpg")}, exp: []string{"11/2/2.png", "11/2/3.png"}},
+		{name: "max bytes", options: []DownloadOption{MaxDownloadBytes(45)}, exp: []string{"10/1/1.png", "10/1/2.png", "11/2/2.png"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			artifacts, err := accessor.PlanBatchJobDownload(context.Background(), "out", "jobid", tc.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paths := []string{}
+			for _, a := range artifacts {
+				paths = append(paths, a.Path)
+				if exp := int64(len(b.objects[a.Key])); a.Size != exp {
+					t.Fatalf("%s has size %d, expected %d", a.Key, a.Size, exp)
+				}
+				if exp := filepath.Join("out", filepath.FromSlash(a.Path)); a.File != exp {
+					t.Fatalf("%s would be downloaded to %s, expected %s", a.Key, a.File, exp)
+				}
+			}
+			if !reflect.DeepEqual(paths, tc.exp) {
+				t.Fatalf("planned %v, expected %v", paths, tc.exp)
+			}
+		})
+	}
+
+	// A dry run shouldn't have created anything, or needed more
+	// than the listing.
+	if _, err := os.Stat("out"); !os.IsNotExist(err) {
+		t.Fatalf("expected planning not to create the output directory, err: %v", err)
+	}
+	if b.heads != 0 {
+		t.Fatalf("expected planning not to head anything, but got %d heads", b.heads)
+	}
+
+	if _, err := accessor.PlanBatchJobDownload(context.Background(), "out", "jobid", IncludeArtifacts("[")); err == nil {
+		t.Fatal("expected an error for a malformed pattern")
+	}
+}
+
+func TestRDADeleteBatchJobArtifacts(t *testing.T) {
+	newBucket := func() *fakeBucket {
+		b := fakeBucket{objects: map[string][]byte{}}
+		for i := 0; i < 2500; i++ {
+			b.objects[fmt.Sprintf("prefix/rda/jobid/tile_%04d.tif", i)] = []byte{}
+		}
+		b.objects["prefix/rda/jobid/tile_0100.locked.tif"] = []byte{}
+		b.objects["prefix/rda/jobid/tile_2100.locked.tif"] = []byte{}
+		b.objects["prefix/rda/otherjob/tile_0000.tif"] = []byte{}
+		return &b
+	}
+
+	// S3 failing to delete some artifacts is reported, but
+	// everything else is still deleted.
+	b := newBucket()
+	accessor := b.accessor(1)
+	m := b.s3()
+	m.delObjects = b.deleter(0)
+	accessor.svc = m
+
+	numDel, err := accessor.RDADeleteBatchJobArtifacts(context.Background(), "jobid")
+	if numDel != 2500 {
+		t.Fatalf("expected 2500 artifacts deleted, but got %d", numDel)
+	}
+	delErr, ok := err.(*DeleteError)
+	if !ok {
+		t.Fatalf("expected a *DeleteError, but got %v", err)
+	}
+	if len(delErr.Failed) != 2 || delErr.Failed[0].Key != "prefix/rda/jobid/tile_0100.locked.tif" || delErr.Failed[0].Code != "AccessDenied" {
+		t.Fatalf("unexpected failures %+v", delErr.Failed)
+	}
+	if len(b.objects) != 3 {
+		t.Fatalf("expected 3 objects left in the bucket, but found %d", len(b.objects))
+	}
+
+	// A request failing partway through reports what was deleted
+	// before it.
+	b = newBucket()
+	accessor = b.accessor(1)
+	m = b.s3()
+	m.delObjects = b.deleter(2)
+	accessor.svc = m
+
+	numDel, err = accessor.RDADeleteBatchJobArtifacts(context.Background(), "jobid")
+	if err == nil {
+		t.Fatal("expected an error when a delete request fails")
+	}
+	if numDel != 1999 {
+		t.Fatalf("expected 1999 artifacts deleted, but got %d", numDel)
+	}
+	if !strings.Contains(err.Error(), "tile_0100.locked.tif") {
+		t.Fatalf("expected the error to mention the artifact that couldn't be deleted, got %v", err)
 	}
 }
@@ -0,0 +1,373 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gbdx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/pkg/errors"
+)
+
+// SyncDestination is somewhere a job's artifacts are synced to, laid
+// out as DownloadBatchJobArtifacts lays them out: either a local
+// directory (see LocalDestination) or an S3 location (see
+// NewS3Destination).
+type SyncDestination interface {
+	fmt.Stringer
+
+	// dir is the local directory artifacts are synced to, if any.
+	dir() string
+
+	// list returns what is at the destination, by path relative
+	// to it.
+	list(ctx context.Context) (map[string]destObject, error)
+
+	// done returns true if dl's object is already at the
+	// destination, given what list returned.  If verify is true,
+	// local copies must match its checksum too.
+	done(ctx context.Context, a *S3Accessor, dl *downloadLocation, existing map[string]destObject, verify bool) (bool, error)
+
+	// copy copies dl's object to the destination.
+	copy(ctx context.Context, a *S3Accessor, dl *downloadLocation) error
+
+	// remove removes the given paths from the destination,
+	// returning how many were removed.
+	remove(ctx context.Context, paths []string) (int, error)
+
+	// location returns where path is at the destination.
+	location(path string) string
+}
+
+// destObject is what a SyncDestination has at a path.
+type destObject struct {
+	size int64
+	etag string
+}
+
+// SyncPlan is what syncing a job's artifacts to a destination will do.
+type SyncPlan struct {
+	JobID string `json:"jobId"`
+
+	// Copy lists the artifacts that are new or changed, with File
+	// set to where each is copied to.
+	Copy []Artifact `json:"copy"`
+
+	// Delete lists what will be deleted from the destination
+	// because the job has no such artifact.
+	Delete []string `json:"delete,omitempty"`
+
+	dest     SyncDestination
+	toCopy   []*downloadLocation
+	toDelete []string
+}
+
+// PlanSync returns what SyncBatchJobArtifacts will do to make dest
+// hold the given job's artifacts.  Artifacts at the destination with
+// the same size, and for S3 destinations the same checksum, are left
+// alone; if verify is true, so are those in a local directory only if
+// their checksum matches too, as with VerifyArtifacts.  If deleteExtra
+// is true, anything at the destination that isn't one of the job's
+// artifacts is deleted.
+func (a *S3Accessor) PlanSync(ctx context.Context, jobID string, dest SyncDestination, deleteExtra, verify bool) (*SyncPlan, error) {
+	existing, err := dest.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	all, toCopy, err := a.locateArtifacts(ctx, dest.dir(), jobID, &downloadFilter{}, func(ctx context.Context, dl *downloadLocation) (bool, error) {
+		return dest.done(ctx, a, dl, existing, verify)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plan := SyncPlan{JobID: jobID, Copy: []Artifact{}, dest: dest, toCopy: toCopy}
+	for _, dl := range toCopy {
+		plan.Copy = append(plan.Copy, Artifact{
+			Key:          aws.StringValue(dl.object.Key),
+			Path:         dl.path,
+			File:         dest.location(dl.path),
+			Size:         dl.size,
+			LastModified: dl.lastModified,
+		})
+	}
+
+	if deleteExtra {
+		for _, dl := range all {
+			delete(existing, dl.path)
+		}
+		for p := range existing {
+			plan.toDelete = append(plan.toDelete, p)
+		}
+		sort.Strings(plan.toDelete)
+		for _, p := range plan.toDelete {
+			plan.Delete = append(plan.Delete, dest.location(p))
+		}
+	}
+	return &plan, nil
+}
+
+// SyncBatchJobArtifacts carries out plan, copying artifacts
+// concurrently and calling the function given via WithProgressFunc as
+// each finishes.  Nothing is deleted unless every artifact was copied.
+// It returns how many things were deleted from the destination.
+func (a *S3Accessor) SyncBatchJobArtifacts(ctx context.Context, plan *SyncPlan) (int, error) {
+	if err := a.forEach(ctx, len(plan.toCopy), func(ctx context.Context, i int) error {
+		if err := plan.dest.copy(ctx, a, plan.toCopy[i]); err != nil {
+			return err
+		}
+		a.progressMu.Lock()
+		defer a.progressMu.Unlock()
+		a.progressFunc()
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	if len(plan.toDelete) == 0 {
+		return 0, nil
+	}
+	return plan.dest.remove(ctx, plan.toDelete)
+}
+
+// localDestination syncs artifacts to a local directory.
+type localDestination string
+
+// LocalDestination returns a SyncDestination for syncing to the
+// directory dir, which is created if need be.
+func LocalDestination(dir string) SyncDestination {
+	return localDestination(dir)
+}
+
+func (d localDestination) String() string { return string(d) }
+
+func (d localDestination) dir() string { return string(d) }
+
+func (d localDestination) list(ctx context.Context) (map[string]destObject, error) {
+	existing := map[string]destObject{}
+	err := filepath.Walk(string(d), func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == string(d) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(string(d), p)
+		if err != nil {
+			return err
+		}
+		existing[filepath.ToSlash(rel)] = destObject{size: fi.Size()}
+		return nil
+	})
+	return existing, errors.Wrapf(err, "failed listing %s", d)
+}
+
+func (d localDestination) done(ctx context.Context, a *S3Accessor, dl *downloadLocation, existing map[string]destObject, verify bool) (bool, error) {
+	obj, ok := existing[dl.path]
+	if !ok || obj.size != dl.size {
+		return false, nil
+	}
+	return a.localDone(ctx, dl, verify)
+}
+
+func (d localDestination) copy(ctx context.Context, a *S3Accessor, dl *downloadLocation) error {
+	return a.downloadArtifact(ctx, dl)
+}
+
+func (d localDestination) remove(_ context.Context, paths []string) (int, error) {
+	n := 0
+	for _, p := range paths {
+		if err := os.Remove(d.location(p)); err != nil && !os.IsNotExist(err) {
+			return n, errors.Wrapf(err, "failed removing %s after removing %d of %d files", d.location(p), n, len(paths))
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (d localDestination) location(p string) string {
+	return filepath.Join(string(d), filepath.FromSlash(p))
+}
+
+// maxSinglePartCopy is the largest object copied to an S3 destination
+// in a single part, which needs holding in memory, so that its ETag
+// matches its source's.
+const maxSinglePartCopy = 64 << 20
+
+// sourceETagKey is the user metadata key an artifact's ETag is kept
+// under when copied to an S3 destination, to compare against when its
+// own ETag differs, e.g. because it was uploaded in different parts.
+const sourceETagKey = "Rda-Source-Etag"
+
+// s3Destination syncs artifacts to an S3 location, which is accessed
+// with its own AWS credentials.
+type s3Destination struct {
+	bucket, prefix string
+	svc            s3iface.S3API
+	uploader       s3manageriface.UploaderAPI
+}
+
+// NewS3Destination returns a SyncDestination for syncing to the S3
+// location given as an s3://bucket/prefix URL, accessed via p, e.g. an
+// AWS session.Session with credentials for it.
+func NewS3Destination(p client.ConfigProvider, url string, cfgs ...*aws.Config) (SyncDestination, error) {
+	if !strings.HasPrefix(url, "s3://") {
+		return nil, errors.Errorf("%q is not an s3://bucket/prefix URL", url)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(url, "s3://"), "/", 2)
+	if parts[0] == "" {
+		return nil, errors.Errorf("%q has no bucket", url)
+	}
+	d := s3Destination{bucket: parts[0]}
+	if len(parts) == 2 {
+		d.prefix = strings.Trim(parts[1], "/")
+	}
+	svc := s3.New(p, cfgs...)
+	d.svc, d.uploader = svc, s3manager.NewUploaderWithClient(svc)
+	return &d, nil
+}
+
+func (d *s3Destination) String() string {
+	return d.location("")
+}
+
+func (d *s3Destination) dir() string { return "" }
+
+func (d *s3Destination) key(p string) string {
+	if d.prefix == "" {
+		return p
+	}
+	return d.prefix + "/" + p
+}
+
+func (d *s3Destination) list(ctx context.Context) (map[string]destObject, error) {
+	prefix := ""
+	if d.prefix != "" {
+		prefix = d.prefix + "/"
+	}
+	existing := map[string]destObject{}
+	if err := d.svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(d.bucket),
+		Prefix: aws.String(prefix),
+	}, func(p *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, o := range p.Contents {
+			existing[strings.TrimPrefix(aws.StringValue(o.Key), prefix)] = destObject{
+				size: aws.Int64Value(o.Size),
+				etag: strings.Trim(aws.StringValue(o.ETag), `"`),
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, errors.Wrapf(err, "failed listing %s", d)
+	}
+	return existing, nil
+}
+
+func (d *s3Destination) done(ctx context.Context, _ *S3Accessor, dl *downloadLocation, existing map[string]destObject, _ bool) (bool, error) {
+	obj, ok := existing[dl.path]
+	if !ok || obj.size != dl.size {
+		return false, nil
+	}
+	if obj.etag == dl.etag {
+		return true, nil
+	}
+
+	// Fall back on the ETag we recorded when copying it.
+	out, err := d.svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(d.bucket), Key: aws.String(d.key(dl.path))})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed fetching the details of %s", d.location(dl.path))
+	}
+	for k, v := range out.Metadata {
+		if strings.EqualFold(k, sourceETagKey) {
+			return aws.StringValue(v) == dl.etag, nil
+		}
+	}
+	return false, nil
+}
+
+func (d *s3Destination) copy(ctx context.Context, a *S3Accessor, dl *downloadLocation) error {
+	// Only copy the version of the object we headed.
+	if err := a.headArtifact(ctx, dl); err != nil {
+		return err
+	}
+	obj := *dl.object
+	if dl.etag != "" {
+		obj.IfMatch = aws.String(`"` + dl.etag + `"`)
+	}
+	out, err := a.svc.GetObjectWithContext(ctx, &obj)
+	if err != nil {
+		return errors.Wrapf(err, "failed reading s3://%s/%s", aws.StringValue(obj.Bucket), aws.StringValue(obj.Key))
+	}
+	defer out.Body.Close()
+
+	// Upload in the same parts as the source where we can, so the
+	// copy's ETag matches.
+	var partSize int64
+	switch {
+	case dl.partSize >= s3manager.MinUploadPartSize:
+		partSize = dl.partSize
+	case dl.partSize == 0 && dl.size < maxSinglePartCopy:
+		partSize = dl.size + 1
+		if partSize < s3manager.MinUploadPartSize {
+			partSize = s3manager.MinUploadPartSize
+		}
+	}
+
+	if _, err := d.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(d.bucket),
+		Key:         aws.String(d.key(dl.path)),
+		Body:        out.Body,
+		ContentType: out.ContentType,
+		Metadata:    map[string]*string{sourceETagKey: aws.String(dl.etag)},
+	}, func(u *s3manager.Uploader) {
+		if partSize > 0 {
+			u.PartSize = partSize
+		}
+	}); err != nil {
+		return errors.Wrapf(err, "failed copying s3://%s/%s to %s", aws.StringValue(obj.Bucket), aws.StringValue(obj.Key), d.location(dl.path))
+	}
+	return nil
+}
+
+func (d *s3Destination) remove(ctx context.Context, paths []string) (int, error) {
+	keys := make([]string, len(paths))
+	for i, p := range paths {
+		keys[i] = d.key(p)
+	}
+	return deleteObjects(ctx, d.svc, d.bucket, keys)
+}
+
+func (d *s3Destination) location(p string) string {
+	return fmt.Sprintf("s3://%s/%s", d.bucket, d.key(p))
+}
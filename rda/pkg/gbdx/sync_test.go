@@ -0,0 +1,213 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gbdx
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// syncSource returns a bucket holding the artifacts of job "jobid".
+func syncSource() *fakeBucket {
+	return &fakeBucket{objects: map[string][]byte{
+		"prefix/rda/jobid/1/0/0.tif": []byte("zoom 1 tile 0,0"),
+		"prefix/rda/jobid/1/0/1.tif": []byte("zoom 1 tile 0,1"),
+		"prefix/rda/jobid/1/1/0.tif": []byte("zoom 1 tile 1,0"),
+		"prefix/rda/jobid/2/0/0.tif": []byte("zoom 2 tile 0,0"),
+	}}
+}
+
+func planPaths(plan *SyncPlan) []string {
+	paths := []string{}
+	for _, a := range plan.Copy {
+		paths = append(paths, a.Path)
+	}
+	return paths
+}
+
+func TestSyncToS3(t *testing.T) {
+	src := syncSource()
+	accessor := src.accessor(2)
+
+	// The destination stores objects as though uploaded in parts,
+	// so their ETags never match the source's.
+	dst := &fakeBucket{objects: map[string][]byte{
+		"project/out/1/0/0.tif": []byte("zoom 1 tile 0,0"), // Copied elsewhere, so no source ETag.
+		"project/out/1/0/1.tif": []byte("stale tile 0,1!"), // Same size, different contents.
+		"project/out/9/9/9.tif": []byte("extraneous"),
+		"project/other.txt":     []byte("not ours"),
+	}, partSize: 4}
+	dest := &s3Destination{bucket: "project-bucket", prefix: "project/out", svc: dst.s3(), uploader: dst.uploader()}
+	m := dst.s3()
+	m.delObjects = dst.deleter(0)
+	dest.svc = m
+
+	plan, err := accessor.PlanSync(context.Background(), "jobid", dest, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.heads != 0 {
+		t.Fatalf("expected planning not to head any artifacts, but got %d heads", src.heads)
+	}
+	if exp := []string{"1/0/0.tif", "1/0/1.tif", "1/1/0.tif", "2/0/0.tif"}; !reflect.DeepEqual(planPaths(plan), exp) {
+		t.Fatalf("planned copying %v, expected %v", planPaths(plan), exp)
+	}
+	if exp := []string{"s3://project-bucket/project/out/9/9/9.tif"}; !reflect.DeepEqual(plan.Delete, exp) {
+		t.Fatalf("planned deleting %v, expected %v", plan.Delete, exp)
+	}
+	if plan.Copy[0].File != "s3://project-bucket/project/out/1/0/0.tif" {
+		t.Fatalf("unexpected destination %s", plan.Copy[0].File)
+	}
+
+	numDel, err := accessor.SyncBatchJobArtifacts(context.Background(), plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numDel != 1 {
+		t.Fatalf("expected 1 object deleted, but got %d", numDel)
+	}
+	for key, data := range src.objects {
+		dstKey := "project/out/" + key[len("prefix/rda/jobid/"):]
+		if got := dst.objects[dstKey]; string(got) != string(data) {
+			t.Fatalf("%s holds %q, expected %q", dstKey, got, data)
+		}
+	}
+	if _, ok := dst.objects["project/other.txt"]; !ok {
+		t.Fatal("expected objects outside the destination prefix to be left alone")
+	}
+
+	// Everything is now in sync, which is known from the source
+	// ETags recorded on the copies.
+	plan, err = accessor.PlanSync(context.Background(), "jobid", dest, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Copy) != 0 || len(plan.Delete) != 0 {
+		t.Fatalf("expected nothing to sync, but planned copying %v and deleting %v", planPaths(plan), plan.Delete)
+	}
+
+	// Changing an artifact gets it copied again.
+	src.objects["prefix/rda/jobid/2/0/0.tif"] = []byte("zoom 2 tile 0,0, redone")
+	plan, err = accessor.PlanSync(context.Background(), "jobid", dest, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"2/0/0.tif"}; !reflect.DeepEqual(planPaths(plan), exp) {
+		t.Fatalf("planned copying %v, expected %v", planPaths(plan), exp)
+	}
+}
+
+func TestSyncToLocal(t *testing.T) {
+	src := syncSource()
+	accessor := src.accessor(2)
+
+	tmpDir, err := ioutil.TempDir("", "TestSyncToLocal-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Start with stale artifacts, one the same size as the original,
+	// and something extraneous.
+	for name, data := range map[string]string{"1/0/0.tif": "zoom 1 tile 0,0", "1/1/0.tif": "truncated", "2/0/0.tif": "stale tile 0,0!", "notes.txt": "extraneous"} {
+		file := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0775); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only sizes are compared unless we verify.
+	dest := LocalDestination(tmpDir)
+	plan, err := accessor.PlanSync(context.Background(), "jobid", dest, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"1/0/1.tif", "1/1/0.tif"}; !reflect.DeepEqual(planPaths(plan), exp) {
+		t.Fatalf("planned copying %v without verifying, expected %v", planPaths(plan), exp)
+	}
+	if src.heads != 0 {
+		t.Fatalf("expected planning without verifying not to head any artifacts, but got %d heads", src.heads)
+	}
+
+	plan, err = accessor.PlanSync(context.Background(), "jobid", dest, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"1/0/1.tif", "1/1/0.tif", "2/0/0.tif"}; !reflect.DeepEqual(planPaths(plan), exp) {
+		t.Fatalf("planned copying %v, expected %v", planPaths(plan), exp)
+	}
+	if exp := []string{filepath.Join(tmpDir, "notes.txt")}; !reflect.DeepEqual(plan.Delete, exp) {
+		t.Fatalf("planned deleting %v, expected %v", plan.Delete, exp)
+	}
+
+	if _, err := accessor.SyncBatchJobArtifacts(context.Background(), plan); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "notes.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected notes.txt to be deleted, err: %v", err)
+	}
+	for key, data := range src.objects {
+		file := filepath.Join(tmpDir, filepath.FromSlash(key[len("prefix/rda/jobid/"):]))
+		got, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(data) {
+			t.Fatalf("%s holds %q, expected %q", file, got, data)
+		}
+	}
+}
+
+func TestNewS3Destination(t *testing.T) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String("us-east-1"), Credentials: credentials.NewStaticCredentials("id", "secret", "")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for url, exp := range map[string]string{
+		"s3://bucket":              "s3://bucket/",
+		"s3://bucket/":             "s3://bucket/",
+		"s3://bucket/some/prefix/": "s3://bucket/some/prefix/",
+		"bucket/prefix":            "",
+		"s3:///prefix":             "",
+	} {
+		dest, err := NewS3Destination(sess, url)
+		switch {
+		case exp == "" && err == nil:
+			t.Errorf("expected an error for %q", url)
+		case exp != "" && err != nil:
+			t.Errorf("unexpected error for %q: %v", url, err)
+		case exp != "" && fmt.Sprint(dest) != exp:
+			t.Errorf("%q parsed as %s, expected %s", url, dest, exp)
+		}
+	}
+}
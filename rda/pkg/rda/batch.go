@@ -22,6 +22,8 @@ package rda
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"runtime"
@@ -73,6 +75,33 @@ func (b BatchFormat) String() string {
 	}
 }
 
+// CheckMetadata returns an error if the imagery described by md can't
+// be batch materialized in format b.  All formats need georeferenced
+// imagery, TMS tile stacks need 8 bit imagery with 1, 3, or 4 bands to
+// render tiles from, and the vector formats need a single band binary
+// image to trace features from.
+func (b BatchFormat) CheckMetadata(md *Metadata) error {
+	if md.ImageGeoreferencing.SpatialReferenceSystemCode == "" {
+		return errors.New("rda batch materialization requires georeferenced imagery, but we found no EPSG code")
+	}
+
+	im := md.ImageMetadata
+	switch b {
+	case TMS:
+		if im.DataType != "BYTE" {
+			return errors.Errorf("%s output requires BYTE imagery, but the image is %s; consider applying a DRA", b, im.DataType)
+		}
+		if im.NumBands != 1 && im.NumBands != 3 && im.NumBands != 4 {
+			return errors.Errorf("%s output requires 1, 3, or 4 bands, but the image has %d", b, im.NumBands)
+		}
+	case Vector, VectorTile:
+		if im.NumBands != 1 || im.DataType != "BYTE" {
+			return errors.Errorf("%s output requires a single band binary (BYTE) image, but the image has %d %s band(s)", b, im.NumBands, im.DataType)
+		}
+	}
+	return nil
+}
+
 // MarshalText writes BatchFormat in JSON that RDA expects.
 func (b BatchFormat) MarshalText() ([]byte, error) {
 	return []byte(b.String()), nil
@@ -110,6 +139,64 @@ type BatchRequest struct {
 	EmailAddress    string            `json:"emailAddress,omitempty"`
 }
 
+// BatchOption sets optional fields of a BatchRequest.
+type BatchOption func(*BatchRequest)
+
+// WithFormatOption sets the output format option key to val, e.g.
+// the zoom levels of a TMS tile stack.  What options are understood
+// depends on the output format.
+func WithFormatOption(key, val string) BatchOption {
+	return func(r *BatchRequest) {
+		if r.FormatOptions == nil {
+			r.FormatOptions = make(map[string]string)
+		}
+		r.FormatOptions[key] = val
+	}
+}
+
+// WithCallbackURL has RDA POST to url when the batch job completes.
+func WithCallbackURL(url string) BatchOption {
+	return func(r *BatchRequest) {
+		r.CallbackURL = url
+	}
+}
+
+// WithAccountID sets the GBDX account the batch job is charged to.
+func WithAccountID(id string) BatchOption {
+	return func(r *BatchRequest) {
+		r.AccountID = id
+	}
+}
+
+// WithEmailAddress has RDA email addr when the batch job completes.
+func WithEmailAddress(addr string) BatchOption {
+	return func(r *BatchRequest) {
+		r.EmailAddress = addr
+	}
+}
+
+// WithCropGeometry crops the batch job's output to the geometry
+// described by wkt, given in the image's coordinate reference system,
+// in place of any window the template was given.
+func WithCropGeometry(wkt string) BatchOption {
+	return func(r *BatchRequest) {
+		r.CropGeometryWKT = wkt
+	}
+}
+
+// Hash returns a hex encoded SHA-256 digest of the request as sent to
+// RDA, which identifies requests for the same output.
+func (r *BatchRequest) Hash() (string, error) {
+	// Maps are encoded with their keys sorted, so equal requests
+	// encode identically.
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", errors.Wrap(err, "failed encoding batch materialization request")
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 // ImageReference hold the portion of RDA's batch materialization POST
 // describing the template we're trying to render.
 type ImageReference struct {
@@ -143,11 +230,31 @@ func (et EpochTime) String() string {
 	return time.Time(et).String()
 }
 
-// UnmarshalJSON lets us unmarshal a unix time stamped field from RDA as a EpochTime
+// epochTimeLayout is how EpochTime marshals itself, i.e. time.Time's String format.
+const epochTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// UnmarshalJSON lets us unmarshal a unix time stamped field from RDA
+// as a EpochTime.  It also accepts the text written by MarshalText, so
+// that we can read back what we've written.
 func (et *EpochTime) UnmarshalJSON(b []byte) (err error) {
 	if len(b) == 0 || string(b) == "null" {
 		return nil
 	}
+	if b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return errors.Wrap(err, "couldn't unmarshal epoch time")
+		}
+		if s == "" {
+			return nil
+		}
+		t, err := time.Parse(epochTimeLayout, s)
+		if err != nil {
+			return errors.Wrap(err, "couldn't unmarshal epoch time")
+		}
+		*et = EpochTime(t)
+		return nil
+	}
 	epoch, err := strconv.Atoi(string(b))
 	if err != nil {
 		return errors.Wrap(err, "couldn't unmarshal epoch time")
@@ -182,11 +289,28 @@ func (et EpochDuration) MarshalText() ([]byte, error) {
 	return []byte(t.String()), nil
 }
 
-// UnmarshalJSON lets us unmarshal a unix time stamped field from RDA as a EpochTime
+// UnmarshalJSON lets us unmarshal a duration in milliseconds from RDA
+// as a EpochDuration.  It also accepts the text written by
+// MarshalText, so that we can read back what we've written.
 func (et *EpochDuration) UnmarshalJSON(b []byte) (err error) {
 	if len(b) == 0 || string(b) == "null" {
 		return nil
 	}
+	if b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return errors.Wrap(err, "couldn't unmarshal epoch duration")
+		}
+		if s == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return errors.Wrap(err, "couldn't unmarshal epoch duration")
+		}
+		*et = EpochDuration(d)
+		return nil
+	}
 	dur, err := strconv.Atoi(string(b))
 	if err != nil {
 		return errors.Wrap(err, "couldn't unmarshal epoch duration")
@@ -202,6 +326,13 @@ type batchStatusResponse struct {
 
 // FetchBatchStatus returns the status of RDA batch materialization jobs.
 func FetchBatchStatus(ctx context.Context, client *retryablehttp.Client, jobIDs ...string) ([]*BatchResponse, error) {
+	return NewClient(client, nil).FetchBatchStatus(ctx, jobIDs...)
+}
+
+// FetchBatchStatus returns the status of RDA batch materialization
+// jobs.  If some statuses couldn't be fetched, those that could are
+// returned along with an error describing the rest.
+func (c *Client) FetchBatchStatus(ctx context.Context, jobIDs ...string) ([]*BatchResponse, error) {
 
 	numParallel := 4 * runtime.NumCPU()
 	if len(jobIDs) < numParallel {
@@ -229,7 +360,7 @@ func FetchBatchStatus(ctx context.Context, client *retryablehttp.Client, jobIDs
 		go func(jobIDsIn <-chan string, jobsOut chan<- *batchStatusResponse) {
 			defer wg.Done()
 			for jobID := range jobIDsIn {
-				resp, err := batchStatusJob(ctx, client, jobID)
+				resp, err := c.batchStatusJob(ctx, jobID)
 				jobsOut <- &batchStatusResponse{resp: resp, err: err}
 			}
 		}(jobIDsIn, jobsOut)
@@ -246,26 +377,26 @@ func FetchBatchStatus(ctx context.Context, client *retryablehttp.Client, jobIDs
 	var jobserr *rdaErrors
 	for jobResp := range jobsOut {
 		if jobResp.err != nil {
-			jobserr.addError(jobResp.err)
+			jobserr = jobserr.addError(jobResp.err)
 		} else {
 			jobs = append(jobs, jobResp.resp)
 		}
 	}
 	if jobserr != nil {
-		return nil, jobserr
+		return jobs, jobserr
 	}
 	return jobs, nil
 }
 
-func batchStatusJob(ctx context.Context, client *retryablehttp.Client, jobID string) (*BatchResponse, error) {
-	ep := urls.jobURL(jobID)
+func (c *Client) batchStatusJob(ctx context.Context, jobID string) (*BatchResponse, error) {
+	ep := c.urls.jobURL(jobID)
 	req, err := retryablehttp.NewRequest("GET", ep, nil)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed forming request for batch job id %s", ep)
 	}
 	req = req.WithContext(ctx)
 
-	res, err := client.Do(req)
+	res, err := c.client.Do(req)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to form GET for fetching job status")
 	}
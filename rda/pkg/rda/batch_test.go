@@ -156,6 +156,62 @@ func TestUnmarshalBatchFormatFail(t *testing.T) {
 	}
 }
 
+func TestBatchStatusRoundTrip(t *testing.T) {
+	start := EpochTime(time.Unix(0, 1550000000123*1e6))
+	end := EpochTime(time.Unix(0, 1550000090456*1e6))
+	want := BatchStatus{
+		InternalJobID: "internal",
+		Status:        "complete",
+		StartTime:     &start,
+		EndTime:       &end,
+		ElapsedTime:   EpochDuration(90333 * time.Millisecond),
+	}
+
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got BatchStatus
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("failed reading back %s: %v", b, err)
+	}
+	opt := cmp.Comparer(func(a, b EpochTime) bool { return time.Time(a).Equal(time.Time(b)) })
+	if diff := cmp.Diff(want, got, opt); diff != "" {
+		t.Fatalf("BatchStatus didn't round trip (-want +got):\n%s", diff)
+	}
+}
+
+func TestBatchFormatCheckMetadata(t *testing.T) {
+	md := func(srs, dataType string, numBands int) *Metadata {
+		m := Metadata{}
+		m.ImageGeoreferencing.SpatialReferenceSystemCode = srs
+		m.ImageMetadata.DataType = dataType
+		m.ImageMetadata.NumBands = numBands
+		return &m
+	}
+	tests := []struct {
+		format BatchFormat
+		md     *Metadata
+		ok     bool
+	}{
+		{Tif, md("EPSG:4326", "UNSIGNED_SHORT", 8), true},
+		{Tif, md("", "BYTE", 3), false},
+		{TileStream, md("EPSG:4326", "FLOAT", 4), true},
+		{TMS, md("EPSG:3857", "BYTE", 3), true},
+		{TMS, md("EPSG:3857", "UNSIGNED_SHORT", 3), false},
+		{TMS, md("EPSG:3857", "BYTE", 2), false},
+		{Vector, md("EPSG:4326", "BYTE", 1), true},
+		{Vector, md("EPSG:4326", "BYTE", 3), false},
+		{VectorTile, md("EPSG:4326", "FLOAT", 1), false},
+	}
+	for _, tc := range tests {
+		err := tc.format.CheckMetadata(tc.md)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s with %d %s bands in %q: got err = %v, want ok = %t", tc.format, tc.md.ImageMetadata.NumBands, tc.md.ImageMetadata.DataType, tc.md.ImageGeoreferencing.SpatialReferenceSystemCode, err, tc.ok)
+		}
+	}
+}
+
 func TestFetchBatchStatus(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
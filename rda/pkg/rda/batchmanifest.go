@@ -0,0 +1,501 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BatchManifestRow describes one batch materialization job listed in
+// a batch manifest.
+type BatchManifestRow struct {
+	// Row is the row's position in the manifest, counting from 1
+	// and not counting a CSV header.
+	Row int `json:"-"`
+
+	// Exactly one of CatalogID, to realize a DigitalGlobe strip, or
+	// TemplateID is given.
+	CatalogID  string `json:"catalogId,omitempty"`
+	TemplateID string `json:"templateId,omitempty"`
+	NodeID     string `json:"nodeId,omitempty"`
+
+	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// Format is the output format; if empty, a default is used.
+	Format        string            `json:"format,omitempty"`
+	FormatOptions map[string]string `json:"formatOptions,omitempty"`
+
+	// At most one of WKT, BBox (minx,miny,maxx,maxy), and GeoJSON
+	// gives the geometry to crop the output to, in the image's
+	// coordinate reference system.
+	WKT     string          `json:"wkt,omitempty"`
+	BBox    []float64       `json:"bbox,omitempty"`
+	GeoJSON json.RawMessage `json:"geojson,omitempty"`
+
+	// err is why the row couldn't be read, if it couldn't.
+	err error
+}
+
+// Validate returns an error if the row can't be submitted as is.
+func (r *BatchManifestRow) Validate() error {
+	if r.err != nil {
+		return r.err
+	}
+	switch {
+	case r.CatalogID == "" && r.TemplateID == "":
+		return errors.New("row has neither a catalogId nor a templateId")
+	case r.CatalogID != "" && r.TemplateID != "":
+		return errors.New("row has both a catalogId and a templateId")
+	case r.CatalogID != "" && r.NodeID != "":
+		return errors.New("row has a nodeId, which only applies to a templateId")
+	}
+	if r.Format != "" {
+		if _, err := r.OutputFormat(Tif); err != nil {
+			return err
+		}
+	}
+	_, err := r.CropWKT()
+	return err
+}
+
+// OutputFormat returns the row's output format, or def if it has none.
+func (r *BatchManifestRow) OutputFormat(def BatchFormat) (BatchFormat, error) {
+	if r.Format == "" {
+		return def, nil
+	}
+	var f BatchFormat
+	if err := f.UnmarshalText([]byte(r.Format)); err != nil {
+		return f, errors.Errorf("row's format %q is not one of TIF, TILE_STREAM, TMS, VECTOR, or VECTOR_TILE", r.Format)
+	}
+	return f, nil
+}
+
+// CropWKT returns the geometry to crop the row's output to as WKT,
+// or "" if it isn't to be cropped.
+func (r *BatchManifestRow) CropWKT() (string, error) {
+	given := 0
+	for _, ok := range []bool{r.WKT != "", len(r.BBox) > 0, len(r.GeoJSON) > 0 && string(r.GeoJSON) != "null"} {
+		if ok {
+			given++
+		}
+	}
+	if given > 1 {
+		return "", errors.New("row gives more than one of wkt, bbox, and geojson")
+	}
+
+	switch {
+	case r.WKT != "":
+		if _, err := ParseWKT(r.WKT); err != nil {
+			return "", errors.Wrap(err, "row's wkt is invalid")
+		}
+		return strings.TrimSpace(r.WKT), nil
+	case len(r.BBox) > 0:
+		b := r.BBox
+		if len(b) != 4 || b[0] >= b[2] || b[1] >= b[3] {
+			return "", errors.Errorf("row's bbox %v is not minx,miny,maxx,maxy", b)
+		}
+		return WKTBox{ULX: b[0], ULY: b[3], LRX: b[2], LRY: b[1]}.String(), nil
+	case given == 1:
+		g, err := ParseGeoJSON(r.GeoJSON)
+		if err != nil {
+			return "", errors.Wrap(err, "row's geojson is invalid")
+		}
+		return g.WKT(), nil
+	}
+	return "", nil
+}
+
+// ReadBatchManifest reads the rows of the batch manifest at path,
+// either a CSV file (.csv) or a JSON array of rows (.json).
+//
+// A CSV manifest has a header naming its columns.  The columns
+// catalogId, templateId, nodeId, format, wkt, bbox, and geojson are
+// read into the fields of the same name, those named format.<key> give
+// format option <key>, and any others give template parameters.  Empty
+// cells are ignored.
+//
+// Problems with individual rows are reported by their Validate
+// method rather than failing the whole manifest.
+func ReadBatchManifest(path string) ([]*BatchManifestRow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed opening batch manifest")
+	}
+	defer f.Close()
+
+	var rows []*BatchManifestRow
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		rows, err = readCSVManifest(f)
+	case ".json":
+		rows, err = readJSONManifest(f)
+	default:
+		return nil, errors.Errorf("batch manifest %s is neither a .csv nor a .json file", path)
+	}
+	return rows, errors.Wrapf(err, "failed reading batch manifest %s", path)
+}
+
+func readCSVManifest(r io.Reader) ([]*BatchManifestRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("manifest is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	var rows []*BatchManifestRow
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := BatchManifestRow{Row: len(rows) + 1}
+		if len(rec) != len(header) {
+			row.err = errors.Errorf("row has %d columns, but the header names %d", len(rec), len(header))
+		}
+		for i, val := range rec {
+			val = strings.TrimSpace(val)
+			if val == "" || i >= len(header) {
+				continue
+			}
+			col := header[i]
+			switch strings.ToLower(col) {
+			case "catalogid":
+				row.CatalogID = val
+			case "templateid":
+				row.TemplateID = val
+			case "nodeid":
+				row.NodeID = val
+			case "format":
+				row.Format = val
+			case "wkt":
+				row.WKT = val
+			case "geojson":
+				row.GeoJSON = json.RawMessage(val)
+			case "bbox":
+				for _, s := range strings.Split(val, ",") {
+					f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+					if err != nil {
+						row.err = errors.Errorf("row's bbox %q is not minx,miny,maxx,maxy", val)
+						break
+					}
+					row.BBox = append(row.BBox, f)
+				}
+			default:
+				if strings.HasPrefix(strings.ToLower(col), "format.") {
+					if row.FormatOptions == nil {
+						row.FormatOptions = make(map[string]string)
+					}
+					row.FormatOptions[col[len("format."):]] = val
+					continue
+				}
+				if row.Parameters == nil {
+					row.Parameters = make(map[string]string)
+				}
+				row.Parameters[col] = val
+			}
+		}
+		rows = append(rows, &row)
+	}
+}
+
+func readJSONManifest(r io.Reader) ([]*BatchManifestRow, error) {
+	// Decode rows one at a time, so that one bad row doesn't
+	// prevent submitting the rest.
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, errors.Wrap(err, "manifest is not a JSON array of rows")
+	}
+	rows := make([]*BatchManifestRow, len(raw))
+	for i, b := range raw {
+		row := BatchManifestRow{}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row); err != nil {
+			row = BatchManifestRow{err: errors.Wrap(err, "failed decoding row")}
+		}
+		row.Row = i + 1
+		rows[i] = &row
+	}
+	return rows, nil
+}
+
+// BatchSubmission records the outcome of submitting a row of a batch
+// manifest.
+type BatchSubmission struct {
+	// Row is the row's position in the manifest when it was last
+	// submitted or skipped, or 0 for a request that was submitted
+	// but is no longer at any row.
+	Row int `json:"row"`
+
+	// RequestHash is the Hash of the batch materialization request
+	// made for the row.
+	RequestHash string `json:"requestHash,omitempty"`
+
+	// JobID is set if the request was submitted, in which case
+	// SubmittedAt is when, otherwise Error says why it wasn't.
+	JobID       string     `json:"jobId,omitempty"`
+	SubmittedAt *time.Time `json:"submittedAt,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// BatchResults records the outcomes of submitting the rows of a batch
+// manifest to a file, one JSON encoded BatchSubmission per line.  Each
+// outcome is appended as soon as it's known, so that an interrupted
+// run loses nothing, and requests already submitted can be found by
+// their hash when the manifest is submitted again, even if its rows
+// have since been reordered, inserted, or removed.
+type BatchResults struct {
+	path string
+	f    *os.File
+
+	mu     sync.Mutex
+	byRow  map[int]BatchSubmission
+	byHash map[string]BatchSubmission
+
+	// added holds the rows added since the results were opened.
+	added map[int]bool
+
+	// pending holds a channel for each request hash reserved but
+	// not yet added, which is closed once it is.
+	pending map[string]chan struct{}
+}
+
+// OpenBatchResults opens the results file at path, reading any
+// results already recorded there.  The file is created when the first
+// result is added.
+func OpenBatchResults(path string) (*BatchResults, error) {
+	r := BatchResults{
+		path:    path,
+		byRow:   make(map[int]BatchSubmission),
+		byHash:  make(map[string]BatchSubmission),
+		added:   make(map[int]bool),
+		pending: make(map[string]chan struct{}),
+	}
+	if f, err := os.Open(path); err == nil {
+		err = r.read(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading batch results %s", path)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed opening batch results")
+	}
+	return &r, nil
+}
+
+func (r *BatchResults) read(rd io.Reader) error {
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var s BatchSubmission
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			return errors.Wrapf(err, "line %d is not a batch submission", line)
+		}
+		r.index(s)
+	}
+	return sc.Err()
+}
+
+func (r *BatchResults) index(s BatchSubmission) {
+	if s.Row > 0 {
+		r.byRow[s.Row] = s
+	}
+	if s.JobID != "" && s.RequestHash != "" {
+		r.byHash[s.RequestHash] = s
+	}
+}
+
+// Submitted returns the recorded submission of the request with the
+// given hash, if it has been submitted.
+func (r *BatchResults) Submitted(hash string) (BatchSubmission, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.byHash[hash]
+	return s, ok
+}
+
+// Reserve returns the recorded submission of the request with the
+// given hash if it has been submitted, as Submitted does.  If it
+// hasn't, the caller is reserved to submit it, and must Add its
+// outcome; until then, others reserving the same hash wait, so that
+// rows with the same request aren't submitted twice.  An error is
+// returned if ctx is done while waiting.
+func (r *BatchResults) Reserve(ctx context.Context, hash string) (BatchSubmission, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		if s, ok := r.byHash[hash]; ok {
+			return s, true, nil
+		}
+		wait, ok := r.pending[hash]
+		if !ok {
+			r.pending[hash] = make(chan struct{})
+			return BatchSubmission{}, false, nil
+		}
+
+		r.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+		}
+		r.mu.Lock()
+		if ctx.Err() != nil {
+			return BatchSubmission{}, false, ctx.Err()
+		}
+	}
+}
+
+// Add records s, replacing any earlier outcome for its row, and
+// releasing any reservation of its request hash.  Successful
+// submissions are kept, by their request hash, even once no row
+// refers to them.
+func (r *BatchResults) Add(s BatchSubmission) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "failed encoding batch submission")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.index(s)
+	if s.Row > 0 {
+		r.added[s.Row] = true
+	}
+	if wait, ok := r.pending[s.RequestHash]; ok {
+		close(wait)
+		delete(r.pending, s.RequestHash)
+	}
+	if r.f == nil {
+		f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return errors.Wrap(err, "failed opening batch results")
+		}
+		r.f = f
+	}
+	if _, err := r.f.Write(append(b, '\n')); err != nil {
+		return errors.Wrapf(err, "failed recording the outcome of row %d", s.Row)
+	}
+	return nil
+}
+
+// Results returns the latest outcome recorded for each row, in row
+// order.
+func (r *BatchResults) Results() []BatchSubmission {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subs := make([]BatchSubmission, 0, len(r.byRow))
+	for _, s := range r.byRow {
+		subs = append(subs, s)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Row < subs[j].Row })
+	return subs
+}
+
+// Close closes the results file, rewriting it to hold just the latest
+// outcome of each row added since it was opened, followed by any
+// successful submissions no such row refers to, with Row 0.  Rows
+// recorded by earlier runs but not added since, e.g. those past the
+// end of a manifest that has shrunk, are dropped.  Nothing is written
+// if no results were added.
+func (r *BatchResults) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	if err := r.f.Close(); err != nil {
+		return errors.Wrap(err, "failed closing batch results")
+	}
+
+	r.mu.Lock()
+	subs := make([]BatchSubmission, 0, len(r.added))
+	for row := range r.added {
+		subs = append(subs, r.byRow[row])
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Row < subs[j].Row })
+	referenced := make(map[string]bool, len(subs))
+	for _, s := range subs {
+		if s.JobID != "" {
+			referenced[s.RequestHash] = true
+		}
+	}
+	var orphans []BatchSubmission
+	for hash, s := range r.byHash {
+		if !referenced[hash] {
+			s.Row = 0
+			orphans = append(orphans, s)
+		}
+	}
+	r.mu.Unlock()
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].RequestHash < orphans[j].RequestHash })
+
+	var buf bytes.Buffer
+	for _, s := range append(subs, orphans...) {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return errors.Wrap(err, "failed encoding batch submission")
+		}
+		buf.Write(append(b, '\n'))
+	}
+
+	// Write then rename, so the results are never left partial.
+	f, err := ioutil.TempFile(filepath.Dir(r.path), filepath.Base(r.path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed rewriting batch results")
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return errors.Wrap(err, "failed rewriting batch results")
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return errors.Wrap(err, "failed rewriting batch results")
+	}
+	return errors.Wrap(os.Rename(f.Name(), r.path), "failed rewriting batch results")
+}
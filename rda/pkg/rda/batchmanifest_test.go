@@ -0,0 +1,292 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestReadBatchManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batchmanifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	csvManifest := `catalogId,templateId,nodeId,format,format.minZoom,bbox,geojson,draType
+103001000EBC3C00,,,TMS,10,"0,0,100,50",,HistogramDRA
+,tID,nID,,,,"{""type"": ""Polygon"", ""coordinates"": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}",
+,,,,,,,
+103001000EBC3C00,,,JPEG,,,,
+103001000EBC3C00,,,,,"0,0,x,50",,
+103001000EBC3C00,tID,,,,,,
+`
+	jsonManifest := `[
+	{"catalogId": "103001000EBC3C00", "format": "TMS", "formatOptions": {"minZoom": "10"}, "bbox": [0, 0, 100, 50], "parameters": {"draType": "HistogramDRA"}},
+	{"templateId": "tID", "nodeId": "nID", "geojson": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}},
+	{},
+	{"catalogId": "103001000EBC3C00", "format": "JPEG"},
+	{"catalogId": "103001000EBC3C00", "bbox": "0,0,x,50"},
+	{"catalogId": "103001000EBC3C00", "templateId": "tID"}
+]`
+
+	want := []struct {
+		catalogID, templateID string
+		format                BatchFormat
+		formatOptions         map[string]string
+		parameters            map[string]string
+		wkt                   string
+		wantErr               bool
+	}{
+		{"103001000EBC3C00", "", TMS, map[string]string{"minZoom": "10"}, map[string]string{"draType": "HistogramDRA"}, WKTBox{ULX: 0, ULY: 50, LRX: 100, LRY: 0}.String(), false},
+		{"", "tID", Tif, nil, nil, "POLYGON ((0 0, 1 0, 1 1, 0 0))", false},
+		{wantErr: true}, // Neither a catalog nor a template.
+		{wantErr: true}, // Unknown format.
+		{wantErr: true}, // Bad bbox.
+		{wantErr: true}, // Both a catalog and a template.
+	}
+
+	for name, contents := range map[string]string{"m.csv": csvManifest, "m.json": jsonManifest} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			rows, err := ReadBatchManifest(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(want) {
+				t.Fatalf("got %d rows, want %d", len(rows), len(want))
+			}
+			for i, row := range rows {
+				w := want[i]
+				if row.Row != i+1 {
+					t.Errorf("row %d is numbered %d", i+1, row.Row)
+				}
+				err := row.Validate()
+				if (err != nil) != w.wantErr {
+					t.Errorf("row %d: got err = %v, want an error: %t", i+1, err, w.wantErr)
+				}
+				if err != nil || w.wantErr {
+					continue
+				}
+
+				format, err := row.OutputFormat(Tif)
+				if err != nil {
+					t.Fatal(err)
+				}
+				wkt, err := row.CropWKT()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if row.CatalogID != w.catalogID || row.TemplateID != w.templateID || format != w.format || wkt != w.wkt {
+					t.Errorf("row %d: got %s/%s/%s/%q, want %s/%s/%s/%q", i+1, row.CatalogID, row.TemplateID, format, wkt, w.catalogID, w.templateID, w.format, w.wkt)
+				}
+				if diff := cmp.Diff(w.formatOptions, row.FormatOptions); diff != "" {
+					t.Errorf("row %d format options: %s", i+1, diff)
+				}
+				if diff := cmp.Diff(w.parameters, row.Parameters); diff != "" {
+					t.Errorf("row %d parameters: %s", i+1, diff)
+				}
+			}
+		})
+	}
+
+	if _, err := ReadBatchManifest(filepath.Join(dir, "m.txt")); err == nil {
+		t.Error("expected an error reading a manifest that's neither CSV nor JSON")
+	}
+}
+
+func TestBatchResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batchresults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.jsonl")
+
+	// Nothing is written until a result is.
+	r, err := OpenBatchResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no results file, got err = %v", err)
+	}
+
+	now := time.Now()
+	first := []BatchSubmission{
+		{Row: 1, RequestHash: "h1", JobID: "job1", SubmittedAt: &now},
+		{Row: 2, RequestHash: "h2", Error: "boom"},
+		{Row: 3, RequestHash: "h3", JobID: "job3", SubmittedAt: &now},
+	}
+	r, err = OpenBatchResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range first {
+		if err := r.Add(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Simulate an interruption by not closing r; every result
+	// added should already be on disk.
+	r.f.Close()
+
+	r, err = OpenBatchResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Submitted("h2"); ok {
+		t.Error("a failed request shouldn't count as submitted")
+	}
+	if s, ok := r.Submitted("h3"); !ok || s.JobID != "job3" {
+		t.Errorf("got %+v, %t for h3, want job3", s, ok)
+	}
+
+	// Row 1 is skipped, row 2 retried, and row 3 changed.
+	second := []BatchSubmission{
+		first[0],
+		{Row: 2, RequestHash: "h2", JobID: "job2", SubmittedAt: &now},
+		{Row: 3, RequestHash: "h4", JobID: "job4", SubmittedAt: &now},
+	}
+	for _, s := range second {
+		if err := r.Add(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "\n"); n != 4 {
+		t.Errorf("expected the closed results to hold a line per row and one for row 3's old job, got %d lines:\n%s", n, b)
+	}
+	r, err = OpenBatchResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(second, r.Results(), cmpopts.IgnoreFields(BatchSubmission{}, "SubmittedAt")); diff != "" {
+		t.Error(diff)
+	}
+
+	// Row 3's old request is still known to have been submitted, so
+	// restoring it doesn't submit it again.
+	if s, ok := r.Submitted("h3"); !ok || s.JobID != "job3" {
+		t.Errorf("got %+v, %t for h3 after its row changed, want job3", s, ok)
+	}
+
+	// The manifest shrinks to its first row.  The rows past its end
+	// are dropped, but their jobs are still known by hash.
+	if err := r.Add(second[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err = OpenBatchResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(second[:1], r.Results(), cmpopts.IgnoreFields(BatchSubmission{}, "SubmittedAt")); diff != "" {
+		t.Errorf("after shrinking the manifest: %s", diff)
+	}
+	for hash, job := range map[string]string{"h2": "job2", "h3": "job3", "h4": "job4"} {
+		if s, ok := r.Submitted(hash); !ok || s.JobID != job || s.Row != 0 {
+			t.Errorf("got %+v, %t for %s after shrinking the manifest, want %s with no row", s, ok, hash, job)
+		}
+	}
+}
+
+func TestBatchResultsReserve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batchresults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := OpenBatchResults(filepath.Join(dir, "results.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ctx := context.Background()
+	if _, ok, err := r.Reserve(ctx, "h1"); ok || err != nil {
+		t.Fatalf("got %t, %v reserving a new request, want it reserved", ok, err)
+	}
+
+	// Others reserving the same request wait for its outcome.
+	reserved := make(chan BatchSubmission)
+	go func() {
+		s, ok, err := r.Reserve(ctx, "h1")
+		if !ok || err != nil {
+			t.Errorf("got %t, %v reserving a submitted request, want its submission", ok, err)
+		}
+		reserved <- s
+	}()
+	select {
+	case <-reserved:
+		t.Fatal("reserving a pending request didn't wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+	now := time.Now()
+	if err := r.Add(BatchSubmission{Row: 1, RequestHash: "h1", JobID: "job1", SubmittedAt: &now}); err != nil {
+		t.Fatal(err)
+	}
+	if s := <-reserved; s.JobID != "job1" {
+		t.Errorf("got job %q for the waiting reservation, want job1", s.JobID)
+	}
+
+	// A failure frees the request for another try.
+	if _, ok, err := r.Reserve(ctx, "h2"); ok || err != nil {
+		t.Fatalf("got %t, %v reserving a new request, want it reserved", ok, err)
+	}
+	if err := r.Add(BatchSubmission{Row: 2, RequestHash: "h2", Error: "boom"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := r.Reserve(ctx, "h2"); ok || err != nil {
+		t.Fatalf("got %t, %v reserving a failed request, want it reserved again", ok, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err := r.Reserve(cancelled, "h2"); err == nil {
+		t.Error("expected waiting on a reservation to stop when the context is done")
+	}
+}
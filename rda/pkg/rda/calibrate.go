@@ -0,0 +1,252 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CalibrationType is what a Calibrator converts the DNs of a 1B image part to.
+type CalibrationType string
+
+const (
+	// Radiance is top of atmosphere spectral radiance, in W/m^2/sr/um.
+	Radiance CalibrationType = "radiance"
+
+	// Reflectance is top of atmosphere reflectance, as RDA's
+	// TOAReflectance correction of strips gives.
+	Reflectance CalibrationType = "reflectance"
+)
+
+// esun is the band averaged solar spectral irradiance, in W/m^2/um,
+// of each band of DG's satellites, keyed by the IMD's satId and band
+// name, from DG's radiometric use of imagery technical notes.
+var esun = map[string]map[string]float64{
+	"WV01": {"P": 1478.62},
+	"WV02": {"P": 1580.8140, "C": 1758.2229, "B": 1974.2416, "G": 1856.4104, "Y": 1738.4791, "R": 1559.4555, "RE": 1342.0695, "N": 1069.7302, "N2": 861.2866},
+	"WV03": {
+		"P": 1574.41, "C": 1757.89, "B": 2004.61, "G": 1830.18, "Y": 1712.07, "R": 1535.33, "RE": 1348.08, "N": 1055.94, "N2": 858.77,
+		"S1": 479.019, "S2": 263.797, "S3": 225.283, "S4": 197.552, "S5": 90.4178, "S6": 85.0642, "S7": 76.9507, "S8": 68.0988,
+	},
+	"GE01": {"P": 1617, "B": 1960, "G": 1853, "R": 1505, "N": 1039},
+	"QB02": {"P": 1381.79, "B": 1924.59, "G": 1843.08, "R": 1574.77, "N": 1113.71},
+}
+
+// EarthSunDistance returns the distance between the earth and the sun
+// at t, in astronomical units.
+func EarthSunDistance(t time.Time) float64 {
+	jd := float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5 // The Julian day.
+	g := (357.529 + 0.98560028*(jd-2451545)) * math.Pi / 180      // The sun's mean anomaly.
+	return 1.00014 - 0.01671*math.Cos(g) - 0.00014*math.Cos(2*g)
+}
+
+// Calibrator converts the DNs of a 1B image part to top of atmosphere
+// radiance or reflectance, band by band.
+type Calibrator struct {
+	// Type is what DNs are converted to.
+	Type CalibrationType
+
+	// Bands are the names of the part's bands, in the order its
+	// tiles hold them.
+	Bands []string
+
+	// Factors are what each band's DNs are multiplied by.
+	Factors []float64
+
+	numParallel  int
+	progressFunc func() int
+}
+
+// CalibratorOption sets optional parameters of a Calibrator.
+type CalibratorOption func(*Calibrator)
+
+// WithCalibrationParallel sets how many tiles are calibrated at once,
+// which defaults to the number of CPUs.
+func WithCalibrationParallel(n int) CalibratorOption {
+	return func(c *Calibrator) {
+		if n > 0 {
+			c.numParallel = n
+		}
+	}
+}
+
+// WithCalibrationProgressFunc sets progressFunc to be called every time a tile is calibrated.
+func WithCalibrationProgressFunc(progressFunc func() int) CalibratorOption {
+	return func(c *Calibrator) {
+		c.progressFunc = progressFunc
+	}
+}
+
+// NewCalibrator returns a Calibrator for the 1B image part imd
+// describes.  Radiance is DN * absCalFactor / effectiveBandwidth, and
+// reflectance is radiance * d^2 * pi / (esun * cos(sun zenith)),
+// where d is the earth sun distance when the part was collected and
+// esun the band's solar irradiance.
+func NewCalibrator(imd *IMD, ct CalibrationType, options ...CalibratorOption) (*Calibrator, error) {
+	if len(imd.Bands) == 0 {
+		return nil, errors.New("the IMD has no bands to calibrate")
+	}
+
+	var sunFactor float64
+	var irradiances map[string]float64
+	switch ct {
+	case Radiance:
+	case Reflectance:
+		var ok bool
+		if irradiances, ok = esun[imd.Image.SatID]; !ok {
+			return nil, errors.Errorf("solar irradiances of %q's bands aren't known, so its reflectance can't be computed", imd.Image.SatID)
+		}
+		if imd.Image.FirstLineTime.IsZero() {
+			return nil, errors.New("the IMD has no firstLineTime to compute the earth sun distance from")
+		}
+		if imd.Image.MeanSunEl <= 0 {
+			return nil, errors.Errorf("the IMD's meanSunEl of %g degrees has the sun below the horizon", imd.Image.MeanSunEl)
+		}
+		d := EarthSunDistance(imd.Image.FirstLineTime)
+		sunFactor = d * d * math.Pi / math.Cos((90-imd.Image.MeanSunEl)*math.Pi/180)
+	default:
+		return nil, errors.Errorf("calibration type %q is not %s or %s", ct, Radiance, Reflectance)
+	}
+
+	c := Calibrator{Type: ct, numParallel: runtime.NumCPU()}
+	for _, b := range imd.Bands {
+		if b.AbsCalFactor == 0 || b.EffectiveBandwidth == 0 {
+			return nil, errors.Errorf("band %s has no absCalFactor or effectiveBandwidth", b.Name)
+		}
+		f := b.AbsCalFactor / b.EffectiveBandwidth
+		if ct == Reflectance {
+			e, ok := irradiances[b.Name]
+			if !ok {
+				return nil, errors.Errorf("solar irradiance of %s band %s isn't known, so its reflectance can't be computed", imd.Image.SatID, b.Name)
+			}
+			f *= sunFactor / e
+		}
+		c.Bands = append(c.Bands, b.Name)
+		c.Factors = append(c.Factors, f)
+	}
+
+	for _, option := range options {
+		option(&c)
+	}
+	return &c, nil
+}
+
+// CalibrateTiles writes calibrated Float32 copies of a 1B image part's
+// tiles, which m describes, to outDir under the tiles' names.  It
+// returns the calibrated tiles along with a copy of m describing them.
+// If ctx is canceled, the tiles calibrated so far are returned.
+func (c *Calibrator) CalibrateTiles(ctx context.Context, m *Metadata, tiles []TileInfo, outDir string) (*Metadata, []TileInfo, error) {
+	if m.ImageMetadata.NumBands != len(c.Factors) {
+		return nil, nil, errors.Errorf("the image has %d bands, but its IMD describes %d", m.ImageMetadata.NumBands, len(c.Factors))
+	}
+	gdalType, err := RDAToGDALType(m.ImageMetadata.DataType)
+	if err != nil {
+		return nil, nil, err
+	}
+	pt, err := gdalPixelType(gdalType)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(outDir, 0775); err != nil {
+		return nil, nil, errors.Wrap(err, "failed creating directory for calibrated tiles")
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		done     = make([]bool, len(tiles))
+		jobs     = make(chan int)
+	)
+	for i := 0; i < c.numParallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				err := c.calibrateTile(tiles[i].FilePath, filepath.Join(outDir, filepath.Base(tiles[i].FilePath)), pt)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				done[i] = err == nil
+				mu.Unlock()
+				if err == nil && c.progressFunc != nil {
+					c.progressFunc()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range tiles {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+
+	cm := *m
+	cm.ImageMetadata.DataType = "FLOAT"
+	var out []TileInfo
+	for i, tile := range tiles {
+		if done[i] {
+			tile.FilePath = filepath.Join(outDir, filepath.Base(tile.FilePath))
+			out = append(out, tile)
+		}
+	}
+	return &cm, out, nil
+}
+
+// calibrateTile writes a calibrated Float32 copy of the tile at src,
+// whose samples are of type pt, to dst.
+func (c *Calibrator) calibrateTile(src, dst string, pt pixelType) error {
+	img, err := readTIFF(src)
+	if err != nil {
+		return err
+	}
+	if img.samplesPerPixel != len(c.Factors) {
+		return errors.Errorf("tile %s has %d bands, but %d were expected", src, img.samplesPerPixel, len(c.Factors))
+	}
+	if img.pixelType != pt {
+		return errors.Errorf("tile %s doesn't hold the image's data type", src)
+	}
+
+	f32 := pixelType{4, 3}
+	out := make([]byte, img.width*img.height*img.samplesPerPixel*f32.bytes)
+	for i, n := 0, img.width*img.height*img.samplesPerPixel; i < n; i++ {
+		f32.put(out[i*f32.bytes:], pt.get(img.pix[i*pt.bytes:])*c.Factors[i%img.samplesPerPixel])
+	}
+	return writeTIFF(dst, img.width, img.height, img.samplesPerPixel, f32, out)
+}
@@ -0,0 +1,166 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEarthSunDistance(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want float64
+	}{
+		{time.Date(2018, 1, 3, 5, 35, 0, 0, time.UTC), 0.98329},  // Perihelion.
+		{time.Date(2018, 7, 6, 16, 47, 0, 0, time.UTC), 1.01670}, // Aphelion.
+	}
+	for _, test := range tests {
+		if got := EarthSunDistance(test.t); math.Abs(got-test.want) > 1e-4 {
+			t.Errorf("EarthSunDistance(%s) = %v, want %v", test.t, got, test.want)
+		}
+	}
+}
+
+func calibrationIMD() *IMD {
+	return &IMD{
+		Bands: []IMDBand{
+			{Name: "B", AbsCalFactor: 0.01260825, EffectiveBandwidth: 0.0543},
+			{Name: "N", AbsCalFactor: 0.0122438, EffectiveBandwidth: 0.0989},
+		},
+		Image: IMDImage{
+			SatID:         "WV02",
+			FirstLineTime: time.Date(2018, 2, 28, 17, 21, 40, 0, time.UTC),
+			MeanSunEl:     45.1,
+		},
+	}
+}
+
+func TestNewCalibrator(t *testing.T) {
+	imd := calibrationIMD()
+
+	c, err := NewCalibrator(imd, Radiance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range imd.Bands {
+		if want := b.AbsCalFactor / b.EffectiveBandwidth; math.Abs(c.Factors[i]-want) > 1e-12 {
+			t.Errorf("radiance factor of band %s = %v, want %v", b.Name, c.Factors[i], want)
+		}
+	}
+
+	c, err = NewCalibrator(imd, Reflectance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := EarthSunDistance(imd.Image.FirstLineTime)
+	for i, b := range imd.Bands {
+		want := b.AbsCalFactor / b.EffectiveBandwidth * d * d * math.Pi / (esun["WV02"][b.Name] * math.Sin(45.1*math.Pi/180))
+		if math.Abs(c.Factors[i]-want) > 1e-12 {
+			t.Errorf("reflectance factor of band %s = %v, want %v", b.Name, c.Factors[i], want)
+		}
+	}
+
+	for name, mutate := range map[string]func(*IMD){
+		"unknown satellite":  func(imd *IMD) { imd.Image.SatID = "XX01" },
+		"unknown band":       func(imd *IMD) { imd.Bands[0].Name = "Q" },
+		"sun below horizon":  func(imd *IMD) { imd.Image.MeanSunEl = -3 },
+		"no collection time": func(imd *IMD) { imd.Image.FirstLineTime = time.Time{} },
+		"no absCalFactor":    func(imd *IMD) { imd.Bands[1].AbsCalFactor = 0 },
+	} {
+		imd := calibrationIMD()
+		mutate(imd)
+		if _, err := NewCalibrator(imd, Reflectance); err == nil {
+			t.Errorf("%s: expected an error calibrating to reflectance", name)
+		}
+	}
+	if _, err := NewCalibrator(calibrationIMD(), CalibrationType("dn")); err == nil {
+		t.Error("expected an error for an unknown calibration type")
+	}
+}
+
+func TestCalibrateTiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "calibrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A 2 band UInt16 tile whose samples are their index.
+	const w, h, nb = 4, 3, 2
+	u16 := pixelType{2, 1}
+	pix := make([]byte, w*h*nb*u16.bytes)
+	for i := 0; i < w*h*nb; i++ {
+		u16.put(pix[i*u16.bytes:], float64(i))
+	}
+	src := filepath.Join(dir, "tiles", "tile_0_0.tif")
+	if err := os.MkdirAll(filepath.Dir(src), 0775); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTIFF(src, w, h, nb, u16, pix); err != nil {
+		t.Fatal(err)
+	}
+
+	m := Metadata{}
+	m.ImageMetadata.NumBands = nb
+	m.ImageMetadata.DataType = "UNSIGNED_SHORT"
+	m.ImageMetadata.TileXSize, m.ImageMetadata.TileYSize = w, h
+
+	progress := 0
+	c, err := NewCalibrator(calibrationIMD(), Reflectance, WithCalibrationProgressFunc(func() int { progress++; return progress }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, tiles, err := c.CalibrateTiles(context.Background(), &m, []TileInfo{{FilePath: src}}, filepath.Join(dir, "reflectance"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.ImageMetadata.DataType != "FLOAT" || m.ImageMetadata.DataType != "UNSIGNED_SHORT" {
+		t.Errorf("calibrated metadata has data type %s and the original %s, want FLOAT and UNSIGNED_SHORT", cm.ImageMetadata.DataType, m.ImageMetadata.DataType)
+	}
+	if len(tiles) != 1 || tiles[0].FilePath != filepath.Join(dir, "reflectance", "tile_0_0.tif") || progress != 1 {
+		t.Fatalf("got calibrated tiles %+v with %d progress calls, want the one tile in the reflectance directory", tiles, progress)
+	}
+
+	img, err := readTIFF(tiles[0].FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.width != w || img.height != h || img.samplesPerPixel != nb || img.pixelType != (pixelType{4, 3}) {
+		t.Fatalf("calibrated tile is %dx%dx%d of %+v, want %dx%dx%d Float32", img.width, img.height, img.samplesPerPixel, img.pixelType, w, h, nb)
+	}
+	for i := 0; i < w*h*nb; i++ {
+		want := float64(i) * c.Factors[i%nb]
+		if got := img.pixelType.get(img.pix[i*4:]); math.Abs(got-want) > 1e-6 {
+			t.Fatalf("calibrated sample %d = %v, want %v", i, got, want)
+		}
+	}
+
+	m.ImageMetadata.NumBands = 4
+	if _, _, err := c.CalibrateTiles(context.Background(), &m, []TileInfo{{FilePath: src}}, filepath.Join(dir, "reflectance")); err == nil {
+		t.Error("expected an error calibrating an image with more bands than its IMD")
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxCallbackBytes is the largest callback body we accept.
+const maxCallbackBytes = 1 << 20
+
+// ParseBatchCallback decodes and validates the body of the callback
+// RDA makes to a batch materialization request's CallbackURL, which
+// describes the job as its status does.
+func ParseBatchCallback(r io.Reader) (*BatchResponse, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxCallbackBytes+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading callback")
+	}
+	if len(b) > maxCallbackBytes {
+		return nil, errors.Errorf("callback is larger than %d bytes", maxCallbackBytes)
+	}
+
+	var resp BatchResponse
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed decoding callback")
+	}
+	switch {
+	case resp.JobID == "":
+		return nil, errors.New("callback has no job id")
+	case strings.ContainsAny(resp.JobID, `/\`) || strings.TrimSpace(resp.JobID) != resp.JobID:
+		return nil, errors.Errorf("callback has an invalid job id %q", resp.JobID)
+	case resp.Status.Status == "":
+		return nil, errors.Errorf("callback for job %s has no status", resp.JobID)
+	}
+	return &resp, nil
+}
+
+// CallbackOption is a type to use for setting options on the handler
+// NewCallbackHandler returns.
+type CallbackOption func(*callbackHandler)
+
+// CallbackToken requires callbacks to carry token as their "token"
+// query parameter, e.g. as part of the CallbackURL given with
+// WithCallbackURL, so that only callbacks for jobs we submitted are
+// accepted.
+func CallbackToken(token string) CallbackOption {
+	return func(h *callbackHandler) {
+		h.token = token
+	}
+}
+
+// NewCallbackHandler returns an http.Handler receiving RDA's batch
+// materialization callbacks, passing each valid one to handle.  Only
+// POSTs are accepted; callbacks that don't validate get a 4xx
+// response, and those handle fails on a 500 so that they may be
+// retried.
+func NewCallbackHandler(handle func(context.Context, *BatchResponse) error, options ...CallbackOption) http.Handler {
+	h := callbackHandler{handle: handle}
+	for _, opt := range options {
+		opt(&h)
+	}
+	return &h
+}
+
+type callbackHandler struct {
+	handle func(context.Context, *BatchResponse) error
+	token  string
+}
+
+func (h *callbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "callbacks must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.token != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(h.token)) != 1 {
+		http.Error(w, "callback token missing or incorrect", http.StatusForbidden)
+		return
+	}
+
+	resp, err := ParseBatchCallback(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.handle(r.Context(), resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,86 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewCallbackHandler(t *testing.T) {
+	var got []*BatchResponse
+	failHandling := false
+	h := NewCallbackHandler(func(_ context.Context, resp *BatchResponse) error {
+		if failHandling {
+			return errors.New("failed handling")
+		}
+		got = append(got, resp)
+		return nil
+	}, CallbackToken("s3cret"))
+
+	valid := `{"jobId": "job-1", "request": {"imageReference": {"templateId": "tmpl"}, "outputFormat": "TIF"}, "status": {"jobStatus": "complete", "startTime": 1533340800000, "endTime": 1533344400000}}`
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{name: "valid", method: "POST", target: "/callback?token=s3cret", body: valid, status: http.StatusNoContent},
+		{name: "get", method: "GET", target: "/callback?token=s3cret", status: http.StatusMethodNotAllowed},
+		{name: "no token", method: "POST", target: "/callback", body: valid, status: http.StatusForbidden},
+		{name: "wrong token", method: "POST", target: "/callback?token=guess", body: valid, status: http.StatusForbidden},
+		{name: "not json", method: "POST", target: "/callback?token=s3cret", body: "job-1 done", status: http.StatusBadRequest},
+		{name: "no job id", method: "POST", target: "/callback?token=s3cret", body: `{"status": {"jobStatus": "complete"}}`, status: http.StatusBadRequest},
+		{name: "bad job id", method: "POST", target: "/callback?token=s3cret", body: `{"jobId": "../../etc", "status": {"jobStatus": "complete"}}`, status: http.StatusBadRequest},
+		{name: "no status", method: "POST", target: "/callback?token=s3cret", body: `{"jobId": "job-1"}`, status: http.StatusBadRequest},
+		{name: "too big", method: "POST", target: "/callback?token=s3cret", body: `{"jobId": "` + strings.Repeat("a", maxCallbackBytes) + `"}`, status: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+			if w.Code != tc.status {
+				t.Fatalf("got status %d, expected %d: %s", w.Code, tc.status, w.Body)
+			}
+		})
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 callback handled, got %d", len(got))
+	}
+	if got[0].JobID != "job-1" || got[0].Status.Status != "complete" || got[0].Request.ImageReference.TemplateID != "tmpl" {
+		t.Fatalf("unexpected callback %+v", got[0])
+	}
+
+	// Failing to handle a callback is a server error, so RDA may retry.
+	failHandling = true
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/callback?token=s3cret", strings.NewReader(valid)))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d, expected %d", w.Code, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+)
+
+// Client accesses the RDA API found at a particular set of Endpoints.
+// The package level functions that take a *retryablehttp.Client are
+// equivalent to using a Client configured with the production RDA
+// endpoints.
+type Client struct {
+	client *retryablehttp.Client
+	urls   *Endpoints
+
+	templateOptions []TemplateOption
+}
+
+// ClientOption sets options on a Client.
+type ClientOption func(*Client)
+
+// WithTemplateOptions sets options applied to every Template created
+// through the client, before any passed to NewTemplate.
+func WithTemplateOptions(options ...TemplateOption) ClientOption {
+	return func(c *Client) {
+		c.templateOptions = append(c.templateOptions, options...)
+	}
+}
+
+// NewClient returns a Client that makes requests with client against
+// the RDA API located at endpoints.  If endpoints is nil, the
+// production RDA API is used.
+func NewClient(client *retryablehttp.Client, endpoints *Endpoints, options ...ClientOption) *Client {
+	if endpoints == nil {
+		endpoints = &urls
+	}
+	c := &Client{client: client, urls: endpoints}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c
+}
+
+// Endpoints returns the RDA endpoints this client makes requests against.
+func (c *Client) Endpoints() *Endpoints {
+	return c.urls
+}
+
+// NewTemplate returns a Template that is accessed through this client.
+func (c *Client) NewTemplate(templateID string, options ...TemplateOption) *Template {
+	opts := append([]TemplateOption{WithEndpoints(c.urls)}, c.templateOptions...)
+	return NewTemplate(templateID, c.client, append(opts, options...)...)
+}
@@ -0,0 +1,99 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+)
+
+func TestNewEndpoints(t *testing.T) {
+	tests := []struct {
+		base  string
+		valid bool
+	}{
+		{DefaultBaseURL, true},
+		{"http://localhost:8080/v1", true},
+		{"rda.geobigdata.io/v1", false},
+		{"/v1", false},
+		{"://bad", false},
+	}
+
+	for _, tc := range tests {
+		_, err := NewEndpoints(tc.base)
+		if tc.valid && err != nil {
+			t.Fatalf("expected %q to be a valid base url, err: %v", tc.base, err)
+		} else if !tc.valid && err == nil {
+			t.Fatalf("expected %q to be rejected as a base url", tc.base)
+		}
+	}
+}
+
+// TestClientEndpoints checks that a Client sends its requests to its
+// own endpoints rather than the package defaults.
+func TestClientEndpoints(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch {
+		case strings.Contains(r.URL.Path, "materialize/status"):
+			json.NewEncoder(w).Encode(BatchResponse{JobID: "jobid"})
+		default:
+			json.NewEncoder(w).Encode(Metadata{})
+		}
+	}))
+	defer ts.Close()
+
+	// Point the package default somewhere that will fail.
+	defer func(orig Endpoints) { urls = orig }(urls)
+	urls = newEndpoints("http://127.0.0.1:1")
+
+	endpoints, err := NewEndpoints(ts.URL + "/staging/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := retryablehttp.NewClient()
+	rc.RetryMax = 0
+	c := NewClient(rc, endpoints)
+
+	if _, err := c.NewTemplate("tID").Metadata(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.FetchBatchStatus(context.Background(), "jobid"); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"/staging/v1/template/tID/metadata", "/staging/v1/template/materialize/status/jobid"}
+	if len(paths) != len(exp) {
+		t.Fatalf("expected requests to %v, got %v", exp, paths)
+	}
+	for i := range exp {
+		if paths[i] != exp[i] {
+			t.Fatalf("expected request to %s, got %s", exp[i], paths[i])
+		}
+	}
+}
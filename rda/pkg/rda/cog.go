@@ -0,0 +1,743 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// COGOption sets options used when writing a COG.
+type COGOption func(*cogConfig)
+
+type cogConfig struct {
+	blockSize int
+	compress  bool
+	rpcs      *RPCs
+	cutline   *Geometry
+}
+
+// WithCOGBlockSize sets the width and height of the COG's internal
+// tiles.  It must be a multiple of 16; 256 is used by default.
+func WithCOGBlockSize(size int) COGOption {
+	return func(c *cogConfig) {
+		c.blockSize = size
+	}
+}
+
+// WithCOGCompression sets whether the COG's internal tiles are
+// deflate compressed, which they are by default.
+func WithCOGCompression(compress bool) COGOption {
+	return func(c *cogConfig) {
+		c.compress = compress
+	}
+}
+
+// WithCOGRPCs writes rpcs to the COG in the RPCCoefficientTag, which
+// is where GDAL looks for them.
+func WithCOGRPCs(rpcs *RPCs) COGOption {
+	return func(c *cogConfig) {
+		c.rpcs = rpcs
+	}
+}
+
+// WithCOGCutline zeros the pixels whose centers are outside g, which
+// is given in the image's coordinate reference system.
+func WithCOGCutline(g *Geometry) COGOption {
+	return func(c *cogConfig) {
+		c.cutline = g
+	}
+}
+
+// WriteCOG assembles tiles realized from RDA into a single Cloud
+// Optimized GeoTIFF at path, laying them out as NewVRT does.  The COG
+// is internally tiled and holds overviews, each half the size of the
+// last, down to a single tile.  Tiles missing from tiles are left as
+// zeros.
+func WriteCOG(path string, m *Metadata, tiles []TileInfo, options ...COGOption) error {
+	cfg, err := newCOGConfig(options)
+	if err != nil {
+		return err
+	}
+	if len(tiles) == 0 {
+		return errors.New("no tiles were provided to build a COG from")
+	}
+
+	gdalType, err := RDAToGDALType(m.ImageMetadata.DataType)
+	if err != nil {
+		return err
+	}
+	pt, err := gdalPixelType(gdalType)
+	if err != nil {
+		return err
+	}
+
+	// Size the image the same way NewVRT does.
+	minXTile, minYTile, maxXTile, maxYTile := tileExtents(tiles)
+	width := m.ImageMetadata.TileXSize * (maxXTile - minXTile + 1)
+	height := m.ImageMetadata.TileYSize * (maxYTile - minYTile + 1)
+	if m.ImageMetadata.tileGeoTransform.SpatialReferenceSystemCode == "" {
+		width, height = m.ImageMetadata.ImageWidth, m.ImageMetadata.ImageHeight
+	}
+
+	src := newTileSource(m, tiles, minXTile, minYTile, pt, width)
+	if cfg.cutline != nil {
+		if src.cutline, err = pixelGeometry(m, cfg.cutline); err != nil {
+			return err
+		}
+	}
+
+	// The geotransform of the COG starts at the minimum tile.
+	gt := m.ImageGeoreferencing
+	gt.SpatialReferenceSystemCode = m.ImageMetadata.tileGeoTransform.SpatialReferenceSystemCode
+	gt.TranslateX, gt.TranslateY = m.ImageMetadata.tileGeoTransform.Apply(float64(minXTile), float64(minYTile))
+	return writeCOG(path, cfg, pt, m.ImageMetadata.NumBands, width, height, src, gt)
+}
+
+func newCOGConfig(options []COGOption) (cogConfig, error) {
+	cfg := cogConfig{blockSize: 256, compress: true}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	if cfg.blockSize < 16 || cfg.blockSize%16 != 0 {
+		return cfg, errors.Errorf("COG block size %d must be a positive multiple of 16", cfg.blockSize)
+	}
+	return cfg, nil
+}
+
+// rowSource supplies the rows of the full resolution image of a COG.
+type rowSource interface {
+	// rows returns n rows of pixels starting at row y0.  Calls are
+	// made with increasing y0.
+	rows(y0, n int) ([]byte, error)
+}
+
+// writeCOG writes the width by height image src supplies as a COG at
+// path, georeferenced by gt unless it has no coordinate reference
+// system.
+func writeCOG(path string, cfg cogConfig, pt pixelType, numBands, width, height int, src rowSource, gt ImageGeoreferencing) error {
+	w := cogWriter{
+		cfg:       cfg,
+		pixelType: pt,
+		numBands:  numBands,
+		pixelSize: pt.bytes * numBands,
+	}
+	defer w.cleanup()
+
+	// Check we can georeference the COG before realizing it.
+	fields, err := w.georefFields(gt)
+	if err != nil {
+		return err
+	}
+
+	// Each overview is half the size of the last, until one fits in a block.
+	for lw, lh := width, height; ; lw, lh = (lw+1)/2, (lh+1)/2 {
+		tmp, err := ioutil.TempFile(filepath.Dir(path), ".cog-level-")
+		if err != nil {
+			return errors.Wrap(err, "failed creating temporary file to hold COG tiles")
+		}
+		w.levels = append(w.levels, &cogLevel{
+			width:  lw,
+			height: lh,
+			across: (lw + cfg.blockSize - 1) / cfg.blockSize,
+			down:   (lh + cfg.blockSize - 1) / cfg.blockSize,
+			buf:    make([]byte, cfg.blockSize*lw*w.pixelSize),
+			tmp:    tmp,
+			out:    bufio.NewWriter(tmp),
+		})
+		if lw <= cfg.blockSize && lh <= cfg.blockSize {
+			break
+		}
+	}
+
+	// Push the image through the pyramid a block row at a time.
+	for y := 0; y < height; y += cfg.blockSize {
+		rows, err := src.rows(y, min(cfg.blockSize, height-y))
+		if err != nil {
+			return err
+		}
+		if err := w.push(0, rows); err != nil {
+			return err
+		}
+	}
+	for i, l := range w.levels {
+		if l.rows > 0 {
+			if err := w.emit(i); err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.assemble(path, fields)
+}
+
+// cogWriter builds the tiles of each level of a COG in temporary
+// files, then assembles them into the final COG.
+type cogWriter struct {
+	cfg       cogConfig
+	pixelType pixelType
+	numBands  int
+	pixelSize int
+	levels    []*cogLevel
+}
+
+// cogLevel is the full resolution image or one of its overviews.
+type cogLevel struct {
+	width, height int
+	across, down  int
+
+	buf  []byte // Holds a block row of pixels.
+	rows int    // How many rows buf holds.
+
+	tmp     *os.File
+	out     *bufio.Writer
+	size    uint64
+	offsets []uint64
+	counts  []uint64
+}
+
+func (w *cogWriter) cleanup() {
+	for _, l := range w.levels {
+		l.tmp.Close()
+		os.Remove(l.tmp.Name())
+	}
+}
+
+// push adds rows of pixels to the given level, emitting tiles each
+// time a block row fills up.
+func (w *cogWriter) push(level int, pix []byte) error {
+	l := w.levels[level]
+	rowSize := l.width * w.pixelSize
+	for len(pix) > 0 {
+		n := copy(l.buf[l.rows*rowSize:], pix)
+		l.rows += n / rowSize
+		pix = pix[n:]
+		if l.rows == w.cfg.blockSize {
+			if err := w.emit(level); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// emit writes out the tiles of the block row held by level, and
+// passes the block row at half resolution to the next level.
+func (w *cogWriter) emit(level int) error {
+	l, bs := w.levels[level], w.cfg.blockSize
+	rowSize := l.width * w.pixelSize
+	tile := make([]byte, bs*bs*w.pixelSize)
+	for tx := 0; tx < l.across; tx++ {
+		for i := range tile {
+			tile[i] = 0
+		}
+		x0, cols := tx*bs*w.pixelSize, min(bs, l.width-tx*bs)*w.pixelSize
+		for r := 0; r < l.rows; r++ {
+			copy(tile[r*bs*w.pixelSize:], l.buf[r*rowSize+x0:r*rowSize+x0+cols])
+		}
+
+		data := tile
+		if w.cfg.compress {
+			var buf bytes.Buffer
+			zw := zlib.NewWriter(&buf)
+			if _, err := zw.Write(tile); err != nil {
+				return errors.Wrap(err, "failed compressing COG tile")
+			}
+			if err := zw.Close(); err != nil {
+				return errors.Wrap(err, "failed compressing COG tile")
+			}
+			data = buf.Bytes()
+		}
+		if _, err := l.out.Write(data); err != nil {
+			return errors.Wrap(err, "failed writing COG tile to temporary file")
+		}
+		l.offsets = append(l.offsets, l.size)
+		l.counts = append(l.counts, uint64(len(data)))
+		l.size += uint64(len(data))
+	}
+
+	rows := l.rows
+	l.rows = 0
+	if level+1 == len(w.levels) {
+		return nil
+	}
+	return w.push(level+1, w.downsample(l, rows))
+}
+
+// downsample averages each 2x2 block of pixels in the level's block
+// row, repeating the last row or column when there's an odd number.
+func (w *cogWriter) downsample(l *cogLevel, rows int) []byte {
+	outW, outRows := (l.width+1)/2, (rows+1)/2
+	out := make([]byte, outW*outRows*w.pixelSize)
+	rowSize, bps := l.width*w.pixelSize, w.pixelType.bytes
+	for r := 0; r < outRows; r++ {
+		r0, r1 := 2*r*rowSize, min(2*r+1, rows-1)*rowSize
+		for c := 0; c < outW; c++ {
+			c0, c1 := 2*c*w.pixelSize, min(2*c+1, l.width-1)*w.pixelSize
+			for b := 0; b < w.numBands; b++ {
+				o := b * bps
+				sum := w.pixelType.get(l.buf[r0+c0+o:]) + w.pixelType.get(l.buf[r0+c1+o:]) +
+					w.pixelType.get(l.buf[r1+c0+o:]) + w.pixelType.get(l.buf[r1+c1+o:])
+				w.pixelType.put(out[(r*outW+c)*w.pixelSize+o:], sum/4)
+			}
+		}
+	}
+	return out
+}
+
+// tileSource reads the rows of the image out of the realized RDA tiles.
+type tileSource struct {
+	m                  *Metadata
+	tiles              map[[2]int]TileInfo
+	minXTile, minYTile int
+	pixelType          pixelType
+	width              int
+	cache              map[[2]int]*tiffImage
+
+	// cutline, if set, is the geometry in pixel coordinates outside
+	// which pixels are zeroed.
+	cutline *Geometry
+}
+
+func newTileSource(m *Metadata, tiles []TileInfo, minXTile, minYTile int, pt pixelType, width int) *tileSource {
+	s := tileSource{
+		m:         m,
+		tiles:     make(map[[2]int]TileInfo),
+		minXTile:  minXTile,
+		minYTile:  minYTile,
+		pixelType: pt,
+		width:     width,
+		cache:     make(map[[2]int]*tiffImage),
+	}
+	for _, t := range tiles {
+		s.tiles[[2]int{t.XTile - minXTile, t.YTile - minYTile}] = t
+	}
+	return &s
+}
+
+// rows returns n rows of pixels starting at row y0.  Calls must be
+// made with increasing y0, as tiles above y0 are dropped from the
+// cache.
+func (s *tileSource) rows(y0, n int) ([]byte, error) {
+	tileW, tileH := s.m.ImageMetadata.TileXSize, s.m.ImageMetadata.TileYSize
+	pixelSize := s.pixelType.bytes * s.m.ImageMetadata.NumBands
+	out := make([]byte, n*s.width*pixelSize)
+
+	firstRow, lastRow := y0/tileH, (y0+n-1)/tileH
+	for key := range s.cache {
+		if key[1] < firstRow {
+			delete(s.cache, key)
+		}
+	}
+
+	for ty := firstRow; ty <= lastRow; ty++ {
+		for tx := 0; tx*tileW < s.width; tx++ {
+			img, err := s.tile(tx, ty)
+			if err != nil {
+				return nil, err
+			}
+			if img == nil {
+				continue
+			}
+
+			// Copy the part of the tile that overlaps the requested rows.
+			x0, cols := tx*tileW, min(tileW, s.width-tx*tileW)
+			for r := max(y0, ty*tileH); r < min(y0+n, (ty+1)*tileH); r++ {
+				src := img.pix[(r-ty*tileH)*tileW*pixelSize:]
+				copy(out[((r-y0)*s.width+x0)*pixelSize:], src[:cols*pixelSize])
+			}
+		}
+	}
+	return out, nil
+}
+
+// tile returns the decoded tile at (tx, ty) relative to the minimum
+// tile, or nil if that tile wasn't realized.
+func (s *tileSource) tile(tx, ty int) (*tiffImage, error) {
+	key := [2]int{tx, ty}
+	if img, ok := s.cache[key]; ok {
+		return img, nil
+	}
+	t, ok := s.tiles[key]
+	if !ok {
+		return nil, nil
+	}
+
+	img, err := readTIFF(t.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	im := &s.m.ImageMetadata
+	if img.width != im.TileXSize || img.height != im.TileYSize || img.samplesPerPixel != im.NumBands || img.pixelType != s.pixelType {
+		return nil, errors.Errorf("tile %s is %dx%dx%d, but RDA metadata describes %dx%dx%d %s tiles",
+			t.FilePath, img.width, img.height, img.samplesPerPixel, im.TileXSize, im.TileYSize, im.NumBands, im.DataType)
+	}
+	if s.cutline != nil {
+		mask := make([]byte, im.TileXSize*im.TileYSize)
+		s.cutline.rasterize(mask, im.TileXSize, im.TileYSize, float64(t.XTile*im.TileXSize), float64(t.YTile*im.TileYSize))
+		pixelSize := s.pixelType.bytes * im.NumBands
+		for i, v := range mask {
+			if v == 0 {
+				for j := i * pixelSize; j < (i+1)*pixelSize; j++ {
+					img.pix[j] = 0
+				}
+			}
+		}
+	}
+	s.cache[key] = img
+	return img, nil
+}
+
+// georefFields returns the TIFF fields holding the georeferencing and
+// RPCs of the full resolution image, whose geotransform is igt.
+func (w *cogWriter) georefFields(igt ImageGeoreferencing) ([]tiffField, error) {
+	var fields []tiffField
+	if igt.SpatialReferenceSystemCode != "" {
+		tx, ty := igt.TranslateX, igt.TranslateY
+		if igt.ShearX == 0 && igt.ShearY == 0 {
+			fields = append(fields,
+				tiffField{tag: tagModelPixelScale, doubles: []float64{igt.ScaleX, -igt.ScaleY, 0}},
+				tiffField{tag: tagModelTiepoint, doubles: []float64{0, 0, 0, tx, ty, 0}})
+		} else {
+			fields = append(fields, tiffField{tag: tagModelTransform, doubles: []float64{
+				igt.ScaleX, igt.ShearX, 0, tx,
+				igt.ShearY, igt.ScaleY, 0, ty,
+				0, 0, 0, 0,
+				0, 0, 0, 1,
+			}})
+		}
+		keys, err := geoKeys(igt.SpatialReferenceSystemCode)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, tiffField{tag: tagGeoKeyDirectory, shorts: keys})
+	}
+
+	if rpcs := w.cfg.rpcs; rpcs != nil {
+		vals := []float64{
+			rpcs.ERRBIAS, rpcs.ERRRAND,
+			float64(rpcs.LINEOFFSET), float64(rpcs.SAMPOFFSET), rpcs.LATOFFSET, rpcs.LONGOFFSET, float64(rpcs.HEIGHTOFFSET),
+			float64(rpcs.LINESCALE), float64(rpcs.SAMPSCALE), rpcs.LATSCALE, rpcs.LONGSCALE, float64(rpcs.HEIGHTSCALE),
+		}
+		for _, coefs := range []FloatsAsString{
+			rpcs.LINENUMCOEFList.LINENUMCOEF,
+			rpcs.LINEDENCOEFList.LINEDENCOEF,
+			rpcs.SAMPNUMCOEFList.SAMPNUMCOEF,
+			rpcs.SAMPDENCOEFList.SAMPDENCOEF,
+		} {
+			if len(coefs) != 20 {
+				return nil, errors.Errorf("RPCs must have 20 coefficients per polynomial, found %d", len(coefs))
+			}
+			vals = append(vals, coefs...)
+		}
+		fields = append(fields, tiffField{tag: tagRPCCoefficients, doubles: vals})
+	}
+	return fields, nil
+}
+
+// geographicEPSG holds the EPSG codes of common geographic coordinate
+// reference systems besides WGS84, which geoKeys declares as such.
+var geographicEPSG = map[int]bool{
+	4230: true, // ED50
+	4258: true, // ETRS89
+	4267: true, // NAD27
+	4269: true, // NAD83
+	4283: true, // GDA94
+	4490: true, // CGCS2000
+	4612: true, // JGD2000
+	4617: true, // NAD83(CSRS)
+	4674: true, // SIRGAS 2000
+	4759: true, // NAD83(NSRS2007)
+	6318: true, // NAD83(2011)
+	6668: true, // JGD2011
+	7844: true, // GDA2020
+}
+
+// geoKeys returns a GeoKeyDirectory for srs, an EPSG code.  Declaring
+// it only needs the code, so any is accepted: WGS84 and the codes in
+// geographicEPSG are declared geographic, and the rest projected.
+func geoKeys(srs string) ([]uint16, error) {
+	code, err := parseEPSG(srs)
+	if err != nil {
+		return nil, err
+	}
+	if code <= 0 || code > math.MaxUint16 {
+		return nil, errors.Errorf("EPSG:%d can't be declared in a GeoTIFF", code)
+	}
+
+	geographic := geographicEPSG[code]
+	if proj, err := newProjection(srs); err == nil {
+		_, geographic = proj.(lonLat)
+	}
+	modelType, csKey := uint16(1), uint16(3072) // Projected, ProjectedCSTypeGeoKey.
+	if geographic {
+		modelType, csKey = 2, 2048 // Geographic, GeographicTypeGeoKey.
+	}
+	return []uint16{
+		1, 1, 0, 3,
+		1024, 0, 1, modelType, // GTModelTypeGeoKey
+		1025, 0, 1, 1, // GTRasterTypeGeoKey, PixelIsArea
+		csKey, 0, 1, uint16(code),
+	}, nil
+}
+
+// assemble writes the COG to path: the header, every level's IFD,
+// then the tiles of each level from the smallest overview to the full
+// resolution image.
+func (w *cogWriter) assemble(path string, georef []tiffField) error {
+	var dataSize uint64
+	for _, l := range w.levels {
+		if err := l.out.Flush(); err != nil {
+			return errors.Wrap(err, "failed writing COG tiles to temporary file")
+		}
+		dataSize += l.size
+	}
+	big := dataSize > math.MaxUint32-(1<<24) // Leave room for the IFDs.
+
+	ifds := make([][]tiffField, len(w.levels))
+	for i, l := range w.levels {
+		ifds[i] = w.levelFields(i, l, big)
+		if i == 0 {
+			ifds[i] = append(ifds[i], georef...)
+		}
+	}
+
+	// Figure out where everything goes.
+	headerSize := uint64(8)
+	if big {
+		headerSize = 16
+	}
+	ifdOffsets := make([]uint64, len(ifds))
+	pos := headerSize
+	for i, fields := range ifds {
+		ifdOffsets[i] = pos
+		pos += uint64(ifdSize(fields, big))
+	}
+	for i := len(w.levels) - 1; i >= 0; i-- {
+		l := w.levels[i]
+		offsets := make([]uint64, len(l.offsets))
+		for j, off := range l.offsets {
+			offsets[j] = pos + off
+		}
+		for j := range ifds[i] {
+			if ifds[i][j].tag == tagTileOffsets {
+				ifds[i][j].longs = offsets
+			}
+		}
+		pos += l.size
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "failed creating COG")
+	}
+	defer f.Close()
+	out := bufio.NewWriter(f)
+
+	if big {
+		out.Write([]byte{'I', 'I', 43, 0, 8, 0, 0, 0})
+		binary.Write(out, binary.LittleEndian, ifdOffsets[0])
+	} else {
+		out.Write([]byte{'I', 'I', 42, 0})
+		binary.Write(out, binary.LittleEndian, uint32(ifdOffsets[0]))
+	}
+	for i, fields := range ifds {
+		next := uint64(0)
+		if i+1 < len(ifds) {
+			next = ifdOffsets[i+1]
+		}
+		out.Write(encodeIFD(fields, ifdOffsets[i], next, big))
+	}
+	for i := len(w.levels) - 1; i >= 0; i-- {
+		l := w.levels[i]
+		if _, err := l.tmp.Seek(0, io.SeekStart); err != nil {
+			return errors.Wrap(err, "failed rewinding temporary file holding COG tiles")
+		}
+		if _, err := io.Copy(out, l.tmp); err != nil {
+			return errors.Wrap(err, "failed copying tiles into COG")
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return errors.Wrap(err, "failed writing COG")
+	}
+	return errors.Wrap(f.Close(), "failed closing COG")
+}
+
+// levelFields returns the fields describing the layout of a level;
+// the tile offsets are filled in once the layout of the file is known.
+func (w *cogWriter) levelFields(i int, l *cogLevel, big bool) []tiffField {
+	bps := make([]uint16, w.numBands)
+	formats := make([]uint16, w.numBands)
+	for b := range bps {
+		bps[b] = uint16(8 * w.pixelType.bytes)
+		formats[b] = w.pixelType.format
+	}
+	compression := uint16(compressionNone)
+	if w.cfg.compress {
+		compression = compressionDeflate
+	}
+	longType := uint16(typeLong)
+	if big {
+		longType = typeLong8
+	}
+
+	fields := []tiffField{
+		{tag: tagImageWidth, longs: []uint64{uint64(l.width)}, typ: typeLong},
+		{tag: tagImageLength, longs: []uint64{uint64(l.height)}, typ: typeLong},
+		{tag: tagBitsPerSample, shorts: bps},
+		{tag: tagCompression, shorts: []uint16{compression}},
+		{tag: tagPhotometric, shorts: []uint16{1}}, // Min is black.
+		{tag: tagSamplesPerPixel, shorts: []uint16{uint16(w.numBands)}},
+		{tag: tagPlanarConfig, shorts: []uint16{1}}, // Pixel interleaved.
+		{tag: tagTileWidth, shorts: []uint16{uint16(w.cfg.blockSize)}},
+		{tag: tagTileLength, shorts: []uint16{uint16(w.cfg.blockSize)}},
+		{tag: tagTileOffsets, longs: make([]uint64, len(l.offsets)), typ: longType},
+		{tag: tagTileByteCounts, longs: l.counts, typ: longType},
+		{tag: tagSampleFormat, shorts: formats},
+	}
+	if i > 0 {
+		fields = append(fields, tiffField{tag: tagNewSubfileType, longs: []uint64{1}, typ: typeLong}) // Reduced resolution image.
+	}
+	if w.numBands > 1 {
+		fields = append(fields, tiffField{tag: tagExtraSamples, shorts: make([]uint16, w.numBands-1)})
+	}
+	return fields
+}
+
+// tiffField is a TIFF tag and its values.  Only one of shorts,
+// longs, or doubles is set; longs are written as typ.
+type tiffField struct {
+	tag     uint16
+	typ     uint16
+	shorts  []uint16
+	longs   []uint64
+	doubles []float64
+}
+
+func (f *tiffField) fieldType() (typ uint16, count, size int) {
+	switch {
+	case f.shorts != nil:
+		return typeShort, len(f.shorts), 2 * len(f.shorts)
+	case f.doubles != nil:
+		return typeDouble, len(f.doubles), 8 * len(f.doubles)
+	case f.typ == typeLong8:
+		return typeLong8, len(f.longs), 8 * len(f.longs)
+	default:
+		return typeLong, len(f.longs), 4 * len(f.longs)
+	}
+}
+
+func (f *tiffField) values() []byte {
+	buf := &bytes.Buffer{}
+	switch typ, _, _ := f.fieldType(); typ {
+	case typeShort:
+		binary.Write(buf, binary.LittleEndian, f.shorts)
+	case typeDouble:
+		binary.Write(buf, binary.LittleEndian, f.doubles)
+	case typeLong8:
+		binary.Write(buf, binary.LittleEndian, f.longs)
+	default:
+		for _, v := range f.longs {
+			binary.Write(buf, binary.LittleEndian, uint32(v))
+		}
+	}
+	return buf.Bytes()
+}
+
+// ifdSize returns the number of bytes the IFD holding fields takes,
+// including its values that don't fit in their entries.
+func ifdSize(fields []tiffField, big bool) int {
+	entrySize, inline, size := 12, 4, 2+4
+	if big {
+		entrySize, inline, size = 20, 8, 8+8
+	}
+	for i := range fields {
+		size += entrySize
+		if _, _, n := fields[i].fieldType(); n > inline {
+			size += n + n%2
+		}
+	}
+	return size
+}
+
+// encodeIFD returns the IFD holding fields as it is to be written at
+// offset at, pointing to the IFD at next.
+func encodeIFD(fields []tiffField, at, next uint64, big bool) []byte {
+	// TIFF requires the entries to be sorted by tag.
+	for i := 1; i < len(fields); i++ {
+		for j := i; j > 0 && fields[j].tag < fields[j-1].tag; j-- {
+			fields[j], fields[j-1] = fields[j-1], fields[j]
+		}
+	}
+
+	entrySize, inline := 12, 4
+	if big {
+		entrySize, inline = 20, 8
+	}
+	header := 2
+	if big {
+		header = 8
+	}
+	extraAt := at + uint64(header+entrySize*len(fields)+inline)
+
+	buf, extra := &bytes.Buffer{}, &bytes.Buffer{}
+	putUint := func(b *bytes.Buffer, v uint64) {
+		if big {
+			binary.Write(b, binary.LittleEndian, v)
+		} else {
+			binary.Write(b, binary.LittleEndian, uint32(v))
+		}
+	}
+	if big {
+		binary.Write(buf, binary.LittleEndian, uint64(len(fields)))
+	} else {
+		binary.Write(buf, binary.LittleEndian, uint16(len(fields)))
+	}
+	for i := range fields {
+		typ, count, size := fields[i].fieldType()
+		binary.Write(buf, binary.LittleEndian, fields[i].tag)
+		binary.Write(buf, binary.LittleEndian, typ)
+		putUint(buf, uint64(count))
+		if size <= inline {
+			v := make([]byte, inline)
+			copy(v, fields[i].values())
+			buf.Write(v)
+			continue
+		}
+		putUint(buf, extraAt+uint64(extra.Len()))
+		extra.Write(fields[i].values())
+		if size%2 == 1 {
+			extra.WriteByte(0)
+		}
+	}
+	putUint(buf, next)
+	buf.Write(extra.Bytes())
+	return buf.Bytes()
+}
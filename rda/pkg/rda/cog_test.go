@@ -0,0 +1,298 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestTile writes an uncompressed, single strip TIFF tile whose
+// pixels are derived from their location in the full image.
+func writeTestTile(t *testing.T, path string, m *Metadata, pt pixelType, xTile, yTile int) {
+	im := m.ImageMetadata
+	pixelSize := pt.bytes * im.NumBands
+	pix := make([]byte, im.TileXSize*im.TileYSize*pixelSize)
+	for r := 0; r < im.TileYSize; r++ {
+		for c := 0; c < im.TileXSize; c++ {
+			for b := 0; b < im.NumBands; b++ {
+				v := testPixel(xTile*im.TileXSize+c, yTile*im.TileYSize+r, b)
+				pt.put(pix[(r*im.TileXSize+c)*pixelSize+b*pt.bytes:], v)
+			}
+		}
+	}
+
+	bps, formats := make([]uint16, im.NumBands), make([]uint16, im.NumBands)
+	for b := range bps {
+		bps[b], formats[b] = uint16(8*pt.bytes), pt.format
+	}
+	fields := []tiffField{
+		{tag: tagImageWidth, longs: []uint64{uint64(im.TileXSize)}},
+		{tag: tagImageLength, longs: []uint64{uint64(im.TileYSize)}},
+		{tag: tagBitsPerSample, shorts: bps},
+		{tag: tagSamplesPerPixel, shorts: []uint16{uint16(im.NumBands)}},
+		{tag: tagRowsPerStrip, longs: []uint64{uint64(im.TileYSize)}},
+		{tag: tagStripByteCounts, longs: []uint64{uint64(len(pix))}},
+		{tag: tagSampleFormat, shorts: formats},
+		{tag: tagStripOffsets, longs: []uint64{0}},
+	}
+	fields[len(fields)-1].longs[0] = uint64(8 + ifdSize(fields, false))
+
+	b := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	b = append(b, encodeIFD(fields, 8, 0, false)...)
+	b = append(b, pix...)
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testPixel(col, row, band int) float64 {
+	return float64((col + 2*row + 7*band) % 251)
+}
+
+// ifdOffsets walks the IFD chain of a little endian classic TIFF.
+func ifdOffsets(t *testing.T, b []byte) []uint32 {
+	var offsets []uint32
+	for off := binary.LittleEndian.Uint32(b[4:]); off != 0; {
+		offsets = append(offsets, off)
+		n := uint32(binary.LittleEndian.Uint16(b[off:]))
+		off = binary.LittleEndian.Uint32(b[off+2+12*n:])
+	}
+	return offsets
+}
+
+// decodeIFD decodes the image described by the IFD at off.
+func decodeIFD(t *testing.T, b []byte, off uint32) *tiffImage {
+	c := append([]byte{}, b...)
+	binary.LittleEndian.PutUint32(c[4:], off)
+	img, err := decodeTIFF(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestWriteCOG(t *testing.T) {
+	tests := []struct {
+		name        string
+		dataType    string
+		width       int
+		height      int
+		bands       int
+		srs         string
+		blockSize   int
+		compress    bool
+		rpcs        bool
+		missing     bool
+		wantLevels  int
+		wantTagSet  []uint16
+		wantTagMiss []uint16
+	}{
+		{"byte-georeferenced", "BYTE", 3, 2, 3, "EPSG:32615", 128, true, false, false, 3,
+			[]uint16{tagModelPixelScale, tagModelTiepoint, tagGeoKeyDirectory, tagExtraSamples}, []uint16{tagRPCCoefficients}},
+		{"uint16-1b-rpcs", "UNSIGNED_SHORT", 2, 2, 1, "", 64, false, true, false, 3,
+			[]uint16{tagRPCCoefficients}, []uint16{tagModelPixelScale, tagGeoKeyDirectory, tagExtraSamples}},
+		{"float-missing-tile", "FLOAT", 2, 2, 2, "EPSG:4326", 256, true, false, true, 1,
+			[]uint16{tagModelTiepoint, tagGeoKeyDirectory}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "cog")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			m := &Metadata{}
+			m.ImageMetadata.DataType = tc.dataType
+			m.ImageMetadata.NumBands = tc.bands
+			m.ImageMetadata.TileXSize, m.ImageMetadata.TileYSize = 100, 100
+			m.ImageMetadata.ImageWidth, m.ImageMetadata.ImageHeight = 100*tc.width-30, 100*tc.height-10
+			m.ImageGeoreferencing = ImageGeoreferencing{SpatialReferenceSystemCode: tc.srs, TranslateX: 500000, ScaleX: 2, TranslateY: 4000000, ScaleY: -2}
+			m.ImageMetadata.tileGeoTransform = ImageGeoreferencing{SpatialReferenceSystemCode: tc.srs, TranslateX: 500000, ScaleX: 200, TranslateY: 4000000, ScaleY: -200}
+
+			gdalType, err := RDAToGDALType(tc.dataType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pt, err := gdalPixelType(gdalType)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var tiles []TileInfo
+			for y := 0; y < tc.height; y++ {
+				for x := 0; x < tc.width; x++ {
+					tile := TileInfo{FilePath: filepath.Join(dir, fmt.Sprintf("%d_%d.tif", x, y)), XTile: x, YTile: y}
+					writeTestTile(t, tile.FilePath, m, pt, x, y)
+					tiles = append(tiles, tile)
+				}
+			}
+			if tc.missing {
+				tiles = tiles[1:]
+			}
+
+			options := []COGOption{WithCOGBlockSize(tc.blockSize), WithCOGCompression(tc.compress)}
+			if tc.rpcs {
+				rpcs := &RPCs{}
+				rpcs.LINENUMCOEFList.LINENUMCOEF = make(FloatsAsString, 20)
+				rpcs.LINEDENCOEFList.LINEDENCOEF = make(FloatsAsString, 20)
+				rpcs.SAMPNUMCOEFList.SAMPNUMCOEF = make(FloatsAsString, 20)
+				rpcs.SAMPDENCOEFList.SAMPDENCOEF = make(FloatsAsString, 20)
+				options = append(options, WithCOGRPCs(rpcs))
+			}
+			cogPath := filepath.Join(dir, "out.tif")
+			if err := WriteCOG(cogPath, m, tiles, options...); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := ioutil.ReadFile(cogPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ifds := ifdOffsets(t, b)
+			if len(ifds) != tc.wantLevels {
+				t.Fatalf("got %d IFDs, want %d", len(ifds), tc.wantLevels)
+			}
+
+			// Check the full resolution image matches the tiles.
+			wantW, wantH := 100*tc.width, 100*tc.height
+			if tc.srs == "" {
+				wantW, wantH = m.ImageMetadata.ImageWidth, m.ImageMetadata.ImageHeight
+			}
+			img := decodeIFD(t, b, ifds[0])
+			if img.width != wantW || img.height != wantH || img.samplesPerPixel != tc.bands || img.pixelType != pt {
+				t.Fatalf("got a %dx%dx%d %v image, want %dx%dx%d %v", img.width, img.height, img.samplesPerPixel, img.pixelType, wantW, wantH, tc.bands, pt)
+			}
+			pixelSize := pt.bytes * tc.bands
+			for r := 0; r < img.height; r++ {
+				for c := 0; c < img.width; c++ {
+					for band := 0; band < tc.bands; band++ {
+						want := testPixel(c, r, band)
+						if tc.missing && r < 100 && c < 100 {
+							want = 0
+						}
+						if got := pt.get(img.pix[(r*img.width+c)*pixelSize+band*pt.bytes:]); got != want {
+							t.Fatalf("pixel (%d, %d) band %d is %v, want %v", c, r, band, got, want)
+						}
+					}
+				}
+			}
+
+			// Each overview is half the size of the last.
+			for i := 1; i < len(ifds); i++ {
+				wantW, wantH = (wantW+1)/2, (wantH+1)/2
+				ov := decodeIFD(t, b, ifds[i])
+				if ov.width != wantW || ov.height != wantH {
+					t.Fatalf("overview %d is %dx%d, want %dx%d", i, ov.width, ov.height, wantW, wantH)
+				}
+			}
+			if wantW > tc.blockSize || wantH > tc.blockSize {
+				t.Fatalf("smallest overview %dx%d doesn't fit in a block", wantW, wantH)
+			}
+
+			// Check the tags that should and shouldn't be on the full resolution IFD.
+			tags := map[uint16]bool{}
+			n := int(binary.LittleEndian.Uint16(b[ifds[0]:]))
+			for i := 0; i < n; i++ {
+				tags[binary.LittleEndian.Uint16(b[int(ifds[0])+2+12*i:])] = true
+			}
+			for _, tag := range tc.wantTagSet {
+				if !tags[tag] {
+					t.Errorf("tag %d is missing", tag)
+				}
+			}
+			for _, tag := range tc.wantTagMiss {
+				if tags[tag] {
+					t.Errorf("tag %d should not be set", tag)
+				}
+			}
+
+			// No temporary files should be left behind.
+			files, err := filepath.Glob(filepath.Join(dir, ".cog-level-*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) > 0 {
+				t.Fatalf("temporary files %v were left behind", files)
+			}
+		})
+	}
+}
+
+func TestWriteCOGErrors(t *testing.T) {
+	m := &Metadata{}
+	m.ImageMetadata.DataType = "BYTE"
+	m.ImageMetadata.NumBands = 1
+	m.ImageMetadata.TileXSize, m.ImageMetadata.TileYSize = 100, 100
+
+	if err := WriteCOG("out.tif", m, nil); err == nil {
+		t.Error("expected an error when no tiles are given")
+	}
+	if err := WriteCOG("out.tif", m, []TileInfo{{}}, WithCOGBlockSize(100)); err == nil {
+		t.Error("expected an error for a block size that isn't a multiple of 16")
+	}
+	m.ImageMetadata.DataType = "COMPLEX"
+	if err := WriteCOG("out.tif", m, []TileInfo{{}}); err == nil {
+		t.Error("expected an error for an unsupported data type")
+	}
+}
+
+func TestGeoKeys(t *testing.T) {
+	tests := []struct {
+		srs           string
+		wantModelType uint16
+		wantCSKey     uint16
+		wantErr       bool
+	}{
+		{"EPSG:4326", 2, 2048, false},
+		{"urn:ogc:def:crs:OGC:1.3:CRS84", 2, 2048, false},
+		{"EPSG:32615", 1, 3072, false},
+		{"EPSG:32733", 1, 3072, false},
+		{"EPSG:3857", 1, 3072, false},
+		{"EPSG:4269", 2, 2048, false},
+		{"EPSG:26915", 1, 3072, false},
+		{"EPSG:2193", 1, 3072, false},
+		{"EPSG:70000", 0, 0, true},
+		{"not an srs", 0, 0, true},
+	}
+	for _, tc := range tests {
+		keys, err := geoKeys(tc.srs)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("geoKeys(%q): expected an error", tc.srs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("geoKeys(%q) failed: %v", tc.srs, err)
+			continue
+		}
+		if keys[7] != tc.wantModelType || keys[12] != tc.wantCSKey {
+			t.Errorf("geoKeys(%q) declared model type %d with key %d, want %d with key %d", tc.srs, keys[7], keys[12], tc.wantModelType, tc.wantCSKey)
+		}
+	}
+}
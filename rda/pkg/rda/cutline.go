@@ -0,0 +1,108 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// pixelGeometry returns g, given in the image's coordinate reference
+// system, in the image's pixel coordinates.
+func pixelGeometry(m *Metadata, g *Geometry) (*Geometry, error) {
+	if m.ImageGeoreferencing.SpatialReferenceSystemCode == "" {
+		return nil, errors.New("can't apply a cutline to imagery that isn't georeferenced")
+	}
+	inv, err := m.ImageGeoreferencing.Invert()
+	if err != nil {
+		return nil, err
+	}
+	return g.apply("", inv.Apply), nil
+}
+
+// AddCutlineMask gives vrt, as returned by NewVRT for m and tiles, a
+// mask band hiding the pixels whose centers are outside g, which is
+// given in the image's coordinate reference system.  The masks of
+// tiles straddling g's boundary are written next to them, named
+// mask_<x>_<y>.tif; tiles wholly inside g are unmasked by way of a
+// source that scales them to 255.
+func (vrt *VRTDataset) AddCutlineMask(m *Metadata, tiles []TileInfo, g *Geometry) error {
+	pg, err := pixelGeometry(m, g)
+	if err != nil {
+		return err
+	}
+	gdalType, err := RDAToGDALType(m.ImageMetadata.DataType)
+	if err != nil {
+		return err
+	}
+
+	tw, th := m.ImageMetadata.TileXSize, m.ImageMetadata.TileYSize
+	minXTile, minYTile, _, _ := tileExtents(tiles)
+	rect := Rect{XSize: tw, YSize: th}
+	band := VRTRasterBand{DataType: "Byte"}
+	mask := make([]byte, tw*th)
+	for _, tile := range tiles {
+		pg.rasterize(mask, tw, th, float64(tile.XTile*tw), float64(tile.YTile*th))
+		inside := bytes.Count(mask, []byte{255})
+		if inside == 0 {
+			continue // Sourceless areas of the mask band are masked.
+		}
+		dst := Rect{XOff: (tile.XTile - minXTile) * tw, YOff: (tile.YTile - minYTile) * th, XSize: tw, YSize: th}
+
+		if inside == len(mask) {
+			fp, err := filepath.Abs(tile.FilePath)
+			if err != nil {
+				return errors.Wrap(err, "failed building absolute file path in VRT")
+			}
+			band.ComplexSource = append(band.ComplexSource, ComplexSource{
+				SourceFilename:   SourceFilename{Filename: fp},
+				SourceBand:       1,
+				SourceProperties: SourceProperties{RasterXSize: tw, RasterYSize: th, DataType: gdalType, BlockXSize: tw, BlockYSize: th},
+				SrcRect:          rect,
+				DstRect:          dst,
+				ScaleOffset:      255,
+				ScaleRatio:       0,
+			})
+			continue
+		}
+
+		path := filepath.Join(filepath.Dir(tile.FilePath), fmt.Sprintf("mask_%d_%d.tif", tile.XTile, tile.YTile))
+		if err := writeTIFF(path, tw, th, 1, pixelType{1, 1}, mask); err != nil {
+			return err
+		}
+		fp, err := filepath.Abs(path)
+		if err != nil {
+			return errors.Wrap(err, "failed building absolute file path in VRT")
+		}
+		band.SimpleSource = append(band.SimpleSource, SimpleSource{
+			SourceFilename:   SourceFilename{Filename: fp},
+			SourceBand:       1,
+			SourceProperties: SourceProperties{RasterXSize: tw, RasterYSize: th, DataType: "Byte", BlockXSize: tw, BlockYSize: th},
+			SrcRect:          rect,
+			DstRect:          dst,
+		})
+	}
+	vrt.MaskBand = &VRTMaskBand{Band: band}
+	return nil
+}
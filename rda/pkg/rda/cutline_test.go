@@ -0,0 +1,151 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// cutlineTestImage returns the metadata of a 3x2 tile byte image
+// whose tiles are written to dir, along with a cutline covering tile
+// (0, 0) and the left half of tile (1, 0).
+func cutlineTestImage(t *testing.T, dir string) (*Metadata, []TileInfo, *Geometry) {
+	m := &Metadata{}
+	m.ImageMetadata.DataType = "BYTE"
+	m.ImageMetadata.NumBands = 1
+	m.ImageMetadata.TileXSize, m.ImageMetadata.TileYSize = 100, 100
+	m.ImageMetadata.ImageWidth, m.ImageMetadata.ImageHeight = 300, 200
+	m.ImageGeoreferencing = ImageGeoreferencing{SpatialReferenceSystemCode: "EPSG:32615", TranslateX: 500000, ScaleX: 2, TranslateY: 4000000, ScaleY: -2}
+	m.ImageMetadata.tileGeoTransform = ImageGeoreferencing{SpatialReferenceSystemCode: "EPSG:32615", TranslateX: 500000, ScaleX: 200, TranslateY: 4000000, ScaleY: -200}
+
+	var tiles []TileInfo
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			tile := TileInfo{FilePath: filepath.Join(dir, fmt.Sprintf("%d_%d.tif", x, y)), XTile: x, YTile: y}
+			writeTestTile(t, tile.FilePath, m, pixelType{bytes: 1, format: 1}, x, y)
+			tiles = append(tiles, tile)
+		}
+	}
+
+	g, err := ParseWKT("SRID=32615;POLYGON ((499990 4000010, 500300 4000010, 500300 3999800, 499990 3999800, 499990 4000010))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, tiles, g
+}
+
+func TestAddCutlineMask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cutline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m, tiles, g := cutlineTestImage(t, dir)
+
+	vrt, err := NewVRT(m, tiles, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vrt.AddCutlineMask(m, tiles, g); err != nil {
+		t.Fatal(err)
+	}
+	if vrt.MaskBand == nil {
+		t.Fatal("VRT has no mask band")
+	}
+	band := vrt.MaskBand.Band
+	if len(band.ComplexSource) != 1 || len(band.SimpleSource) != 1 {
+		t.Fatalf("got %d complex and %d simple mask sources, want 1 of each", len(band.ComplexSource), len(band.SimpleSource))
+	}
+	if cs := band.ComplexSource[0]; cs.DstRect.XOff != 0 || cs.DstRect.YOff != 0 || cs.ScaleOffset != 255 || cs.ScaleRatio != 0 {
+		t.Errorf("got complex source %+v, want tile (0, 0) scaled to 255", cs)
+	}
+
+	ss := band.SimpleSource[0]
+	if ss.DstRect.XOff != 100 || ss.DstRect.YOff != 0 {
+		t.Errorf("got simple source %+v, want tile (1, 0)", ss)
+	}
+	img, err := readTIFF(ss.SourceFilename.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.width != 100 || img.height != 100 || img.samplesPerPixel != 1 {
+		t.Fatalf("mask is %dx%dx%d, want 100x100x1", img.width, img.height, img.samplesPerPixel)
+	}
+	for r := 0; r < 100; r++ {
+		for c := 0; c < 100; c++ {
+			want := byte(0)
+			if c < 50 {
+				want = 255
+			}
+			if got := img.pix[r*100+c]; got != want {
+				t.Fatalf("mask pixel (%d, %d) is %d, want %d", c, r, got, want)
+			}
+		}
+	}
+
+	if err := vrt.MakeRelative(dir); err != nil {
+		t.Fatal(err)
+	}
+	if fn := vrt.MaskBand.Band.SimpleSource[0].SourceFilename; !fn.RelativeToVRT || fn.Filename != "mask_1_0.tif" {
+		t.Errorf("got mask source file %+v, want mask_1_0.tif relative to the VRT", fn)
+	}
+	if fn := vrt.MaskBand.Band.ComplexSource[0].SourceFilename; !fn.RelativeToVRT || fn.Filename != "0_0.tif" {
+		t.Errorf("got complex source file %+v, want 0_0.tif relative to the VRT", fn)
+	}
+
+	m.ImageGeoreferencing.SpatialReferenceSystemCode = ""
+	if err := vrt.AddCutlineMask(m, tiles, g); err == nil {
+		t.Error("expected an error masking imagery that isn't georeferenced")
+	}
+}
+
+func TestWriteCOGCutline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cutline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m, tiles, g := cutlineTestImage(t, dir)
+
+	cogPath := filepath.Join(dir, "out.tif")
+	if err := WriteCOG(cogPath, m, tiles, WithCOGCutline(g)); err != nil {
+		t.Fatal(err)
+	}
+	img, err := readTIFF(cogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := 0; r < img.height; r++ {
+		for c := 0; c < img.width; c++ {
+			want := testPixel(c, r, 0)
+			if c >= 150 || r >= 100 {
+				want = 0
+			}
+			if got := float64(img.pix[r*img.width+c]); got != want {
+				t.Fatalf("pixel (%d, %d) is %v, want %v", c, r, got, want)
+			}
+		}
+	}
+}
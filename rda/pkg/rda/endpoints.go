@@ -29,16 +29,21 @@ import (
 	"github.com/pkg/errors"
 )
 
-// urls is a package global we use to fetch rda urls; we do it this
-// way as a private global so that tests can configure the base url in
-// one place.
-var urls endpoints
+// DefaultBaseURL is the base URL of the production RDA API.
+const DefaultBaseURL = "https://rda.geobigdata.io/v1"
+
+// urls is a package global holding the default RDA endpoints used by
+// the package level functions and by Templates not given their own
+// Endpoints; we do it this way as a private global so that tests can
+// configure the base url in one place.
+var urls Endpoints
 
 func init() {
-	urls = newEndpoints("https://rda.geobigdata.io/v1")
+	urls = newEndpoints(DefaultBaseURL)
 }
 
-type endpoints struct {
+// Endpoints holds the location of the RDA API endpoints we access.
+type Endpoints struct {
 	u *url.URL
 
 	// operator is the endpoint for getting information on a RDA operator.
@@ -66,13 +71,19 @@ type endpoints struct {
 	job string
 }
 
-func newEndpoints(base string) endpoints {
+// NewEndpoints returns Endpoints rooted at the given base URL,
+// e.g. DefaultBaseURL, a staging deployment of RDA, or a local stand
+// in for it.
+func NewEndpoints(base string) (*Endpoints, error) {
 	u, err := url.Parse(base)
 	if err != nil {
-		log.Fatalf("base RDA url %s must parse successfully", base)
+		return nil, errors.Wrapf(err, "failed parsing RDA base url %s", base)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.Errorf("RDA base url %q must be an absolute URL, e.g. %s", base, DefaultBaseURL)
 	}
 
-	return endpoints{
+	return &Endpoints{
 		u: u,
 
 		operator:  "operator",
@@ -83,16 +94,29 @@ func newEndpoints(base string) endpoints {
 		tile:      "template/%s/tile/%d/%d",
 		batch:     "template/materialize",
 		job:       "template/materialize/status/%s",
+	}, nil
+}
+
+func newEndpoints(base string) Endpoints {
+	e, err := NewEndpoints(base)
+	if err != nil {
+		log.Fatalf("base RDA url %s must parse successfully", base)
 	}
+	return *e
+}
+
+// BaseURL returns the base URL that all the endpoints are rooted at.
+func (e *Endpoints) BaseURL() string {
+	return e.u.String()
 }
 
-func (e *endpoints) formURL(toJoin ...string) string {
+func (e *Endpoints) formURL(toJoin ...string) string {
 	u := *e.u
 	u.Path = path.Join(append([]string{u.Path}, toJoin...)...)
 	return u.String()
 }
 
-func (e *endpoints) stripinfoURL(catalogID string, zipped bool) string {
+func (e *Endpoints) stripinfoURL(catalogID string, zipped bool) string {
 	ep := fmt.Sprintf(e.stripinfo, catalogID)
 	if zipped {
 		ep = path.Join(ep, "factoryMetadata")
@@ -100,7 +124,7 @@ func (e *endpoints) stripinfoURL(catalogID string, zipped bool) string {
 	return e.formURL(ep)
 }
 
-func (e *endpoints) operatorURL(opNames ...string) []string {
+func (e *Endpoints) operatorURL(opNames ...string) []string {
 	if len(opNames) == 0 {
 		return []string{e.formURL(e.operator)}
 	}
@@ -112,31 +136,31 @@ func (e *endpoints) operatorURL(opNames ...string) []string {
 	return eps
 }
 
-func (e *endpoints) jobURL(jobID string) string {
+func (e *Endpoints) jobURL(jobID string) string {
 	return e.formURL(fmt.Sprintf(e.job, jobID))
 }
 
-func (e *endpoints) batchURL() string {
+func (e *Endpoints) batchURL() string {
 	return e.formURL(e.batch)
 }
 
-func (e *endpoints) uploadURL() string {
+func (e *Endpoints) uploadURL() string {
 	return e.formURL(e.upload)
 }
 
-func (e *endpoints) describeURL(templateID string) string {
+func (e *Endpoints) describeURL(templateID string) string {
 	return e.formURL(fmt.Sprintf(e.describe, templateID))
 }
 
-func (e *endpoints) metadataURL(templateID string, queryParams url.Values) (string, error) {
+func (e *Endpoints) metadataURL(templateID string, queryParams url.Values) (string, error) {
 	return e.addQueryParams(e.formURL(fmt.Sprintf(e.metadata, templateID)), queryParams)
 }
 
-func (e *endpoints) tileURL(templateID string, x, y int, queryParams url.Values) (string, error) {
+func (e *Endpoints) tileURL(templateID string, x, y int, queryParams url.Values) (string, error) {
 	return e.addQueryParams(e.formURL(fmt.Sprintf(e.tile, templateID, x, y)), queryParams)
 }
 
-func (e *endpoints) addQueryParams(ep string, queryParams url.Values) (string, error) {
+func (e *Endpoints) addQueryParams(ep string, queryParams url.Values) (string, error) {
 	u, err := url.Parse(ep)
 	if err != nil {
 		return "", errors.Wrapf(err, "failed parsing %s as a URL", ep)
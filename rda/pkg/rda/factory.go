@@ -0,0 +1,856 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// FactoryMetadata holds the DG factory metadata of a 1B image part,
+// parsed either from the part's XML file or from its older key=value
+// IMD, TIL, EPH, and ATT files.  Sections the part's metadata doesn't
+// include are nil.
+type FactoryMetadata struct {
+	// Prefix is the file name prefix of the part's metadata, e.g. PAN_P001.
+	Prefix string `json:"prefix"`
+
+	IMD       *IMD              `json:"imd,omitempty"`
+	TIL       *TIL              `json:"til,omitempty"`
+	Ephemeris *EphemerisSummary `json:"ephemeris,omitempty"`
+	Attitude  *AttitudeSummary  `json:"attitude,omitempty"`
+}
+
+// IMD is the image metadata of a 1B image part.
+type IMD struct {
+	Version          string    `json:"version"`
+	GenerationTime   time.Time `json:"generationTime"`
+	ProductOrderID   string    `json:"productOrderId"`
+	ProductCatalogID string    `json:"productCatalogId"`
+	ImageDescriptor  string    `json:"imageDescriptor"`
+	BandID           string    `json:"bandId"`
+	NumRows          int       `json:"numRows"`
+	NumColumns       int       `json:"numColumns"`
+	ProductLevel     string    `json:"productLevel"`
+	ProductType      string    `json:"productType"`
+	RadiometricLevel string    `json:"radiometricLevel"`
+	BitsPerPixel     int       `json:"bitsPerPixel"`
+
+	// FootprintWKT is the part's footprint, from the corners of its
+	// first band, as WKT in EPSG:4326.
+	FootprintWKT string `json:"footprintWkt,omitempty"`
+
+	// Bands are in the order the metadata lists them, which is the
+	// order they are stored in the part's imagery.
+	Bands []IMDBand `json:"bands"`
+	Image IMDImage  `json:"image"`
+}
+
+// IMDBand describes one band of a 1B image part.
+type IMDBand struct {
+	// Name is the band's name, e.g. P for panchromatic or N2 for the second near infrared band.
+	Name string `json:"name"`
+
+	// The band's corners.
+	ULLon float64 `json:"ulLon"`
+	ULLat float64 `json:"ulLat"`
+	URLon float64 `json:"urLon"`
+	URLat float64 `json:"urLat"`
+	LRLon float64 `json:"lrLon"`
+	LRLat float64 `json:"lrLat"`
+	LLLon float64 `json:"llLon"`
+	LLLat float64 `json:"llLat"`
+
+	// AbsCalFactor and EffectiveBandwidth convert the band's DNs to
+	// top of atmosphere radiance, in W/m^2/sr/um, as
+	// DN * AbsCalFactor / EffectiveBandwidth.
+	AbsCalFactor       float64 `json:"absCalFactor"`
+	EffectiveBandwidth float64 `json:"effectiveBandwidth"`
+	TDILevel           int     `json:"tdiLevel"`
+}
+
+// IMDImage describes how a 1B image part was collected.  Angles are
+// in degrees, and GSDs in meters.
+type IMDImage struct {
+	SatID                   string    `json:"satId"`
+	Mode                    string    `json:"mode"`
+	ScanDirection           string    `json:"scanDirection"`
+	CatID                   string    `json:"catId"`
+	FirstLineTime           time.Time `json:"firstLineTime"`
+	AvgLineRate             float64   `json:"avgLineRate"`
+	ExposureDuration        float64   `json:"exposureDuration"`
+	MeanCollectedGSD        float64   `json:"meanCollectedGSD"`
+	MeanProductGSD          float64   `json:"meanProductGSD"`
+	MeanSunAz               float64   `json:"meanSunAz"`
+	MeanSunEl               float64   `json:"meanSunEl"`
+	MeanSatAz               float64   `json:"meanSatAz"`
+	MeanSatEl               float64   `json:"meanSatEl"`
+	MeanInTrackViewAngle    float64   `json:"meanInTrackViewAngle"`
+	MeanCrossTrackViewAngle float64   `json:"meanCrossTrackViewAngle"`
+	MeanOffNadirViewAngle   float64   `json:"meanOffNadirViewAngle"`
+	CloudCover              float64   `json:"cloudCover"`
+	RevNumber               int       `json:"revNumber"`
+}
+
+// TIL describes how a 1B image part's imagery is tiled into files.
+type TIL struct {
+	BandID      string    `json:"bandId"`
+	NumTiles    int       `json:"numTiles"`
+	TileSizeX   int       `json:"tileSizeX"`
+	TileSizeY   int       `json:"tileSizeY"`
+	TileUnits   string    `json:"tileUnits"`
+	TileOverlap int       `json:"tileOverlap"`
+	Tiles       []TILTile `json:"tiles"`
+}
+
+// TILTile is one file of a 1B image part's imagery, with the pixel
+// offsets and locations of its corners.
+type TILTile struct {
+	Filename    string  `json:"filename"`
+	ULColOffset int     `json:"ulColOffset"`
+	ULRowOffset int     `json:"ulRowOffset"`
+	URColOffset int     `json:"urColOffset"`
+	URRowOffset int     `json:"urRowOffset"`
+	LRColOffset int     `json:"lrColOffset"`
+	LRRowOffset int     `json:"lrRowOffset"`
+	LLColOffset int     `json:"llColOffset"`
+	LLRowOffset int     `json:"llRowOffset"`
+	ULLon       float64 `json:"ulLon"`
+	ULLat       float64 `json:"ulLat"`
+	URLon       float64 `json:"urLon"`
+	URLat       float64 `json:"urLat"`
+	LRLon       float64 `json:"lrLon"`
+	LRLat       float64 `json:"lrLat"`
+	LLLon       float64 `json:"llLon"`
+	LLLat       float64 `json:"llLat"`
+}
+
+// SupportDataHeader is common to the ephemeris and attitude of a 1B
+// image part, which are sampled every TimeInterval seconds from
+// StartTime until EndTime.
+type SupportDataHeader struct {
+	SatID          string    `json:"satId"`
+	RevNumber      int       `json:"revNumber"`
+	StripID        string    `json:"stripId"`
+	Type           string    `json:"type"`
+	GenerationTime time.Time `json:"generationTime"`
+	StartTime      time.Time `json:"startTime"`
+	EndTime        time.Time `json:"endTime"`
+	NumPoints      int       `json:"numPoints"`
+	TimeInterval   float64   `json:"timeInterval"`
+}
+
+// EphemerisSummary summarizes where the satellite was while
+// collecting a 1B image part, rather than holding every point of its
+// ephemeris.  Positions are earth centered, earth fixed, in meters.
+type EphemerisSummary struct {
+	SupportDataHeader
+
+	FirstPosition [3]float64 `json:"firstPosition"`
+	LastPosition  [3]float64 `json:"lastPosition"`
+
+	// MeanAltitude is the satellite's mean height above the WGS84 ellipsoid, in meters.
+	MeanAltitude float64 `json:"meanAltitude"`
+
+	// MeanSpeed is the satellite's mean speed, in meters per second.
+	MeanSpeed float64 `json:"meanSpeed"`
+}
+
+// AttitudeSummary summarizes how the satellite was pointed while
+// collecting a 1B image part, rather than holding every point of its
+// attitude.  Attitudes are quaternions, q1, q2, q3, q4, with q4 the
+// scalar part.
+type AttitudeSummary struct {
+	SupportDataHeader
+
+	FirstAttitude [4]float64 `json:"firstAttitude"`
+	LastAttitude  [4]float64 `json:"lastAttitude"`
+}
+
+// ParseFactoryXML parses the XML factory metadata file of a 1B image
+// part, which holds all of its metadata in one document.
+func ParseFactoryXML(r io.Reader) (*FactoryMetadata, error) {
+	root, err := parseISDXML(r)
+	if err != nil {
+		return nil, err
+	}
+	if root.name != "isd" {
+		return nil, errors.Errorf("factory metadata XML has a root element of %s, not isd", root.orig)
+	}
+
+	fm := FactoryMetadata{}
+	if n := root.child("imd"); n != nil {
+		if fm.IMD, err = newIMD(n); err != nil {
+			return nil, err
+		}
+	}
+	if n := root.child("til"); n != nil {
+		if fm.TIL, err = newTIL(n); err != nil {
+			return nil, err
+		}
+	}
+	if n := root.child("eph"); n != nil {
+		if fm.Ephemeris, err = newEphemerisSummary(n); err != nil {
+			return nil, err
+		}
+	}
+	if n := root.child("att"); n != nil {
+		if fm.Attitude, err = newAttitudeSummary(n); err != nil {
+			return nil, err
+		}
+	}
+	return &fm, nil
+}
+
+// ParseIMD parses the image metadata of a 1B image part, given either
+// as a key=value IMD file or as its XML factory metadata file.
+func ParseIMD(r io.Reader) (*IMD, error) {
+	n, err := parseISDSection(r, "imd")
+	if err != nil {
+		return nil, err
+	}
+	return newIMD(n)
+}
+
+// ParseTIL parses the tiling of a 1B image part, given either as a
+// key=value TIL file or as its XML factory metadata file.
+func ParseTIL(r io.Reader) (*TIL, error) {
+	n, err := parseISDSection(r, "til")
+	if err != nil {
+		return nil, err
+	}
+	return newTIL(n)
+}
+
+// ParseEphemeris summarizes the ephemeris of a 1B image part, given
+// either as a key=value EPH file or as its XML factory metadata file.
+func ParseEphemeris(r io.Reader) (*EphemerisSummary, error) {
+	n, err := parseISDSection(r, "eph")
+	if err != nil {
+		return nil, err
+	}
+	return newEphemerisSummary(n)
+}
+
+// ParseAttitude summarizes the attitude of a 1B image part, given
+// either as a key=value ATT file or as its XML factory metadata file.
+func ParseAttitude(r io.Reader) (*AttitudeSummary, error) {
+	n, err := parseISDSection(r, "att")
+	if err != nil {
+		return nil, err
+	}
+	return newAttitudeSummary(n)
+}
+
+// ParseFactoryMetadataZip parses the factory metadata of every 1B
+// image part in a zip of them, as RDA returns for a strip, ordered by
+// prefix.  A part's XML file is used if it has one, otherwise its
+// key=value files are.
+func ParseFactoryMetadataZip(r io.ReaderAt, size int64) ([]*FactoryMetadata, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading factory metadata zip")
+	}
+
+	// Group the files of each part by their prefix.
+	parts := make(map[string]map[string]*zip.File)
+	for _, f := range zr.File {
+		name := path.Base(f.Name)
+		ext := strings.ToUpper(path.Ext(name))
+		prefix := strings.TrimSuffix(name, path.Ext(name))
+		switch ext {
+		case ".XML", ".IMD", ".TIL", ".EPH", ".ATT":
+		default:
+			continue
+		}
+		if parts[prefix] == nil {
+			parts[prefix] = make(map[string]*zip.File)
+		}
+		parts[prefix][ext] = f
+	}
+
+	prefixes := make([]string, 0, len(parts))
+	for prefix := range parts {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	var fms []*FactoryMetadata
+	for _, prefix := range prefixes {
+		files := parts[prefix]
+		fm := &FactoryMetadata{}
+		if f, ok := files[".XML"]; ok {
+			if err := parseZipFile(f, func(r io.Reader) (err error) {
+				fm, err = ParseFactoryXML(r)
+				return err
+			}); err != nil {
+				return nil, err
+			}
+		} else {
+			for _, section := range []struct {
+				ext   string
+				parse func(io.Reader) error
+			}{
+				{".IMD", func(r io.Reader) (err error) { fm.IMD, err = ParseIMD(r); return }},
+				{".TIL", func(r io.Reader) (err error) { fm.TIL, err = ParseTIL(r); return }},
+				{".EPH", func(r io.Reader) (err error) { fm.Ephemeris, err = ParseEphemeris(r); return }},
+				{".ATT", func(r io.Reader) (err error) { fm.Attitude, err = ParseAttitude(r); return }},
+			} {
+				if f, ok := files[section.ext]; ok {
+					if err := parseZipFile(f, section.parse); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		fm.Prefix = prefix
+		fms = append(fms, fm)
+	}
+	return fms, nil
+}
+
+func parseZipFile(f *zip.File, parse func(io.Reader) error) error {
+	rc, err := f.Open()
+	if err != nil {
+		return errors.Wrapf(err, "failed opening %s in factory metadata zip", f.Name)
+	}
+	defer rc.Close()
+	return errors.Wrapf(parse(rc), "failed parsing %s", f.Name)
+}
+
+// FactoryMetadata fetches and parses the DG factory metadata of each
+// 1B image part of the given catalog id; see ParseFactoryMetadataZip.
+func (c *Client) FactoryMetadata(catalogID string) ([]*FactoryMetadata, error) {
+	ep := c.urls.stripinfoURL(catalogID, true)
+	res, err := c.client.Get(ep)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failure requesting %s", ep)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed fetching strip info from %s, HTTP Status: %s", ep, res.Status)
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading response from %s", ep)
+	}
+	return ParseFactoryMetadataZip(bytes.NewReader(b), int64(len(b)))
+}
+
+func newIMD(n *isdNode) (*IMD, error) {
+	f := isdFields{n: n}
+	imd := IMD{
+		Version:          f.str("version"),
+		GenerationTime:   f.time("generationtime"),
+		ProductOrderID:   f.str("productorderid"),
+		ProductCatalogID: f.str("productcatalogid"),
+		ImageDescriptor:  f.str("imagedescriptor"),
+		BandID:           f.str("bandid"),
+		NumRows:          f.int("numrows"),
+		NumColumns:       f.int("numcolumns"),
+		ProductLevel:     f.str("productlevel"),
+		ProductType:      f.str("producttype"),
+		RadiometricLevel: f.str("radiometriclevel"),
+		BitsPerPixel:     f.int("bitsperpixel"),
+	}
+
+	for _, bn := range n.groups("band") {
+		name := strings.TrimPrefix(bn.orig[len("band"):], "_")
+		if name == "" {
+			return nil, errors.Errorf("band group %s doesn't name its band, e.g. BAND_P", bn.orig)
+		}
+		bf := isdFields{n: bn}
+		imd.Bands = append(imd.Bands, IMDBand{
+			Name:               name,
+			ULLon:              bf.float("ullon"),
+			ULLat:              bf.float("ullat"),
+			URLon:              bf.float("urlon"),
+			URLat:              bf.float("urlat"),
+			LRLon:              bf.float("lrlon"),
+			LRLat:              bf.float("lrlat"),
+			LLLon:              bf.float("lllon"),
+			LLLat:              bf.float("lllat"),
+			AbsCalFactor:       bf.float("abscalfactor"),
+			EffectiveBandwidth: bf.float("effectivebandwidth"),
+			TDILevel:           bf.int("tdilevel"),
+		})
+		if bf.err != nil {
+			return nil, errors.Wrapf(bf.err, "band %s", bn.orig)
+		}
+	}
+	if len(imd.Bands) > 0 {
+		b := imd.Bands[0]
+		ring := Ring{{b.ULLon, b.ULLat}, {b.URLon, b.URLat}, {b.LRLon, b.LRLat}, {b.LLLon, b.LLLat}, {b.ULLon, b.ULLat}}
+		imd.FootprintWKT = (&Geometry{SRS: "EPSG:4326", Polygons: []Polygon{{ring}}}).WKT()
+	}
+
+	if images := n.groups("image"); len(images) > 0 {
+		inf := isdFields{n: images[0]}
+		imd.Image = IMDImage{
+			SatID:                   inf.str("satid"),
+			Mode:                    inf.str("mode"),
+			ScanDirection:           inf.str("scandirection"),
+			CatID:                   inf.str("catid"),
+			FirstLineTime:           inf.time("firstlinetime"),
+			AvgLineRate:             inf.float("avglinerate"),
+			ExposureDuration:        inf.float("exposureduration"),
+			MeanCollectedGSD:        inf.float("meancollectedgsd"),
+			MeanProductGSD:          inf.float("meanproductgsd"),
+			MeanSunAz:               inf.float("meansunaz"),
+			MeanSunEl:               inf.float("meansunel"),
+			MeanSatAz:               inf.float("meansataz"),
+			MeanSatEl:               inf.float("meansatel"),
+			MeanInTrackViewAngle:    inf.float("meanintrackviewangle"),
+			MeanCrossTrackViewAngle: inf.float("meancrosstrackviewangle"),
+			MeanOffNadirViewAngle:   inf.float("meanoffnadirviewangle"),
+			CloudCover:              inf.float("cloudcover"),
+			RevNumber:               inf.int("revnumber"),
+		}
+		if inf.err != nil {
+			return nil, errors.Wrap(inf.err, "image")
+		}
+	}
+	return &imd, errors.Wrap(f.err, "IMD")
+}
+
+func newTIL(n *isdNode) (*TIL, error) {
+	f := isdFields{n: n}
+	til := TIL{
+		BandID:      f.str("bandid"),
+		NumTiles:    f.int("numtiles"),
+		TileSizeX:   f.int("tilesizex"),
+		TileSizeY:   f.int("tilesizey"),
+		TileUnits:   f.str("tileunits"),
+		TileOverlap: f.int("tileoverlap"),
+	}
+	for _, tn := range n.groups("tile") {
+		tf := isdFields{n: tn}
+		til.Tiles = append(til.Tiles, TILTile{
+			Filename:    tf.str("filename"),
+			ULColOffset: tf.int("ulcoloffset"),
+			ULRowOffset: tf.int("ulrowoffset"),
+			URColOffset: tf.int("urcoloffset"),
+			URRowOffset: tf.int("urrowoffset"),
+			LRColOffset: tf.int("lrcoloffset"),
+			LRRowOffset: tf.int("lrrowoffset"),
+			LLColOffset: tf.int("llcoloffset"),
+			LLRowOffset: tf.int("llrowoffset"),
+			ULLon:       tf.float("ullon"),
+			ULLat:       tf.float("ullat"),
+			URLon:       tf.float("urlon"),
+			URLat:       tf.float("urlat"),
+			LRLon:       tf.float("lrlon"),
+			LRLat:       tf.float("lrlat"),
+			LLLon:       tf.float("lllon"),
+			LLLat:       tf.float("lllat"),
+		})
+		if tf.err != nil {
+			return nil, errors.Wrapf(tf.err, "TIL %s", tn.orig)
+		}
+	}
+	return &til, errors.Wrap(f.err, "TIL")
+}
+
+func newSupportDataHeader(f *isdFields) SupportDataHeader {
+	h := SupportDataHeader{
+		SatID:          f.str("satid"),
+		RevNumber:      f.int("revnumber"),
+		StripID:        f.str("stripid"),
+		Type:           f.str("type"),
+		GenerationTime: f.time("generationtime"),
+		StartTime:      f.time("starttime"),
+		NumPoints:      f.int("numpoints"),
+		TimeInterval:   f.float("timeinterval"),
+	}
+	if h.NumPoints > 0 && !h.StartTime.IsZero() {
+		h.EndTime = h.StartTime.Add(time.Duration(float64(h.NumPoints-1) * h.TimeInterval * float64(time.Second)))
+	}
+	return h
+}
+
+func newEphemerisSummary(n *isdNode) (*EphemerisSummary, error) {
+	f := isdFields{n: n}
+	eph := EphemerisSummary{SupportDataHeader: newSupportDataHeader(&f)}
+
+	// Each point is its index, position, velocity, and the covariance of its position.
+	points := f.rows("ephemlist", 7)
+	if f.err != nil {
+		return nil, errors.Wrap(f.err, "EPH")
+	}
+	if len(points) == 0 {
+		return &eph, nil
+	}
+	copy(eph.FirstPosition[:], points[0][1:4])
+	copy(eph.LastPosition[:], points[len(points)-1][1:4])
+	for _, p := range points {
+		eph.MeanAltitude += ellipsoidHeight(p[1], p[2], p[3])
+		eph.MeanSpeed += math.Sqrt(p[4]*p[4] + p[5]*p[5] + p[6]*p[6])
+	}
+	eph.MeanAltitude /= float64(len(points))
+	eph.MeanSpeed /= float64(len(points))
+	return &eph, nil
+}
+
+func newAttitudeSummary(n *isdNode) (*AttitudeSummary, error) {
+	f := isdFields{n: n}
+	att := AttitudeSummary{SupportDataHeader: newSupportDataHeader(&f)}
+
+	// Each point is its index, quaternion, and the quaternion's covariance.
+	points := f.rows("attlist", 5)
+	if f.err != nil {
+		return nil, errors.Wrap(f.err, "ATT")
+	}
+	if len(points) > 0 {
+		copy(att.FirstAttitude[:], points[0][1:5])
+		copy(att.LastAttitude[:], points[len(points)-1][1:5])
+	}
+	return &att, nil
+}
+
+// ellipsoidHeight returns the height above the WGS84 ellipsoid of the
+// earth centered, earth fixed point (x, y, z), via Bowring's method.
+func ellipsoidHeight(x, y, z float64) float64 {
+	a, f := wgs84A, wgs84F
+	b := a * (1 - f)
+	e2 := f * (2 - f)
+	ep2 := e2 / (1 - e2)
+
+	p := math.Hypot(x, y)
+	theta := math.Atan2(z*a, p*b)
+	sin, cos := math.Sincos(theta)
+	lat := math.Atan2(z+ep2*b*sin*sin*sin, p-e2*a*cos*cos*cos)
+	sinLat, cosLat := math.Sincos(lat)
+	n := a / math.Sqrt(1-e2*sinLat*sinLat)
+	if math.Abs(cosLat) > 1e-6 {
+		return p/cosLat - n
+	}
+	return math.Abs(z)/math.Abs(sinLat) - n*(1-e2)
+}
+
+// isdNode is an element of DG image support data, parsed from either
+// its XML or key=value syntax, which share their names once lower
+// cased.  A node has either children or a value.  Lists, e.g. the
+// points of an ephemeris, are a node named after the list with a
+// "list" suffix, holding a node per row whose value is the row's
+// values separated by spaces.
+type isdNode struct {
+	name     string
+	orig     string
+	value    string
+	children []*isdNode
+}
+
+// child returns the first child named name, or nil if there isn't one.
+func (n *isdNode) child(name string) *isdNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// groups returns the children named prefix, or prefix followed by an
+// underscore and a suffix, e.g. IMAGE in XML and IMAGE_1 in key=value
+// syntax, or BAND_P and BAND_MS1.
+func (n *isdNode) groups(prefix string) []*isdNode {
+	var out []*isdNode
+	for _, c := range n.children {
+		if c.name == prefix || strings.HasPrefix(c.name, prefix+"_") {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// parseISDSection parses r, either XML or key=value syntax, returning
+// the section named name, e.g. imd.  An XML factory metadata file
+// holds all the sections, while a key=value file is a single section.
+func parseISDSection(r io.Reader, name string) (*isdNode, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading factory metadata")
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(b), []byte("<")) {
+		return parseISDKeyValue(string(b))
+	}
+
+	root, err := parseISDXML(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if root.name == name {
+		return root, nil
+	}
+	if n := root.child(name); n != nil {
+		return n, nil
+	}
+	return nil, errors.Errorf("factory metadata XML has no %s section", strings.ToUpper(name))
+}
+
+// parseISDXML parses XML image support data into a tree of isdNodes,
+// returning the root element.
+func parseISDXML(r io.Reader) (*isdNode, error) {
+	d := xml.NewDecoder(r)
+	var stack []*isdNode
+	var text strings.Builder
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil, errors.New("factory metadata XML ended before its root element did")
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed parsing factory metadata XML")
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &isdNode{name: strings.ToLower(t.Name.Local), orig: t.Name.Local}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+			stack = append(stack, n)
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			if len(n.children) == 0 {
+				n.value = strings.TrimSpace(text.String())
+			}
+			text.Reset()
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return n, nil
+			}
+		}
+	}
+}
+
+// parseISDKeyValue parses the key=value syntax of IMD, TIL, EPH, ATT,
+// and RPB files into a tree of isdNodes, returning an unnamed root.
+// Statements are "key = value;", with values quoted or not, lists
+// given as "key = ((a, b), (c, d));", and groups opened by
+// "BEGIN_GROUP = name" and closed by "END_GROUP = name".
+func parseISDKeyValue(s string) (*isdNode, error) {
+	root := &isdNode{}
+	stack := []*isdNode{root}
+	for line := 1; ; {
+		// Skip to the next statement, counting lines for errors.
+		trimmed := strings.TrimLeftFunc(s, unicode.IsSpace)
+		line += strings.Count(s[:len(s)-len(trimmed)], "\n")
+		s = trimmed
+		if s == "" {
+			break
+		}
+
+		end := strings.IndexAny(s, "=;\n")
+		if end < 0 || s[end] != '=' {
+			// A statement without a value, which can only be the END terminating the file.
+			if end < 0 {
+				end = len(s)
+			}
+			if stmt := strings.TrimSpace(s[:end]); stmt != "END" {
+				return nil, errors.Errorf("line %d: expected key = value, but got %q", line, stmt)
+			}
+			s = strings.TrimPrefix(s[end:], ";")
+			continue
+		}
+		key := strings.TrimSpace(s[:end])
+		s = strings.TrimLeft(s[end+1:], " \t")
+
+		var val string
+		if strings.HasPrefix(s, "(") {
+			depth, i := 0, 0
+			for ; i < len(s); i++ {
+				if s[i] == '(' {
+					depth++
+				} else if s[i] == ')' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			if depth != 0 {
+				return nil, errors.Errorf("line %d: list %s isn't closed", line, key)
+			}
+			val = s[:i+1]
+			line += strings.Count(val, "\n")
+			s = strings.TrimLeft(s[i+1:], " \t")
+		} else {
+			end := strings.IndexAny(s, ";\n")
+			if end < 0 {
+				end = len(s)
+			}
+			val = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		s = strings.TrimPrefix(s, ";")
+
+		parent := stack[len(stack)-1]
+		switch key {
+		case "BEGIN_GROUP":
+			n := &isdNode{name: strings.ToLower(val), orig: val}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case "END_GROUP":
+			if len(stack) == 1 || parent.orig != val {
+				return nil, errors.Errorf("line %d: END_GROUP = %s doesn't close an open group", line, val)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if strings.HasPrefix(val, "(") {
+				parent.children = append(parent.children, isdList(key, val))
+			} else {
+				parent.children = append(parent.children, &isdNode{name: strings.ToLower(key), orig: key, value: strings.Trim(val, `"`)})
+			}
+		}
+	}
+	if len(stack) > 1 {
+		return nil, errors.Errorf("group %s isn't closed", stack[len(stack)-1].orig)
+	}
+	return root, nil
+}
+
+// isdList returns the list val, e.g. "((a, b), (c, d))" or a single
+// row "(a, b)", as a node named key with a "list" suffix holding a
+// node per row, as XML image support data does.
+func isdList(key, val string) *isdNode {
+	n := &isdNode{name: strings.ToLower(key) + "list", orig: key + "List"}
+	inner := strings.TrimSpace(val[1 : len(val)-1])
+	var rows []string
+	if strings.HasPrefix(inner, "(") {
+		for _, row := range strings.Split(inner, ")") {
+			if row = strings.Trim(strings.TrimSpace(row), ",( \t\n"); row != "" {
+				rows = append(rows, row)
+			}
+		}
+	} else {
+		rows = []string{inner}
+	}
+	for _, row := range rows {
+		vals := strings.Split(row, ",")
+		for i := range vals {
+			vals[i] = strings.TrimSpace(vals[i])
+		}
+		n.children = append(n.children, &isdNode{name: strings.ToLower(key), orig: key, value: strings.Join(vals, " ")})
+	}
+	return n
+}
+
+// isdFields reads typed values out of an isdNode, keeping the first
+// error encountered.  Missing values are left as zero values, as
+// which fields are present varies with the metadata's version.
+type isdFields struct {
+	n   *isdNode
+	err error
+}
+
+func (f *isdFields) str(name string) string {
+	if c := f.n.child(name); c != nil {
+		return c.value
+	}
+	return ""
+}
+
+func (f *isdFields) float(name string) float64 {
+	s := f.str(name)
+	if s == "" {
+		return 0
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil && f.err == nil {
+		f.err = errors.Errorf("%s = %q is not a number", name, s)
+	}
+	return v
+}
+
+func (f *isdFields) int(name string) int {
+	s := f.str(name)
+	if s == "" {
+		return 0
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil && f.err == nil {
+		f.err = errors.Errorf("%s = %q is not an integer", name, s)
+	}
+	return v
+}
+
+func (f *isdFields) time(name string) time.Time {
+	s := f.str(name)
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil && f.err == nil {
+		f.err = errors.Errorf("%s = %q is not a time", name, s)
+	}
+	return t
+}
+
+// rows returns the rows of the list named name, each of which must
+// have at least minLen values.
+func (f *isdFields) rows(name string, minLen int) [][]float64 {
+	list := f.n.child(name + "list")
+	if list == nil {
+		return nil
+	}
+	var out [][]float64
+	for i, row := range list.children {
+		fields := strings.Fields(row.value)
+		if len(fields) < minLen {
+			if f.err == nil {
+				f.err = errors.Errorf("%s row %d has %d values, but needs at least %d", name, i+1, len(fields), minLen)
+			}
+			return nil
+		}
+		vals := make([]float64, len(fields))
+		for j, field := range fields {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				if f.err == nil {
+					f.err = errors.Errorf("%s row %d has %q, which is not a number", name, i+1, field)
+				}
+				return nil
+			}
+			vals[j] = v
+		}
+		out = append(out, vals)
+	}
+	return out
+}
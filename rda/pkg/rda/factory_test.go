@@ -0,0 +1,213 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"archive/zip"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFactoryXML(t *testing.T) {
+	f, err := os.Open(filepath.Join("test-fixtures", "metadata", "MUL_P001.XML"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fm, err := ParseFactoryXML(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fm.IMD == nil || fm.TIL == nil || fm.Ephemeris == nil || fm.Attitude == nil {
+		t.Fatalf("parsed factory metadata is missing sections: %+v", fm)
+	}
+
+	var names []string
+	for _, b := range fm.IMD.Bands {
+		names = append(names, b.Name)
+		if b.AbsCalFactor <= 0 || b.EffectiveBandwidth <= 0 {
+			t.Errorf("band %s has absCalFactor %v and effectiveBandwidth %v, want both positive", b.Name, b.AbsCalFactor, b.EffectiveBandwidth)
+		}
+	}
+	if want := []string{"C", "B", "G", "Y", "R", "RE", "N", "N2"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("bands = %v, want %v", names, want)
+	}
+	if fm.IMD.Image.SatID != "WV02" {
+		t.Errorf("image satId = %q, want WV02", fm.IMD.Image.SatID)
+	}
+	if el := fm.IMD.Image.MeanSunEl; el <= 0 || el > 90 {
+		t.Errorf("image meanSunEl = %v, want between 0 and 90", el)
+	}
+	if !strings.HasPrefix(fm.IMD.FootprintWKT, "POLYGON ((") {
+		t.Errorf("footprint = %q, want a POLYGON", fm.IMD.FootprintWKT)
+	}
+
+	if len(fm.TIL.Tiles) != fm.TIL.NumTiles || fm.TIL.NumTiles == 0 {
+		t.Errorf("TIL has %d tiles, but says it has %d", len(fm.TIL.Tiles), fm.TIL.NumTiles)
+	}
+
+	eph := fm.Ephemeris
+	if want := eph.StartTime.Add(time.Duration(float64(eph.NumPoints-1) * eph.TimeInterval * float64(time.Second))); !eph.EndTime.Equal(want) {
+		t.Errorf("ephemeris endTime = %v, want %v", eph.EndTime, want)
+	}
+	if eph.MeanAltitude < 700e3 || eph.MeanAltitude > 800e3 {
+		t.Errorf("ephemeris meanAltitude = %v, want WorldView-2's altitude of about 770 km", eph.MeanAltitude)
+	}
+	if eph.MeanSpeed < 7e3 || eph.MeanSpeed > 8e3 {
+		t.Errorf("ephemeris meanSpeed = %v, want about 7.5 km/s", eph.MeanSpeed)
+	}
+
+	q := fm.Attitude.FirstAttitude
+	if norm := math.Sqrt(q[0]*q[0] + q[1]*q[1] + q[2]*q[2] + q[3]*q[3]); math.Abs(norm-1) > 1e-6 {
+		t.Errorf("first attitude %v isn't a unit quaternion", q)
+	}
+}
+
+func TestParseFactoryKeyValue(t *testing.T) {
+	zr, err := zip.OpenReader(filepath.Join("test-fixtures", "metadata", "1040010038A86500-metadata.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[filepath.Base(f.Name)] = f
+	}
+	open := func(name string) io.ReadCloser {
+		f, ok := files[name]
+		if !ok {
+			t.Fatalf("%s isn't in the metadata zip", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rc
+	}
+
+	// The key=value files of each part must parse the same as its XML file.
+	for _, prefix := range []string{"PAN_P001", "MUL_P001"} {
+		rc := open(prefix + ".XML")
+		want, err := ParseFactoryXML(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := FactoryMetadata{}
+		rc = open(prefix + ".IMD")
+		got.IMD, err = ParseIMD(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s.IMD: %v", prefix, err)
+		}
+		rc = open(prefix + ".TIL")
+		got.TIL, err = ParseTIL(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s.TIL: %v", prefix, err)
+		}
+		rc = open(prefix + ".EPH")
+		got.Ephemeris, err = ParseEphemeris(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s.EPH: %v", prefix, err)
+		}
+		rc = open(prefix + ".ATT")
+		got.Attitude, err = ParseAttitude(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s.ATT: %v", prefix, err)
+		}
+
+		if !reflect.DeepEqual(got.IMD, want.IMD) {
+			t.Errorf("%s.IMD = %+v, but the XML has %+v", prefix, got.IMD, want.IMD)
+		}
+		if !reflect.DeepEqual(got.TIL, want.TIL) {
+			t.Errorf("%s.TIL = %+v, but the XML has %+v", prefix, got.TIL, want.TIL)
+		}
+		if got.Ephemeris.SupportDataHeader != want.Ephemeris.SupportDataHeader || math.Abs(got.Ephemeris.MeanAltitude-want.Ephemeris.MeanAltitude) > 1e-3 {
+			t.Errorf("%s.EPH = %+v, but the XML has %+v", prefix, got.Ephemeris, want.Ephemeris)
+		}
+		if got.Attitude.SupportDataHeader != want.Attitude.SupportDataHeader || got.Attitude.LastAttitude != want.Attitude.LastAttitude {
+			t.Errorf("%s.ATT = %+v, but the XML has %+v", prefix, got.Attitude, want.Attitude)
+		}
+	}
+}
+
+func TestParseFactoryMetadataZip(t *testing.T) {
+	f, err := os.Open(filepath.Join("test-fixtures", "metadata", "1040010038A86500-metadata.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fms, err := ParseFactoryMetadataZip(f, fi.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prefixes []string
+	for _, fm := range fms {
+		prefixes = append(prefixes, fm.Prefix)
+		if fm.IMD == nil || fm.IMD.Image.CatID != "1040010038A86500" {
+			t.Errorf("%s doesn't have the strip's IMD", fm.Prefix)
+		}
+	}
+	if want := []string{"MUL_P001", "MUL_P002", "MUL_P003", "MUL_P004", "PAN_P001", "PAN_P002", "PAN_P003", "PAN_P004"}; !reflect.DeepEqual(prefixes, want) {
+		t.Errorf("parts = %v, want %v", prefixes, want)
+	}
+}
+
+func TestParseISDKeyValueErrors(t *testing.T) {
+	tests := []struct {
+		name, in string
+	}{
+		{"unclosed group", "BEGIN_GROUP = BAND_P\n\tabsCalFactor = 1.0;\nEND;"},
+		{"mismatched group", "BEGIN_GROUP = BAND_P\nEND_GROUP = BAND_B\nEND;"},
+		{"unclosed list", "ephemList = (\n\t(1, 2, 3);\nEND;"},
+		{"not a statement", "numRows 10;\nEND;"},
+		{"unnamed band", "BEGIN_GROUP = BAND\n\tabsCalFactor = 1.0;\nEND_GROUP = BAND\nEND;"},
+		{"empty band name", "BEGIN_GROUP = BAND_\n\tabsCalFactor = 1.0;\nEND_GROUP = BAND_\nEND;"},
+	}
+	for _, test := range tests {
+		if _, err := ParseIMD(strings.NewReader(test.in)); err == nil {
+			t.Errorf("%s: expected an error parsing %q", test.name, test.in)
+		}
+	}
+
+	if _, err := ParseIMD(strings.NewReader("numRows = ten;\nEND;")); err == nil {
+		t.Error("expected an error parsing a non-integer numRows")
+	}
+}
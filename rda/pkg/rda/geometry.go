@@ -0,0 +1,538 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Ring is a closed ring of (x, y) points; the last point may or may
+// not repeat the first.
+type Ring [][2]float64
+
+// Polygon is an exterior ring followed by any holes in it.
+type Polygon []Ring
+
+// Geometry is a polygon or multipolygon, e.g. to crop imagery to.
+type Geometry struct {
+	// SRS is the coordinate reference system of the geometry's
+	// coordinates, e.g. "EPSG:4326", or "" if it isn't known.
+	SRS string
+
+	Polygons []Polygon
+}
+
+// boxEdgePoints is how many points NewBoxGeometry places along each
+// edge of a box.
+const boxEdgePoints = 16
+
+// NewBoxGeometry returns the box with the given corners in the
+// coordinate reference system srs.  Its edges are densified so that
+// transforming it into another coordinate reference system keeps
+// close to the curves they become.
+func NewBoxGeometry(minX, minY, maxX, maxY float64, srs string) *Geometry {
+	corners := [][2]float64{{minX, maxY}, {maxX, maxY}, {maxX, minY}, {minX, minY}, {minX, maxY}}
+	var ring Ring
+	for i := 0; i+1 < len(corners); i++ {
+		a, b := corners[i], corners[i+1]
+		for j := 0; j < boxEdgePoints; j++ {
+			f := float64(j) / boxEdgePoints
+			ring = append(ring, [2]float64{a[0] + f*(b[0]-a[0]), a[1] + f*(b[1]-a[1])})
+		}
+	}
+	ring = append(ring, ring[0])
+	return &Geometry{SRS: srs, Polygons: []Polygon{{ring}}}
+}
+
+// ReadGeometry reads the polygon or multipolygon in the file at path,
+// given either as GeoJSON (see ParseGeoJSON) or as WKT (see ParseWKT).
+func ReadGeometry(path string) (*Geometry, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading geometry")
+	}
+	b = bytes.TrimSpace(b)
+	if bytes.HasPrefix(b, []byte("{")) {
+		g, err := ParseGeoJSON(b)
+		return g, errors.Wrapf(err, "failed parsing GeoJSON in %s", path)
+	}
+	g, err := ParseWKT(string(b))
+	return g, errors.Wrapf(err, "failed parsing WKT in %s", path)
+}
+
+// ParseGeoJSON returns the geometry in b, a Polygon or MultiPolygon,
+// a Feature holding one, or a FeatureCollection of them, which are
+// combined.  Its SRS is EPSG:4326, as RFC 7946 requires, unless b
+// names another via the older "crs" member.
+func ParseGeoJSON(b []byte) (*Geometry, error) {
+	var obj struct {
+		Type        string            `json:"type"`
+		Coordinates json.RawMessage   `json:"coordinates"`
+		Geometry    json.RawMessage   `json:"geometry"`
+		Features    []json.RawMessage `json:"features"`
+		CRS         *struct {
+			Properties struct {
+				Name string `json:"name"`
+			} `json:"properties"`
+		} `json:"crs"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, errors.Wrap(err, "failed decoding GeoJSON")
+	}
+
+	g := Geometry{SRS: "EPSG:4326"}
+	if obj.CRS != nil {
+		code, err := parseEPSG(obj.CRS.Properties.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "GeoJSON crs")
+		}
+		g.SRS = "EPSG:" + strconv.Itoa(code)
+	}
+
+	switch obj.Type {
+	case "Feature":
+		if len(obj.Geometry) == 0 || string(obj.Geometry) == "null" {
+			return nil, errors.New("GeoJSON feature has no geometry")
+		}
+		fg, err := ParseGeoJSON(obj.Geometry)
+		if err != nil {
+			return nil, err
+		}
+		g.Polygons = fg.Polygons
+	case "FeatureCollection":
+		if len(obj.Features) == 0 {
+			return nil, errors.New("GeoJSON feature collection has no features")
+		}
+		for i, f := range obj.Features {
+			fg, err := ParseGeoJSON(f)
+			if err != nil {
+				return nil, errors.Wrapf(err, "feature %d", i)
+			}
+			g.Polygons = append(g.Polygons, fg.Polygons...)
+		}
+	case "Polygon":
+		var poly [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &poly); err != nil {
+			return nil, errors.Wrap(err, "failed decoding GeoJSON polygon coordinates")
+		}
+		p, err := geoJSONPolygon(poly)
+		if err != nil {
+			return nil, err
+		}
+		g.Polygons = []Polygon{p}
+	case "MultiPolygon":
+		var multi [][][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &multi); err != nil {
+			return nil, errors.Wrap(err, "failed decoding GeoJSON multipolygon coordinates")
+		}
+		if len(multi) == 0 {
+			return nil, errors.New("GeoJSON multipolygon has no coordinates")
+		}
+		for _, poly := range multi {
+			p, err := geoJSONPolygon(poly)
+			if err != nil {
+				return nil, err
+			}
+			g.Polygons = append(g.Polygons, p)
+		}
+	default:
+		return nil, errors.Errorf("GeoJSON %q is not a Polygon or MultiPolygon", obj.Type)
+	}
+	return &g, nil
+}
+
+func geoJSONPolygon(coords [][][]float64) (Polygon, error) {
+	if len(coords) == 0 {
+		return nil, errors.New("GeoJSON polygon has no coordinates")
+	}
+	p := make(Polygon, len(coords))
+	for i, ring := range coords {
+		for _, pt := range ring {
+			if len(pt) < 2 {
+				return nil, errors.New("GeoJSON position has fewer than two coordinates")
+			}
+			p[i] = append(p[i], [2]float64{pt[0], pt[1]})
+		}
+		if len(p[i]) < 3 {
+			return nil, errors.Errorf("GeoJSON polygon ring has %d positions, but needs at least 3", len(p[i]))
+		}
+	}
+	return p, nil
+}
+
+// ParseWKT returns the POLYGON or MULTIPOLYGON described by the WKT
+// s.  An EWKT SRID prefix, e.g. "SRID=32615;POLYGON ((...))", sets
+// the geometry's SRS; otherwise it is left unknown.
+func ParseWKT(s string) (*Geometry, error) {
+	g := Geometry{}
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(strings.ToUpper(s), "SRID=") {
+		i := strings.Index(s, ";")
+		if i < 0 {
+			return nil, errors.Errorf("%q has an SRID but no geometry", s)
+		}
+		code, err := strconv.Atoi(strings.TrimSpace(s[len("SRID="):i]))
+		if err != nil {
+			return nil, errors.Errorf("%q has an invalid SRID", s)
+		}
+		g.SRS = "EPSG:" + strconv.Itoa(code)
+		s = s[i+1:]
+	}
+
+	p := wktParser{toks: wktTokens(s)}
+	switch kind := strings.ToUpper(p.next()); kind {
+	case "POLYGON":
+		poly, err := p.polygon()
+		if err != nil {
+			return nil, err
+		}
+		g.Polygons = []Polygon{poly}
+	case "MULTIPOLYGON":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		for {
+			poly, err := p.polygon()
+			if err != nil {
+				return nil, err
+			}
+			g.Polygons = append(g.Polygons, poly)
+			if p.peek() != "," {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("WKT %q is not a POLYGON or MULTIPOLYGON", kind)
+	}
+	if p.peek() != "" {
+		return nil, errors.Errorf("unexpected %q after the WKT geometry", p.peek())
+	}
+	return &g, nil
+}
+
+// wktTokens splits WKT into parentheses, commas, and words.
+func wktTokens(s string) []string {
+	var toks []string
+	word := strings.Builder{}
+	flush := func() {
+		if word.Len() > 0 {
+			toks = append(toks, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == ',':
+			flush()
+			toks = append(toks, string(r))
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return toks
+}
+
+type wktParser struct {
+	toks []string
+}
+
+func (p *wktParser) peek() string {
+	if len(p.toks) == 0 {
+		return ""
+	}
+	return p.toks[0]
+}
+
+func (p *wktParser) next() string {
+	tok := p.peek()
+	if len(p.toks) > 0 {
+		p.toks = p.toks[1:]
+	}
+	return tok
+}
+
+func (p *wktParser) expect(tok string) error {
+	if got := p.next(); got != tok {
+		if got == "" {
+			return errors.Errorf("WKT ended where %q was expected", tok)
+		}
+		return errors.Errorf("found %q in WKT where %q was expected", got, tok)
+	}
+	return nil
+}
+
+// polygon parses "((x y, ...), (x y, ...))".
+func (p *wktParser) polygon() (Polygon, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var poly Polygon
+	for {
+		ring, err := p.ring()
+		if err != nil {
+			return nil, err
+		}
+		poly = append(poly, ring)
+		if p.peek() != "," {
+			break
+		}
+		p.next()
+	}
+	return poly, p.expect(")")
+}
+
+// ring parses "(x y, x y, ...)", ignoring any z or m values.
+func (p *wktParser) ring() (Ring, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var ring Ring
+	for {
+		var pt []float64
+		for p.peek() != "," && p.peek() != ")" && p.peek() != "" {
+			tok := p.next()
+			v, err := strconv.ParseFloat(tok, 64)
+			if err != nil {
+				return nil, errors.Errorf("WKT coordinate %q is not a number", tok)
+			}
+			pt = append(pt, v)
+		}
+		if len(pt) < 2 || len(pt) > 4 {
+			return nil, errors.Errorf("WKT point has %d coordinates", len(pt))
+		}
+		ring = append(ring, [2]float64{pt[0], pt[1]})
+		if p.peek() != "," {
+			break
+		}
+		p.next()
+	}
+	if len(ring) < 3 {
+		return nil, errors.Errorf("WKT ring has %d points, but needs at least 3", len(ring))
+	}
+	return ring, p.expect(")")
+}
+
+// WKT returns the geometry as WKT, a POLYGON if it has one polygon and
+// a MULTIPOLYGON otherwise.
+func (g *Geometry) WKT() string {
+	polys := make([]string, len(g.Polygons))
+	for i, poly := range g.Polygons {
+		rings := make([]string, len(poly))
+		for j, ring := range poly {
+			pts := make([]string, len(ring))
+			for k, pt := range ring {
+				pts[k] = strconv.FormatFloat(pt[0], 'f', -1, 64) + " " + strconv.FormatFloat(pt[1], 'f', -1, 64)
+			}
+			rings[j] = "(" + strings.Join(pts, ", ") + ")"
+		}
+		polys[i] = "(" + strings.Join(rings, ", ") + ")"
+	}
+	if len(polys) == 1 {
+		return "POLYGON " + polys[0]
+	}
+	return "MULTIPOLYGON (" + strings.Join(polys, ", ") + ")"
+}
+
+// GeoJSON returns the geometry as a GeoJSON Polygon if it has one
+// polygon and a MultiPolygon otherwise.  Its SRS isn't recorded, so
+// it should be EPSG:4326 as RFC 7946 requires.
+func (g *Geometry) GeoJSON() json.RawMessage {
+	polys := make([][][][2]float64, len(g.Polygons))
+	for i, poly := range g.Polygons {
+		polys[i] = make([][][2]float64, len(poly))
+		for j, ring := range poly {
+			polys[i][j] = ring
+		}
+	}
+
+	var obj struct {
+		Type        string      `json:"type"`
+		Coordinates interface{} `json:"coordinates"`
+	}
+	if len(polys) == 1 {
+		obj.Type, obj.Coordinates = "Polygon", polys[0]
+	} else {
+		obj.Type, obj.Coordinates = "MultiPolygon", polys
+	}
+	b, _ := json.Marshal(obj)
+	return b
+}
+
+// Transform returns the geometry reprojected to the coordinate
+// reference system srs; see NewCoordTransform for those supported.
+// Only the vertices are transformed, so edges should be short
+// compared to how curved they become.
+func (g *Geometry) Transform(srs string) (*Geometry, error) {
+	if g.SRS == "" {
+		return nil, errors.New("the geometry's coordinate reference system isn't known")
+	}
+	ct, err := NewCoordTransform(g.SRS, srs)
+	if err != nil {
+		return nil, err
+	}
+	return g.apply(srs, ct.Apply), nil
+}
+
+// apply returns the geometry with f applied to every point.
+func (g *Geometry) apply(srs string, f func(x, y float64) (float64, float64)) *Geometry {
+	out := Geometry{SRS: srs, Polygons: make([]Polygon, len(g.Polygons))}
+	for i, poly := range g.Polygons {
+		out.Polygons[i] = make(Polygon, len(poly))
+		for j, ring := range poly {
+			r := make(Ring, len(ring))
+			for k, pt := range ring {
+				r[k][0], r[k][1] = f(pt[0], pt[1])
+			}
+			out.Polygons[i][j] = r
+		}
+	}
+	return &out
+}
+
+// Bounds returns the bounding box of the geometry.
+func (g *Geometry) Bounds() (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, poly := range g.Polygons {
+		for _, ring := range poly {
+			for _, pt := range ring {
+				minX, maxX = math.Min(minX, pt[0]), math.Max(maxX, pt[0])
+				minY, maxY = math.Min(minY, pt[1]), math.Max(maxY, pt[1])
+			}
+		}
+	}
+	return minX, minY, maxX, maxY
+}
+
+// Contains returns true if (x, y) is inside the geometry.
+func (g *Geometry) Contains(x, y float64) bool {
+	for _, poly := range g.Polygons {
+		// Within a polygon, holes count as crossings too, so being
+		// inside is an odd number of crossings.
+		inside := false
+		for _, ring := range poly {
+			for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+				a, b := ring[i], ring[j]
+				if (a[1] > y) != (b[1] > y) && x < a[0]+(y-a[1])*(b[0]-a[0])/(b[1]-a[1]) {
+					inside = !inside
+				}
+			}
+		}
+		if inside {
+			return true
+		}
+	}
+	return false
+}
+
+// IntersectsQuad returns true if the geometry overlaps the
+// quadrilateral with the given corners, in order around it.
+func (g *Geometry) IntersectsQuad(quad [4][2]float64) bool {
+	quadGeom := Geometry{Polygons: []Polygon{{quad[:]}}}
+	for _, pt := range quad {
+		if g.Contains(pt[0], pt[1]) {
+			return true
+		}
+	}
+	for _, poly := range g.Polygons {
+		for _, ring := range poly {
+			for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+				if quadGeom.Contains(ring[i][0], ring[i][1]) {
+					return true
+				}
+				for k, l := 0, 3; k < 4; l, k = k, k+1 {
+					if segmentsCross(ring[j], ring[i], quad[l], quad[k]) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// segmentsCross returns true if the segments ab and cd cross.
+func segmentsCross(a, b, c, d [2]float64) bool {
+	orient := func(p, q, r [2]float64) float64 {
+		return (q[0]-p[0])*(r[1]-p[1]) - (q[1]-p[1])*(r[0]-p[0])
+	}
+	d1, d2 := orient(c, d, a), orient(c, d, b)
+	d3, d4 := orient(a, b, c), orient(a, b, d)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+// rasterize sets pix, a width by height image, to 255 where the
+// center of a pixel is inside the geometry and 0 elsewhere.  The
+// geometry is given in pixel coordinates with (x0, y0) the top left
+// of pix.
+func (g *Geometry) rasterize(pix []byte, width, height int, x0, y0 float64) {
+	for i := range pix {
+		pix[i] = 0
+	}
+	var xs []float64
+	for row := 0; row < height; row++ {
+		y := y0 + float64(row) + 0.5
+		for _, poly := range g.Polygons {
+			// Fill between pairs of crossings of the row's center
+			// line, which handles holes too.
+			xs = xs[:0]
+			for _, ring := range poly {
+				for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+					a, b := ring[i], ring[j]
+					if (a[1] > y) != (b[1] > y) {
+						xs = append(xs, a[0]+(y-a[1])*(b[0]-a[0])/(b[1]-a[1]))
+					}
+				}
+			}
+			sort.Float64s(xs)
+			for k := 0; k+1 < len(xs); k += 2 {
+				// Pixel col is filled if xs[k] <= x0+col+0.5 < xs[k+1].
+				first := int(math.Ceil(xs[k] - x0 - 0.5))
+				last := int(math.Ceil(xs[k+1]-x0-0.5)) - 1
+				if first < 0 {
+					first = 0
+				}
+				if last >= width {
+					last = width - 1
+				}
+				for col := first; col <= last; col++ {
+					pix[row*width+col] = 255
+				}
+			}
+		}
+	}
+}
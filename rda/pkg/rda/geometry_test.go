@@ -0,0 +1,235 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseGeoJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		geojson string
+		want    string
+		wantSRS string
+		wantErr bool
+	}{
+		{"polygon", `{"type": "Polygon", "coordinates": [[[0, 0], [10.5, 0], [10.5, 10], [0, 0]]]}`, "POLYGON ((0 0, 10.5 0, 10.5 10, 0 0))", "EPSG:4326", false},
+		{"polygon-with-hole", `{"type": "Polygon", "coordinates": [[[0, 0], [4, 0], [4, 4], [0, 0]], [[1, 1], [2, 1], [2, 2], [1, 1]]]}`, "POLYGON ((0 0, 4 0, 4 4, 0 0), (1 1, 2 1, 2 2, 1 1))", "EPSG:4326", false},
+		{"multipolygon", `{"type": "MultiPolygon", "coordinates": [[[[0, 0], [1, 0], [1, 1], [0, 0]]], [[[5, 5], [6, 5], [6, 6], [5, 5]]]]}`, "MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)), ((5 5, 6 5, 6 6, 5 5)))", "EPSG:4326", false},
+		{"feature", `{"type": "Feature", "properties": {}, "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}}`, "POLYGON ((0 0, 1 0, 1 1, 0 0))", "EPSG:4326", false},
+		{"collection", `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}}, {"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[5, 5], [6, 5], [6, 6], [5, 5]]]}}]}`, "MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)), ((5 5, 6 5, 6 6, 5 5)))", "EPSG:4326", false},
+		{"crs", `{"type": "Polygon", "crs": {"type": "name", "properties": {"name": "urn:ogc:def:crs:EPSG::32615"}}, "coordinates": [[[500000, 0], [500100, 0], [500100, 100], [500000, 0]]]}`, "POLYGON ((500000 0, 500100 0, 500100 100, 500000 0))", "EPSG:32615", false},
+		{"feature-without-geometry", `{"type": "FeatureCollection", "features": [{"type": "Feature"}]}`, "", "", true},
+		{"short-ring", `{"type": "Polygon", "coordinates": [[[0, 0], [1, 1]]]}`, "", "", true},
+		{"point", `{"type": "Point", "coordinates": [0, 0]}`, "", "", true},
+		{"not-json", `POLYGON ((0 0, 1 0, 1 1, 0 0))`, "", "", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := ParseGeoJSON([]byte(tc.geojson))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got err = %v, want an error: %t", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := g.WKT(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if g.SRS != tc.wantSRS {
+				t.Errorf("got SRS %q, want %q", g.SRS, tc.wantSRS)
+			}
+		})
+	}
+}
+
+func TestParseWKT(t *testing.T) {
+	tests := []struct {
+		wkt     string
+		want    string
+		wantSRS string
+		wantErr bool
+	}{
+		{"POLYGON ((0 0, 1 0, 1 1, 0 0))", "POLYGON ((0 0, 1 0, 1 1, 0 0))", "", false},
+		{" multipolygon (((0 0,1 0,1 1,0 0)),((5 5, 6 5, 6 6)))\n", "MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)), ((5 5, 6 5, 6 6)))", "", false},
+		{"SRID=32615;POLYGON Z ((500000 0 1, 500100 0 1, 500100 100 1, 500000 0 1))", "", "", true},
+		{"SRID=32615;POLYGON ((500000 0 1, 500100 0 1, 500100 100 1, 500000 0 1))", "POLYGON ((500000 0, 500100 0, 500100 100, 500000 0))", "EPSG:32615", false},
+		{"POINT (0 0)", "", "", true},
+		{"POLYGON ((0 0, 1 0, 1 1, 0 0)", "", "", true},
+		{"POLYGON )0 0, 1 0, 1 1, 0 0(", "", "", true},
+		{"POLYGON ((0 0, 1 x, 1 1, 0 0))", "", "", true},
+		{"POLYGON ((0 0, 1 1))", "", "", true},
+		{"POLYGON ((0 0, 1 0, 1 1, 0 0)) extra", "", "", true},
+		{"SRID=abc;POLYGON ((0 0, 1 0, 1 1, 0 0))", "", "", true},
+	}
+	for _, tc := range tests {
+		g, err := ParseWKT(tc.wkt)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseWKT(%q) err = %v, want an error: %t", tc.wkt, err, tc.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := g.WKT(); got != tc.want {
+			t.Errorf("ParseWKT(%q) = %q, want %q", tc.wkt, got, tc.want)
+		}
+		if g.SRS != tc.wantSRS {
+			t.Errorf("ParseWKT(%q) SRS = %q, want %q", tc.wkt, g.SRS, tc.wantSRS)
+		}
+	}
+}
+
+func TestGeometryGeoJSON(t *testing.T) {
+	tests := []struct {
+		wkt  string
+		want string
+	}{
+		{"POLYGON ((0 0, 1.5 0, 1 1, 0 0))", `{"type":"Polygon","coordinates":[[[0,0],[1.5,0],[1,1],[0,0]]]}`},
+		{"MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)), ((5 5, 6 5, 6 6, 5 5)))", `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[5,5],[6,5],[6,6],[5,5]]]]}`},
+	}
+	for _, tc := range tests {
+		g, err := ParseWKT(tc.wkt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(g.GeoJSON()); got != tc.want {
+			t.Errorf("GeoJSON of %q = %s, want %s", tc.wkt, got, tc.want)
+		}
+	}
+}
+
+// squareWithHole is a 10x10 square with a 2x2 hole in its middle,
+// plus a separate 1x1 square.
+const squareWithHole = "MULTIPOLYGON (((0 0, 10 0, 10 10, 0 10, 0 0), (4 4, 6 4, 6 6, 4 6, 4 4)), ((20 20, 21 20, 21 21, 20 21, 20 20)))"
+
+func TestGeometryContains(t *testing.T) {
+	g, err := ParseWKT(squareWithHole)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		x, y float64
+		want bool
+	}{
+		{1, 1, true},
+		{9.5, 5, true},
+		{5, 5, false},
+		{11, 5, false},
+		{-1, -1, false},
+		{20.5, 20.5, true},
+		{15, 15, false},
+	} {
+		if got := g.Contains(tc.x, tc.y); got != tc.want {
+			t.Errorf("Contains(%v, %v) = %t, want %t", tc.x, tc.y, got, tc.want)
+		}
+	}
+
+	if minX, minY, maxX, maxY := g.Bounds(); minX != 0 || minY != 0 || maxX != 21 || maxY != 21 {
+		t.Errorf("Bounds() = (%v, %v, %v, %v), want (0, 0, 21, 21)", minX, minY, maxX, maxY)
+	}
+}
+
+func TestGeometryIntersectsQuad(t *testing.T) {
+	g, err := ParseWKT(squareWithHole)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quad := func(x0, y0, x1, y1 float64) [4][2]float64 {
+		return [4][2]float64{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+	}
+	for _, tc := range []struct {
+		name string
+		quad [4][2]float64
+		want bool
+	}{
+		{"inside", quad(1, 1, 2, 2), true},
+		{"overlapping-corner", quad(-1, -1, 1, 1), true},
+		{"covering", quad(-5, -5, 30, 30), true},
+		{"crossing-without-corners-inside", quad(-1, 2, 11, 3), true},
+		{"in-hole", quad(4.5, 4.5, 5.5, 5.5), false},
+		{"outside", quad(12, 12, 18, 18), false},
+		{"rotated", [4][2]float64{{10.5, 12}, {12, 10.5}, {13, 11.5}, {11.5, 13}}, false},
+	} {
+		if got := g.IntersectsQuad(tc.quad); got != tc.want {
+			t.Errorf("%s: IntersectsQuad(%v) = %t, want %t", tc.name, tc.quad, got, tc.want)
+		}
+	}
+}
+
+func TestGeometryRasterize(t *testing.T) {
+	g, err := ParseWKT(squareWithHole)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rasterize a window offset from the geometry's origin, checking
+	// each pixel center against Contains.
+	const w, h, x0, y0 = 12, 9, -1, 2
+	pix := make([]byte, w*h)
+	for i := range pix {
+		pix[i] = 7
+	}
+	g.rasterize(pix, w, h, x0, y0)
+	for r := 0; r < h; r++ {
+		for c := 0; c < w; c++ {
+			want := byte(0)
+			if g.Contains(x0+float64(c)+0.5, y0+float64(r)+0.5) {
+				want = 255
+			}
+			if got := pix[r*w+c]; got != want {
+				t.Fatalf("pixel (%d, %d) is %d, want %d", c, r, got, want)
+			}
+		}
+	}
+}
+
+func TestNewBoxGeometry(t *testing.T) {
+	g := NewBoxGeometry(-94, 44, -92, 46, "EPSG:4326")
+	if minX, minY, maxX, maxY := g.Bounds(); minX != -94 || minY != 44 || maxX != -92 || maxY != 46 {
+		t.Fatalf("Bounds() = (%v, %v, %v, %v), want (-94, 44, -92, 46)", minX, minY, maxX, maxY)
+	}
+	if !g.Contains(-93, 45) || g.Contains(-91, 45) {
+		t.Fatal("box should contain (-93, 45) but not (-91, 45)")
+	}
+
+	// In UTM, the bottom edge of the box sags south of its corners
+	// towards the central meridian, so the densified box's bounds
+	// reach further south than the corners do.
+	utm, err := g.Transform("EPSG:32615")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := NewCoordTransform("EPSG:4326", "EPSG:32615")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, cornerY := ct.Apply(-94, 44)
+	_, minY, _, _ := utm.Bounds()
+	if cornerY-minY < 100 {
+		t.Errorf("reprojected box reaches %v south, want well past its corner's %v", minY, cornerY)
+	}
+	if math.IsNaN(minY) {
+		t.Error("reprojected box has NaN coordinates")
+	}
+}
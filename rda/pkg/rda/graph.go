@@ -109,10 +109,120 @@ func NewGraphFromAPI(r io.Reader) (*Graph, error) {
 		}
 		g.defaultNode = defNodeIdx
 	}
+	g.defaultNodeSet = true
 
 	return &g, nil
 }
 
+// NewGraph returns an empty Graph, to be built up via AddNode and
+// Connect.
+func NewGraph() *Graph {
+	return &Graph{}
+}
+
+// AddNode adds a node with the given id that applies operator
+// configured with params.  Node ids must be unique within a graph.
+func (g *Graph) AddNode(id, operator string, params map[string]string) error {
+	if id == "" || operator == "" {
+		return errors.New("nodes require both an id and an operator")
+	}
+	if _, ok := g.nodeIndex(id); ok {
+		return errors.Errorf("the graph already has a node with id %q", id)
+	}
+
+	p := make(map[string]string, len(params))
+	for k, v := range params {
+		p[k] = v
+	}
+	g.nodes = append(g.nodes, node{ID: id, Operator: operator, Parameters: p})
+	g.edges = append(g.edges, nil)
+	return nil
+}
+
+// Connect feeds the output of the node src into the node dst.  The
+// order Connect is called for a given dst is the order its sources
+// are given to its operator, which RDA numbers from 1.
+func (g *Graph) Connect(src, dst string) error {
+	srcIdx, ok := g.nodeIndex(src)
+	if !ok {
+		return errors.Errorf("the source %q is not a node in the graph", src)
+	}
+	dstIdx, ok := g.nodeIndex(dst)
+	if !ok {
+		return errors.Errorf("the destination %q is not a node in the graph", dst)
+	}
+	g.edges[srcIdx] = append(g.edges[srcIdx], edge{nIdx: dstIdx, sourceIndex: len(g.sources(dstIdx)) + 1})
+	return nil
+}
+
+// SetDefaultNode sets the node evaluated when a template doesn't ask
+// for a particular one.  If it is never set, the node at the end of
+// the longest path through the graph is used.
+func (g *Graph) SetDefaultNode(id string) error {
+	idx, ok := g.nodeIndex(id)
+	if !ok {
+		return errors.Errorf("the default node %q is not a node in the graph", id)
+	}
+	g.defaultNode, g.defaultNodeSet = idx, true
+	return nil
+}
+
+// DefaultNode returns the id of the node evaluated when a template
+// doesn't ask for a particular one.
+func (g *Graph) DefaultNode() (string, error) {
+	if err := g.Validate(); err != nil {
+		return "", err
+	}
+	return g.nodes[g.defaultNode].ID, nil
+}
+
+// Validate checks that g is a non-empty DAG, choosing a default node
+// if one hasn't been set.
+func (g *Graph) Validate() error {
+	if len(g.nodes) == 0 {
+		return errors.New("the graph has no nodes")
+	}
+	defNodeIdx, err := g.findDefaultNode()
+	if err != nil {
+		return err
+	}
+	if !g.defaultNodeSet {
+		g.defaultNode = defNodeIdx
+	}
+	return nil
+}
+
+// nodeIndex returns the index of the node with the given id.
+func (g *Graph) nodeIndex(id string) (int, bool) {
+	for i, n := range g.nodes {
+		if n.ID == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// sources returns the indices of the nodes feeding into the node at
+// nIdx, ordered as they are given to its operator.
+func (g *Graph) sources(nIdx int) []int {
+	type source struct{ nIdx, sourceIndex int }
+	var srcs []source
+	for srcIdx, eList := range g.edges {
+		for _, e := range eList {
+			if e.nIdx == nIdx {
+				srcs = append(srcs, source{srcIdx, e.sourceIndex})
+			}
+		}
+	}
+	sort.SliceStable(srcs, func(i, j int) bool { return srcs[i].sourceIndex < srcs[j].sourceIndex })
+
+	idxs := make([]int, len(srcs))
+	for i, s := range srcs {
+		idxs[i] = s.nIdx
+	}
+	return idxs
+}
+
 // Graph encapsulates an RDA template graph.
 type Graph struct {
 	nodes []node
@@ -124,6 +234,9 @@ type Graph struct {
 
 	// defaultNode is the default node to evaluate in an RDA template.
 	defaultNode int
+
+	// defaultNodeSet is true once defaultNode has been chosen.
+	defaultNodeSet bool
 }
 
 // node is a node in an RDA graph.
@@ -165,6 +278,9 @@ func (g *Graph) toRDA() *rdaGraph {
 
 // MarshalJSON lets a Graph marshal itself as a user friendly format.
 func (g *Graph) MarshalJSON() ([]byte, error) {
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
 	return json.Marshal(g.toRDA())
 }
 
@@ -21,9 +21,13 @@
 package rda
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func loadGraph(file string, t *testing.T) (*Graph, error) {
@@ -118,3 +122,72 @@ func TestCustomGraph(t *testing.T) {
 		t.Fatalf("the default node returned should be 12, not %d", n)
 	}
 }
+
+func TestGraphBuilder(t *testing.T) {
+	g := NewGraph()
+	for _, n := range []struct{ id, op string }{
+		{"ms", "IdahoRead"},
+		{"pan", "IdahoRead"},
+		{"ps", "LocallyProjectedPanSharpen"},
+		{"ortho", "GridOrthorectify"},
+	} {
+		if err := g.AddNode(n.id, n.op, map[string]string{"key": "${" + n.id + "}"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.AddNode("ms", "IdahoRead", nil); err == nil {
+		t.Fatal("adding a node with a duplicate id should fail")
+	}
+	for _, e := range [][2]string{{"ms", "ps"}, {"pan", "ps"}, {"ps", "ortho"}} {
+		if err := g.Connect(e[0], e[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Connect("nope", "ps"); err == nil {
+		t.Fatal("connecting a missing node should fail")
+	}
+
+	def, err := g.DefaultNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def != "ortho" {
+		t.Fatalf("default node is %q, want ortho", def)
+	}
+
+	// The sources of ps must keep the order they were connected in.
+	rg := g.toRDA()
+	want := map[string]int{"ms": 1, "pan": 2, "ps": 1}
+	for _, e := range rg.Edges {
+		if e.Index != want[e.Source] {
+			t.Errorf("edge %s -> %s has index %d, want %d", e.Source, e.Destination, e.Index, want[e.Source])
+		}
+	}
+
+	// It should survive a round trip through RDA's JSON.
+	if err := g.SetDefaultNode("ps"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g2, err := NewGraphFromAPI(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(g.toRDA(), g2.toRDA()); diff != "" {
+		t.Fatalf("graph changed after a round trip through JSON (-want +got):\n%s", diff)
+	}
+
+	// Cycles are caught when validating.
+	if err := g.Connect("ortho", "ms"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Validate(); err == nil {
+		t.Fatal("a graph with a cycle should fail validation")
+	}
+	if err := NewGraph().Validate(); err == nil {
+		t.Fatal("an empty graph should fail validation")
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GraphNode describes a node of a graph.
+type GraphNode struct {
+	ID         string            `json:"id"`
+	Operator   string            `json:"operator"`
+	Parameters map[string]string `json:"parameters"`
+}
+
+// GraphEdge describes an edge of a graph, feeding Source into
+// Destination as its Index'th source.
+type GraphEdge struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Index       int    `json:"index"`
+}
+
+func (e GraphEdge) String() string {
+	return fmt.Sprintf("%q -> %q (index %d)", e.Source, e.Destination, e.Index)
+}
+
+// The ways a node's parameter can differ between two graphs.
+const (
+	ParameterAdded   = "added"
+	ParameterRemoved = "removed"
+	ParameterChanged = "changed"
+)
+
+// ParameterDiff describes how a node's parameter differs between two
+// graphs.  Change is one of ParameterAdded, ParameterRemoved, or
+// ParameterChanged.
+type ParameterDiff struct {
+	Name   string `json:"name"`
+	Change string `json:"change"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// NodeDiff describes how a node found in both of two graphs differs
+// between them.  OldOperator and NewOperator are only set if its
+// operator changed.
+type NodeDiff struct {
+	NodeID      string          `json:"nodeId"`
+	OldOperator string          `json:"oldOperator,omitempty"`
+	NewOperator string          `json:"newOperator,omitempty"`
+	Parameters  []ParameterDiff `json:"parameters,omitempty"`
+}
+
+// GraphDiff is the structural difference between two graphs, whose
+// nodes are matched by id.  OldDefaultNode and NewDefaultNode are only
+// set if the default node changed.
+type GraphDiff struct {
+	AddedNodes     []GraphNode `json:"addedNodes,omitempty"`
+	RemovedNodes   []GraphNode `json:"removedNodes,omitempty"`
+	ChangedNodes   []NodeDiff  `json:"changedNodes,omitempty"`
+	AddedEdges     []GraphEdge `json:"addedEdges,omitempty"`
+	RemovedEdges   []GraphEdge `json:"removedEdges,omitempty"`
+	OldDefaultNode string      `json:"oldDefaultNode,omitempty"`
+	NewDefaultNode string      `json:"newDefaultNode,omitempty"`
+}
+
+// Empty returns true if the graphs compared are the same.
+func (d *GraphDiff) Empty() bool {
+	return len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 && len(d.ChangedNodes) == 0 &&
+		len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0 && d.OldDefaultNode == d.NewDefaultNode
+}
+
+// String returns the differences one per line, prefixed by "-" for
+// what was removed, "+" for what was added, and "~" for what changed.
+func (d *GraphDiff) String() string {
+	var sb strings.Builder
+	for _, n := range d.RemovedNodes {
+		fmt.Fprintf(&sb, "- node %q (%s)\n", n.ID, n.Operator)
+	}
+	for _, n := range d.AddedNodes {
+		fmt.Fprintf(&sb, "+ node %q (%s)\n", n.ID, n.Operator)
+	}
+	for _, n := range d.ChangedNodes {
+		if n.OldOperator != n.NewOperator {
+			fmt.Fprintf(&sb, "~ node %q: operator %s -> %s\n", n.NodeID, n.OldOperator, n.NewOperator)
+		}
+		for _, p := range n.Parameters {
+			switch p.Change {
+			case ParameterRemoved:
+				fmt.Fprintf(&sb, "- node %q: parameter %q = %q\n", n.NodeID, p.Name, p.Old)
+			case ParameterAdded:
+				fmt.Fprintf(&sb, "+ node %q: parameter %q = %q\n", n.NodeID, p.Name, p.New)
+			default:
+				fmt.Fprintf(&sb, "~ node %q: parameter %q: %q -> %q\n", n.NodeID, p.Name, p.Old, p.New)
+			}
+		}
+	}
+	for _, e := range d.RemovedEdges {
+		fmt.Fprintf(&sb, "- edge %s\n", e)
+	}
+	for _, e := range d.AddedEdges {
+		fmt.Fprintf(&sb, "+ edge %s\n", e)
+	}
+	if d.OldDefaultNode != d.NewDefaultNode {
+		fmt.Fprintf(&sb, "~ default node %q -> %q\n", d.OldDefaultNode, d.NewDefaultNode)
+	}
+	return sb.String()
+}
+
+// DiffGraphs returns how the graph b differs from the graph a.
+func DiffGraphs(a, b *Graph) (*GraphDiff, error) {
+	aDef, err := a.DefaultNode()
+	if err != nil {
+		return nil, err
+	}
+	bDef, err := b.DefaultNode()
+	if err != nil {
+		return nil, err
+	}
+
+	var d GraphDiff
+	if aDef != bDef {
+		d.OldDefaultNode, d.NewDefaultNode = aDef, bDef
+	}
+
+	// Compare nodes, in the order they appear in each graph.
+	for _, an := range a.nodes {
+		bIdx, ok := b.nodeIndex(an.ID)
+		if !ok {
+			d.RemovedNodes = append(d.RemovedNodes, GraphNode(an))
+			continue
+		}
+		if nd := diffNodes(an, b.nodes[bIdx]); nd != nil {
+			d.ChangedNodes = append(d.ChangedNodes, *nd)
+		}
+	}
+	for _, bn := range b.nodes {
+		if _, ok := a.nodeIndex(bn.ID); !ok {
+			d.AddedNodes = append(d.AddedNodes, GraphNode(bn))
+		}
+	}
+
+	// Compare edges, which are the same if they connect the same
+	// nodes at the same index.
+	aEdges, bEdges := a.graphEdges(), b.graphEdges()
+	inEdges := func(es []GraphEdge, e GraphEdge) bool {
+		for _, o := range es {
+			if o == e {
+				return true
+			}
+		}
+		return false
+	}
+	for _, e := range aEdges {
+		if !inEdges(bEdges, e) {
+			d.RemovedEdges = append(d.RemovedEdges, e)
+		}
+	}
+	for _, e := range bEdges {
+		if !inEdges(aEdges, e) {
+			d.AddedEdges = append(d.AddedEdges, e)
+		}
+	}
+	return &d, nil
+}
+
+// diffNodes returns how b differs from a, or nil if it doesn't.
+func diffNodes(a, b node) *NodeDiff {
+	nd := NodeDiff{NodeID: a.ID}
+	if a.Operator != b.Operator {
+		nd.OldOperator, nd.NewOperator = a.Operator, b.Operator
+	}
+	for _, key := range sortedParamKeys(a.Parameters) {
+		bVal, ok := b.Parameters[key]
+		switch {
+		case !ok:
+			nd.Parameters = append(nd.Parameters, ParameterDiff{Name: key, Change: ParameterRemoved, Old: a.Parameters[key]})
+		case bVal != a.Parameters[key]:
+			nd.Parameters = append(nd.Parameters, ParameterDiff{Name: key, Change: ParameterChanged, Old: a.Parameters[key], New: bVal})
+		}
+	}
+	for _, key := range sortedParamKeys(b.Parameters) {
+		if _, ok := a.Parameters[key]; !ok {
+			nd.Parameters = append(nd.Parameters, ParameterDiff{Name: key, Change: ParameterAdded, New: b.Parameters[key]})
+		}
+	}
+	if nd.OldOperator == nd.NewOperator && len(nd.Parameters) == 0 {
+		return nil
+	}
+	return &nd
+}
+
+// graphEdges returns g's edges, ordered by destination and index.
+func (g *Graph) graphEdges() []GraphEdge {
+	var es []GraphEdge
+	for srcIdx, eList := range g.edges {
+		for _, e := range eList {
+			es = append(es, GraphEdge{Source: g.nodes[srcIdx].ID, Destination: g.nodes[e.nIdx].ID, Index: e.sourceIndex})
+		}
+	}
+	sort.SliceStable(es, func(i, j int) bool {
+		if es[i].Destination != es[j].Destination {
+			return es[i].Destination < es[j].Destination
+		}
+		return es[i].Index < es[j].Index
+	})
+	return es
+}
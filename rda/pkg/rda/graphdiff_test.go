@@ -0,0 +1,80 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffGraphs(t *testing.T) {
+	a, err := NewGraphFromDSL(strings.NewReader(`
+ms = IdahoRead(imageId=$ms, bucketName=idaho)
+pan = IdahoRead(imageId=$pan)
+ps = PanSharpen(ms, pan)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewGraphFromDSL(strings.NewReader(`
+ms = IdahoRead(imageId=$ms, objectStore=S3)
+pan = IdahoRead(imageId=$pan)
+ps = LocallyProjectedPanSharpen(pan, ms)
+ortho = Orthorectify(ps)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := DiffGraphs(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &GraphDiff{
+		AddedNodes: []GraphNode{{ID: "ortho", Operator: "Orthorectify", Parameters: map[string]string{}}},
+		ChangedNodes: []NodeDiff{
+			{NodeID: "ms", Parameters: []ParameterDiff{
+				{Name: "bucketName", Change: ParameterRemoved, Old: "idaho"},
+				{Name: "objectStore", Change: ParameterAdded, New: "S3"},
+			}},
+			{NodeID: "ps", OldOperator: "PanSharpen", NewOperator: "LocallyProjectedPanSharpen"},
+		},
+		RemovedEdges:   []GraphEdge{{"ms", "ps", 1}, {"pan", "ps", 2}},
+		AddedEdges:     []GraphEdge{{"ps", "ortho", 1}, {"pan", "ps", 1}, {"ms", "ps", 2}},
+		OldDefaultNode: "ps",
+		NewDefaultNode: "ortho",
+	}
+	if diff := cmp.Diff(want, d); diff != "" {
+		t.Fatalf("unexpected diff (-want +got):\n%s", diff)
+	}
+	if d.Empty() {
+		t.Fatal("differing graphs reported an empty diff")
+	}
+	if !strings.Contains(d.String(), `~ node "ps": operator PanSharpen -> LocallyProjectedPanSharpen`) {
+		t.Fatalf("operator change missing from\n%s", d)
+	}
+
+	if d, err := DiffGraphs(a, a); err != nil || !d.Empty() {
+		t.Fatalf("a graph differs from itself: %v, %v", d, err)
+	}
+}
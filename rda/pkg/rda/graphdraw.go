@@ -0,0 +1,113 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxLabelValue is the longest a parameter value gets in a node's
+// label before it is cut short.
+const maxLabelValue = 40
+
+// WriteDOT writes g to w in Graphviz's DOT language, e.g. for piping
+// to "dot -Tsvg".  Nodes are labeled with their id, operator, and
+// parameters, edges with the index they feed their destination at,
+// and the default node is drawn bold and filled.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	var sb strings.Builder
+	sb.WriteString("digraph template {\n\tnode [shape=box];\n")
+	for nIdx, n := range g.nodes {
+		lines := g.nodeLabel(nIdx)
+		for i := range lines {
+			lines[i] = quote.Replace(lines[i])
+		}
+		fmt.Fprintf(&sb, "\t\"%s\" [label=\"%s\"", quote.Replace(n.ID), strings.Join(lines, `\n`))
+		if nIdx == g.defaultNode {
+			sb.WriteString(", style=\"bold,filled\", fillcolor=lightyellow")
+		}
+		sb.WriteString("];\n")
+	}
+	for srcIdx, eList := range g.edges {
+		for _, e := range eList {
+			fmt.Fprintf(&sb, "\t\"%s\" -> \"%s\" [label=\"%d\"];\n", quote.Replace(g.nodes[srcIdx].ID), quote.Replace(g.nodes[e.nIdx].ID), e.sourceIndex)
+		}
+	}
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return errors.Wrap(err, "failed writing DOT graph")
+}
+
+// WriteMermaid writes g to w as a Mermaid flowchart, e.g. for
+// embedding in Markdown.  It is labeled and highlighted as WriteDOT
+// does.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	// Mermaid ids are restricted, so nodes are named by index and
+	// labels escaped with its entity codes.
+	quote := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+	var sb strings.Builder
+	sb.WriteString("flowchart TD\n")
+	for nIdx := range g.nodes {
+		lines := g.nodeLabel(nIdx)
+		for i := range lines {
+			lines[i] = quote.Replace(lines[i])
+		}
+		fmt.Fprintf(&sb, "\tn%d[\"%s\"]\n", nIdx, strings.Join(lines, "<br/>"))
+	}
+	for srcIdx, eList := range g.edges {
+		for _, e := range eList {
+			fmt.Fprintf(&sb, "\tn%d -->|%d| n%d\n", srcIdx, e.sourceIndex, e.nIdx)
+		}
+	}
+	fmt.Fprintf(&sb, "\tclassDef defaultNode stroke-width:3px,fill:#ffffe0\n\tclass n%d defaultNode\n", g.defaultNode)
+
+	_, err := io.WriteString(w, sb.String())
+	return errors.Wrap(err, "failed writing Mermaid graph")
+}
+
+// nodeLabel returns the lines describing the node at nIdx: its id,
+// its operator, and then its parameters in sorted order.
+func (g *Graph) nodeLabel(nIdx int) []string {
+	n := g.nodes[nIdx]
+	lines := []string{n.ID, n.Operator}
+	for _, key := range sortedParamKeys(n.Parameters) {
+		val := n.Parameters[key]
+		if r := []rune(val); len(r) > maxLabelValue {
+			val = string(r[:maxLabelValue-3]) + "..."
+		}
+		lines = append(lines, key+"="+val)
+	}
+	return lines
+}
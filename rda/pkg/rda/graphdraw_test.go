@@ -0,0 +1,100 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const drawDSL = `
+ms = IdahoRead(imageId=$ms, note="say \"hi\"")
+pan = IdahoRead(imageId=$pan)
+ps = PanSharpen(ms, pan)
+ortho = Orthorectify(ps)
+default ps
+`
+
+func TestGraphWriteDOT(t *testing.T) {
+	g, err := NewGraphFromDSL(strings.NewReader(drawDSL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph template {
+	node [shape=box];
+	"ms" [label="ms\nIdahoRead\nimageId=${ms}\nnote=say \"hi\""];
+	"pan" [label="pan\nIdahoRead\nimageId=${pan}"];
+	"ps" [label="ps\nPanSharpen", style="bold,filled", fillcolor=lightyellow];
+	"ortho" [label="ortho\nOrthorectify"];
+	"ms" -> "ps" [label="1"];
+	"pan" -> "ps" [label="2"];
+	"ps" -> "ortho" [label="1"];
+}
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Fatalf("unexpected DOT (-want +got):\n%s", diff)
+	}
+}
+
+func TestGraphWriteMermaid(t *testing.T) {
+	g, err := NewGraphFromDSL(strings.NewReader(drawDSL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteMermaid(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `flowchart TD
+	n0["ms<br/>IdahoRead<br/>imageId=${ms}<br/>note=say #quot;hi#quot;"]
+	n1["pan<br/>IdahoRead<br/>imageId=${pan}"]
+	n2["ps<br/>PanSharpen"]
+	n3["ortho<br/>Orthorectify"]
+	n0 -->|1| n2
+	n1 -->|2| n2
+	n2 -->|1| n3
+	classDef defaultNode stroke-width:3px,fill:#ffffe0
+	class n2 defaultNode
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Fatalf("unexpected Mermaid (-want +got):\n%s", diff)
+	}
+}
+
+func TestNodeLabelTruncates(t *testing.T) {
+	g := NewGraph()
+	if err := g.AddNode("n", "Op", map[string]string{"wkt": strings.Repeat("x", 100)}); err != nil {
+		t.Fatal(err)
+	}
+	lines := g.nodeLabel(0)
+	if got := lines[len(lines)-1]; got != "wkt="+strings.Repeat("x", maxLabelValue-3)+"..." {
+		t.Fatalf("long parameter value was labeled %q", got)
+	}
+}
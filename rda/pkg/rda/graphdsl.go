@@ -0,0 +1,326 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// NewGraphFromDSL creates a Graph from its description in a compact
+// text format, for example
+//
+//	# Orthorectify a strip.
+//	ms = DigitalGlobeStrip(catalogId=$cat, bands=MS, GSD=$gsd:-)
+//	ortho = Orthorectify(ms, "Output Coordinate Reference System"=EPSG:4326)
+//	default ortho
+//
+// Each statement, ended by a newline or semicolon, adds a node whose
+// id is on the left and whose operator is called on the right.  The
+// operator's bare arguments name the nodes feeding it, in order, and
+// must be defined above; its key=value arguments are its parameters.
+// Keys and values may be quoted as Go strings, which are taken
+// literally; unquoted values are taken verbatim too, except that $name
+// and $name:-default become the template placeholders ${name} and
+// ${name:-default}.  The optional
+// "default <id>" statement picks the node evaluated by default.
+func NewGraphFromDSL(r io.Reader) (*Graph, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading graph description")
+	}
+	p := dslParser{lex: dslLexer{src: []rune(string(b)), line: 1}, g: NewGraph()}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	if err := p.g.Validate(); err != nil {
+		return nil, err
+	}
+	return p.g, nil
+}
+
+type dslTokenKind int
+
+const (
+	dslEOF dslTokenKind = iota
+	dslWord
+	dslString
+	dslPunct // One of = ( ) ,
+	dslEnd   // A newline or semicolon.
+)
+
+type dslToken struct {
+	kind dslTokenKind
+	val  string
+	line int
+}
+
+func (t dslToken) String() string {
+	switch t.kind {
+	case dslEOF:
+		return "end of input"
+	case dslEnd:
+		return "end of statement"
+	}
+	return strconv.Quote(t.val)
+}
+
+// dslLexer splits a graph description into tokens.
+type dslLexer struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+func (l *dslLexer) next() (dslToken, error) {
+	// Skip spaces and comments.
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == '#' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		if c == '\n' || !unicode.IsSpace(c) {
+			break
+		}
+		l.pos++
+	}
+	if l.pos == len(l.src) {
+		return dslToken{kind: dslEOF, line: l.line}, nil
+	}
+
+	start, line := l.pos, l.line
+	switch c := l.src[l.pos]; {
+	case c == '\n' || c == ';':
+		l.pos++
+		if c == '\n' {
+			l.line++
+		}
+		return dslToken{kind: dslEnd, val: string(c), line: line}, nil
+	case strings.ContainsRune("=(),", c):
+		l.pos++
+		return dslToken{kind: dslPunct, val: string(c), line: line}, nil
+	case c == '"':
+		for l.pos++; l.pos < len(l.src) && l.src[l.pos] != '"'; l.pos++ {
+			switch l.src[l.pos] {
+			case '\\':
+				l.pos++
+			case '\n':
+				return dslToken{}, errors.Errorf("line %d: unterminated string", line)
+			}
+		}
+		if l.pos >= len(l.src) {
+			return dslToken{}, errors.Errorf("line %d: unterminated string", line)
+		}
+		l.pos++
+		s, err := strconv.Unquote(string(l.src[start:l.pos]))
+		if err != nil {
+			return dslToken{}, errors.Errorf("line %d: malformed string %s", line, string(l.src[start:l.pos]))
+		}
+		return dslToken{kind: dslString, val: s, line: line}, nil
+	}
+	for l.pos < len(l.src) && !unicode.IsSpace(l.src[l.pos]) && !strings.ContainsRune("=(),;#\"", l.src[l.pos]) {
+		l.pos++
+	}
+	return dslToken{kind: dslWord, val: string(l.src[start:l.pos]), line: line}, nil
+}
+
+// dslParser builds a Graph from the tokens of a graph description.
+type dslParser struct {
+	lex    dslLexer
+	tok    dslToken
+	peeked bool
+	g      *Graph
+}
+
+// advance returns the next token, consuming it.
+func (p *dslParser) advance() (dslToken, error) {
+	if p.peeked {
+		p.peeked = false
+		return p.tok, nil
+	}
+	var err error
+	p.tok, err = p.lex.next()
+	return p.tok, err
+}
+
+// peek returns the next token without consuming it.
+func (p *dslParser) peek() (dslToken, error) {
+	if p.peeked {
+		return p.tok, nil
+	}
+	tok, err := p.advance()
+	p.peeked = err == nil
+	return tok, err
+}
+
+// expect consumes the next token, which must be the punctuation val.
+func (p *dslParser) expect(val string) error {
+	tok, err := p.advance()
+	if err != nil {
+		return err
+	}
+	if tok.kind != dslPunct || tok.val != val {
+		return errors.Errorf("line %d: expected %q but found %s", tok.line, val, tok)
+	}
+	return nil
+}
+
+func (p *dslParser) parse() error {
+	for {
+		tok, err := p.advance()
+		switch {
+		case err != nil:
+			return err
+		case tok.kind == dslEOF:
+			return nil
+		case tok.kind == dslEnd:
+			continue
+		case tok.kind != dslWord:
+			return errors.Errorf("line %d: expected a node id but found %s", tok.line, tok)
+		}
+
+		next, err := p.peek()
+		if err != nil {
+			return err
+		}
+		if tok.val == "default" && next.kind == dslWord {
+			p.advance()
+			if err := p.g.SetDefaultNode(next.val); err != nil {
+				return errors.WithMessagef(err, "line %d", next.line)
+			}
+		} else if err := p.parseNode(tok); err != nil {
+			return err
+		}
+
+		end, err := p.advance()
+		if err != nil {
+			return err
+		}
+		if end.kind != dslEnd && end.kind != dslEOF {
+			return errors.Errorf("line %d: expected the end of the statement but found %s", end.line, end)
+		}
+	}
+}
+
+// parseNode parses "<id> = <operator>(<args>)", given the id.
+func (p *dslParser) parseNode(id dslToken) error {
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	op, err := p.advance()
+	if err != nil {
+		return err
+	}
+	if op.kind != dslWord {
+		return errors.Errorf("line %d: expected an operator for node %q but found %s", op.line, id.val, op)
+	}
+	if err := p.expect("("); err != nil {
+		return err
+	}
+
+	var sources []dslToken
+	params := map[string]string{}
+	for first := true; ; first = false {
+		tok, err := p.nextArgToken()
+		if err != nil {
+			return err
+		}
+		if tok.kind == dslPunct && tok.val == ")" {
+			break
+		}
+		if !first {
+			if tok.kind != dslPunct || tok.val != "," {
+				return errors.Errorf("line %d: expected \",\" or \")\" but found %s", tok.line, tok)
+			}
+			if tok, err = p.nextArgToken(); err != nil {
+				return err
+			}
+		}
+		if tok.kind != dslWord && tok.kind != dslString {
+			return errors.Errorf("line %d: expected a source node or parameter but found %s", tok.line, tok)
+		}
+
+		// Bare words not followed by "=" are sources.
+		next, err := p.peek()
+		if err != nil {
+			return err
+		}
+		if next.kind != dslPunct || next.val != "=" {
+			if tok.kind == dslString {
+				return errors.Errorf("line %d: parameter %s of node %q has no value", tok.line, tok, id.val)
+			}
+			sources = append(sources, tok)
+			continue
+		}
+		p.advance()
+		val, err := p.nextArgToken()
+		if err != nil {
+			return err
+		}
+		if val.kind != dslWord && val.kind != dslString {
+			return errors.Errorf("line %d: expected a value for parameter %q but found %s", val.line, tok.val, val)
+		}
+		if _, ok := params[tok.val]; ok {
+			return errors.Errorf("line %d: parameter %q is given twice to node %q", tok.line, tok.val, id.val)
+		}
+		params[tok.val] = val.val
+		if val.kind == dslWord {
+			params[tok.val] = expandPlaceholder(val.val)
+		}
+	}
+
+	if err := p.g.AddNode(id.val, op.val, params); err != nil {
+		return errors.WithMessagef(err, "line %d", id.line)
+	}
+	for _, src := range sources {
+		if err := p.g.Connect(src.val, id.val); err != nil {
+			return errors.WithMessagef(err, "line %d: sources must be defined before the nodes they feed", src.line)
+		}
+	}
+	return nil
+}
+
+// nextArgToken returns the next token within an operator's
+// arguments, where newlines don't end the statement.
+func (p *dslParser) nextArgToken() (dslToken, error) {
+	for {
+		tok, err := p.advance()
+		if err != nil || tok.kind != dslEnd || tok.val == ";" {
+			return tok, err
+		}
+	}
+}
+
+// expandPlaceholder turns $name into ${name}, leaving other values alone.
+func expandPlaceholder(val string) string {
+	if len(val) > 1 && val[0] == '$' && val[1] != '{' {
+		return "${" + val[1:] + "}"
+	}
+	return val
+}
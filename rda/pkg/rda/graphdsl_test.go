@@ -0,0 +1,104 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewGraphFromDSL(t *testing.T) {
+	tests := []struct {
+		name string
+		dsl  string
+		want *rdaGraph
+	}{
+		{"single-node", `read = IdahoRead(imageId=$image, bucketName="idaho-images")`, &rdaGraph{
+			DefaultNodeID: "read",
+			Nodes:         []rdaNode{{"read", "IdahoRead", map[string]string{"imageId": "${image}", "bucketName": "idaho-images"}}},
+		}},
+		{"chain-with-default", `
+# A strip, orthorectified.
+ms = DigitalGlobeStrip(catalogId=$cat, bands=MS, GSD=$gsd:-)
+ortho = Orthorectify(ms,
+	"Output Coordinate Reference System"=EPSG:4326)  # Trailing comment.
+default ms
+`, &rdaGraph{
+			DefaultNodeID: "ms",
+			Nodes: []rdaNode{
+				{"ms", "DigitalGlobeStrip", map[string]string{"catalogId": "${cat}", "bands": "MS", "GSD": "${gsd:-}"}},
+				{"ortho", "Orthorectify", map[string]string{"Output Coordinate Reference System": "EPSG:4326"}},
+			},
+			Edges: []rdaEdge{{"edge-0", 1, "ms", "ortho"}},
+		}},
+		{"ordered-sources", `a = IdahoRead(); b = IdahoRead(); c = PanSharpen(b, a, "quoted \"value\""="${x}")`, &rdaGraph{
+			DefaultNodeID: "c",
+			Nodes: []rdaNode{
+				{"a", "IdahoRead", map[string]string{}},
+				{"b", "IdahoRead", map[string]string{}},
+				{"c", "PanSharpen", map[string]string{`quoted "value"`: "${x}"}},
+			},
+			Edges: []rdaEdge{{"edge-0", 2, "a", "c"}, {"edge-1", 1, "b", "c"}},
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := NewGraphFromDSL(strings.NewReader(tc.dsl))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, g.toRDA()); diff != "" {
+				t.Fatalf("unexpected graph (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewGraphFromDSLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dsl     string
+		wantErr string
+	}{
+		{"empty", "# nothing here", "no nodes"},
+		{"undefined-source", "a = Op(b)", "line 1: sources must be defined"},
+		{"duplicate-node", "a = Op()\na = Op()", "line 2"},
+		{"duplicate-param", "a = Op(x=1, x=2)", "given twice"},
+		{"missing-paren", "a = Op(x=1", "expected \",\" or \")\""},
+		{"missing-operator", "a = (x=1)", "expected an operator"},
+		{"unterminated-string", "a = Op(x=\"1)", "unterminated string"},
+		{"valueless-quoted-param", "a = Op(\"x\")", "has no value"},
+		{"unknown-default", "a = Op()\ndefault b", "line 2"},
+		{"trailing-junk", "a = Op() b", "expected the end of the statement"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewGraphFromDSL(strings.NewReader(tc.dsl))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("got error %v, want one containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
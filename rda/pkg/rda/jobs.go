@@ -0,0 +1,266 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JobRecord is what we know locally about a batch materialization
+// job submitted to RDA.
+type JobRecord struct {
+	JobID string `json:"jobId"`
+
+	// Request is the body of the batch materialization request,
+	// which holds the template and parameters the job realizes.
+	Request BatchRequest `json:"request"`
+
+	// Profile is the configuration profile the job was submitted
+	// with, and so the account its artifacts are found in.
+	Profile string `json:"profile,omitempty"`
+
+	SubmittedAt time.Time `json:"submittedAt,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+
+	// Status is the last status fetched from RDA, as of UpdatedAt.
+	Status    BatchStatus `json:"status"`
+	UpdatedAt time.Time   `json:"updatedAt,omitempty"`
+
+	// Downloads lists where the job's artifacts have been
+	// downloaded to.
+	Downloads []JobDownload `json:"downloads,omitempty"`
+
+	// ActedOn is the finished status a callback listener last
+	// acted on for the job, so that callbacks RDA repeats aren't
+	// acted on again.
+	ActedOn string `json:"actedOn,omitempty"`
+}
+
+// JobDownload records a download of a job's artifacts.
+type JobDownload struct {
+	Dir          string    `json:"dir"`
+	NumArtifacts int       `json:"numArtifacts"`
+	CompletedAt  time.Time `json:"completedAt"`
+}
+
+// NewJobRecord returns a record of the job RDA described in resp,
+// submitted just now.
+func NewJobRecord(resp *BatchResponse, profile string, tags ...string) *JobRecord {
+	now := time.Now()
+	return &JobRecord{
+		JobID:       resp.JobID,
+		Request:     resp.Request,
+		Profile:     profile,
+		SubmittedAt: now,
+		Tags:        tags,
+		Status:      resp.Status,
+		UpdatedAt:   now,
+	}
+}
+
+// UpdateStatus records the status RDA gave for the job in resp.
+func (r *JobRecord) UpdateStatus(resp *BatchResponse) {
+	r.Status = resp.Status
+	r.UpdatedAt = time.Now()
+	if r.Request.ImageReference.TemplateID == "" {
+		r.Request = resp.Request
+	}
+}
+
+// AddDownload records that numArtifacts of the job's artifacts were
+// just downloaded to dir, adding to any earlier downloads to dir.
+func (r *JobRecord) AddDownload(dir string, numArtifacts int) {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	d := JobDownload{Dir: dir, NumArtifacts: numArtifacts, CompletedAt: time.Now()}
+	for i := range r.Downloads {
+		if r.Downloads[i].Dir == dir {
+			d.NumArtifacts += r.Downloads[i].NumArtifacts
+			r.Downloads[i] = d
+			return
+		}
+	}
+	r.Downloads = append(r.Downloads, d)
+}
+
+// HasTag returns true if the job was tagged with tag.
+func (r *JobRecord) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// JobFilter selects job records.  Zero valued fields select
+// everything.
+type JobFilter struct {
+	// Status selects jobs whose last known status is Status,
+	// ignoring case.
+	Status string
+
+	// Tags selects jobs tagged with every one of Tags.
+	Tags []string
+
+	// Since and Until select jobs submitted in that time range.
+	Since, Until time.Time
+}
+
+// Match returns true if r is selected by f.
+func (f JobFilter) Match(r *JobRecord) bool {
+	if f.Status != "" && !strings.EqualFold(f.Status, r.Status.Status) {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !r.HasTag(tag) {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && r.SubmittedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && r.SubmittedAt.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// JobStore keeps JobRecords on disk, one JSON file per job in its
+// directory, so that concurrent rda commands only contend over the
+// jobs they share.
+type JobStore struct {
+	dir string
+}
+
+// NewJobStore returns a JobStore keeping its records in dir, which is
+// created when the first record is written.
+func NewJobStore(dir string) *JobStore {
+	return &JobStore{dir: dir}
+}
+
+func (s *JobStore) path(jobID string) string {
+	return filepath.Join(s.dir, jobID+".json")
+}
+
+// Put writes r to the store, replacing any record of the same job.
+func (s *JobStore) Put(r *JobRecord) error {
+	if r.JobID == "" || strings.ContainsAny(r.JobID, `/\`) {
+		return errors.Errorf("can't record a job with id %q", r.JobID)
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return errors.Wrap(err, "failed creating the job store")
+	}
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "failed encoding the record of job %s", r.JobID)
+	}
+
+	// Write then rename, so readers never see a partial record.
+	f, err := ioutil.TempFile(s.dir, r.JobID+".*.tmp")
+	if err != nil {
+		return errors.Wrapf(err, "failed recording job %s", r.JobID)
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return errors.Wrapf(err, "failed recording job %s", r.JobID)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return errors.Wrapf(err, "failed recording job %s", r.JobID)
+	}
+	return errors.Wrapf(os.Rename(f.Name(), s.path(r.JobID)), "failed recording job %s", r.JobID)
+}
+
+// Get returns the record of the job with the given id.  The error
+// satisfies os.IsNotExist if there is no such record.
+func (s *JobStore) Get(jobID string) (*JobRecord, error) {
+	b, err := ioutil.ReadFile(s.path(jobID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, errors.Wrapf(err, "failed reading the record of job %s", jobID)
+	}
+	var r JobRecord
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, errors.Wrapf(err, "failed decoding the record of job %s", jobID)
+	}
+	return &r, nil
+}
+
+// Update applies update to the record of the job with the given id
+// and writes it back, starting from an empty record if there is none
+// yet, e.g. for jobs submitted elsewhere.
+func (s *JobStore) Update(jobID string, update func(*JobRecord)) error {
+	r, err := s.Get(jobID)
+	switch {
+	case os.IsNotExist(err):
+		r = &JobRecord{JobID: jobID}
+	case err != nil:
+		return err
+	}
+	update(r)
+	return s.Put(r)
+}
+
+// Remove deletes the record of the job with the given id, if any.
+func (s *JobStore) Remove(jobID string) error {
+	if err := os.Remove(s.path(jobID)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed removing the record of job %s", jobID)
+	}
+	return nil
+}
+
+// List returns the records selected by f, oldest submission first.
+func (s *JobStore) List(f JobFilter) ([]*JobRecord, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed listing the job store")
+	}
+
+	var rs []*JobRecord
+	for _, p := range paths {
+		r, err := s.Get(strings.TrimSuffix(filepath.Base(p), ".json"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // Removed since we listed it.
+			}
+			return nil, err
+		}
+		if f.Match(r) {
+			rs = append(rs, r)
+		}
+	}
+	sort.SliceStable(rs, func(i, j int) bool { return rs[i].SubmittedAt.Before(rs[j].SubmittedAt) })
+	return rs, nil
+}
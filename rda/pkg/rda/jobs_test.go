@@ -0,0 +1,134 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJobStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := NewJobStore(filepath.Join(dir, "jobs"))
+
+	if _, err := s.Get("nope"); !os.IsNotExist(err) {
+		t.Fatalf("getting a missing job should be a not exist error, got %v", err)
+	}
+	if jobs, err := s.List(JobFilter{}); err != nil || len(jobs) != 0 {
+		t.Fatalf("an empty store listed %v, %v", jobs, err)
+	}
+
+	// Record a few jobs, submitted a day apart.
+	day := 24 * time.Hour
+	t0 := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, j := range []struct {
+		id, status string
+		tags       []string
+	}{
+		{"job-a", "complete", []string{"alaska"}},
+		{"job-b", "processing", []string{"alaska", "ms"}},
+		{"job-c", "failed", nil},
+	} {
+		r := NewJobRecord(&BatchResponse{
+			JobID:   j.id,
+			Request: BatchRequest{ImageReference: ImageReference{TemplateID: "tID"}},
+			Status:  BatchStatus{Status: j.status},
+		}, "default", j.tags...)
+		r.SubmittedAt = t0.Add(time.Duration(i) * day)
+		if err := s.Put(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter JobFilter
+		want   []string
+	}{
+		{"all", JobFilter{}, []string{"job-a", "job-b", "job-c"}},
+		{"status", JobFilter{Status: "PROCESSING"}, []string{"job-b"}},
+		{"tag", JobFilter{Tags: []string{"alaska"}}, []string{"job-a", "job-b"}},
+		{"tags", JobFilter{Tags: []string{"alaska", "ms"}}, []string{"job-b"}},
+		{"since", JobFilter{Since: t0.Add(day)}, []string{"job-b", "job-c"}},
+		{"until", JobFilter{Until: t0.Add(day)}, []string{"job-a", "job-b"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			jobs, err := s.List(tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, j := range jobs {
+				got = append(got, j.JobID)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("unexpected jobs (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// Updates keep what was recorded at submission.
+	if err := s.Update("job-b", func(r *JobRecord) {
+		r.UpdateStatus(&BatchResponse{JobID: "job-b", Status: BatchStatus{Status: "complete"}})
+		r.AddDownload(dir, 3)
+		r.AddDownload(dir, 2)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.Get("job-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status.Status != "complete" || r.Request.ImageReference.TemplateID != "tID" || !r.HasTag("ms") {
+		t.Fatalf("update lost or failed to record something: %+v", r)
+	}
+	if len(r.Downloads) != 1 || r.Downloads[0].NumArtifacts != 5 {
+		t.Fatalf("unexpected downloads %+v", r.Downloads)
+	}
+
+	// Updating an unknown job records it.
+	if err := s.Update("job-d", func(r *JobRecord) { r.Tags = []string{"found"} }); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := s.Get("job-d"); err != nil || !r.HasTag("found") {
+		t.Fatalf("updating an unrecorded job didn't record it: %+v, %v", r, err)
+	}
+
+	if err := s.Remove("job-d"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("job-d"); !os.IsNotExist(err) {
+		t.Fatalf("removed job is still recorded: %v", err)
+	}
+	if err := s.Put(&JobRecord{JobID: "../escape"}); err == nil {
+		t.Fatal("job ids with path separators should be refused")
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Manifest records what was realized into a tile directory, so that
+// realization can safely pick up where it left off.  It is kept next
+// to the tile directory, see ManifestPath, as JSON lines: the first
+// line describes the realization and each following line records a
+// completed tile.
+type Manifest struct {
+	TemplateID string     `json:"templateId"`
+	Parameters url.Values `json:"parameters"`
+	Window     TileWindow `json:"window"`
+
+	// Tiles holds the completed tiles keyed by their file name.
+	Tiles map[string]ManifestTile `json:"-"`
+}
+
+// ManifestTile describes a single tile that was realized from RDA.
+type ManifestTile struct {
+	File        string    `json:"file"`
+	URL         string    `json:"url"`
+	XTile       int       `json:"xTile"`
+	YTile       int       `json:"yTile"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ETag        string    `json:"etag,omitempty"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// ManifestPath returns where the manifest of the tiles realized into
+// tileDir is kept.
+func ManifestPath(tileDir string) string {
+	return filepath.Clean(tileDir) + ".manifest"
+}
+
+// ReadManifest reads the manifest found at path.  A partially
+// written final line, as left by a crash, is ignored.
+func ReadManifest(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed opening realization manifest")
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	m := Manifest{Tiles: make(map[string]ManifestTile)}
+	if err := dec.Decode(&m); err != nil {
+		return nil, errors.Wrapf(err, "failed parsing the header of realization manifest %s", path)
+	}
+	for {
+		var tile ManifestTile
+		if err := dec.Decode(&tile); err != nil {
+			break
+		}
+		m.Tiles[tile.File] = tile
+	}
+	return &m, nil
+}
+
+// matches returns an error if the manifest describes a different
+// realization than the one given.
+func (m *Manifest) matches(templateID string, params url.Values, window TileWindow) error {
+	w := m.Window
+	switch {
+	case m.TemplateID != templateID:
+		return errors.Errorf("tiles were realized from template %s, not %s", m.TemplateID, templateID)
+	case m.Parameters.Encode() != params.Encode():
+		return errors.Errorf("tiles were realized with parameters %q, not %q", m.Parameters.Encode(), params.Encode())
+	case w.MinTileX != window.MinTileX || w.MinTileY != window.MinTileY || w.MaxTileX != window.MaxTileX || w.MaxTileY != window.MaxTileY:
+		return errors.Errorf("tiles were realized for tile window x %d-%d, y %d-%d, not x %d-%d, y %d-%d",
+			w.MinTileX, w.MaxTileX, w.MinTileY, w.MaxTileY, window.MinTileX, window.MaxTileX, window.MinTileY, window.MaxTileY)
+	}
+	return nil
+}
+
+// manifestLog appends completed tiles to a manifest as they finish.
+type manifestLog struct {
+	mu       sync.Mutex
+	path     string
+	manifest *Manifest
+	f        *os.File
+}
+
+// openManifest opens the manifest for tileDir, creating it if needed.
+// Tiles already in the manifest are only trusted if it describes the
+// same template, parameters, and window; otherwise an error is
+// returned rather than mix tiles from different realizations.
+func openManifest(tileDir, templateID string, params url.Values, window TileWindow) (*manifestLog, error) {
+	path := ManifestPath(tileDir)
+	m, err := ReadManifest(path)
+	switch {
+	case os.IsNotExist(errors.Cause(err)):
+		m = &Manifest{
+			TemplateID: templateID,
+			Parameters: params,
+			Window:     window,
+			Tiles:      make(map[string]ManifestTile),
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if err := m.matches(templateID, params, window); err != nil {
+			return nil, errors.WithMessagef(err, "refusing to mix tiles in %s with those of a different realization; remove them or realize elsewhere", tileDir)
+		}
+	}
+
+	l := manifestLog{path: path, manifest: m}
+	if err := l.compact(); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// compact rewrites the manifest with one line per tile, leaving it
+// open for appending.
+func (l *manifestLog) compact() error {
+	tmp := l.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return errors.Wrap(err, "failed creating realization manifest")
+	}
+	enc := json.NewEncoder(f)
+	if err := enc.Encode(l.manifest); err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed writing realization manifest")
+	}
+	for _, tile := range l.manifest.Tiles {
+		if err := enc.Encode(tile); err != nil {
+			f.Close()
+			return errors.Wrap(err, "failed writing realization manifest")
+		}
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "failed closing realization manifest")
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return errors.Wrap(err, "failed moving realization manifest into place")
+	}
+
+	l.f, err = os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0664)
+	return errors.Wrap(err, "failed opening realization manifest for appending")
+}
+
+// tile returns the manifest's record of the tile written to filePath.
+func (l *manifestLog) tile(filePath string) (ManifestTile, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tile, ok := l.manifest.Tiles[filepath.Base(filePath)]
+	return tile, ok
+}
+
+// add records a completed tile.
+func (l *manifestLog) add(tile ManifestTile) error {
+	b, err := json.Marshal(tile)
+	if err != nil {
+		return errors.Wrap(err, "failed encoding tile for realization manifest")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.manifest.Tiles[tile.File] = tile
+	_, err = l.f.Write(append(b, '\n'))
+	return errors.Wrap(err, "failed recording tile in realization manifest")
+}
+
+// close rewrites the manifest compactly and closes it.
+func (l *manifestLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.f.Close(); err != nil {
+		return errors.Wrap(err, "failed closing realization manifest")
+	}
+	if err := l.compact(); err != nil {
+		return err
+	}
+	return errors.Wrap(l.f.Close(), "failed closing realization manifest")
+}
+
+// fileChecksum returns the size and hex encoded SHA256 of the file at path.
+func fileChecksum(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
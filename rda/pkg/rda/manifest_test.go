@@ -0,0 +1,78 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name      string
+		contents  string
+		wantErr   bool
+		wantTiles int
+	}{
+		{"complete", `{"templateId":"tID","parameters":{"p":["v"]},"window":{"MaxTileX":1}}
+{"file":"tile_0_0.tif","size":10}
+{"file":"tile_1_0.tif","size":10}
+`, false, 2},
+		{"crash-truncated", `{"templateId":"tID","parameters":{"p":["v"]},"window":{"MaxTileX":1}}
+{"file":"tile_0_0.tif","size":10}
+{"file":"tile_1_0.t`, false, 1},
+		{"repeated-tile", `{"templateId":"tID","parameters":{"p":["v"]},"window":{"MaxTileX":1}}
+{"file":"tile_0_0.tif","size":10}
+{"file":"tile_0_0.tif","size":12}
+`, false, 1},
+		{"bad-header", `{"templateId":`, true, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name+".manifest")
+			if err := ioutil.WriteFile(path, []byte(tc.contents), 0664); err != nil {
+				t.Fatal(err)
+			}
+			m, err := ReadManifest(path)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, wanted one: %t", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(m.Tiles) != tc.wantTiles {
+				t.Fatalf("read %d tiles, want %d", len(m.Tiles), tc.wantTiles)
+			}
+			if m.TemplateID != "tID" || m.Parameters.Get("p") != "v" || m.Window.MaxTileX != 1 {
+				t.Fatalf("header was read as %+v", m)
+			}
+		})
+	}
+}
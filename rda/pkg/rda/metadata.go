@@ -22,7 +22,9 @@ package rda
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
@@ -145,6 +147,10 @@ type ImageMetadata struct {
 	AcquisitionDate time.Time
 	ImageID         string
 	TileBucketName  string
+
+	// SatElevation is how high, in degrees, the satellite was above
+	// the horizon when the image was acquired, if RDA knows.
+	SatElevation float64 `json:",omitempty"`
 }
 
 // TileWindow contains tile specific metadata.
@@ -257,11 +263,18 @@ func (gt *ImageGeoreferencing) hardInvert() (ImageGeoreferencing, error) {
 // the given name.  If no names are provided, all operators will be
 // described.
 func OperatorInfo(client *retryablehttp.Client, w io.Writer, opNames ...string) error {
+	return NewClient(client, nil).OperatorInfo(w, opNames...)
+}
+
+// OperatorInfo returns information describing the RDA operators with
+// the given name.  If no names are provided, all operators will be
+// described.
+func (c *Client) OperatorInfo(w io.Writer, opNames ...string) error {
 	opInfo := []interface{}{}
-	for _, ep := range urls.operatorURL(opNames...) {
+	for _, ep := range c.urls.operatorURL(opNames...) {
 
 		if err := func() error {
-			res, err := client.Get(ep)
+			res, err := c.client.Get(ep)
 			if err != nil {
 				return errors.Wrapf(err, "failure requesting %s", ep)
 			}
@@ -294,8 +307,15 @@ func OperatorInfo(client *retryablehttp.Client, w io.Writer, opNames ...string)
 // zipped is true, we call the endpoint that returns zipped metadata,
 // otherwise we stream the expected json response.
 func StripInfo(client *retryablehttp.Client, w io.Writer, catalogID string, zipped bool) error {
-	ep := urls.stripinfoURL(catalogID, zipped)
-	res, err := client.Get(ep)
+	return NewClient(client, nil).StripInfo(w, catalogID, zipped)
+}
+
+// StripInfo returns information describing the DG catalog id.  If
+// zipped is true, we call the endpoint that returns zipped metadata,
+// otherwise we stream the expected json response.
+func (c *Client) StripInfo(w io.Writer, catalogID string, zipped bool) error {
+	ep := c.urls.stripinfoURL(catalogID, zipped)
+	res, err := c.client.Get(ep)
 	if err != nil {
 		return errors.Wrapf(err, "failure requesting %s", ep)
 	}
@@ -315,6 +335,31 @@ func StripInfo(client *retryablehttp.Client, w io.Writer, catalogID string, zipp
 	return errors.Wrapf(err, "failed writing zipped response from %s", ep)
 }
 
+// StripAvailable returns true if RDA has strip info for the catalog
+// id, and so can serve imagery for it, or false if RDA doesn't know
+// of it.
+func (c *Client) StripAvailable(ctx context.Context, catalogID string) (bool, error) {
+	ep := c.urls.stripinfoURL(catalogID, false)
+	req, err := retryablehttp.NewRequest("GET", ep, nil)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed forming request for %s", ep)
+	}
+	res, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, errors.Wrapf(err, "failure requesting %s", ep)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, ResponseToError(res.Body, fmt.Sprintf("failed fetching strip info from %s, HTTP Status: %s", ep, res.Status))
+	}
+}
+
 // PartMetadata downloads the DG metadata returned by RDA for the
 // given catalog id.  Metadata in this case is the "raw" data that the
 // DG factory provides, not RDA metadata.
@@ -323,13 +368,20 @@ func StripInfo(client *retryablehttp.Client, w io.Writer, catalogID string, zipp
 // which files to extract, e.g. PAN_001 would grab all metadata files
 // that start with that string.
 func PartMetadata(client *retryablehttp.Client, catalogID, prefix, outDir string) (*RPCs, error) {
+	return NewClient(client, nil).PartMetadata(catalogID, prefix, outDir)
+}
+
+// PartMetadata downloads the DG metadata returned by RDA for the
+// given catalog id, extracting the files starting with prefix to
+// outDir.  See the package level PartMetadata for details.
+func (c *Client) PartMetadata(catalogID, prefix, outDir string) (*RPCs, error) {
 	if err := os.MkdirAll(outDir, 0775); err != nil {
 		return nil, errors.Wrap(err, "couldn't make directory to write metadata to")
 	}
 
 	// Get all the zipped metadata from RDA.
-	ep := urls.stripinfoURL(catalogID, true)
-	res, err := client.Get(ep)
+	ep := c.urls.stripinfoURL(catalogID, true)
+	res, err := c.client.Get(ep)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failure requesting %s", ep)
 	}
@@ -389,16 +441,44 @@ func PartMetadata(client *retryablehttp.Client, catalogID, prefix, outDir string
 // ImageParts describes the images that compose a DigitalGlobe Catalog ID.
 type ImageParts struct {
 	CatID       string `json:"catalogIdentifier"`
-	CavisImages []ImageMetadata
-	PanImages   []ImageMetadata
-	SWIRImages  []ImageMetadata
-	VNIRImages  []ImageMetadata
+	CavisImages []StripImage
+	PanImages   []StripImage
+	SWIRImages  []StripImage
+	VNIRImages  []StripImage
+}
+
+// StripImage describes one of the 1B image parts of a DigitalGlobe
+// Catalog ID, as RDA's strip metadata reports it.
+type StripImage struct {
+	ImageMetadata
+
+	SensorName         string
+	SensorPlatformName string
+
+	// GroundSampleDistanceMeters is the part's native resolution.
+	GroundSampleDistanceMeters float64
+
+	// CloudCover is the percent of the part covered by cloud.
+	CloudCover float64
+
+	// Sun and satellite angles, in degrees; SatElevation is in ImageMetadata.
+	SunAzimuth   float64
+	SunElevation float64
+	SatAzimuth   float64
+
+	// ImageBoundsWGS84 is the part's footprint as WKT, in EPSG:4326.
+	ImageBoundsWGS84 string
 }
 
 // PartSummary returns information describing the DG 1B parts stored in RDA.
 func PartSummary(client *retryablehttp.Client, catalogID string) (*ImageParts, error) {
-	ep := urls.stripinfoURL(catalogID, false)
-	res, err := client.Get(ep)
+	return NewClient(client, nil).PartSummary(catalogID)
+}
+
+// PartSummary returns information describing the DG 1B parts stored in RDA.
+func (c *Client) PartSummary(catalogID string) (*ImageParts, error) {
+	ep := c.urls.stripinfoURL(catalogID, false)
+	res, err := c.client.Get(ep)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failure requesting %s", ep)
 	}
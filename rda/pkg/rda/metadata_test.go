@@ -21,6 +21,7 @@
 package rda
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"math/rand"
@@ -325,3 +326,39 @@ func TestStripInfo(t *testing.T) {
 	})
 
 }
+
+func TestStripAvailable(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path.Base(r.URL.Path) {
+		case "known":
+			json.NewEncoder(w).Encode(map[string]string{"catalogIdentifier": "known"})
+		case "unknown":
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "no such strip"})
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "not allowed"})
+		}
+	}))
+	defer ts.Close()
+
+	endpoints, err := NewEndpoints(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(retryablehttp.NewClient(), endpoints)
+
+	for catID, want := range map[string]bool{"known": true, "unknown": false} {
+		got, err := client.StripAvailable(context.Background(), catID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("StripAvailable(%q) = %t, want %t", catID, got, want)
+		}
+	}
+
+	if _, err := client.StripAvailable(context.Background(), "forbidden"); err == nil {
+		t.Error("expected an error when RDA refuses the request")
+	}
+}
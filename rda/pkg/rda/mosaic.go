@@ -0,0 +1,341 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MosaicOrder picks which image of a mosaic shows where images overlap.
+type MosaicOrder int
+
+const (
+	// MostRecentFirst shows the most recently acquired image on top.
+	MostRecentFirst MosaicOrder = iota
+
+	// LeastOffNadirFirst shows the image taken closest to straight
+	// down on top, i.e. the one with the highest satellite elevation.
+	LeastOffNadirFirst
+
+	// GivenOrder shows the images in the order they're given, the
+	// first on top.
+	GivenOrder
+)
+
+func (o MosaicOrder) String() string {
+	switch o {
+	case MostRecentFirst:
+		return "recent"
+	case LeastOffNadirFirst:
+		return "off-nadir"
+	case GivenOrder:
+		return "given"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// UnmarshalText reads a MosaicOrder as written by String.
+func (o *MosaicOrder) UnmarshalText(buf []byte) error {
+	switch val := strings.ToLower(string(buf)); val {
+	case "recent":
+		*o = MostRecentFirst
+	case "off-nadir":
+		*o = LeastOffNadirFirst
+	case "given":
+		*o = GivenOrder
+	default:
+		return errors.Errorf("mosaic order %q is not one of recent, off-nadir, or given", val)
+	}
+	return nil
+}
+
+// MosaicSource is one of the images of a mosaic, along with the tiles
+// of it that were realized.
+type MosaicSource struct {
+	Metadata *Metadata
+	Tiles    []TileInfo
+}
+
+// SortMosaicSources sorts sources into the given order, the one to
+// show on top first.
+func SortMosaicSources(sources []MosaicSource, order MosaicOrder) error {
+	switch order {
+	case MostRecentFirst:
+		sort.SliceStable(sources, func(i, j int) bool {
+			return sources[i].Metadata.ImageMetadata.AcquisitionDate.After(sources[j].Metadata.ImageMetadata.AcquisitionDate)
+		})
+	case LeastOffNadirFirst:
+		for _, src := range sources {
+			if src.Metadata.ImageMetadata.SatElevation == 0 {
+				return errors.Errorf("RDA doesn't know the satellite elevation of image %s, so images can't be ordered by how far off nadir they are", src.Metadata.ImageMetadata.ImageID)
+			}
+		}
+		sort.SliceStable(sources, func(i, j int) bool {
+			return sources[i].Metadata.ImageMetadata.SatElevation > sources[j].Metadata.ImageMetadata.SatElevation
+		})
+	case GivenOrder:
+	default:
+		return errors.Errorf("unknown mosaic order %d", order)
+	}
+	return nil
+}
+
+// Mosaic lays the realized tiles of several images out on a single
+// pixel grid, that of the first image.  The images must share a
+// coordinate reference system, resolution, data type, and bands.
+type Mosaic struct {
+	Width, Height int
+	GeoTransform  ImageGeoreferencing
+	DataType      string
+	NumBands      int
+
+	// tiles are placed on the grid, the one to show on top last.
+	tiles []mosaicTile
+}
+
+// mosaicTile is a tile placed on the pixel grid of a mosaic.
+type mosaicTile struct {
+	TileInfo
+	xOff, yOff    int
+	width, height int
+}
+
+// NewMosaic returns a mosaic of sources, which are ordered by which to
+// show on top where they overlap, first to last; see
+// SortMosaicSources.  Tiles are placed at the nearest whole pixel of
+// the first image's grid.  Zero is the nodata value of every band, and
+// as GDAL does, each band is drawn separately: where an image has a
+// zero in a band, the images beneath it show through in that band.
+func NewMosaic(sources []MosaicSource) (*Mosaic, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("no images were provided to mosaic")
+	}
+	first := sources[0].Metadata
+	gt0 := first.ImageGeoreferencing
+	if gt0.SpatialReferenceSystemCode == "" {
+		return nil, errors.New("only georeferenced images can be mosaicked")
+	}
+	if gt0.ShearX != 0 || gt0.ShearY != 0 {
+		return nil, errors.New("only north up images can be mosaicked")
+	}
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) <= 1e-6*math.Abs(b)
+	}
+	for _, src := range sources[1:] {
+		m, gt := src.Metadata, src.Metadata.ImageGeoreferencing
+		switch {
+		case gt.SpatialReferenceSystemCode != gt0.SpatialReferenceSystemCode:
+			return nil, errors.Errorf("image %s is in %s, but image %s is in %s", m.ImageMetadata.ImageID, gt.SpatialReferenceSystemCode, first.ImageMetadata.ImageID, gt0.SpatialReferenceSystemCode)
+		case !near(gt.ScaleX, gt0.ScaleX) || !near(gt.ScaleY, gt0.ScaleY) || gt.ShearX != 0 || gt.ShearY != 0:
+			return nil, errors.Errorf("image %s has pixels of %gx%g, but image %s has pixels of %gx%g", m.ImageMetadata.ImageID, gt.ScaleX, gt.ScaleY, first.ImageMetadata.ImageID, gt0.ScaleX, gt0.ScaleY)
+		case m.ImageMetadata.DataType != first.ImageMetadata.DataType || m.ImageMetadata.NumBands != first.ImageMetadata.NumBands:
+			return nil, errors.Errorf("image %s has %d %s bands, but image %s has %d %s bands", m.ImageMetadata.ImageID, m.ImageMetadata.NumBands, m.ImageMetadata.DataType, first.ImageMetadata.ImageID, first.ImageMetadata.NumBands, first.ImageMetadata.DataType)
+		}
+	}
+
+	mosaic := Mosaic{DataType: first.ImageMetadata.DataType, NumBands: first.ImageMetadata.NumBands}
+	minX, minY := math.MaxInt32, math.MaxInt32
+	maxX, maxY := math.MinInt32, math.MinInt32
+	for i := len(sources) - 1; i >= 0; i-- {
+		im := &sources[i].Metadata.ImageMetadata
+		for _, t := range sources[i].Tiles {
+			gx, gy := im.tileGeoTransform.Apply(float64(t.XTile), float64(t.YTile))
+			mt := mosaicTile{
+				TileInfo: t,
+				xOff:     int(math.Round((gx - gt0.TranslateX) / gt0.ScaleX)),
+				yOff:     int(math.Round((gy - gt0.TranslateY) / gt0.ScaleY)),
+				width:    im.TileXSize,
+				height:   im.TileYSize,
+			}
+			minX, minY = min(minX, mt.xOff), min(minY, mt.yOff)
+			maxX, maxY = max(maxX, mt.xOff+mt.width), max(maxY, mt.yOff+mt.height)
+			mosaic.tiles = append(mosaic.tiles, mt)
+		}
+	}
+	if len(mosaic.tiles) == 0 {
+		return nil, errors.New("none of the images have any tiles to mosaic")
+	}
+
+	for i := range mosaic.tiles {
+		mosaic.tiles[i].xOff -= minX
+		mosaic.tiles[i].yOff -= minY
+	}
+	mosaic.Width, mosaic.Height = maxX-minX, maxY-minY
+	mosaic.GeoTransform = gt0
+	mosaic.GeoTransform.TranslateX, mosaic.GeoTransform.TranslateY = gt0.Apply(float64(minX), float64(minY))
+	return &mosaic, nil
+}
+
+// NewMosaicVRT returns a populated VRT struct laying out the tiles of
+// mosaic, with zero as the nodata value.
+func NewMosaicVRT(mosaic *Mosaic) (*VRTDataset, error) {
+	gdalType, err := RDAToGDALType(mosaic.DataType)
+	if err != nil {
+		return nil, err
+	}
+	gt := mosaic.GeoTransform
+	vrt := VRTDataset{
+		RasterXSize:  mosaic.Width,
+		RasterYSize:  mosaic.Height,
+		SRS:          gt.SpatialReferenceSystemCode,
+		GeoTransform: &GeoTransform{gt.TranslateX, gt.ScaleX, gt.ShearX, gt.TranslateY, gt.ShearY, gt.ScaleY},
+		Bands:        make([]VRTRasterBand, 0, mosaic.NumBands),
+	}
+
+	// GDAL draws sources in order, so the tile to show on top goes last.
+	nodata := 0.0
+	for b := 0; b < mosaic.NumBands; b++ {
+		band := VRTRasterBand{
+			DataType:    gdalType,
+			Band:        b + 1,
+			NoDataValue: &nodata,
+		}
+		for _, t := range mosaic.tiles {
+			fp, err := filepath.Abs(t.FilePath)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed building absolute file path in VRT")
+			}
+			band.ComplexSource = append(band.ComplexSource, ComplexSource{
+				SourceFilename:   SourceFilename{Filename: fp},
+				SourceBand:       b + 1,
+				SourceProperties: SourceProperties{RasterXSize: t.width, RasterYSize: t.height, DataType: gdalType, BlockXSize: t.width, BlockYSize: t.height},
+				SrcRect:          Rect{XSize: t.width, YSize: t.height},
+				DstRect:          Rect{XOff: t.xOff, YOff: t.yOff, XSize: t.width, YSize: t.height},
+				ScaleRatio:       1,
+				NODATA:           &nodata,
+			})
+		}
+		vrt.Bands = append(vrt.Bands, band)
+	}
+	return &vrt, nil
+}
+
+// WriteMosaicCOG assembles the tiles of mosaic into a single Cloud
+// Optimized GeoTIFF at path, as WriteCOG does for a single image.  Any
+// cutline is given in the mosaic's coordinate reference system.
+func WriteMosaicCOG(path string, mosaic *Mosaic, options ...COGOption) error {
+	cfg, err := newCOGConfig(options)
+	if err != nil {
+		return err
+	}
+	gdalType, err := RDAToGDALType(mosaic.DataType)
+	if err != nil {
+		return err
+	}
+	pt, err := gdalPixelType(gdalType)
+	if err != nil {
+		return err
+	}
+
+	src := mosaicSource{mosaic: mosaic, pixelType: pt, pixelSize: pt.bytes * mosaic.NumBands, cache: make(map[int]*tiffImage)}
+	if cfg.cutline != nil {
+		inv, err := mosaic.GeoTransform.Invert()
+		if err != nil {
+			return err
+		}
+		src.cutline = cfg.cutline.apply("", inv.Apply)
+	}
+	return writeCOG(path, cfg, pt, mosaic.NumBands, mosaic.Width, mosaic.Height, &src, mosaic.GeoTransform)
+}
+
+// mosaicSource reads the rows of a mosaic out of its tiles.
+type mosaicSource struct {
+	mosaic    *Mosaic
+	pixelType pixelType
+	pixelSize int
+	cache     map[int]*tiffImage
+
+	// cutline, if set, is the geometry in pixel coordinates outside
+	// which pixels are zeroed.
+	cutline *Geometry
+}
+
+// rows returns n rows of pixels starting at row y0.  Calls must be
+// made with increasing y0, as tiles above y0 are dropped from the
+// cache.
+func (s *mosaicSource) rows(y0, n int) ([]byte, error) {
+	width := s.mosaic.Width
+	out := make([]byte, n*width*s.pixelSize)
+	zero := make([]byte, s.pixelSize)
+	for i, t := range s.mosaic.tiles {
+		if t.yOff+t.height <= y0 {
+			delete(s.cache, i)
+			continue
+		}
+		if t.yOff >= y0+n {
+			continue
+		}
+		img, err := s.tile(i)
+		if err != nil {
+			return nil, err
+		}
+
+		// Later tiles are drawn over earlier ones, band by band,
+		// except where they hold nodata, as GDAL draws the VRT.
+		sampleSize := s.pixelType.bytes
+		for r := max(y0, t.yOff); r < min(y0+n, t.yOff+t.height); r++ {
+			for c := max(0, t.xOff); c < min(width, t.xOff+t.width); c++ {
+				px := img.pix[((r-t.yOff)*t.width+c-t.xOff)*s.pixelSize:][:s.pixelSize]
+				dst := out[((r-y0)*width+c)*s.pixelSize:][:s.pixelSize]
+				for off := 0; off < s.pixelSize; off += sampleSize {
+					if sample := px[off : off+sampleSize]; s.pixelType.get(sample) != 0 {
+						copy(dst[off:], sample)
+					}
+				}
+			}
+		}
+	}
+
+	if s.cutline != nil {
+		mask := make([]byte, width*n)
+		s.cutline.rasterize(mask, width, n, 0, float64(y0))
+		for i, v := range mask {
+			if v == 0 {
+				copy(out[i*s.pixelSize:(i+1)*s.pixelSize], zero)
+			}
+		}
+	}
+	return out, nil
+}
+
+// tile returns the decoded i'th tile of the mosaic.
+func (s *mosaicSource) tile(i int) (*tiffImage, error) {
+	if img, ok := s.cache[i]; ok {
+		return img, nil
+	}
+	t := s.mosaic.tiles[i]
+	img, err := readTIFF(t.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	if img.width != t.width || img.height != t.height || img.samplesPerPixel != s.mosaic.NumBands || img.pixelType != s.pixelType {
+		return nil, errors.Errorf("tile %s is %dx%dx%d, but RDA metadata describes %dx%dx%d %s tiles",
+			t.FilePath, img.width, img.height, img.samplesPerPixel, t.width, t.height, s.mosaic.NumBands, s.mosaic.DataType)
+	}
+	s.cache[i] = img
+	return img, nil
+}
@@ -0,0 +1,248 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// mosaicTestSources returns two 2x2 tile byte images with numBands
+// bands, whose tiles are written to dir.  The second is offset from the
+// first by (150, 50) pixels, and was acquired a day later.
+func mosaicTestSources(t *testing.T, dir string, numBands int) []MosaicSource {
+	var sources []MosaicSource
+	for i, off := range [][2]float64{{0, 0}, {150, 50}} {
+		m := &Metadata{}
+		m.ImageMetadata.ImageID = fmt.Sprintf("image%d", i)
+		m.ImageMetadata.DataType = "BYTE"
+		m.ImageMetadata.NumBands = numBands
+		m.ImageMetadata.TileXSize, m.ImageMetadata.TileYSize = 100, 100
+		m.ImageMetadata.ImageWidth, m.ImageMetadata.ImageHeight = 200, 200
+		m.ImageMetadata.AcquisitionDate = time.Date(2018, 2, 28+i, 0, 0, 0, 0, time.UTC)
+		m.ImageMetadata.SatElevation = float64(80 - 10*i)
+		x0, y0 := 500000+2*off[0], 4000000-2*off[1]
+		m.ImageGeoreferencing = ImageGeoreferencing{SpatialReferenceSystemCode: "EPSG:32615", TranslateX: x0, ScaleX: 2, TranslateY: y0, ScaleY: -2}
+		m.ImageMetadata.tileGeoTransform = ImageGeoreferencing{SpatialReferenceSystemCode: "EPSG:32615", TranslateX: x0, ScaleX: 200, TranslateY: y0, ScaleY: -200}
+
+		src := MosaicSource{Metadata: m}
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 2; x++ {
+				tile := TileInfo{FilePath: filepath.Join(dir, fmt.Sprintf("%d_%d_%d.tif", i, x, y)), XTile: x, YTile: y}
+				writeTestTile(t, tile.FilePath, m, pixelType{bytes: 1, format: 1}, x, y)
+				src.Tiles = append(src.Tiles, tile)
+			}
+		}
+		sources = append(sources, src)
+	}
+	return sources
+}
+
+// wantMosaicPixel returns the pixel at (c, r) of a mosaic of
+// mosaicTestSources with the second image on top.
+func wantMosaicPixel(c, r int) float64 {
+	if c >= 150 && r >= 50 {
+		if v := testPixel(c-150, r-50, 0); v != 0 {
+			return v
+		}
+	}
+	if c < 200 && r < 200 {
+		return testPixel(c, r, 0)
+	}
+	return 0
+}
+
+func TestSortMosaicSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mosaic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sources := mosaicTestSources(t, dir, 1)
+
+	for _, tc := range []struct {
+		order MosaicOrder
+		want  string
+	}{
+		{MostRecentFirst, "image1"},
+		{LeastOffNadirFirst, "image0"},
+		{GivenOrder, "image0"},
+	} {
+		if err := SortMosaicSources(sources, tc.order); err != nil {
+			t.Fatal(err)
+		}
+		if got := sources[0].Metadata.ImageMetadata.ImageID; got != tc.want {
+			t.Errorf("%s: got %s first, want %s", tc.order, got, tc.want)
+		}
+	}
+
+	sources[1].Metadata.ImageMetadata.SatElevation = 0
+	if err := SortMosaicSources(sources, LeastOffNadirFirst); err == nil {
+		t.Error("expected an error ordering by off nadir angle without satellite elevations")
+	}
+
+	var order MosaicOrder
+	if err := order.UnmarshalText([]byte("Off-Nadir")); err != nil || order != LeastOffNadirFirst {
+		t.Errorf("got %v, %v unmarshaling Off-Nadir, want off-nadir", order, err)
+	}
+	if err := order.UnmarshalText([]byte("sideways")); err == nil {
+		t.Error("expected an error unmarshaling an unknown order")
+	}
+}
+
+func TestNewMosaic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mosaic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sources := mosaicTestSources(t, dir, 1)
+	sources[0], sources[1] = sources[1], sources[0]
+
+	mosaic, err := NewMosaic(sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mosaic.Width != 350 || mosaic.Height != 250 {
+		t.Fatalf("mosaic is %dx%d, want 350x250", mosaic.Width, mosaic.Height)
+	}
+	if gt := mosaic.GeoTransform; gt.TranslateX != 500000 || gt.TranslateY != 4000000 {
+		t.Fatalf("mosaic starts at (%v, %v), want (500000, 4000000)", gt.TranslateX, gt.TranslateY)
+	}
+
+	vrt, err := NewMosaicVRT(mosaic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vrt.RasterXSize != 350 || vrt.RasterYSize != 250 || len(vrt.Bands) != 1 {
+		t.Fatalf("VRT is %dx%dx%d, want 350x250x1", vrt.RasterXSize, vrt.RasterYSize, len(vrt.Bands))
+	}
+	srcs := vrt.Bands[0].ComplexSource
+	if len(srcs) != 8 {
+		t.Fatalf("got %d VRT sources, want 8", len(srcs))
+	}
+	if first, last := srcs[0], srcs[7]; filepath.Base(first.SourceFilename.Filename) != "0_0_0.tif" || last.DstRect.XOff != 250 || last.DstRect.YOff != 150 {
+		t.Errorf("got first source %+v and last %+v, want image0's tiles drawn first and image1's last", first, last)
+	}
+
+	cogPath := filepath.Join(dir, "out.tif")
+	if err := WriteMosaicCOG(cogPath, mosaic, WithCOGBlockSize(64)); err != nil {
+		t.Fatal(err)
+	}
+	img, err := readTIFF(cogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.width != 350 || img.height != 250 {
+		t.Fatalf("COG is %dx%d, want 350x250", img.width, img.height)
+	}
+	for r := 0; r < img.height; r++ {
+		for c := 0; c < img.width; c++ {
+			if got, want := float64(img.pix[r*img.width+c]), wantMosaicPixel(c, r); got != want {
+				t.Fatalf("pixel (%d, %d) is %v, want %v", c, r, got, want)
+			}
+		}
+	}
+}
+
+func TestMosaicVRTMatchesCOG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mosaic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The images overlap where the top one has pixels that are
+	// zero in some bands but not others.
+	mosaic, err := NewMosaic(mosaicTestSources(t, dir, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vrt, err := NewMosaicVRT(mosaic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Draw the VRT as GDAL does: each band's sources in order,
+	// skipping their nodata.
+	pixelSize := mosaic.NumBands
+	want := make([]byte, mosaic.Width*mosaic.Height*pixelSize)
+	for b, band := range vrt.Bands {
+		for _, src := range band.ComplexSource {
+			img, err := readTIFF(src.SourceFilename.Filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := src.DstRect
+			for r := 0; r < d.YSize; r++ {
+				for c := 0; c < d.XSize; c++ {
+					v := img.pix[(r*img.width+c)*pixelSize+src.SourceBand-1]
+					if src.NODATA != nil && float64(v) == *src.NODATA {
+						continue
+					}
+					want[((d.YOff+r)*mosaic.Width+d.XOff+c)*pixelSize+b] = v
+				}
+			}
+		}
+	}
+
+	cogPath := filepath.Join(dir, "out.tif")
+	if err := WriteMosaicCOG(cogPath, mosaic); err != nil {
+		t.Fatal(err)
+	}
+	img, err := readTIFF(cogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if img.pix[i] != want[i] {
+			p := i / pixelSize
+			t.Fatalf("band %d of pixel (%d, %d) is %d in the COG but %d in the VRT", i%pixelSize+1, p%mosaic.Width, p/mosaic.Width, img.pix[i], want[i])
+		}
+	}
+}
+
+func TestNewMosaicErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mosaic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := NewMosaic(nil); err == nil {
+		t.Error("expected an error mosaicking no images")
+	}
+	for name, change := range map[string]func(m *Metadata){
+		"srs":   func(m *Metadata) { m.ImageGeoreferencing.SpatialReferenceSystemCode = "EPSG:32616" },
+		"scale": func(m *Metadata) { m.ImageGeoreferencing.ScaleX = 4 },
+		"bands": func(m *Metadata) { m.ImageMetadata.NumBands = 3 },
+	} {
+		sources := mosaicTestSources(t, dir, 1)
+		change(sources[1].Metadata)
+		if _, err := NewMosaic(sources); err == nil {
+			t.Errorf("%s: expected an error mosaicking mismatched images", name)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// placeholderRE matches RDA template placeholders, e.g. "${name}" or "${name:-default}".
+var placeholderRE = regexp.MustCompile(`\$\{([^}:]+)(:-([^}]*))?\}`)
+
+// Placeholder is a template parameter, written as ${name} or
+// ${name:-default} in the parameters of a graph's nodes, that is
+// filled in when the template is evaluated.
+type Placeholder struct {
+	Name string `json:"name"`
+
+	// Required is true if any use of the placeholder lacks a
+	// default, in which case it must be provided at evaluation.
+	Required bool `json:"required"`
+
+	// Default is the default value of the placeholder's first use
+	// that has one.
+	Default string `json:"default,omitempty"`
+
+	// NodeIDs lists the nodes using the placeholder.
+	NodeIDs []string `json:"nodeIds"`
+}
+
+// Placeholders returns the template placeholders used by g's nodes,
+// sorted by name.
+func (g *Graph) Placeholders() []Placeholder {
+	byName := map[string]*Placeholder{}
+	for _, n := range g.nodes {
+		for _, key := range sortedParamKeys(n.Parameters) {
+			for _, m := range placeholderRE.FindAllStringSubmatch(n.Parameters[key], -1) {
+				p, ok := byName[m[1]]
+				if !ok {
+					p = &Placeholder{Name: m[1]}
+					byName[m[1]] = p
+				}
+				if m[2] == "" {
+					p.Required = true
+				} else if p.Default == "" {
+					p.Default = m[3]
+				}
+				if len(p.NodeIDs) == 0 || p.NodeIDs[len(p.NodeIDs)-1] != n.ID {
+					p.NodeIDs = append(p.NodeIDs, n.ID)
+				}
+			}
+		}
+	}
+
+	ps := make([]Placeholder, 0, len(byName))
+	for _, p := range byName {
+		ps = append(ps, *p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Name < ps[j].Name })
+	return ps
+}
+
+// Render returns a copy of g with its placeholders substituted by the
+// first value given for them in params, or their defaults if params
+// has none.  It fails, listing them, if any placeholders without
+// defaults are missing from params.
+func (g *Graph) Render(params url.Values) (*Graph, error) {
+	var missing []string
+	seen := map[string]bool{}
+	sub := func(m string) string {
+		sm := placeholderRE.FindStringSubmatch(m)
+		if val := params.Get(sm[1]); val != "" {
+			return val
+		}
+		if sm[2] != "" {
+			return sm[3]
+		}
+		if !seen[sm[1]] {
+			seen[sm[1]] = true
+			missing = append(missing, sm[1])
+		}
+		return m
+	}
+
+	rg := *g
+	rg.nodes = make([]node, len(g.nodes))
+	for i, n := range g.nodes {
+		nParams := make(map[string]string, len(n.Parameters))
+		for key, val := range n.Parameters {
+			nParams[placeholderRE.ReplaceAllStringFunc(key, sub)] = placeholderRE.ReplaceAllStringFunc(val, sub)
+		}
+		rg.nodes[i] = node{ID: n.ID, Operator: n.Operator, Parameters: nParams}
+	}
+	rg.edges = make([][]edge, len(g.edges))
+	for i, eList := range g.edges {
+		rg.edges[i] = append([]edge(nil), eList...)
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, errors.Errorf("no value given for the template parameter(s) %s", strings.Join(missing, ", "))
+	}
+	return &rg, nil
+}
+
+// reservedParameters are query parameters RDA understands for every
+// template, rather than ones filling in placeholders.
+var reservedParameters = map[string]bool{"nodeId": true}
+
+// CheckParameters compares the parameters given in params against
+// the placeholders ps, returning the names of those in params that no
+// placeholder uses, and of the required placeholders params lacks.
+func CheckParameters(ps []Placeholder, params url.Values) (unknown, missing []string) {
+	used := map[string]bool{}
+	for _, p := range ps {
+		used[p.Name] = true
+		if _, ok := params[p.Name]; !ok && p.Required {
+			missing = append(missing, p.Name)
+		}
+	}
+	for key := range params {
+		if !used[key] && !reservedParameters[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, missing
+}
@@ -0,0 +1,102 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGraphPlaceholders(t *testing.T) {
+	g, err := NewGraphFromDSL(strings.NewReader(`
+a = IdahoRead(imageId=$image, bucketName="${bucket:-idaho}")
+b = IdahoRead(imageId="${image}-${suffix:-pan}", bucketName=$bucket)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Placeholder{
+		{Name: "bucket", Required: true, Default: "idaho", NodeIDs: []string{"a", "b"}},
+		{Name: "image", Required: true, NodeIDs: []string{"a", "b"}},
+		{Name: "suffix", Default: "pan", NodeIDs: []string{"b"}},
+	}
+	v := ValidateGraph(g, testOperators)
+	if diff := cmp.Diff(want, v.Placeholders); diff != "" {
+		t.Fatalf("unexpected placeholders (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"bucket", "image"}, v.RequiredParameters()); diff != "" {
+		t.Fatalf("unexpected required parameters (-want +got):\n%s", diff)
+	}
+}
+
+func TestGraphRender(t *testing.T) {
+	g, err := NewGraphFromDSL(strings.NewReader(`
+a = IdahoRead(imageId=$image, bucketName="${bucket:-idaho}")
+b = IdahoRead(imageId="${image}-${suffix:-pan}", bucketName=$bucket)
+m = BandMerge(a, b)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rg, err := g.Render(url.Values{"image": {"abc"}, "bucket": {"rda"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []rdaNode{
+		{"a", "IdahoRead", map[string]string{"imageId": "abc", "bucketName": "rda"}},
+		{"b", "IdahoRead", map[string]string{"imageId": "abc-pan", "bucketName": "rda"}},
+		{"m", "BandMerge", map[string]string{}},
+	}
+	if diff := cmp.Diff(want, rg.toRDA().Nodes); diff != "" {
+		t.Fatalf("unexpected rendered nodes (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(g.toRDA().Edges, rg.toRDA().Edges); diff != "" {
+		t.Fatalf("rendering changed the edges (-want +got):\n%s", diff)
+	}
+	if g.nodes[0].Parameters["imageId"] != "${image}" {
+		t.Fatal("rendering modified the original graph")
+	}
+
+	_, err = g.Render(url.Values{"suffix": {"ms"}})
+	if err == nil || !strings.HasSuffix(err.Error(), "bucket, image") {
+		t.Fatalf("rendering without required parameters should name them, got %v", err)
+	}
+}
+
+func TestCheckParameters(t *testing.T) {
+	ps := []Placeholder{
+		{Name: "bucket", Default: "idaho"},
+		{Name: "image", Required: true},
+		{Name: "suffix", Required: true},
+	}
+	unknown, missing := CheckParameters(ps, url.Values{"imageid": {"abc"}, "suffix": {"ms"}, "nodeId": {"m"}})
+	if diff := cmp.Diff([]string{"imageid"}, unknown); diff != "" {
+		t.Errorf("unexpected unknown parameters (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"image"}, missing); diff != "" {
+		t.Errorf("unexpected missing parameters (-want +got):\n%s", diff)
+	}
+}
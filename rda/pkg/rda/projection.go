@@ -0,0 +1,230 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WGS84 ellipsoid parameters.
+const (
+	wgs84A = 6378137.0
+	wgs84F = 1 / 298.257223563
+)
+
+// projection maps geographic coordinates, longitude and latitude in
+// degrees on the WGS84 datum, to and from a projected coordinate
+// reference system.
+type projection interface {
+	forward(lon, lat float64) (x, y float64)
+	inverse(x, y float64) (lon, lat float64)
+}
+
+// lonLat is EPSG:4326, whose coordinates are given, as GDAL and
+// GeoJSON do, longitude first.
+type lonLat struct{}
+
+func (lonLat) forward(lon, lat float64) (float64, float64) { return lon, lat }
+func (lonLat) inverse(x, y float64) (float64, float64)     { return x, y }
+
+// webMercator is EPSG:3857.
+type webMercator struct{}
+
+// maxMercatorLat is where Web Mercator is conventionally cut off, the
+// latitude making the world square.
+const maxMercatorLat = 85.06
+
+func (webMercator) forward(lon, lat float64) (float64, float64) {
+	lat = math.Max(-maxMercatorLat, math.Min(maxMercatorLat, lat))
+	return wgs84A * lon * math.Pi / 180, wgs84A * math.Log(math.Tan(math.Pi/4+lat*math.Pi/360))
+}
+
+func (webMercator) inverse(x, y float64) (float64, float64) {
+	return x / wgs84A * 180 / math.Pi, (2*math.Atan(math.Exp(y/wgs84A)) - math.Pi/2) * 180 / math.Pi
+}
+
+// transverseMercator is a UTM zone on the WGS84 datum, EPSG:326xx in
+// the north and EPSG:327xx in the south, computed with Krüger's series
+// to fourth order, which is accurate to well under a millimeter within
+// the zone.
+type transverseMercator struct {
+	lon0   float64 // Central meridian, in radians.
+	falseN float64
+
+	// a is the rectifying radius scaled by k0.
+	a                  float64
+	n                  float64
+	alpha, beta, delta [4]float64
+}
+
+const (
+	utmK0     = 0.9996
+	utmFalseE = 500000.0
+)
+
+func newUTM(zone int, south bool) *transverseMercator {
+	n := wgs84F / (2 - wgs84F)
+	n2, n3, n4 := n*n, n*n*n, n*n*n*n
+	tm := transverseMercator{
+		lon0: float64(6*zone-183) * math.Pi / 180,
+		a:    utmK0 * wgs84A / (1 + n) * (1 + n2/4 + n4/64),
+		n:    n,
+		alpha: [4]float64{
+			n/2 - 2*n2/3 + 5*n3/16 + 41*n4/180,
+			13*n2/48 - 3*n3/5 + 557*n4/1440,
+			61*n3/240 - 103*n4/140,
+			49561 * n4 / 161280,
+		},
+		beta: [4]float64{
+			n/2 - 2*n2/3 + 37*n3/96 - n4/360,
+			n2/48 + n3/15 - 437*n4/1440,
+			17*n3/480 - 37*n4/840,
+			4397 * n4 / 161280,
+		},
+		delta: [4]float64{
+			2*n - 2*n2/3 - 2*n3 + 116*n4/45,
+			7*n2/3 - 8*n3/5 - 227*n4/45,
+			56*n3/15 - 136*n4/35,
+			4279 * n4 / 630,
+		},
+	}
+	if south {
+		tm.falseN = 10000000
+	}
+	return &tm
+}
+
+func (tm *transverseMercator) forward(lon, lat float64) (float64, float64) {
+	phi, lambda := lat*math.Pi/180, lon*math.Pi/180-tm.lon0
+	c := 2 * math.Sqrt(tm.n) / (1 + tm.n)
+	sinPhi := math.Sin(phi)
+	t := math.Sinh(math.Atanh(sinPhi) - c*math.Atanh(c*sinPhi))
+	xi := math.Atan2(t, math.Cos(lambda))
+	eta := math.Atanh(math.Sin(lambda) / math.Sqrt(1+t*t))
+
+	x, y := eta, xi
+	for j, a := range tm.alpha {
+		k := float64(2 * (j + 1))
+		x += a * math.Cos(k*xi) * math.Sinh(k*eta)
+		y += a * math.Sin(k*xi) * math.Cosh(k*eta)
+	}
+	return utmFalseE + tm.a*x, tm.falseN + tm.a*y
+}
+
+func (tm *transverseMercator) inverse(x, y float64) (float64, float64) {
+	xi, eta := (y-tm.falseN)/tm.a, (x-utmFalseE)/tm.a
+	xiP, etaP := xi, eta
+	for j, b := range tm.beta {
+		k := float64(2 * (j + 1))
+		xiP -= b * math.Sin(k*xi) * math.Cosh(k*eta)
+		etaP -= b * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+	chi := math.Asin(math.Sin(xiP) / math.Cosh(etaP))
+	phi := chi
+	for j, d := range tm.delta {
+		phi += d * math.Sin(float64(2*(j+1))*chi)
+	}
+	lambda := tm.lon0 + math.Atan2(math.Sinh(etaP), math.Cos(xiP))
+	lon := math.Remainder(lambda*180/math.Pi, 360)
+	return lon, phi * 180 / math.Pi
+}
+
+// parseEPSG returns the EPSG code in srs, e.g. "EPSG:32615".
+func parseEPSG(srs string) (int, error) {
+	s := strings.ToUpper(strings.TrimSpace(srs))
+	for _, prefix := range []string{"EPSG:", "URN:OGC:DEF:CRS:EPSG::", "URN:OGC:DEF:CRS:EPSG:"} {
+		if strings.HasPrefix(s, prefix) {
+			if code, err := strconv.Atoi(s[len(prefix):]); err == nil {
+				return code, nil
+			}
+		}
+	}
+	if s == "URN:OGC:DEF:CRS:OGC:1.3:CRS84" || s == "CRS84" {
+		return 4326, nil
+	}
+	return 0, errors.Errorf("%q is not an EPSG code, e.g. EPSG:4326", srs)
+}
+
+// newProjection returns the projection for srs.  EPSG:4326 (WGS84),
+// EPSG:3857 (Web Mercator), and the WGS84 UTM zones are supported.
+func newProjection(srs string) (projection, error) {
+	code, err := parseEPSG(srs)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case code == 4326:
+		return lonLat{}, nil
+	case code == 3857 || code == 900913:
+		return webMercator{}, nil
+	case code > 32600 && code <= 32660:
+		return newUTM(code-32600, false), nil
+	case code > 32700 && code <= 32760:
+		return newUTM(code-32700, true), nil
+	}
+	return nil, errors.Errorf("can't reproject EPSG:%d; only EPSG:4326, EPSG:3857, and the WGS84 UTM zones (EPSG:326xx and EPSG:327xx) are supported", code)
+}
+
+// CoordTransform transforms coordinates from one coordinate reference
+// system to another.
+type CoordTransform struct {
+	src, dst projection
+	same     bool
+}
+
+// NewCoordTransform returns a CoordTransform from the coordinate
+// reference system src to dst, both given as EPSG codes, e.g.
+// "EPSG:4326".  EPSG:4326 (WGS84 longitude and latitude), EPSG:3857
+// (Web Mercator), and the WGS84 UTM zones are supported.
+func NewCoordTransform(src, dst string) (*CoordTransform, error) {
+	srcCode, err := parseEPSG(src)
+	if err != nil {
+		return nil, err
+	}
+	dstCode, err := parseEPSG(dst)
+	if err != nil {
+		return nil, err
+	}
+	if srcCode == dstCode {
+		return &CoordTransform{same: true}, nil
+	}
+
+	t := CoordTransform{}
+	if t.src, err = newProjection(src); err != nil {
+		return nil, err
+	}
+	if t.dst, err = newProjection(dst); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Apply transforms the coordinate (x, y).
+func (t *CoordTransform) Apply(x, y float64) (float64, float64) {
+	if t.same {
+		return x, y
+	}
+	return t.dst.forward(t.src.inverse(x, y))
+}
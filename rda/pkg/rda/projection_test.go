@@ -0,0 +1,90 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCoordTransform(t *testing.T) {
+	tests := []struct {
+		src, dst     string
+		x, y         float64
+		wantX, wantY float64
+		tol          float64
+	}{
+		{"EPSG:4326", "EPSG:4326", -93, 45, -93, 45, 0},
+		{"EPSG:4326", "CRS84", -93, 45, -93, 45, 0},
+		{"EPSG:4326", "EPSG:32615", -93, 45, 500000, 4982950.4, 0.1},
+		{"EPSG:4326", "EPSG:32615", -96, 30, 210590.3, 3322575.9, 0.1},
+		{"EPSG:4326", "EPSG:32755", 147, -42, 500000, 5350223.8, 0.1},
+		{"EPSG:4326", "EPSG:3857", 10, 50, 1113194.9, 6446275.8, 0.1},
+		{"EPSG:4326", "EPSG:32617", -79.3871, 43.6426, 630087.4, 4833442.3, 0.1},
+		{"urn:ogc:def:crs:EPSG::3857", "EPSG:4326", 1113194.9, 6446275.8, 10, 50, 1e-6},
+	}
+	for _, tc := range tests {
+		ct, err := NewCoordTransform(tc.src, tc.dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, y := ct.Apply(tc.x, tc.y)
+		if math.Abs(x-tc.wantX) > tc.tol || math.Abs(y-tc.wantY) > tc.tol {
+			t.Errorf("%s -> %s: (%v, %v) went to (%v, %v), want (%v, %v)", tc.src, tc.dst, tc.x, tc.y, x, y, tc.wantX, tc.wantY)
+		}
+	}
+}
+
+func TestCoordTransformRoundTrip(t *testing.T) {
+	// Points in or near each UTM zone, where the series is accurate.
+	for _, tc := range []struct {
+		srs string
+		pts [][2]float64
+	}{
+		{"EPSG:32615", [][2]float64{{-93, 45}, {-90.5, 10}, {-95, -60}}},
+		{"EPSG:32601", [][2]float64{{-177, 45}, {179, 10}, {-175, -60}}},
+		{"EPSG:32760", [][2]float64{{177, -45}, {-179, -10}, {175, 60}}},
+		{"EPSG:3857", [][2]float64{{-93, 45}, {120, -80}, {0, 0}}},
+	} {
+		srs := tc.srs
+		fwd, err := NewCoordTransform("EPSG:4326", srs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inv, err := NewCoordTransform(srs, "EPSG:4326")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pt := range tc.pts {
+			x, y := fwd.Apply(pt[0], pt[1])
+			lon, lat := inv.Apply(x, y)
+			if math.Abs(lon-pt[0]) > 1e-8 || math.Abs(lat-pt[1]) > 1e-8 {
+				t.Errorf("%s: (%v, %v) round tripped to (%v, %v)", srs, pt[0], pt[1], lon, lat)
+			}
+		}
+	}
+
+	for _, srs := range []string{"EPSG:2193", "EPSG:32661", "EPSG:327", "EPSG", ""} {
+		if _, err := NewCoordTransform("EPSG:4326", srs); err == nil {
+			t.Errorf("expected an error transforming to %q", srs)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// maxRetryAfter caps how long we'll honor a Retry-After for.
+const maxRetryAfter = 5 * time.Minute
+
+// rateController adapts how many tile requests are in flight in the
+// spirit of TCP congestion control.  The limit starts small and grows
+// while RDA responds promptly, and is cut whenever RDA throttles us or
+// its latency climbs.  It also enforces an optional cap on how quickly
+// requests are started, and holds off all requests when RDA sends a
+// Retry-After.
+type rateController struct {
+	mu      sync.Mutex
+	changed chan struct{} // Closed and replaced whenever waiters may be able to proceed.
+
+	limit     float64
+	maxLimit  float64
+	slowStart bool
+	inFlight  int
+
+	interval    time.Duration // Minimum time between request starts, if nonzero.
+	nextStart   time.Time
+	pausedUntil time.Time
+
+	baseLatency time.Duration // Best latency seen, drifting upwards slowly.
+	avgLatency  time.Duration // Moving average of latency.
+	lastCut     time.Time
+}
+
+// newRateController returns a rateController allowing at most
+// maxConcurrency requests in flight and starting no more than maxRPS
+// requests a second; maxRPS <= 0 means no cap.
+func newRateController(maxConcurrency int, maxRPS float64) *rateController {
+	maxConcurrency = max(1, maxConcurrency)
+	c := rateController{
+		changed:   make(chan struct{}),
+		limit:     float64(min(4, maxConcurrency)),
+		maxLimit:  float64(maxConcurrency),
+		slowStart: true,
+	}
+	if maxRPS > 0 {
+		c.interval = time.Duration(float64(time.Second) / maxRPS)
+	}
+	return &c
+}
+
+// acquire blocks until a request may be made or ctx is done.  Every
+// successful call must be paired with a call to release.
+func (c *rateController) acquire(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		now := time.Now()
+		var wait time.Duration
+		switch {
+		case now.Before(c.pausedUntil):
+			wait = c.pausedUntil.Sub(now)
+		case c.inFlight >= int(c.limit):
+			wait = -1 // Until a request finishes.
+		case now.Before(c.nextStart):
+			wait = c.nextStart.Sub(now)
+		default:
+			c.inFlight++
+			if c.interval > 0 {
+				c.nextStart = now.Add(c.interval)
+			}
+			c.mu.Unlock()
+			return nil
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// release records a finished request that RDA took latency to respond
+// to; ok is true if it succeeded on its first attempt, and only then is
+// latency taken as a sample of how RDA is responding.
+func (c *rateController) release(latency time.Duration, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.notify()
+
+	c.inFlight--
+	if !ok {
+		return
+	}
+
+	if c.avgLatency == 0 {
+		c.baseLatency, c.avgLatency = latency, latency
+	}
+	c.avgLatency += (latency - c.avgLatency) / 8
+	if latency < c.baseLatency {
+		c.baseLatency = latency
+	} else {
+		c.baseLatency += (latency - c.baseLatency) / 128
+	}
+
+	// Back off if latency is climbing, otherwise ramp up.
+	if c.avgLatency > 2*c.baseLatency && c.avgLatency-c.baseLatency > 20*time.Millisecond {
+		c.cut(0.75)
+		return
+	}
+	if c.slowStart {
+		c.limit++
+	} else {
+		c.limit += 1 / c.limit
+	}
+	c.limit = math.Min(c.limit, c.maxLimit)
+}
+
+// throttle records that RDA responded with a 429 or 503, asking us to
+// wait retryAfter before trying again if it's nonzero.
+func (c *rateController) throttle(retryAfter time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.notify()
+
+	c.cut(0.5)
+	if until := time.Now().Add(retryAfter); retryAfter > 0 && until.After(c.pausedUntil) {
+		c.pausedUntil = until
+	}
+}
+
+// cut reduces the limit by factor, at most once per average request
+// latency so a burst of bad responses only counts once.
+func (c *rateController) cut(factor float64) {
+	now := time.Now()
+	if now.Sub(c.lastCut) < c.avgLatency {
+		return
+	}
+	c.lastCut = now
+	c.slowStart = false
+	c.limit = math.Max(1, c.limit*factor)
+}
+
+// notify wakes up everyone waiting in acquire; c.mu must be held.
+func (c *rateController) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// currentLimit returns the number of requests currently allowed in flight.
+func (c *rateController) currentLimit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.limit)
+}
+
+// requestTiming records the attempts made at a request, and how long
+// RDA took to respond to the last, up to its response headers.
+type requestTiming struct {
+	attempts int
+	latency  time.Duration
+}
+
+type requestTimingKey struct{}
+
+// withRequestTiming returns a copy of ctx that has requests made with
+// it through a throttledClient record their timing in rt.
+func withRequestTiming(ctx context.Context, rt *requestTiming) context.Context {
+	return context.WithValue(ctx, requestTimingKey{}, rt)
+}
+
+// timingTransport records the timing of each attempt at a request made
+// with a context from withRequestTiming.
+type timingTransport struct {
+	base http.RoundTripper
+}
+
+func (t timingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.base.RoundTrip(req)
+	if rt, ok := req.Context().Value(requestTimingKey{}).(*requestTiming); ok {
+		rt.attempts++
+		rt.latency = time.Since(start)
+	}
+	return res, err
+}
+
+// throttledClient returns a copy of client that reports throttling
+// responses to rc, retries 429s as well as 503s, and waits as long as
+// RDA asks via Retry-After between attempts.  Requests made with a
+// context from withRequestTiming have their timing recorded.
+func throttledClient(client *retryablehttp.Client, rc *rateController) *retryablehttp.Client {
+	checkRetry, backoff := client.CheckRetry, client.Backoff
+	if checkRetry == nil {
+		checkRetry = retryablehttp.DefaultRetryPolicy
+	}
+	if backoff == nil {
+		backoff = retryablehttp.DefaultBackoff
+	}
+
+	httpClient := http.Client{}
+	if client.HTTPClient != nil {
+		httpClient = *client.HTTPClient
+	}
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	httpClient.Transport = timingTransport{base: base}
+
+	return &retryablehttp.Client{
+		HTTPClient:      &httpClient,
+		Logger:          client.Logger,
+		RetryWaitMin:    client.RetryWaitMin,
+		RetryWaitMax:    client.RetryWaitMax,
+		RetryMax:        client.RetryMax,
+		RequestLogHook:  client.RequestLogHook,
+		ResponseLogHook: client.ResponseLogHook,
+		ErrorHandler:    client.ErrorHandler,
+		CheckRetry: func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+			if err == nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+				rc.throttle(retryAfter(resp))
+				if ctx.Err() != nil {
+					return false, ctx.Err()
+				}
+				return true, nil
+			}
+			return checkRetry(ctx, resp, err)
+		},
+		Backoff: func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+			if wait := retryAfter(resp); wait > 0 {
+				return wait
+			}
+			return backoff(min, max, attemptNum, resp)
+		},
+	}
+}
+
+// retryAfter returns how long resp's Retry-After header asks us to
+// wait, which is zero if it doesn't have one.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	val := resp.Header.Get("Retry-After")
+	if val == "" {
+		return 0
+	}
+
+	var wait time.Duration
+	if secs, err := strconv.Atoi(val); err == nil {
+		wait = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(val); err == nil {
+		wait = time.Until(t)
+	}
+	switch {
+	case wait < 0:
+		return 0
+	case wait > maxRetryAfter:
+		return maxRetryAfter
+	}
+	return wait
+}
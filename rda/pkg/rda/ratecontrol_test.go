@@ -0,0 +1,202 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+)
+
+func TestRateControllerAdapts(t *testing.T) {
+	rc := newRateController(32, 0)
+	if got := rc.currentLimit(); got != 4 {
+		t.Fatalf("initial limit is %d, want 4", got)
+	}
+
+	// Healthy responses ramp the limit up to the max.
+	for i := 0; i < 100; i++ {
+		if err := rc.acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		rc.release(10*time.Millisecond, true)
+	}
+	if got := rc.currentLimit(); got != 32 {
+		t.Fatalf("limit after healthy responses is %d, want 32", got)
+	}
+
+	// Throttling halves it, but only once for a burst.
+	rc.throttle(0)
+	rc.throttle(0)
+	if got := rc.currentLimit(); got != 16 {
+		t.Fatalf("limit after throttling is %d, want 16", got)
+	}
+
+	// Rising latency backs it off, once enough time has passed since the last cut.
+	rc.lastCut = time.Time{}
+	for i := 0; i < 20; i++ {
+		if err := rc.acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		rc.release(time.Second, true)
+	}
+	if got := rc.currentLimit(); got >= 16 {
+		t.Fatalf("limit after latency rose is %d, want less than 16", got)
+	}
+}
+
+func TestRateControllerWaits(t *testing.T) {
+	tests := []struct {
+		name    string
+		rc      func() *rateController
+		minWait time.Duration
+	}{
+		{"retry-after", func() *rateController {
+			rc := newRateController(4, 0)
+			rc.throttle(200 * time.Millisecond)
+			return rc
+		}, 200 * time.Millisecond},
+		{"max-rps", func() *rateController {
+			return newRateController(4, 50)
+		}, 60 * time.Millisecond},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rc := tc.rc()
+			start := time.Now()
+			for i := 0; i < 4; i++ {
+				if err := rc.acquire(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				rc.release(time.Millisecond, true)
+			}
+			if elapsed := time.Since(start); elapsed < tc.minWait {
+				t.Fatalf("requests were allowed after %s, want at least %s", elapsed, tc.minWait)
+			}
+		})
+	}
+}
+
+func TestRateControllerLimitsInFlight(t *testing.T) {
+	rc := newRateController(1, 0)
+	if err := rc.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := rc.acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("acquire returned %v while the limit was reached, want %v", err, context.DeadlineExceeded)
+	}
+
+	rc.release(time.Millisecond, true)
+	if err := rc.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThrottledClientTiming(t *testing.T) {
+	// Respond promptly, but take a while to send the body, after
+	// throttling the first request.
+	var mu sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("tile"))
+	}))
+	defer ts.Close()
+
+	client := retryablehttp.NewClient()
+	client.Logger = nil
+	client.RetryWaitMin, client.RetryWaitMax = 100*time.Millisecond, 100*time.Millisecond
+	rc := newRateController(1, 0)
+	tc := throttledClient(client, rc)
+
+	get := func() requestTiming {
+		var timing requestTiming
+		req, err := retryablehttp.NewRequest("GET", ts.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := tc.Do(req.WithContext(withRequestTiming(context.Background(), &timing)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if _, err := ioutil.ReadAll(res.Body); err != nil {
+			t.Fatal(err)
+		}
+		return timing
+	}
+
+	if timing := get(); timing.attempts != 2 || timing.latency >= 100*time.Millisecond {
+		t.Errorf("got %d attempts with latency %s for a retried request, want 2 not counting the wait between them", timing.attempts, timing.latency)
+	}
+	if timing := get(); timing.attempts != 1 || timing.latency >= 100*time.Millisecond {
+		t.Errorf("got %d attempts with latency %s, want 1 not counting reading the body", timing.attempts, timing.latency)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"absent", "", 0, 0},
+		{"seconds", "3", 3 * time.Second, 3 * time.Second},
+		{"date", time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+		{"past", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+		{"capped", "86400", maxRetryAfter, maxRetryAfter},
+		{"bogus", "soon", 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tc.value != "" {
+				resp.Header.Set("Retry-After", tc.value)
+			}
+			if got := retryAfter(resp); got < tc.min || got > tc.max {
+				t.Fatalf("retryAfter(%q) = %s, want between %s and %s", tc.value, got, tc.min, tc.max)
+			}
+		})
+	}
+}
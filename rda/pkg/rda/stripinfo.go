@@ -0,0 +1,181 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"strings"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+)
+
+// Strip summarizes a DigitalGlobe Catalog ID from the image parts
+// RDA's strip metadata reports for it.  The sensor, acquisition time,
+// angles, and cloud cover are those of its first part, panchromatic
+// if it has any, as every part of a strip is imaged in the same pass.
+type Strip struct {
+	CatalogID string `json:"catalogId"`
+
+	// PartFootprintsWKT holds the footprint of each part of one band
+	// group, PAN if the strip has any, as a WKT GEOMETRYCOLLECTION of
+	// polygons in EPSG:4326.  Neighboring parts touch or overlap, so
+	// they aren't dissolved into a single footprint.
+	PartFootprintsWKT string `json:"partFootprintsWkt,omitempty"`
+
+	Sensor          string    `json:"sensor,omitempty"`
+	AcquisitionDate time.Time `json:"acquisitionDate"`
+	SunAzimuth      float64   `json:"sunAzimuth"`
+	SunElevation    float64   `json:"sunElevation"`
+	SatAzimuth      float64   `json:"satAzimuth"`
+	SatElevation    float64   `json:"satElevation"`
+	CloudCover      float64   `json:"cloudCover"`
+
+	// GSD is the finest native ground sample distance of any part, in meters.
+	GSD float64 `json:"gsd,omitempty"`
+
+	// BandTypes are the band types the DigitalGlobeStrip template can
+	// produce for the strip: PAN, MS, Pansharp, and SWIR.
+	BandTypes []string `json:"bandTypes"`
+
+	// CorrectionTypes are the correction types the DigitalGlobeStrip
+	// template can apply to the strip: DN, TOAReflectance, and Acomp.
+	// Acomp needs multispectral imagery to estimate the atmosphere
+	// from, and doesn't apply to SWIR.
+	CorrectionTypes []string `json:"correctionTypes"`
+
+	Parts ImageParts `json:"-"`
+}
+
+// NewStrip summarizes the image parts of a strip.
+func NewStrip(parts *ImageParts) (*Strip, error) {
+	s := Strip{CatalogID: parts.CatID, Parts: *parts}
+
+	// The band group whose parts' footprints are given, and the first of which describes the strip.
+	var footprint []StripImage
+	for _, images := range [][]StripImage{parts.PanImages, parts.VNIRImages, parts.SWIRImages, parts.CavisImages} {
+		if len(images) > 0 {
+			footprint = images
+			break
+		}
+	}
+	if len(footprint) == 0 {
+		return nil, errors.Errorf("strip %s has no image parts", parts.CatID)
+	}
+
+	first := footprint[0]
+	s.Sensor = first.SensorPlatformName
+	if s.Sensor == "" {
+		s.Sensor = first.SensorName
+	}
+	s.AcquisitionDate = first.AcquisitionDate
+	s.SunAzimuth, s.SunElevation = first.SunAzimuth, first.SunElevation
+	s.SatAzimuth, s.SatElevation = first.SatAzimuth, first.SatElevation
+	s.CloudCover = first.CloudCover
+
+	var polys []string
+	for _, part := range footprint {
+		if part.ImageBoundsWGS84 == "" {
+			continue
+		}
+		pg, err := ParseWKT(part.ImageBoundsWGS84)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed parsing the footprint of image %s", part.ImageID)
+		}
+		for _, poly := range pg.Polygons {
+			polys = append(polys, (&Geometry{Polygons: []Polygon{poly}}).WKT())
+		}
+	}
+	if len(polys) > 0 {
+		s.PartFootprintsWKT = "GEOMETRYCOLLECTION (" + strings.Join(polys, ", ") + ")"
+	}
+
+	for _, images := range [][]StripImage{parts.PanImages, parts.VNIRImages, parts.SWIRImages, parts.CavisImages} {
+		for _, part := range images {
+			if gsd := part.GroundSampleDistanceMeters; gsd > 0 && (s.GSD == 0 || gsd < s.GSD) {
+				s.GSD = gsd
+			}
+		}
+	}
+
+	hasPan, hasVNIR, hasSWIR := len(parts.PanImages) > 0, len(parts.VNIRImages) > 0, len(parts.SWIRImages) > 0
+	for _, bt := range []struct {
+		name      string
+		available bool
+	}{
+		{"PAN", hasPan},
+		{"MS", hasVNIR},
+		{"Pansharp", hasPan && hasVNIR},
+		{"SWIR", hasSWIR},
+	} {
+		if bt.available {
+			s.BandTypes = append(s.BandTypes, bt.name)
+		}
+	}
+
+	s.CorrectionTypes = []string{"DN"}
+	if hasPan || hasVNIR || hasSWIR {
+		s.CorrectionTypes = append(s.CorrectionTypes, "TOAReflectance")
+	}
+	if hasVNIR {
+		s.CorrectionTypes = append(s.CorrectionTypes, "Acomp")
+	}
+	return &s, nil
+}
+
+// CheckParameters returns an error if the strip can't be realized by
+// the DigitalGlobeStrip template with the given band type and
+// correction type.
+func (s *Strip) CheckParameters(bandType, correctionType string) error {
+	if !containsString(s.BandTypes, bandType) {
+		return errors.Errorf("strip %s has no %s imagery; its band types are %s", s.CatalogID, bandType, strings.Join(s.BandTypes, ", "))
+	}
+	if !containsString(s.CorrectionTypes, correctionType) {
+		return errors.Errorf("strip %s can't be corrected to %s; its correction types are %s", s.CatalogID, correctionType, strings.Join(s.CorrectionTypes, ", "))
+	}
+	if correctionType == "Acomp" && bandType == "SWIR" {
+		return errors.Errorf("strip %s can't be corrected to Acomp as SWIR; Acomp applies to PAN, MS, and Pansharp imagery", s.CatalogID)
+	}
+	return nil
+}
+
+func containsString(vals []string, val string) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// StripMetadata returns a Strip describing the DG catalog id.
+func StripMetadata(client *retryablehttp.Client, catalogID string) (*Strip, error) {
+	return NewClient(client, nil).StripMetadata(catalogID)
+}
+
+// StripMetadata returns a Strip describing the DG catalog id.
+func (c *Client) StripMetadata(catalogID string) (*Strip, error) {
+	parts, err := c.PartSummary(catalogID)
+	if err != nil {
+		return nil, err
+	}
+	return NewStrip(parts)
+}
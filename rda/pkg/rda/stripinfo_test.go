@@ -0,0 +1,125 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// stripMetadataJSON is strip metadata as RDA reports it, trimmed to
+// a two part pan and one part vnir strip.
+const stripMetadataJSON = `{
+  "catalogIdentifier": "1040010038952900",
+  "panImages": [
+    {
+      "imageId": "pan-p001", "tileBucketName": "rda-images-1", "acquisitionDate": "2018-02-28T17:21:40.000Z",
+      "numBands": 1, "dataType": "UNSIGNED_SHORT", "imageWidth": 35180, "imageHeight": 20000,
+      "sensorName": "WorldView 3", "sensorPlatformName": "WORLDVIEW03_PAN", "groundSampleDistanceMeters": 0.31,
+      "cloudCover": 2.5, "sunAzimuth": 158.3, "sunElevation": 41.2, "satAzimuth": 212.7, "satElevation": 72.5,
+      "imageBoundsWGS84": "POLYGON ((-93 36.2, -92.9 36.2, -92.9 36.1, -93 36.1, -93 36.2))"
+    },
+    {
+      "imageId": "pan-p002", "tileBucketName": "rda-images-1", "acquisitionDate": "2018-02-28T17:21:45.000Z",
+      "numBands": 1, "dataType": "UNSIGNED_SHORT", "imageWidth": 35180, "imageHeight": 20000,
+      "sensorName": "WorldView 3", "sensorPlatformName": "WORLDVIEW03_PAN", "groundSampleDistanceMeters": 0.32,
+      "cloudCover": 7, "sunAzimuth": 158.4, "sunElevation": 41.1, "satAzimuth": 213.1, "satElevation": 71.9,
+      "imageBoundsWGS84": "POLYGON ((-93 36.1, -92.9 36.1, -92.9 36, -93 36, -93 36.1))"
+    }
+  ],
+  "vnirImages": [
+    {
+      "imageId": "mul-p001", "tileBucketName": "rda-images-1", "acquisitionDate": "2018-02-28T17:21:40.000Z",
+      "numBands": 8, "dataType": "UNSIGNED_SHORT", "imageWidth": 8795, "imageHeight": 10000,
+      "sensorName": "WorldView 3", "sensorPlatformName": "WORLDVIEW03_VNIR", "groundSampleDistanceMeters": 1.24,
+      "imageBoundsWGS84": "POLYGON ((-93 36.2, -92.9 36.2, -92.9 36, -93 36, -93 36.2))"
+    }
+  ],
+  "swirImages": [],
+  "cavisImages": []
+}`
+
+func TestNewStrip(t *testing.T) {
+	var parts ImageParts
+	if err := json.Unmarshal([]byte(stripMetadataJSON), &parts); err != nil {
+		t.Fatal(err)
+	}
+	info, err := NewStrip(&parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info.Parts = ImageParts{}
+
+	want := &Strip{
+		CatalogID:         "1040010038952900",
+		PartFootprintsWKT: "GEOMETRYCOLLECTION (POLYGON ((-93 36.2, -92.9 36.2, -92.9 36.1, -93 36.1, -93 36.2)), POLYGON ((-93 36.1, -92.9 36.1, -92.9 36, -93 36, -93 36.1)))",
+		Sensor:            "WORLDVIEW03_PAN",
+		AcquisitionDate:   time.Date(2018, 2, 28, 17, 21, 40, 0, time.UTC),
+		SunAzimuth:        158.3,
+		SunElevation:      41.2,
+		SatAzimuth:        212.7,
+		SatElevation:      72.5,
+		CloudCover:        2.5,
+		GSD:               0.31,
+		BandTypes:         []string{"PAN", "MS", "Pansharp"},
+		CorrectionTypes:   []string{"DN", "TOAReflectance", "Acomp"},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+
+	if _, err := NewStrip(&ImageParts{CatID: "empty"}); err == nil {
+		t.Error("expected an error for a strip without parts")
+	}
+	parts.PanImages[1].ImageBoundsWGS84 = "POINT (0 0)"
+	if _, err := NewStrip(&parts); err == nil {
+		t.Error("expected an error for a part with an unusable footprint")
+	}
+}
+
+func TestStripCheckParameters(t *testing.T) {
+	panOnly := &Strip{CatalogID: "pan", BandTypes: []string{"PAN"}, CorrectionTypes: []string{"DN", "TOAReflectance"}}
+	full := &Strip{CatalogID: "full", BandTypes: []string{"PAN", "MS", "Pansharp", "SWIR"}, CorrectionTypes: []string{"DN", "TOAReflectance", "Acomp"}}
+
+	tests := []struct {
+		info           *Strip
+		bandType       string
+		correctionType string
+		wantErr        bool
+	}{
+		{panOnly, "PAN", "DN", false},
+		{panOnly, "PAN", "TOAReflectance", false},
+		{panOnly, "MS", "DN", true},
+		{panOnly, "Pansharp", "DN", true},
+		{panOnly, "PAN", "Acomp", true},
+		{full, "Pansharp", "Acomp", false},
+		{full, "SWIR", "TOAReflectance", false},
+		{full, "SWIR", "Acomp", true},
+	}
+	for _, tc := range tests {
+		err := tc.info.CheckParameters(tc.bandType, tc.correctionType)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s.CheckParameters(%q, %q) = %v, want an error: %t", tc.info.CatalogID, tc.bandType, tc.correctionType, err, tc.wantErr)
+		}
+	}
+}
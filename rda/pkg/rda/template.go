@@ -23,6 +23,8 @@ package rda
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -33,6 +35,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
@@ -43,10 +46,15 @@ type Template struct {
 	templateID  string
 	queryParams url.Values
 	window      TileWindow
+	cutline     *Geometry
 
 	client *retryablehttp.Client
+	urls   *Endpoints
 
 	numParallel  int
+	maxRPS       float64
+	rc           *rateController // Shared with other templates, if set.
+	verify       bool
 	progressFunc func() int
 }
 
@@ -57,6 +65,7 @@ func NewTemplate(templateID string, client *retryablehttp.Client, options ...Tem
 		queryParams: make(url.Values),
 
 		client: client,
+		urls:   &urls,
 
 		numParallel:  4 * runtime.NumCPU(),
 		progressFunc: func() int { return 0 },
@@ -75,7 +84,8 @@ type TemplateOption func(*Template)
 // NumParallel lets you set the max concurrency used when accessing
 // RDA template API endpoints.  This is primarily for controlling how
 // many tiles to concurrently download from RDA when realizing a
-// template.
+// template.  Realization adapts its concurrency to how RDA is
+// responding, so this is an upper bound rather than a fixed amount.
 func NumParallel(val int) TemplateOption {
 	return func(t *Template) {
 		if val > 0 {
@@ -84,6 +94,40 @@ func NumParallel(val int) TemplateOption {
 	}
 }
 
+// MaxRequestsPerSecond caps how many tile requests are started each
+// second when realizing a template.  There is no cap by default.
+func MaxRequestsPerSecond(val float64) TemplateOption {
+	return func(t *Template) {
+		if val > 0 {
+			t.maxRPS = val
+		}
+	}
+}
+
+// ShareRateControl has templates make their tile requests through one
+// rate controller when realized, so realizing them at the same time
+// keeps within the first template's NumParallel and
+// MaxRequestsPerSecond between them rather than each.
+func ShareRateControl(templates ...*Template) {
+	if len(templates) == 0 {
+		return
+	}
+	rc := newRateController(templates[0].numParallel, templates[0].maxRPS)
+	for _, t := range templates {
+		t.rc = rc
+	}
+}
+
+// WithEndpoints sets the RDA endpoints the template is accessed
+// through; by default the production RDA API is used.
+func WithEndpoints(e *Endpoints) TemplateOption {
+	return func(t *Template) {
+		if e != nil {
+			t.urls = e
+		}
+	}
+}
+
 // AddParameter populates the template parameter named by key with val.
 func AddParameter(key, val string) TemplateOption {
 	return func(t *Template) {
@@ -98,6 +142,26 @@ func WithWindow(window TileWindow) TemplateOption {
 	}
 }
 
+// WithCutline crops realization to g, which must be given in the
+// image's coordinate reference system, e.g. via Geometry.Transform.
+// Only the tiles of the window that intersect g are realized, and
+// batch realization crops its output to g exactly.
+func WithCutline(g *Geometry) TemplateOption {
+	return func(t *Template) {
+		t.cutline = g
+	}
+}
+
+// VerifyTiles sets whether realization checks the checksum of every
+// tile recorded in the manifest against the tile on disk, fetching
+// those that don't match again.  By default only their sizes are
+// checked.
+func VerifyTiles(verify bool) TemplateOption {
+	return func(t *Template) {
+		t.verify = verify
+	}
+}
+
 // WithProgressFunc will set progressFunc to be called everytime a tile is downloaded during realization.
 func WithProgressFunc(progressFunc func() int) TemplateOption {
 	return func(t *Template) {
@@ -107,7 +171,7 @@ func WithProgressFunc(progressFunc func() int) TemplateOption {
 
 // Describe returns a description of the RDA template.
 func (t *Template) Describe() (*Graph, error) {
-	ep := urls.describeURL(t.templateID)
+	ep := t.urls.describeURL(t.templateID)
 
 	res, err := t.client.Get(ep)
 	if err != nil {
@@ -122,6 +186,30 @@ func (t *Template) Describe() (*Graph, error) {
 	return NewGraphFromAPI(res.Body)
 }
 
+// Render returns the template's graph with the parameters given via
+// AddParameter substituted for its placeholders, as RDA would see it
+// when evaluating the template.
+func (t *Template) Render() (*Graph, error) {
+	g, err := t.Describe()
+	if err != nil {
+		return nil, err
+	}
+	return g.Render(t.queryParams)
+}
+
+// CheckParameters compares the parameters given via AddParameter
+// against the placeholders in the template, returning the names of
+// those given that the template doesn't use, and of the required
+// placeholders that weren't given.
+func (t *Template) CheckParameters() (unknown, missing []string, err error) {
+	g, err := t.Describe()
+	if err != nil {
+		return nil, nil, err
+	}
+	unknown, missing = CheckParameters(g.Placeholders(), t.queryParams)
+	return unknown, missing, nil
+}
+
 // Upload uploads the graph g to the RDA API, returning the RDA template ID associated with it.
 func (t *Template) Upload(g *Graph) (string, error) {
 	body, err := json.Marshal(g)
@@ -129,7 +217,7 @@ func (t *Template) Upload(g *Graph) (string, error) {
 		return "", errors.Wrap(err, "failed forming request body for RDA template upload")
 	}
 
-	res, err := t.client.Post(urls.uploadURL(), "application/json", bytes.NewBuffer(body))
+	res, err := t.client.Post(t.urls.uploadURL(), "application/json", bytes.NewBuffer(body))
 	if err != nil {
 		return "", errors.Wrap(err, "failed posting template to RDA")
 	}
@@ -150,7 +238,7 @@ func (t *Template) Upload(g *Graph) (string, error) {
 
 // Metadata returns the RDA metadata describing the template.
 func (t *Template) Metadata() (*Metadata, error) {
-	ep, err := urls.metadataURL(t.templateID, t.queryParams)
+	ep, err := t.urls.metadataURL(t.templateID, t.queryParams)
 	if err != nil {
 		return nil, err
 	}
@@ -175,8 +263,16 @@ func (t *Template) Metadata() (*Metadata, error) {
 }
 
 // BatchRealize asks RDA's batch materialization to generate the imagery described by the template and its parameters.
-func (t *Template) BatchRealize(ctx context.Context, format BatchFormat) (*BatchResponse, error) {
-	// Make the request.
+//
+// Use BatchFormat.CheckMetadata beforehand to make sure the imagery
+// can be output in format.
+func (t *Template) BatchRealize(ctx context.Context, format BatchFormat, options ...BatchOption) (*BatchResponse, error) {
+	return t.SubmitBatch(ctx, t.BatchRequest(format, options...))
+}
+
+// BatchRequest returns the batch materialization request that
+// BatchRealize makes for the template and its parameters.
+func (t *Template) BatchRequest(format BatchFormat, options ...BatchOption) *BatchRequest {
 	reqBody := BatchRequest{
 		ImageReference: ImageReference{
 			TemplateID: t.templateID,
@@ -184,6 +280,9 @@ func (t *Template) BatchRealize(ctx context.Context, format BatchFormat) (*Batch
 		OutputFormat:    format,
 		CropGeometryWKT: t.window.wkt(),
 	}
+	if t.cutline != nil {
+		reqBody.CropGeometryWKT = t.cutline.WKT()
+	}
 
 	// Parse out the template's query parameters to where they need to be in the batch request body.
 	tp := make(map[string]string)
@@ -198,13 +297,26 @@ func (t *Template) BatchRealize(ctx context.Context, format BatchFormat) (*Batch
 		}
 	}
 	reqBody.ImageReference.Parameters = tp
+	for _, opt := range options {
+		opt(&reqBody)
+	}
+	return &reqBody
+}
 
-	body, err := json.Marshal(reqBody)
+// SubmitBatch posts req, e.g. as returned by BatchRequest, to RDA's
+// batch materialization.
+func (t *Template) SubmitBatch(ctx context.Context, req *BatchRequest) (*BatchResponse, error) {
+	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed forming request body for batch materialization")
 	}
 
-	res, err := t.client.Post(urls.batchURL(), "application/json", bytes.NewBuffer(body))
+	hreq, err := retryablehttp.NewRequest("POST", t.urls.batchURL(), body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed forming batch materialization request")
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	res, err := t.client.Do(hreq.WithContext(ctx))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed posting batch materialization request")
 	}
@@ -223,15 +335,77 @@ func (t *Template) BatchRealize(ctx context.Context, format BatchFormat) (*Batch
 }
 
 // Realize downloads all the tiles from RDA described by the template and its parameters to tileDir.
-func (t *Template) Realize(ctx context.Context, tileDir string) ([]TileInfo, error) {
+//
+// Completed tiles are recorded in a Manifest next to tileDir, so
+// calling Realize again after an interruption only fetches the tiles
+// that are missing or don't match the manifest.  An error is returned
+// if tileDir holds tiles from a different template, parameters, or
+// window.
+func (t *Template) Realize(ctx context.Context, tileDir string) (tiles []TileInfo, err error) {
 	if err := os.MkdirAll(tileDir, 0775); err != nil {
 		return nil, errors.Wrap(err, "couldn't make directory to realize tiles into")
 	}
 
-	return t.realize(ctx, tileDir)
+	manifest, err := openManifest(tileDir, t.templateID, t.queryParams, t.window)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := manifest.close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	return t.realize(ctx, tileDir, manifest)
+}
+
+// NumTiles returns how many tiles Realize realizes, i.e. those of
+// the window that intersect any cutline.
+func (t *Template) NumTiles() int {
+	n := 0
+	for x := t.window.MinTileX; x <= t.window.MaxTileX; x++ {
+		for y := t.window.MinTileY; y <= t.window.MaxTileY; y++ {
+			if t.wantTile(x, y) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// wantTile returns true if the tile at (x, y) is to be realized.
+func (t *Template) wantTile(x, y int) bool {
+	if t.cutline == nil {
+		return true
+	}
+	gt := &t.window.tileGeoTransform
+	var quad [4][2]float64
+	for i, corner := range [4][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+		quad[i][0], quad[i][1] = gt.Apply(float64(x)+corner[0], float64(y)+corner[1])
+	}
+	return t.cutline.IntersectsQuad(quad)
+}
+
+// realization holds what the workers realizing tiles share.
+type realization struct {
+	client   *retryablehttp.Client
+	rc       *rateController
+	manifest *manifestLog
 }
 
-func (t *Template) realize(ctx context.Context, tileDir string) ([]TileInfo, error) {
+func (t *Template) realize(ctx context.Context, tileDir string, manifest *manifestLog) ([]TileInfo, error) {
+	// Workers share a controller that adapts how many of them may
+	// have requests in flight to how RDA is responding.
+	rc := t.rc
+	if rc == nil {
+		rc = newRateController(t.numParallel, t.maxRPS)
+	}
+	r := realization{
+		client:   throttledClient(t.client, rc),
+		rc:       rc,
+		manifest: manifest,
+	}
+
 	wg := sync.WaitGroup{}
 	jobsIn := make(chan realizeJob)
 	jobsOut := make(chan realizeJob)
@@ -243,7 +417,7 @@ func (t *Template) realize(ctx context.Context, tileDir string) ([]TileInfo, err
 		go func(jobsIn <-chan realizeJob, jobsOut chan<- realizeJob) {
 			defer wg.Done()
 			for job := range jobsIn {
-				t.processJob(ctx, job, jobsOut)
+				t.processJob(ctx, &r, job, jobsOut)
 			}
 		}(jobsIn, jobsOut)
 	}
@@ -258,6 +432,9 @@ func (t *Template) realize(ctx context.Context, tileDir string) ([]TileInfo, err
 
 		for x := t.window.MinTileX; x <= t.window.MaxTileX; x++ {
 			for y := t.window.MinTileY; y <= t.window.MaxTileY; y++ {
+				if !t.wantTile(x, y) {
+					continue
+				}
 				rj := realizeJob{
 					filePath: filepath.Join(tileDir, fmt.Sprintf("tile_%d_%d.tif", x, y)),
 					xTile:    x,
@@ -265,7 +442,7 @@ func (t *Template) realize(ctx context.Context, tileDir string) ([]TileInfo, err
 				}
 
 				// Note that if the rj.err is set, we expect it to be handled by the consumer.
-				rj.url, rj.err = urls.tileURL(t.templateID, x, y, t.queryParams)
+				rj.url, rj.err = t.urls.tileURL(t.templateID, x, y, t.queryParams)
 				select {
 				case jobsIn <- rj:
 				case <-ctx.Done():
@@ -301,7 +478,7 @@ func (t *Template) realize(ctx context.Context, tileDir string) ([]TileInfo, err
 	return completedTiles, nil
 }
 
-func (t *Template) processJob(ctx context.Context, job realizeJob, jobsOut chan<- realizeJob) {
+func (t *Template) processJob(ctx context.Context, r *realization, job realizeJob, jobsOut chan<- realizeJob) {
 	// Note we always send our input jobs to the output channel, adding an error to job if one occurred.
 	defer func() { jobsOut <- job }()
 	defer t.progressFunc()
@@ -311,8 +488,8 @@ func (t *Template) processJob(ctx context.Context, job realizeJob, jobsOut chan<
 		return
 	}
 
-	// If tile is already present, don't download it.
-	if _, err := os.Stat(job.filePath); !os.IsNotExist(err) {
+	// If the tile is already present and matches the manifest, don't download it.
+	if t.haveTile(r.manifest, job.filePath) {
 		return
 	}
 
@@ -322,9 +499,18 @@ func (t *Template) processJob(ctx context.Context, job realizeJob, jobsOut chan<
 		job.err = errors.Wrapf(err, "failed forming request for tile at %s", job.url)
 		return
 	}
-	req = req.WithContext(ctx)
+	var timing requestTiming
+	req = req.WithContext(withRequestTiming(ctx, &timing))
 
-	res, err := t.client.Do(req)
+	// Wait our turn, letting the rate controller know how it went
+	// once we're done.  Only how long RDA took to respond counts as
+	// its latency, not retries or writing the tile.
+	if err := r.rc.acquire(ctx); err != nil {
+		job.err = err
+		return
+	}
+	res, err := r.client.Do(req)
+	defer r.rc.release(timing.latency, err == nil && res.StatusCode == http.StatusOK && timing.attempts == 1)
 	if err != nil {
 		job.err = errors.Wrapf(err, "failed requesting tile at %s", job.url)
 		return
@@ -336,29 +522,65 @@ func (t *Template) processJob(ctx context.Context, job realizeJob, jobsOut chan<
 		return
 	}
 
-	f, err := os.Create(job.filePath)
+	// Write to a temporary file so a partial tile is never mistaken for a complete one.
+	partPath := job.filePath + ".part"
+	f, err := os.Create(partPath)
 	if err != nil {
 		job.err = errors.Wrapf(err, "failed creating file for tile at %s", job.url)
 		return
 	}
-	if _, err := io.Copy(f, res.Body); err != nil {
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), res.Body)
+	if err != nil {
 		err = errors.Wrapf(err, "failed copying tile at %s to disk", job.url)
 		if nerr := f.Close(); nerr != nil {
-			err = errors.WithMessagef(err, "failed closing partially downloaded tile at %s: %v", job.filePath, nerr)
+			err = errors.WithMessagef(err, "failed closing partially downloaded tile at %s: %v", partPath, nerr)
 		}
-		if nerr := os.Remove(job.filePath); nerr != nil {
-			err = errors.WithMessagef(err, "failed removing file for partially downloaded tile at %s, err: %v", job.filePath, nerr)
+		if nerr := os.Remove(partPath); nerr != nil {
+			err = errors.WithMessagef(err, "failed removing file for partially downloaded tile at %s, err: %v", partPath, nerr)
 		}
 		job.err = err
 		return
 	}
 	if err := f.Close(); err != nil {
-		err = errors.Wrapf(err, "failed closing file %s for downloaded tile", job.filePath)
-		if nerr := os.Remove(job.filePath); nerr != nil {
-			err = errors.WithMessagef(err, "failed removing file for downloaded tile at %s: %v", job.filePath, nerr)
+		err = errors.Wrapf(err, "failed closing file %s for downloaded tile", partPath)
+		if nerr := os.Remove(partPath); nerr != nil {
+			err = errors.WithMessagef(err, "failed removing file for downloaded tile at %s: %v", partPath, nerr)
 		}
 		job.err = err
+		return
+	}
+	if err := os.Rename(partPath, job.filePath); err != nil {
+		job.err = errors.Wrapf(err, "failed moving downloaded tile into place at %s", job.filePath)
+		return
+	}
+
+	job.err = r.manifest.add(ManifestTile{
+		File:        filepath.Base(job.filePath),
+		URL:         job.url,
+		XTile:       job.xTile,
+		YTile:       job.yTile,
+		Size:        size,
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+		ETag:        res.Header.Get("ETag"),
+		CompletedAt: time.Now().UTC(),
+	})
+}
+
+// haveTile returns true if the tile at filePath is recorded in the
+// manifest and the file on disk matches it, checking its checksum
+// if we've been asked to verify tiles.
+func (t *Template) haveTile(manifest *manifestLog, filePath string) bool {
+	tile, ok := manifest.tile(filePath)
+	if !ok {
+		return false
+	}
+	if !t.verify {
+		fi, err := os.Stat(filePath)
+		return err == nil && fi.Size() == tile.Size
 	}
+	size, sum, err := fileChecksum(filePath)
+	return err == nil && size == tile.Size && sum == tile.SHA256
 }
 
 // TileInfo holds information about rda tiles that are local on disk.
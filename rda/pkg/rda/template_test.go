@@ -23,10 +23,16 @@ package rda
 import (
 	"context"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	retryablehttp "github.com/hashicorp/go-retryablehttp"
@@ -178,3 +184,261 @@ func TestTemplateBatchRealize(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateBatchRealizeOptions(t *testing.T) {
+	brExp := BatchRequest{
+		ImageReference: ImageReference{TemplateID: "tID"},
+		OutputFormat:   TMS,
+		FormatOptions:  map[string]string{"minZoom": "10", "maxZoom": "14"},
+		CallbackURL:    "https://example.com/done",
+		AccountID:      "acct",
+		EmailAddress:   "me@example.com",
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		br := BatchRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&br); err != nil {
+			t.Error(err)
+		}
+		if diff := cmp.Diff(brExp, br); diff != "" {
+			t.Error(diff)
+		}
+		json.NewEncoder(w).Encode(BatchResponse{JobID: "job"})
+	}))
+	defer ts.Close()
+
+	e := newEndpoints(ts.URL)
+	template := NewTemplate("tID", retryablehttp.NewClient(), WithEndpoints(&e))
+	resp, err := template.BatchRealize(context.Background(), TMS,
+		WithFormatOption("minZoom", "10"), WithFormatOption("maxZoom", "14"),
+		WithCallbackURL("https://example.com/done"), WithAccountID("acct"), WithEmailAddress("me@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.JobID != "job" {
+		t.Fatalf("got job id %q, want job", resp.JobID)
+	}
+}
+
+func TestTemplateCutline(t *testing.T) {
+	// A 4x3 window of 10x10 unit tiles, and a triangle over the
+	// tiles along its top and left edges.
+	tw := TileWindow{
+		NumXTiles: 4,
+		NumYTiles: 3,
+		MaxTileX:  3,
+		MaxTileY:  2,
+		tileGeoTransform: ImageGeoreferencing{
+			TranslateY: 30,
+			ScaleX:     10,
+			ScaleY:     -10,
+		},
+	}
+	g, err := ParseWKT("POLYGON ((1 29, 39 29, 1 1, 1 29))")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := NewTemplate("tID", retryablehttp.NewClient(), WithWindow(tw), WithCutline(g))
+	if n := template.NumTiles(); n != 9 {
+		t.Errorf("got %d tiles to realize, want 9", n)
+	}
+	if template.wantTile(3, 2) || !template.wantTile(0, 2) || !template.wantTile(3, 0) {
+		t.Error("wanted the tiles along the top and left, and only those")
+	}
+	if got := template.BatchRequest(TMS).CropGeometryWKT; got != g.WKT() {
+		t.Errorf("got crop geometry %q, want %q", got, g.WKT())
+	}
+}
+
+func TestTemplateRealizeThrottled(t *testing.T) {
+	// Throttle the first request, asking for a second's pause.
+	var mu sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+
+		if n == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("tile"))
+	}))
+	defer ts.Close()
+
+	e := newEndpoints(ts.URL)
+	client := retryablehttp.NewClient()
+	client.Logger = nil
+	tw := TileWindow{NumXTiles: 2, NumYTiles: 2, MaxTileX: 1, MaxTileY: 1}
+	template := NewTemplate("tID", client, WithEndpoints(&e), WithWindow(tw), NumParallel(2))
+
+	dir, err := ioutil.TempDir("", "realize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Now()
+	tiles, err := template.Realize(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tiles) != 4 {
+		t.Fatalf("realized %d tiles, want 4", len(tiles))
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("realization took %s, so Retry-After wasn't honored", elapsed)
+	}
+	if requests != 5 {
+		t.Fatalf("server saw %d requests, want 5", requests)
+	}
+}
+
+func TestShareRateControl(t *testing.T) {
+	// Track the most requests the server sees in flight at once.
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("tile"))
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	e := newEndpoints(ts.URL)
+	client := retryablehttp.NewClient()
+	client.Logger = nil
+	tw := TileWindow{NumXTiles: 4, NumYTiles: 4, MaxTileX: 3, MaxTileY: 3}
+	templates := make([]*Template, 3)
+	for i := range templates {
+		templates[i] = NewTemplate("tID", client, WithEndpoints(&e), WithWindow(tw), NumParallel(2))
+	}
+	ShareRateControl(templates...)
+
+	dir, err := ioutil.TempDir("", "realize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(templates))
+	for i, template := range templates {
+		wg.Add(1)
+		go func(i int, template *Template) {
+			defer wg.Done()
+			_, errs[i] = template.Realize(context.Background(), filepath.Join(dir, strconv.Itoa(i)))
+		}(i, template)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if maxInFlight > 2 {
+		t.Fatalf("server saw %d requests in flight, want at most 2 across the templates", maxInFlight)
+	}
+}
+
+func TestTemplateRealizeManifest(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Header().Set("ETag", `"etag"`)
+		w.Write([]byte("tile " + r.URL.Path))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "realize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tileDir := filepath.Join(dir, "tiles")
+
+	e := newEndpoints(ts.URL)
+	client := retryablehttp.NewClient()
+	client.Logger = nil
+	tw := TileWindow{NumXTiles: 2, NumYTiles: 2, MaxTileX: 1, MaxTileY: 1}
+	realize := func(options ...TemplateOption) error {
+		mu.Lock()
+		requests = 0
+		mu.Unlock()
+		options = append([]TemplateOption{WithEndpoints(&e), WithWindow(tw), AddParameter("p", "v")}, options...)
+		_, err := NewTemplate("tID", client, options...).Realize(context.Background(), tileDir)
+		return err
+	}
+
+	if err := realize(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadManifest(ManifestPath(tileDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.TemplateID != "tID" || m.Parameters.Get("p") != "v" || len(m.Tiles) != 4 {
+		t.Fatalf("manifest for template %s, parameters %v has %d tiles, want tID, p=v, and 4", m.TemplateID, m.Parameters, len(m.Tiles))
+	}
+	tile := m.Tiles["tile_1_0.tif"]
+	if tile.ETag != `"etag"` || tile.Size == 0 || len(tile.SHA256) != 64 || tile.CompletedAt.IsZero() || !strings.Contains(tile.URL, "p=v") {
+		t.Fatalf("manifest tile %+v is missing details", tile)
+	}
+
+	// Corrupt one tile without changing its size, and truncate another.
+	path := filepath.Join(tileDir, "tile_1_0.tif")
+	if err := ioutil.WriteFile(path, make([]byte, tile.Size), 0664); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tileDir, "tile_0_1.tif"), []byte("ti"), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		options  []TemplateOption
+		wantReqs int
+		wantErr  bool
+	}{
+		{"sizes-checked", nil, 1, false},
+		{"checksums-verified", []TemplateOption{VerifyTiles(true)}, 1, false},
+		{"all-good", []TemplateOption{VerifyTiles(true)}, 0, false},
+		{"different-params", []TemplateOption{AddParameter("p2", "v2")}, 0, true},
+		{"different-window", []TemplateOption{WithWindow(TileWindow{NumXTiles: 1, NumYTiles: 1})}, 0, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := realize(tc.options...)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, wanted one: %t", err, tc.wantErr)
+			}
+			if requests != tc.wantReqs {
+				t.Fatalf("server saw %d requests, want %d", requests, tc.wantReqs)
+			}
+		})
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "tile /template/tID/tile/1/0" {
+		t.Fatalf("corrupted tile holds %q after verification", b)
+	}
+}
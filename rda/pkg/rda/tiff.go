@@ -0,0 +1,411 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// TIFF tags we read and write.
+const (
+	tagNewSubfileType     = 254
+	tagImageWidth         = 256
+	tagImageLength        = 257
+	tagBitsPerSample      = 258
+	tagCompression        = 259
+	tagPhotometric        = 262
+	tagStripOffsets       = 273
+	tagSamplesPerPixel    = 277
+	tagRowsPerStrip       = 278
+	tagStripByteCounts    = 279
+	tagPlanarConfig       = 284
+	tagPredictor          = 317
+	tagTileWidth          = 322
+	tagTileLength         = 323
+	tagTileOffsets        = 324
+	tagTileByteCounts     = 325
+	tagExtraSamples       = 338
+	tagSampleFormat       = 339
+	tagModelPixelScale    = 33550
+	tagModelTiepoint      = 33922
+	tagModelTransform     = 34264
+	tagGeoKeyDirectory    = 34735
+	tagRPCCoefficients    = 50844
+	compressionNone       = 1
+	compressionDeflate    = 8
+	compressionDeflateOld = 32946
+)
+
+// TIFF field types.
+const (
+	typeByte   = 1
+	typeShort  = 3
+	typeLong   = 4
+	typeDouble = 12
+	typeLong8  = 16
+)
+
+// pixelType describes how a sample is stored.
+type pixelType struct {
+	bytes  int
+	format uint16 // 1 = unsigned int, 2 = signed int, 3 = IEEE float, as in the TIFF SampleFormat tag.
+}
+
+// gdalPixelType returns the pixelType of a GDAL data type name, as returned from RDAToGDALType.
+func gdalPixelType(gdalType string) (pixelType, error) {
+	switch gdalType {
+	case "Byte":
+		return pixelType{1, 1}, nil
+	case "Int16":
+		return pixelType{2, 2}, nil
+	case "UInt16":
+		return pixelType{2, 1}, nil
+	case "Int32":
+		return pixelType{4, 2}, nil
+	case "UInt32":
+		return pixelType{4, 1}, nil
+	case "Float32":
+		return pixelType{4, 3}, nil
+	case "Float64":
+		return pixelType{8, 3}, nil
+	}
+	return pixelType{}, errors.Errorf("GDAL type %q is not supported", gdalType)
+}
+
+// get returns the little endian sample at the start of b.
+func (p pixelType) get(b []byte) float64 {
+	switch {
+	case p.format == 3 && p.bytes == 4:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case p.format == 3:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case p.bytes == 1 && p.format == 2:
+		return float64(int8(b[0]))
+	case p.bytes == 1:
+		return float64(b[0])
+	case p.bytes == 2 && p.format == 2:
+		return float64(int16(binary.LittleEndian.Uint16(b)))
+	case p.bytes == 2:
+		return float64(binary.LittleEndian.Uint16(b))
+	case p.format == 2:
+		return float64(int32(binary.LittleEndian.Uint32(b)))
+	default:
+		return float64(binary.LittleEndian.Uint32(b))
+	}
+}
+
+// put writes v as a little endian sample to the start of b, rounding it if p is an integer type.
+func (p pixelType) put(b []byte, v float64) {
+	if p.format != 3 {
+		v = math.Round(v)
+	}
+	switch {
+	case p.format == 3 && p.bytes == 4:
+		binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v)))
+	case p.format == 3:
+		binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+	case p.bytes == 1 && p.format == 2:
+		b[0] = byte(int8(v))
+	case p.bytes == 1:
+		b[0] = byte(v)
+	case p.bytes == 2 && p.format == 2:
+		binary.LittleEndian.PutUint16(b, uint16(int16(v)))
+	case p.bytes == 2:
+		binary.LittleEndian.PutUint16(b, uint16(v))
+	case p.format == 2:
+		binary.LittleEndian.PutUint32(b, uint32(int32(v)))
+	default:
+		binary.LittleEndian.PutUint32(b, uint32(v))
+	}
+}
+
+// tiffImage is a decoded TIFF, holding its samples pixel interleaved
+// and in little endian byte order.
+type tiffImage struct {
+	width, height, samplesPerPixel int
+	pixelType                      pixelType
+	pix                            []byte
+}
+
+// readTIFF decodes the first image in the TIFF file at path.  This
+// covers what RDA serves tiles as: strip or tile organized TIFFs that
+// are uncompressed or deflated, with byte aligned samples.
+func readTIFF(path string) (*tiffImage, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading TIFF %s", path)
+	}
+	img, err := decodeTIFF(b)
+	return img, errors.Wrapf(err, "failed decoding TIFF %s", path)
+}
+
+func decodeTIFF(b []byte) (*tiffImage, error) {
+	if len(b) < 8 {
+		return nil, errors.New("file is too short to be a TIFF")
+	}
+	var bo binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return nil, errors.New("missing TIFF byte order mark")
+	}
+	if bo.Uint16(b[2:]) != 42 {
+		return nil, errors.New("not a classic TIFF")
+	}
+
+	// Read the integer valued tags of the first IFD.
+	ifd := int(bo.Uint32(b[4:]))
+	if ifd+2 > len(b) {
+		return nil, errors.New("IFD offset is past the end of the file")
+	}
+	tags := map[uint16][]uint64{}
+	numEntries := int(bo.Uint16(b[ifd:]))
+	for i := 0; i < numEntries; i++ {
+		e := ifd + 2 + 12*i
+		if e+12 > len(b) {
+			return nil, errors.New("IFD runs past the end of the file")
+		}
+		tag, typ, count := bo.Uint16(b[e:]), bo.Uint16(b[e+2:]), int(bo.Uint32(b[e+4:]))
+
+		var size int
+		switch typ {
+		case typeByte:
+			size = 1
+		case typeShort:
+			size = 2
+		case typeLong:
+			size = 4
+		default:
+			continue // Not a type any tag we need uses.
+		}
+		data := b[e+8 : e+12]
+		if size*count > 4 {
+			off := int(bo.Uint32(data))
+			if off < 0 || off+size*count > len(b) {
+				return nil, errors.Errorf("values of tag %d run past the end of the file", tag)
+			}
+			data = b[off : off+size*count]
+		}
+		vals := make([]uint64, count)
+		for j := range vals {
+			switch size {
+			case 1:
+				vals[j] = uint64(data[j])
+			case 2:
+				vals[j] = uint64(bo.Uint16(data[2*j:]))
+			case 4:
+				vals[j] = uint64(bo.Uint32(data[4*j:]))
+			}
+		}
+		tags[tag] = vals
+	}
+	tag := func(t uint16, def uint64) uint64 {
+		if v, ok := tags[t]; ok && len(v) > 0 {
+			return v[0]
+		}
+		return def
+	}
+
+	img := tiffImage{
+		width:           int(tag(tagImageWidth, 0)),
+		height:          int(tag(tagImageLength, 0)),
+		samplesPerPixel: int(tag(tagSamplesPerPixel, 1)),
+	}
+	if img.width == 0 || img.height == 0 {
+		return nil, errors.New("TIFF has no pixels")
+	}
+	bps := tag(tagBitsPerSample, 1)
+	for _, v := range tags[tagBitsPerSample] {
+		if v != bps {
+			return nil, errors.New("TIFFs with differing bits per sample are not supported")
+		}
+	}
+	if bps%8 != 0 {
+		return nil, errors.Errorf("TIFFs with %d bits per sample are not supported", bps)
+	}
+	img.pixelType = pixelType{bytes: int(bps / 8), format: uint16(tag(tagSampleFormat, 1))}
+	if img.pixelType.format < 1 || img.pixelType.format > 3 {
+		return nil, errors.Errorf("TIFF sample format %d is not supported", img.pixelType.format)
+	}
+	compression, predictor, planar := tag(tagCompression, compressionNone), tag(tagPredictor, 1), tag(tagPlanarConfig, 1)
+	if predictor != 1 && (predictor != 2 || img.pixelType.format == 3) {
+		return nil, errors.Errorf("TIFF predictor %d is not supported for this data type", predictor)
+	}
+
+	// Figure out the chunks, e.g. strips or tiles, that hold the pixels.
+	chunkW, chunkH := img.width, img.height
+	if rps := tag(tagRowsPerStrip, 0); rps > 0 && rps < uint64(img.height) {
+		chunkH = int(rps)
+	}
+	offsets, counts := tags[tagStripOffsets], tags[tagStripByteCounts]
+	if _, ok := tags[tagTileWidth]; ok {
+		chunkW, chunkH = int(tag(tagTileWidth, 0)), int(tag(tagTileLength, 0))
+		offsets, counts = tags[tagTileOffsets], tags[tagTileByteCounts]
+	}
+	if chunkW == 0 || chunkH == 0 || len(offsets) == 0 || len(offsets) != len(counts) {
+		return nil, errors.New("TIFF doesn't describe where its pixels are")
+	}
+	across, down := (img.width+chunkW-1)/chunkW, (img.height+chunkH-1)/chunkH
+	planes, chunkSpp := 1, img.samplesPerPixel
+	if planar == 2 {
+		planes, chunkSpp = img.samplesPerPixel, 1
+	}
+	if len(offsets) < across*down*planes {
+		return nil, errors.Errorf("TIFF has %d chunks, expected %d", len(offsets), across*down*planes)
+	}
+
+	sampleSize := img.pixelType.bytes
+	pixelSize := sampleSize * img.samplesPerPixel
+	chunkRowSize := chunkW * chunkSpp * sampleSize
+	img.pix = make([]byte, img.width*img.height*pixelSize)
+	for i := 0; i < across*down*planes; i++ {
+		off, count := offsets[i], counts[i]
+		if off+count > uint64(len(b)) {
+			return nil, errors.Errorf("TIFF chunk %d runs past the end of the file", i)
+		}
+		chunk := b[off : off+count]
+
+		switch compression {
+		case compressionNone:
+		case compressionDeflate, compressionDeflateOld:
+			zr, err := zlib.NewReader(bytes.NewReader(chunk))
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed inflating TIFF chunk %d", i)
+			}
+			if chunk, err = ioutil.ReadAll(zr); err != nil {
+				return nil, errors.Wrapf(err, "failed inflating TIFF chunk %d", i)
+			}
+		default:
+			return nil, errors.Errorf("TIFF compression %d is not supported", compression)
+		}
+		rows := min(chunkH, len(chunk)/chunkRowSize)
+
+		// Get the samples into little endian order and undo any horizontal differencing.
+		if bo == binary.BigEndian && sampleSize > 1 {
+			for s := 0; s+sampleSize <= len(chunk); s += sampleSize {
+				for l, r := s, s+sampleSize-1; l < r; l, r = l+1, r-1 {
+					chunk[l], chunk[r] = chunk[r], chunk[l]
+				}
+			}
+		}
+		if predictor == 2 {
+			step := chunkSpp * sampleSize
+			for r := 0; r < rows; r++ {
+				row := chunk[r*chunkRowSize : (r+1)*chunkRowSize]
+				for s := step; s < len(row); s += sampleSize {
+					switch sampleSize {
+					case 1:
+						row[s] += row[s-step]
+					case 2:
+						binary.LittleEndian.PutUint16(row[s:], binary.LittleEndian.Uint16(row[s:])+binary.LittleEndian.Uint16(row[s-step:]))
+					case 4:
+						binary.LittleEndian.PutUint32(row[s:], binary.LittleEndian.Uint32(row[s:])+binary.LittleEndian.Uint32(row[s-step:]))
+					case 8:
+						binary.LittleEndian.PutUint64(row[s:], binary.LittleEndian.Uint64(row[s:])+binary.LittleEndian.Uint64(row[s-step:]))
+					}
+				}
+			}
+		}
+
+		// Copy the chunk's samples to where they belong in the image.
+		plane, idx := i/(across*down), i%(across*down)
+		x0, y0 := (idx%across)*chunkW, (idx/across)*chunkH
+		for r := 0; r < rows && y0+r < img.height; r++ {
+			row := chunk[r*chunkRowSize:]
+			for c := 0; c < chunkW && x0+c < img.width; c++ {
+				dst := img.pix[((y0+r)*img.width+x0+c)*pixelSize:]
+				if planar == 2 {
+					copy(dst[plane*sampleSize:(plane+1)*sampleSize], row[c*sampleSize:])
+				} else {
+					copy(dst[:pixelSize], row[c*pixelSize:])
+				}
+			}
+		}
+	}
+	return &img, nil
+}
+
+// writeTIFF writes pix, a width by height image of samplesPerPixel
+// pixel interleaved samples of type pt in little endian byte order, to
+// path as a deflate compressed TIFF.
+func writeTIFF(path string, width, height, samplesPerPixel int, pt pixelType, pix []byte) error {
+	var data bytes.Buffer
+	zw := zlib.NewWriter(&data)
+	zw.Write(pix)
+	if err := zw.Close(); err != nil {
+		return errors.Wrapf(err, "failed compressing %s", path)
+	}
+
+	bps := make([]uint16, samplesPerPixel)
+	formats := make([]uint16, samplesPerPixel)
+	for s := range bps {
+		bps[s] = uint16(8 * pt.bytes)
+		formats[s] = pt.format
+	}
+	stripOffsets := []uint64{0} // Filled in once the IFD's size is known.
+	fields := []tiffField{
+		{tag: tagImageWidth, longs: []uint64{uint64(width)}, typ: typeLong},
+		{tag: tagImageLength, longs: []uint64{uint64(height)}, typ: typeLong},
+		{tag: tagBitsPerSample, shorts: bps},
+		{tag: tagCompression, shorts: []uint16{compressionDeflate}},
+		{tag: tagPhotometric, shorts: []uint16{1}}, // Min is black.
+		{tag: tagStripOffsets, longs: stripOffsets, typ: typeLong},
+		{tag: tagSamplesPerPixel, shorts: []uint16{uint16(samplesPerPixel)}},
+		{tag: tagRowsPerStrip, longs: []uint64{uint64(height)}, typ: typeLong},
+		{tag: tagStripByteCounts, longs: []uint64{uint64(data.Len())}, typ: typeLong},
+		{tag: tagSampleFormat, shorts: formats},
+	}
+	if samplesPerPixel > 1 {
+		fields = append(fields, tiffField{tag: tagPlanarConfig, shorts: []uint16{1}}) // Pixel interleaved.
+		fields = append(fields, tiffField{tag: tagExtraSamples, shorts: make([]uint16, samplesPerPixel-1)})
+	}
+	const ifdAt = 8
+	stripOffsets[0] = uint64(ifdAt + ifdSize(fields, false))
+
+	var buf bytes.Buffer
+	buf.Write([]byte{'I', 'I', 42, 0, ifdAt, 0, 0, 0})
+	buf.Write(encodeIFD(fields, ifdAt, 0, false))
+	buf.Write(data.Bytes())
+	return errors.Wrapf(ioutil.WriteFile(path, buf.Bytes(), 0664), "failed writing TIFF %s", path)
+}
+
+func min(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+func max(x, y int) int {
+	if x > y {
+		return x
+	}
+	return y
+}
@@ -0,0 +1,193 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Operator describes an RDA operator as the operator endpoint does.
+type Operator struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	// NumSources is how many sources the operator takes; -1 means
+	// any number.
+	NumSources int                 `json:"numSources"`
+	Parameters []OperatorParameter `json:"parameters"`
+}
+
+// OperatorParameter describes a parameter an RDA operator accepts.
+type OperatorParameter struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Required     bool   `json:"required"`
+	DefaultValue string `json:"defaultValue,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Operators returns the descriptions of every RDA operator.
+func (c *Client) Operators() ([]Operator, error) {
+	ep := c.urls.operatorURL()[0]
+	res, err := c.client.Get(ep)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failure requesting %s", ep)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, ResponseToError(res.Body, fmt.Sprintf("failed fetching operators from %s, HTTP Status: %s", ep, res.Status))
+	}
+
+	var ops []Operator
+	if err := json.NewDecoder(res.Body).Decode(&ops); err != nil {
+		return nil, errors.Wrapf(err, "couldn't unmarshal operators from %s", ep)
+	}
+	return ops, nil
+}
+
+// GraphProblem is a problem found when validating a graph, pointing
+// at the node it was found in.
+type GraphProblem struct {
+	NodeID  string `json:"nodeId"`
+	Problem string `json:"problem"`
+}
+
+func (p GraphProblem) Error() string {
+	return fmt.Sprintf("node %q: %s", p.NodeID, p.Problem)
+}
+
+// GraphValidation is the result of validating a graph against the RDA
+// operator catalog.
+type GraphValidation struct {
+	// Problems lists what's wrong with the graph, in node order.
+	Problems []GraphProblem `json:"problems"`
+
+	// Placeholders lists the template placeholders found; those
+	// that are required must be provided when evaluating the graph.
+	Placeholders []Placeholder `json:"placeholders"`
+}
+
+// Err returns an error listing every problem found, or nil if there
+// were none.
+func (v *GraphValidation) Err() error {
+	if len(v.Problems) == 0 {
+		return nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "the template has %d problem(s):", len(v.Problems))
+	for _, p := range v.Problems {
+		fmt.Fprintf(&sb, "\n\t%s", p.Error())
+	}
+	return errors.New(sb.String())
+}
+
+// RequiredParameters returns the names of the placeholders that must
+// be provided when evaluating the graph.
+func (v *GraphValidation) RequiredParameters() []string {
+	var names []string
+	for _, p := range v.Placeholders {
+		if p.Required {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// ValidateGraph checks g against the operators in ops, which is
+// typically fetched via Client.Operators.  Every node's operator must
+// exist, be given all its required parameters and no unknown ones,
+// and be fed the number of sources it takes, with the indices of its
+// incoming edges running 1, 2, 3, and so on.
+func ValidateGraph(g *Graph, ops []Operator) *GraphValidation {
+	v := GraphValidation{Placeholders: g.Placeholders()}
+	if err := g.Validate(); err != nil {
+		v.Problems = append(v.Problems, GraphProblem{Problem: err.Error()})
+		return &v
+	}
+
+	byName := make(map[string]*Operator, len(ops))
+	for i := range ops {
+		byName[ops[i].Name] = &ops[i]
+	}
+
+	for nIdx, n := range g.nodes {
+		problem := func(format string, args ...interface{}) {
+			v.Problems = append(v.Problems, GraphProblem{NodeID: n.ID, Problem: fmt.Sprintf(format, args...)})
+		}
+
+		op, ok := byName[n.Operator]
+		if !ok {
+			problem("operator %q does not exist", n.Operator)
+			continue
+		}
+
+		// Check its parameters.
+		known := map[string]bool{}
+		for _, p := range op.Parameters {
+			known[p.Name] = true
+			if _, ok := n.Parameters[p.Name]; !ok && p.Required {
+				problem("required parameter %q of operator %s is missing", p.Name, op.Name)
+			}
+		}
+		for _, key := range sortedParamKeys(n.Parameters) {
+			if !known[key] {
+				problem("operator %s has no parameter %q", op.Name, key)
+			}
+		}
+
+		// Check its sources.
+		var indices []int
+		for _, eList := range g.edges {
+			for _, e := range eList {
+				if e.nIdx == nIdx {
+					indices = append(indices, e.sourceIndex)
+				}
+			}
+		}
+		if op.NumSources >= 0 && len(indices) != op.NumSources {
+			problem("operator %s takes %d source(s), but is given %d", op.Name, op.NumSources, len(indices))
+		}
+		sort.Ints(indices)
+		for i, idx := range indices {
+			if idx != i+1 {
+				problem("source indices %v are not contiguous from 1", indices)
+				break
+			}
+		}
+	}
+	return &v
+}
+
+// sortedParamKeys returns the keys of params in sorted order.
+func sortedParamKeys(params map[string]string) []string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,129 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rda
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+)
+
+var testOperators = []Operator{
+	{Name: "IdahoRead", NumSources: 0, Parameters: []OperatorParameter{
+		{Name: "imageId", Required: true},
+		{Name: "bucketName", Required: true},
+		{Name: "objectStore"},
+	}},
+	{Name: "Orthorectify", NumSources: 1, Parameters: []OperatorParameter{
+		{Name: "Output Coordinate Reference System"},
+	}},
+	{Name: "BandMerge", NumSources: -1},
+}
+
+func TestValidateGraph(t *testing.T) {
+	tests := []struct {
+		name string
+		dsl  string
+		json string
+		want []GraphProblem
+	}{
+		{"valid", `
+r1 = IdahoRead(imageId=$image, bucketName=idaho)
+r2 = IdahoRead(imageId=$image2, bucketName=idaho, objectStore=S3)
+merged = BandMerge(r1, r2)
+ortho = Orthorectify(merged)`, "", nil},
+		{"unknown-operator", `r = IdahoReed(imageId=x, bucketName=y)`, "", []GraphProblem{
+			{"r", `operator "IdahoReed" does not exist`},
+		}},
+		{"parameters", `r = IdahoRead(imageID=x)`, "", []GraphProblem{
+			{"r", `required parameter "imageId" of operator IdahoRead is missing`},
+			{"r", `required parameter "bucketName" of operator IdahoRead is missing`},
+			{"r", `operator IdahoRead has no parameter "imageID"`},
+		}},
+		{"sources", `
+r = IdahoRead(imageId=x, bucketName=y)
+ortho = Orthorectify()
+bad = IdahoRead(r, imageId=x, bucketName=y)`, "", []GraphProblem{
+			{"ortho", "operator Orthorectify takes 1 source(s), but is given 0"},
+			{"bad", "operator IdahoRead takes 0 source(s), but is given 1"},
+		}},
+		{"non-contiguous-indices", "", `{
+  "nodes": [
+    {"id": "r1", "operator": "IdahoRead", "parameters": {"imageId": "x", "bucketName": "y"}},
+    {"id": "r2", "operator": "IdahoRead", "parameters": {"imageId": "x", "bucketName": "y"}},
+    {"id": "m", "operator": "BandMerge", "parameters": {}}
+  ],
+  "edges": [
+    {"index": 1, "source": "r1", "destination": "m"},
+    {"index": 3, "source": "r2", "destination": "m"}
+  ]
+}`, []GraphProblem{
+			{"m", "source indices [1 3] are not contiguous from 1"},
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var g *Graph
+			var err error
+			if tc.dsl != "" {
+				g, err = NewGraphFromDSL(strings.NewReader(tc.dsl))
+			} else {
+				g, err = NewGraphFromAPI(strings.NewReader(tc.json))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v := ValidateGraph(g, testOperators)
+			if diff := cmp.Diff(tc.want, v.Problems); diff != "" {
+				t.Fatalf("unexpected problems (-want +got):\n%s", diff)
+			}
+			if (v.Err() != nil) != (len(tc.want) > 0) {
+				t.Fatalf("Err() returned %v with %d problems", v.Err(), len(tc.want))
+			}
+		})
+	}
+}
+
+func TestClientOperators(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/operator" {
+			t.Errorf("requested %s rather than the list of all operators", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(testOperators)
+	}))
+	defer ts.Close()
+
+	e := newEndpoints(ts.URL)
+	ops, err := NewClient(retryablehttp.NewClient(), &e).Operators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(testOperators, ops); diff != "" {
+		t.Fatalf("unexpected operators (-want +got):\n%s", diff)
+	}
+}
@@ -36,6 +36,7 @@ type VRTDataset struct {
 	SRS          string          `xml:",omitempty"`
 	GeoTransform *GeoTransform   `xml:",omitempty"`
 	Bands        []VRTRasterBand `xml:"VRTRasterBand"`
+	MaskBand     *VRTMaskBand    `xml:",omitempty"`
 	Metadata     *VRTMetadata    `xml:",omitempty"`
 }
 
@@ -47,15 +48,20 @@ func (vrt *VRTDataset) MakeRelative(base string) error {
 	if err != nil {
 		return errors.Wrap(err, "base path in VRT cannot be made absolute, can't make VRT relative to it")
 	}
-	for _, b := range vrt.Bands {
-		for i, s := range b.SimpleSource {
-			s.SourceFilename.RelativeToVRT = true
-			fp, err := filepath.Rel(base, s.SourceFilename.Filename)
-			if err != nil {
-				return errors.Wrap(err, "failed converting VRT paths into relative ones")
+	bands := vrt.Bands
+	if vrt.MaskBand != nil {
+		bands = append(bands[:len(bands):len(bands)], vrt.MaskBand.Band)
+	}
+	for _, b := range bands {
+		for i := range b.SimpleSource {
+			if err := b.SimpleSource[i].SourceFilename.makeRelative(base); err != nil {
+				return err
+			}
+		}
+		for i := range b.ComplexSource {
+			if err := b.ComplexSource[i].SourceFilename.makeRelative(base); err != nil {
+				return err
 			}
-			s.SourceFilename.Filename = fp
-			b.SimpleSource[i] = s
 		}
 	}
 	return nil
@@ -75,9 +81,17 @@ type MDI struct {
 type GeoTransform [6]float64
 
 type VRTRasterBand struct {
-	DataType     string `xml:"dataType,attr"`
-	Band         int    `xml:"band,attr,omitempty"`
-	SimpleSource []SimpleSource
+	DataType      string   `xml:"dataType,attr"`
+	Band          int      `xml:"band,attr,omitempty"`
+	NoDataValue   *float64 `xml:",omitempty"`
+	SimpleSource  []SimpleSource
+	ComplexSource []ComplexSource `xml:",omitempty"`
+}
+
+// VRTMaskBand is a mask shared by all of a VRT's bands, where 0 masks
+// a pixel out and 255 leaves it be.
+type VRTMaskBand struct {
+	Band VRTRasterBand `xml:"VRTRasterBand"`
 }
 
 type SimpleSource struct {
@@ -88,6 +102,20 @@ type SimpleSource struct {
 	DstRect          Rect
 }
 
+// ComplexSource is a SimpleSource whose values are scaled, i.e.
+// ScaleOffset + ScaleRatio * value, and whose NODATA values, if set,
+// leave what's beneath them be.
+type ComplexSource struct {
+	SourceFilename   SourceFilename
+	SourceBand       int
+	SourceProperties SourceProperties
+	SrcRect          Rect
+	DstRect          Rect
+	ScaleOffset      float64
+	ScaleRatio       float64
+	NODATA           *float64 `xml:",omitempty"`
+}
+
 type VRTBool bool
 
 func (b VRTBool) MarshalText() (text []byte, err error) {
@@ -103,6 +131,15 @@ type SourceFilename struct {
 	Filename      string  `xml:",chardata"`
 }
 
+func (s *SourceFilename) makeRelative(base string) error {
+	fp, err := filepath.Rel(base, s.Filename)
+	if err != nil {
+		return errors.Wrap(err, "failed converting VRT paths into relative ones")
+	}
+	s.RelativeToVRT, s.Filename = true, fp
+	return nil
+}
+
 type SourceProperties struct {
 	RasterXSize int    `xml:",attr"`
 	RasterYSize int    `xml:",attr"`
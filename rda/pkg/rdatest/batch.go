@@ -0,0 +1,230 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rdatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+)
+
+// batchJob is the Server's record of a batch materialization request.
+type batchJob struct {
+	request   json.RawMessage
+	status    string
+	message   string
+	start     time.Time
+	end       time.Time
+	polls     int
+	artifacts []artifact // Published to S3 progressively as the job is polled.
+	published int
+
+	// callbackURL is POSTed the job's status when it finishes.
+	callbackURL string
+}
+
+// artifact is an output of a batch materialization job.
+type artifact struct {
+	name string
+	data []byte
+}
+
+// handleMaterialize accepts a batch materialization request,
+// creating a job whose artifacts appear in S3 as its status is polled.
+func (s *Server) handleMaterialize(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req := rda.BatchRequest{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("failed parsing batch materialization request: %v", err))
+		return
+	}
+
+	b, ok := s.template(req.ImageReference.TemplateID)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("template %q not found", req.ImageReference.TemplateID))
+		return
+	}
+	g, err := rda.NewGraphFromAPI(strings.NewReader(string(b)))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	param := func(key string) string { return req.ImageReference.Parameters[key] }
+	if status, err := checkParameters(g, param, req.ImageReference.NodeID); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	md := s.md
+	s.nextJob++
+	jobID := fmt.Sprintf("rdatest-job-%04d", s.nextJob)
+	s.mu.Unlock()
+
+	artifacts, err := batchArtifacts(&md, req.OutputFormat)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	job := &batchJob{
+		request:   body,
+		status:    "processing",
+		start:     time.Now(),
+		artifacts: artifacts,
+
+		callbackURL: req.CallbackURL,
+	}
+
+	s.mu.Lock()
+	s.jobs[jobID] = job
+	s.mu.Unlock()
+
+	writeJSON(w, job.response(jobID))
+}
+
+// handleMaterializeStatus reports on a batch materialization job,
+// advancing it each time it is polled.
+func (s *Server) handleMaterializeStatus(w http.ResponseWriter, jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[jobID]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("job %q not found", jobID))
+		return
+	}
+
+	if job.status == "processing" {
+		job.polls++
+		n := len(job.artifacts) * job.polls / s.jobPolls
+		if job.polls >= s.jobPolls {
+			n = len(job.artifacts)
+			job.status = "complete"
+			job.end = time.Now()
+			job.callback(jobID)
+		}
+		for ; job.published < n; job.published++ {
+			a := job.artifacts[job.published]
+			s.putObject(strings.Join([]string{Prefix, "rda", jobID, a.name}, "/"), a.data)
+		}
+	}
+
+	writeJSON(w, job.response(jobID))
+}
+
+// FailJob marks the batch materialization job as failed with the given message.
+func (s *Server) FailJob(jobID, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[jobID]; ok {
+		job.status = "failed"
+		job.message = msg
+		job.end = time.Now()
+		job.callback(jobID)
+	}
+}
+
+// callback POSTs the job's status to its callback URL, if it has one,
+// in the background as RDA does.  Failures are ignored.
+func (job *batchJob) callback(jobID string) {
+	if job.callbackURL == "" {
+		return
+	}
+	b, err := json.Marshal(job.response(jobID))
+	if err != nil {
+		return
+	}
+	go func(url string) {
+		res, err := http.Post(url, "application/json", bytes.NewReader(b))
+		if err == nil {
+			res.Body.Close()
+		}
+	}(job.callbackURL)
+}
+
+// response renders the job the way RDA does, with times as epoch milliseconds.
+func (job *batchJob) response(jobID string) map[string]interface{} {
+	ms := func(t time.Time) int64 { return t.UnixNano() / int64(time.Millisecond) }
+
+	status := map[string]interface{}{
+		"internalJobId": "internal-" + jobID,
+		"jobStatus":     job.status,
+		"startTime":     ms(job.start),
+		"elapsedTime":   ms(time.Now()) - ms(job.start),
+	}
+	if !job.end.IsZero() {
+		status["endTime"] = ms(job.end)
+		status["elapsedTime"] = ms(job.end) - ms(job.start)
+	}
+	if job.message != "" {
+		status["statusMessage"] = job.message
+	}
+	return map[string]interface{}{
+		"jobId":   jobID,
+		"request": job.request,
+		"status":  status,
+	}
+}
+
+// batchArtifacts returns what a batch materialization job produces
+// for the given output format.  Raster formats get a GeoTIFF per RDA
+// tile, laid out as RDA lays out that format.
+func batchArtifacts(md *rda.Metadata, format rda.BatchFormat) ([]artifact, error) {
+	tw := md.ImageMetadata.TileWindow
+	artifacts := []artifact{}
+	for y := tw.MinTileY; y <= tw.MaxTileY; y++ {
+		for x := tw.MinTileX; x <= tw.MaxTileX; x++ {
+			var name string
+			switch format {
+			case rda.Tif:
+				name = fmt.Sprintf("granule_%d_%d.tif", x, y)
+			case rda.TileStream:
+				name = fmt.Sprintf("tiles/%d/%d.tif", x, y)
+			case rda.TMS:
+				name = fmt.Sprintf("%d/%d/%d.tif", 1, x, y)
+			case rda.VectorTile:
+				name = fmt.Sprintf("%d/%d/%d.pbf", 1, x, y)
+				artifacts = append(artifacts, artifact{name: name, data: []byte{}})
+				continue
+			case rda.Vector:
+				name = fmt.Sprintf("features_%d_%d.geojson", x, y)
+				artifacts = append(artifacts, artifact{name: name, data: []byte(`{"type":"FeatureCollection","features":[]}`)})
+				continue
+			}
+			b, err := encodeTile(md, x, y)
+			if err != nil {
+				return nil, err
+			}
+			artifacts = append(artifacts, artifact{name: name, data: b})
+		}
+	}
+	return artifacts, nil
+}
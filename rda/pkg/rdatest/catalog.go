@@ -0,0 +1,191 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rdatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+)
+
+// UnavailableCatalogID is the catalog id of an acquisition the
+// Server's catalog finds but its RDA has no strip info for.
+const UnavailableCatalogID = "1050010000000000"
+
+// acquisition is a record in the Server's catalog.
+type acquisition struct {
+	catID         string
+	sensor        string
+	date          time.Time
+	cloudCover    float64
+	offNadirAngle float64
+}
+
+// acquisitions returns the records in the Server's catalog, all of
+// which cover the area of the Server's imagery.
+func (s *Server) acquisitions() []acquisition {
+	s.mu.Lock()
+	im := s.md.ImageMetadata
+	s.mu.Unlock()
+
+	return []acquisition{
+		{"1030010000000000", "WORLDVIEW02", time.Date(2016, 5, 1, 17, 12, 5, 0, time.UTC), 3, 21.5},
+		{"1040010000000000", "WORLDVIEW03_VNIR", im.AcquisitionDate, 0, 90 - im.SatElevation},
+		{UnavailableCatalogID, "GEOEYE01", time.Date(2017, 8, 15, 16, 58, 31, 0, time.UTC), 42, 28},
+	}
+}
+
+// footprint returns the footprint of the Server's imagery in EPSG:4326.
+func (s *Server) footprint() (*rda.Geometry, error) {
+	s.mu.Lock()
+	md := s.md
+	s.mu.Unlock()
+
+	ct, err := rda.NewCoordTransform(md.ImageGeoreferencing.SpatialReferenceSystemCode, "EPSG:4326")
+	if err != nil {
+		return nil, err
+	}
+	w, h := float64(md.ImageMetadata.ImageWidth), float64(md.ImageMetadata.ImageHeight)
+	var ring rda.Ring
+	for _, px := range [][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}, {0, 0}} {
+		x, y := md.ImageGeoreferencing.Apply(px[0], px[1])
+		lon, lat := ct.Apply(x, y)
+		ring = append(ring, [2]float64{lon, lat})
+	}
+	return &rda.Geometry{SRS: "EPSG:4326", Polygons: []rda.Polygon{{ring}}}, nil
+}
+
+// catalogFilter matches the filter terms the Server's catalog
+// understands, e.g. "cloudCover <= 10" or "sensorPlatformName = 'WORLDVIEW02'".
+var catalogFilter = regexp.MustCompile(`^(cloudCover|offNadirAngle|sensorPlatformName) (<=|=) (?:'([^']*)'|([-+0-9.eE]+))$`)
+
+// handleCatalogSearch answers a GBDX catalog search with those of
+// the Server's acquisitions that match it.
+func (s *Server) handleCatalogSearch(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "catalog searches must be POSTed")
+		return
+	}
+
+	var req struct {
+		SearchAreaWKT string   `json:"searchAreaWkt"`
+		StartDate     string   `json:"startDate"`
+		EndDate       string   `json:"endDate"`
+		Filters       []string `json:"filters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("failed decoding catalog search: %v", err))
+		return
+	}
+
+	area, err := rda.ParseWKT(req.SearchAreaWKT)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("bad searchAreaWkt: %v", err))
+		return
+	}
+	var from, to time.Time
+	for _, d := range []struct {
+		val string
+		t   *time.Time
+	}{{req.StartDate, &from}, {req.EndDate, &to}} {
+		if d.val == "" {
+			continue
+		}
+		if *d.t, err = time.Parse(time.RFC3339, d.val); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("bad date %q", d.val))
+			return
+		}
+	}
+
+	maxCloud, maxOffNadir := math.Inf(1), math.Inf(1)
+	sensors := map[string]bool{}
+	for _, filter := range req.Filters {
+		for _, term := range strings.Split(strings.Trim(filter, "()"), " OR ") {
+			m := catalogFilter.FindStringSubmatch(strings.TrimSpace(term))
+			if m == nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported filter %q", filter))
+				return
+			}
+			switch {
+			case m[1] == "sensorPlatformName" && m[2] == "=" && m[3] != "":
+				sensors[m[3]] = true
+			case m[1] != "sensorPlatformName" && m[2] == "<=" && m[4] != "":
+				v, _ := strconv.ParseFloat(m[4], 64)
+				if m[1] == "cloudCover" {
+					maxCloud = math.Min(maxCloud, v)
+				} else {
+					maxOffNadir = math.Min(maxOffNadir, v)
+				}
+			default:
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported filter %q", filter))
+				return
+			}
+		}
+	}
+
+	fp, err := s.footprint()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	aMinX, aMinY, aMaxX, aMaxY := area.Bounds()
+	fMinX, fMinY, fMaxX, fMaxY := fp.Bounds()
+	covers := aMinX <= fMaxX && fMinX <= aMaxX && aMinY <= fMaxY && fMinY <= aMaxY
+
+	results := []interface{}{}
+	for _, acq := range s.acquisitions() {
+		switch {
+		case !covers,
+			!from.IsZero() && acq.date.Before(from),
+			!to.IsZero() && acq.date.After(to),
+			acq.cloudCover > maxCloud,
+			acq.offNadirAngle > maxOffNadir,
+			len(sensors) > 0 && !sensors[acq.sensor]:
+			continue
+		}
+		results = append(results, map[string]interface{}{
+			"identifier": acq.catID,
+			"type":       []string{"DigitalGlobeAcquisition", "Acquisition"},
+			"properties": map[string]interface{}{
+				"catalogID":          acq.catID,
+				"timestamp":          acq.date.Format("2006-01-02T15:04:05.000Z"),
+				"sensorPlatformName": acq.sensor,
+				"cloudCover":         acq.cloudCover,
+				"offNadirAngle":      acq.offNadirAngle,
+				"footprintWkt":       fp.WKT(),
+			},
+		})
+	}
+	writeJSON(w, map[string]interface{}{
+		"stats":   map[string]interface{}{"recordsReturned": len(results)},
+		"results": results,
+	})
+}
@@ -0,0 +1,156 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rdatest
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fault describes misbehavior the Server injects into its responses.
+type Fault struct {
+	// Route selects which requests the Fault applies to: those whose
+	// URL path contains Route, e.g. "/tile/" or "/materialize/status/".
+	// An empty Route matches every request.
+	Route string
+
+	// Status, if nonzero, is sent in place of the real response, e.g.
+	// http.StatusServiceUnavailable or http.StatusTooManyRequests.
+	Status int
+
+	// RetryAfter, if nonzero, is sent as a Retry-After header along with Status.
+	RetryAfter time.Duration
+
+	// Delay holds the response back for this long before sending it.
+	Delay time.Duration
+
+	// SlowBody sends the first half of the response body, then waits
+	// this long before sending the rest.
+	SlowBody time.Duration
+
+	// Truncate sends only the first half of the response body while
+	// advertising its full length, as a dropped connection would.
+	Truncate bool
+
+	// Times is how many matching requests the Fault applies to before
+	// it is removed; zero means it applies until ClearFaults is called.
+	Times int
+}
+
+// AddFault injects f into the Server's responses.  When several
+// Faults match a request, the one added first is used.
+func (s *Server) AddFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, f)
+}
+
+// ClearFaults removes all Faults from the Server.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// fault returns the Fault to apply to a request for path, if any,
+// removing it if it has been used up.  Callers must hold the lock.
+func (s *Server) fault(path string) (Fault, bool) {
+	for i, f := range s.faults {
+		if !strings.Contains(path, f.Route) {
+			continue
+		}
+		if f.Times > 0 {
+			s.faults[i].Times--
+			if s.faults[i].Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return f, true
+	}
+	return Fault{}, false
+}
+
+// injectFaults wraps next, recording every request and applying any
+// matching Fault to it.
+func (s *Server) injectFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.Path)
+		f, ok := s.fault(r.URL.Path)
+		s.mu.Unlock()
+
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if f.Delay > 0 {
+			select {
+			case <-time.After(f.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if f.Status != 0 {
+			if f.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(f.RetryAfter.Seconds()))))
+			}
+			writeError(w, f.Status, fmt.Sprintf("rdatest injected a %d response", f.Status))
+			return
+		}
+
+		if !f.Truncate && f.SlowBody == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, r)
+		for key, val := range rec.Header() {
+			w.Header()[key] = val
+		}
+		body := rec.Body.Bytes()
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(rec.Code)
+		w.Write(body[:len(body)/2])
+
+		// A truncated body claims to be all there; the server closes
+		// the connection when it sees the short write.
+		if f.Truncate {
+			return
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		select {
+		case <-time.After(f.SlowBody):
+		case <-r.Context().Done():
+			return
+		}
+		w.Write(body[len(body)/2:])
+	})
+}
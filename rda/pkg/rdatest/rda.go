@@ -0,0 +1,445 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rdatest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+)
+
+const (
+	// DGStripTemplateID is the id of the DigitalGlobeStrip template the Server has preloaded.
+	DGStripTemplateID = "DigitalGlobeStrip"
+
+	// IdahoReadTemplateID is the id of the IdahoRead template the
+	// Server has preloaded; it is the template rda uses to read 1B
+	// image parts.
+	IdahoReadTemplateID = "848c481257a100ae373523df9f23c0176484b6f63757e9e58d2fa9c2d2af12d9"
+)
+
+// preloadedTemplates are templates the Server knows about before any are uploaded.
+var preloadedTemplates = map[string]string{
+	DGStripTemplateID: `{
+  "defaultNodeId": "SmartBandSelect",
+  "edges": [
+    {"id": "edge-0", "index": 1, "source": "DigitalGlobeStrip", "destination": "UniversalDRA"},
+    {"id": "edge-1", "index": 1, "source": "UniversalDRA", "destination": "SmartBandSelect"}
+  ],
+  "nodes": [
+    {
+      "id": "DigitalGlobeStrip",
+      "operator": "DigitalGlobeStrip",
+      "parameters": {
+        "CRS": "${crs:-UTM}",
+        "GSD": "${GSD:-}",
+        "bands": "${bands:-MS}",
+        "catId": "${catalogId}",
+        "correctionType": "${correctionType:-DN}",
+        "fallbackToTOA": "${fallbackToTOA:-false}"
+      }
+    },
+    {"id": "UniversalDRA", "operator": "UniversalDRA", "parameters": {"draType": "${draType:-None}"}},
+    {"id": "SmartBandSelect", "operator": "SmartBandSelect", "parameters": {"bandSelection": "${bandSelection:-All}"}}
+  ]
+}`,
+	IdahoReadTemplateID: `{
+  "defaultNodeId": "IdahoRead",
+  "edges": [],
+  "nodes": [
+    {
+      "id": "IdahoRead",
+      "operator": "IdahoRead",
+      "parameters": {
+        "bucketName": "${bucketName}",
+        "imageId": "${imageId}",
+        "objectStore": "S3",
+        "targetGSD": "${GSD:-}"
+      }
+    }
+  ]
+}`,
+}
+
+// operators describes the RDA operators the Server knows about.
+var operators = []operator{
+	{
+		Name:        "DigitalGlobeStrip",
+		Description: "Reads a DigitalGlobe strip, orthorectified and mosaicked from its 1B parts.",
+		NumSources:  0,
+		Parameters: []operatorParameter{
+			{Name: "catId", Type: "String", Required: true, Description: "DigitalGlobe catalog id of the strip"},
+			{Name: "CRS", Type: "String", DefaultValue: "UTM", Description: "output coordinate reference system"},
+			{Name: "GSD", Type: "Double", Description: "output ground sample distance"},
+			{Name: "bands", Type: "String", DefaultValue: "MS", Description: "band type, one of PAN, MS, SWIR, or PS"},
+			{Name: "correctionType", Type: "String", DefaultValue: "DN", Description: "one of DN, TOAReflectance, or Acomp"},
+			{Name: "fallbackToTOA", Type: "Boolean", DefaultValue: "false", Description: "use TOAReflectance when Acomp is unavailable"},
+			{Name: "Resampling Kernel", Type: "String", DefaultValue: "INTERP_BILINEAR", Description: "kernel used when orthorectifying"},
+		},
+	},
+	{
+		Name:        "IdahoRead",
+		Description: "Reads an image stored in IDAHO.",
+		NumSources:  0,
+		Parameters: []operatorParameter{
+			{Name: "bucketName", Type: "String", Required: true, Description: "bucket the image's tiles are stored in"},
+			{Name: "imageId", Type: "String", Required: true, Description: "IDAHO image id"},
+			{Name: "objectStore", Type: "String", DefaultValue: "S3", Description: "object store holding the tiles"},
+			{Name: "targetGSD", Type: "Double", Description: "ground sample distance to read at"},
+		},
+	},
+	{
+		Name:        "UniversalDRA",
+		Description: "Applies a dynamic range adjustment.",
+		NumSources:  1,
+		Parameters: []operatorParameter{
+			{Name: "draType", Type: "String", DefaultValue: "None", Description: "one of None or HistogramDRA"},
+		},
+	},
+	{
+		Name:        "SmartBandSelect",
+		Description: "Selects bands by name.",
+		NumSources:  1,
+		Parameters: []operatorParameter{
+			{Name: "bandSelection", Type: "String", DefaultValue: "All", Description: "one of All, RGB, or NRG"},
+		},
+	},
+	{
+		Name:        "BandSelect",
+		Description: "Selects bands by index.",
+		NumSources:  1,
+		Parameters: []operatorParameter{
+			{Name: "bandIndices", Type: "String", Required: true, Description: "JSON list of zero based band indices"},
+		},
+	},
+	{
+		Name:        "BandMerge",
+		Description: "Stacks the bands of its sources.",
+		NumSources:  -1,
+	},
+	{
+		Name:        "Crop",
+		Description: "Crops its source to a pixel window.",
+		NumSources:  1,
+		Parameters: []operatorParameter{
+			{Name: "x", Type: "Integer", Required: true},
+			{Name: "y", Type: "Integer", Required: true},
+			{Name: "width", Type: "Integer", Required: true},
+			{Name: "height", Type: "Integer", Required: true},
+		},
+	},
+	{
+		Name:        "Orthorectify",
+		Description: "Orthorectifies its source using its sensor model.",
+		NumSources:  1,
+		Parameters: []operatorParameter{
+			{Name: "Output Coordinate Reference System", Type: "String", DefaultValue: "UTM"},
+			{Name: "Requested GSD", Type: "Double"},
+			{Name: "Resampling Kernel", Type: "String", DefaultValue: "INTERP_BILINEAR"},
+			{Name: "Grid Size", Type: "Integer", DefaultValue: "10"},
+			{Name: "Sensor Model", Type: "String"},
+			{Name: "Elevation Source", Type: "String"},
+			{Name: "Output Pixel to World Transform", Type: "String"},
+		},
+	},
+	{
+		Name:        "TOAReflectance",
+		Description: "Converts DNs to top of atmosphere reflectance.",
+		NumSources:  1,
+	},
+}
+
+// operator describes an RDA operator as the operator endpoint does.
+type operator struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	NumSources  int                 `json:"numSources"` // -1 means any number of sources.
+	Parameters  []operatorParameter `json:"parameters"`
+}
+
+type operatorParameter struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Required     bool   `json:"required"`
+	DefaultValue string `json:"defaultValue,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// AddTemplate registers g with the Server under templateID, as if it had been uploaded.
+func (s *Server) AddTemplate(templateID string, g *rda.Graph) error {
+	b, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates[templateID] = b
+	return nil
+}
+
+// handleRDA routes requests made against the RDA API.
+func (s *Server) handleRDA(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r) {
+		return
+	}
+
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/"), "/"), "/")
+	switch {
+	case path[0] == "operator" && len(path) <= 2 && r.Method == http.MethodGet:
+		s.handleOperator(w, path[1:])
+	case path[0] == "stripMetadata" && len(path) == 2 && r.Method == http.MethodGet:
+		s.handleStripMetadata(w, path[1])
+	case path[0] == "stripMetadata" && len(path) == 3 && path[2] == "factoryMetadata" && r.Method == http.MethodGet:
+		s.handleFactoryMetadata(w, path[1])
+	case path[0] != "template":
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no RDA endpoint at %s", r.URL.Path))
+	case len(path) == 1 && r.Method == http.MethodPost:
+		s.handleUpload(w, r)
+	case len(path) == 2 && path[1] == "materialize" && r.Method == http.MethodPost:
+		s.handleMaterialize(w, r)
+	case len(path) == 4 && path[1] == "materialize" && path[2] == "status" && r.Method == http.MethodGet:
+		s.handleMaterializeStatus(w, path[3])
+	case len(path) == 2 && r.Method == http.MethodGet:
+		s.handleDescribe(w, path[1])
+	case len(path) == 3 && path[2] == "metadata" && r.Method == http.MethodGet:
+		s.handleMetadata(w, r, path[1])
+	case len(path) == 5 && path[2] == "tile" && r.Method == http.MethodGet:
+		s.handleTile(w, r, path[1], path[3], path[4])
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no RDA endpoint at %s %s", r.Method, r.URL.Path))
+	}
+}
+
+func (s *Server) handleOperator(w http.ResponseWriter, names []string) {
+	if len(names) == 0 {
+		writeJSON(w, operators)
+		return
+	}
+	for _, op := range operators {
+		if op.Name == names[0] {
+			writeJSON(w, op)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Sprintf("operator %q not found", names[0]))
+}
+
+// handleUpload stores the posted template under the hash of its
+// contents, returning the template with its id filled in.
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	g, err := rda.NewGraphFromAPI(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	b, err := json.Marshal(g)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sum := sha256.Sum256(b)
+	id := hex.EncodeToString(sum[:])
+
+	s.mu.Lock()
+	s.templates[id] = b
+	s.mu.Unlock()
+
+	resp := map[string]interface{}{}
+	json.Unmarshal(b, &resp)
+	resp["id"] = id
+	writeJSON(w, resp)
+}
+
+// template returns the JSON describing the template with the given id, if there is one.
+func (s *Server) template(templateID string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.templates[templateID]; ok {
+		return b, true
+	}
+	if t, ok := preloadedTemplates[templateID]; ok {
+		return []byte(t), true
+	}
+	return nil, false
+}
+
+func (s *Server) handleDescribe(w http.ResponseWriter, templateID string) {
+	b, ok := s.template(templateID)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("template %q not found", templateID))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// templateParamRE matches RDA template parameters, e.g. "${name}" or "${name:-default}".
+var templateParamRE = regexp.MustCompile(`\$\{([^}:]+)(:-[^}]*)?\}`)
+
+// checkTemplate verifies the template exists and that the query
+// parameters provide every template parameter lacking a default, as
+// RDA refuses to evaluate a template otherwise.
+func (s *Server) checkTemplate(templateID string, r *http.Request) (int, error) {
+	b, ok := s.template(templateID)
+	if !ok {
+		return http.StatusNotFound, fmt.Errorf("template %q not found", templateID)
+	}
+	g, err := rda.NewGraphFromAPI(bytes.NewReader(b))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return checkParameters(g, r.URL.Query().Get, r.URL.Query().Get("nodeId"))
+}
+
+func checkParameters(g *rda.Graph, param func(string) string, nodeID string) (int, error) {
+	b, err := json.Marshal(g)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	desc := struct {
+		Nodes []struct {
+			ID         string            `json:"id"`
+			Parameters map[string]string `json:"parameters"`
+		} `json:"nodes"`
+	}{}
+	if err := json.Unmarshal(b, &desc); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	foundNode := nodeID == ""
+	for _, n := range desc.Nodes {
+		foundNode = foundNode || n.ID == nodeID
+		for _, val := range n.Parameters {
+			for _, match := range templateParamRE.FindAllStringSubmatch(val, -1) {
+				if match[2] == "" && param(match[1]) == "" {
+					return http.StatusBadRequest, fmt.Errorf("template parameter %q was not provided", match[1])
+				}
+			}
+		}
+	}
+	if !foundNode {
+		return http.StatusBadRequest, fmt.Errorf("node %q not found in template", nodeID)
+	}
+	return http.StatusOK, nil
+}
+
+func (s *Server) handleMetadata(w http.ResponseWriter, r *http.Request, templateID string) {
+	if status, err := s.checkTemplate(templateID, r); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	s.mu.Lock()
+	md := s.md
+	s.mu.Unlock()
+	if templateID == IdahoReadTemplateID {
+		md = s.partImage(md, r.URL.Query().Get("imageId"))
+	}
+	writeJSON(w, metadataJSON(&md))
+}
+
+func (s *Server) handleTile(w http.ResponseWriter, r *http.Request, templateID, xStr, yStr string) {
+	if status, err := s.checkTemplate(templateID, r); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	x, xerr := strconv.Atoi(xStr)
+	y, yerr := strconv.Atoi(yStr)
+	if xerr != nil || yerr != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("tile coordinates (%s, %s) are not integers", xStr, yStr))
+		return
+	}
+
+	s.mu.Lock()
+	md := s.md
+	s.mu.Unlock()
+	if templateID == IdahoReadTemplateID {
+		md = s.partImage(md, r.URL.Query().Get("imageId"))
+	}
+
+	tw := md.ImageMetadata.TileWindow
+	if x < tw.MinTileX || x > tw.MaxTileX || y < tw.MinTileY || y > tw.MaxTileY {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("tile (%d, %d) is outside of the image", x, y))
+		return
+	}
+
+	b, err := encodeTile(&md, x, y)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "image/tiff")
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.Write(b)
+}
+
+// metadataJSON renders md the way RDA's metadata endpoint does.
+func metadataJSON(md *rda.Metadata) map[string]interface{} {
+	gt := md.ImageGeoreferencing
+	return map[string]interface{}{
+		"imageMetadata": imageMetadataJSON(&md.ImageMetadata),
+		"imageGeoreferencing": map[string]interface{}{
+			"spatialReferenceSystemCode": gt.SpatialReferenceSystemCode,
+			"translateX":                 gt.TranslateX,
+			"scaleX":                     gt.ScaleX,
+			"shearX":                     gt.ShearX,
+			"translateY":                 gt.TranslateY,
+			"shearY":                     gt.ShearY,
+			"scaleY":                     gt.ScaleY,
+		},
+	}
+}
+
+func imageMetadataJSON(im *rda.ImageMetadata) map[string]interface{} {
+	js := map[string]interface{}{
+		"imageId":              im.ImageID,
+		"tileBucketName":       im.TileBucketName,
+		"acquisitionDate":      im.AcquisitionDate,
+		"nativeTileFileFormat": "TIF",
+		"numXTiles":            im.NumXTiles,
+		"numYTiles":            im.NumYTiles,
+		"tileXSize":            im.TileXSize,
+		"tileYSize":            im.TileYSize,
+		"numBands":             im.NumBands,
+		"dataType":             im.DataType,
+		"imageHeight":          im.ImageHeight,
+		"imageWidth":           im.ImageWidth,
+		"minX":                 im.MinX,
+		"minY":                 im.MinY,
+		"maxX":                 im.MinX + im.ImageWidth - 1,
+		"maxY":                 im.MinY + im.ImageHeight - 1,
+		"minTileX":             im.MinTileX,
+		"minTileY":             im.MinTileY,
+		"maxTileX":             im.MaxTileX,
+		"maxTileY":             im.MaxTileY,
+	}
+	if im.SatElevation != 0 {
+		js["satElevation"] = im.SatElevation
+	}
+	return js
+}
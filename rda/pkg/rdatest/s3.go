@@ -0,0 +1,289 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rdatest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// object is an object stored in the Server's S3 bucket.
+type object struct {
+	data     []byte
+	etag     string
+	modified time.Time
+}
+
+// PutObject stores data in the Server's S3 bucket under key.
+func (s *Server) PutObject(key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putObject(key, data)
+}
+
+// putObject is PutObject for callers already holding the lock.
+func (s *Server) putObject(key string, data []byte) {
+	sum := md5.Sum(data)
+	s.objects[key] = &object{
+		data:     data,
+		etag:     `"` + hex.EncodeToString(sum[:]) + `"`,
+		modified: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// Object returns the contents of the object stored in the Server's
+// S3 bucket under key, if there is one.
+func (s *Server) Object(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[key]
+	if !ok {
+		return nil, false
+	}
+	return o.data, true
+}
+
+// ObjectKeys returns the sorted keys of objects in the Server's S3
+// bucket that start with prefix.
+func (s *Server) ObjectKeys(prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := []string{}
+	for _, key := range sortedKeys(s.objects) {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// handleS3 serves the subset of the path style S3 API that rda
+// uses: listing, getting, heading, putting, and deleting objects.
+func (s *Server) handleS3(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Authorization"), "Credential=rdatest-access-key/") {
+		writeS3Error(w, http.StatusForbidden, "AccessDenied", "request was not signed with credentials from the S3 credentials endpoint")
+		return
+	}
+
+	path := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/s3/"), "/", 2)
+	if path[0] != Bucket {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket", fmt.Sprintf("bucket %q does not exist", path[0]))
+		return
+	}
+	key := ""
+	if len(path) == 2 {
+		key = path[1]
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.handleListObjects(w, r)
+	case key == "" && r.Method == http.MethodPost && r.URL.Query()["delete"] != nil:
+		s.handleDeleteObjects(w, r)
+	case key != "" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		s.mu.Lock()
+		o, ok := s.objects[key]
+		s.mu.Unlock()
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey", fmt.Sprintf("key %q does not exist", key))
+			return
+		}
+		w.Header().Set("ETag", o.etag)
+		http.ServeContent(w, r, key, o.modified, bytes.NewReader(o.data))
+	case key != "" && r.Method == http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return
+		}
+		s.PutObject(key, data)
+		s.mu.Lock()
+		w.Header().Set("ETag", s.objects[key].etag)
+		s.mu.Unlock()
+	case key != "" && r.Method == http.MethodDelete:
+		s.mu.Lock()
+		delete(s.objects, key)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("%s %s is not supported by rdatest", r.Method, r.URL))
+	}
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	KeyCount              int            `xml:"KeyCount"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	Contents              []listContents `xml:"Contents"`
+	CommonPrefixes        []listPrefix   `xml:"CommonPrefixes"`
+}
+
+type listContents struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type listPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// handleListObjects implements ListObjectsV2, using the last key
+// returned as the continuation token.
+func (s *Server) handleListObjects(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix, delim, token := q.Get("prefix"), q.Get("delimiter"), q.Get("continuation-token")
+	if token == "" {
+		token = q.Get("start-after")
+	}
+	maxKeys := 1000
+	if mk, err := strconv.Atoi(q.Get("max-keys")); err == nil && mk > 0 && mk < maxKeys {
+		maxKeys = mk
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := listBucketResult{
+		Name:              Bucket,
+		Prefix:            prefix,
+		Delimiter:         delim,
+		MaxKeys:           maxKeys,
+		ContinuationToken: q.Get("continuation-token"),
+	}
+	seen := map[string]bool{}
+	for _, key := range sortedKeys(s.objects) {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		entry, isPrefix := key, false
+		if i := strings.Index(key[len(prefix):], delim); delim != "" && i >= 0 {
+			entry, isPrefix = key[:len(prefix)+i+len(delim)], true
+		}
+		if entry <= token || seen[entry] {
+			continue
+		}
+		if res.KeyCount == maxKeys {
+			res.IsTruncated = true
+			break
+		}
+		seen[entry] = true
+		res.KeyCount++
+		res.NextContinuationToken = entry
+
+		if isPrefix {
+			res.CommonPrefixes = append(res.CommonPrefixes, listPrefix{Prefix: entry})
+			continue
+		}
+		o := s.objects[key]
+		res.Contents = append(res.Contents, listContents{
+			Key:          key,
+			LastModified: o.modified.Format("2006-01-02T15:04:05.000Z"),
+			ETag:         o.etag,
+			Size:         len(o.data),
+			StorageClass: "STANDARD",
+		})
+	}
+	if !res.IsTruncated {
+		res.NextContinuationToken = ""
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+type deleteRequest struct {
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+	Quiet bool `xml:"Quiet"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []deletedObject `xml:"Deleted"`
+}
+
+type deletedObject struct {
+	Key string `xml:"Key"`
+}
+
+// handleDeleteObjects implements DeleteObjects.  Deleting a key that
+// doesn't exist succeeds, as it does in S3.
+func (s *Server) handleDeleteObjects(w http.ResponseWriter, r *http.Request) {
+	req := deleteRequest{}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeS3Error(w, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := deleteResult{}
+	for _, o := range req.Objects {
+		delete(s.objects, o.Key)
+		if !req.Quiet {
+			res.Deleted = append(res.Deleted, deletedObject{Key: o.Key})
+		}
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+// writeXML writes v as the XML body of a response.
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+}
+
+// writeS3Error writes an error response shaped like those S3 sends.
+func writeS3Error(w http.ResponseWriter, status int, code, msg string) {
+	b, _ := xml.Marshal(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: code, Message: msg})
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+}
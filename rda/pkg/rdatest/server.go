@@ -0,0 +1,277 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package rdatest provides a fake RDA and GBDX API for exercising rda
+// and its commands without network access or GBDX credentials.
+//
+// A Server answers the RDA endpoints rda uses (operator, strip
+// metadata, template upload/describe/metadata/tile, and batch
+// materialization and its status), the GBDX oauth2 token, S3
+// credentials, and catalog search endpoints, and a minimal path style
+// S3 API holding batch materialization artifacts.  Faults can be
+// injected per route to simulate RDA misbehaving.
+package rdatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+)
+
+const (
+	// Username and Password are the GBDX credentials the Server accepts.
+	Username = "rdatest@example.com"
+	Password = "rdatest"
+
+	// AccessToken is the oauth2 token handed out by the Server.
+	AccessToken = "rdatest-access-token"
+
+	// Bucket and Prefix are the customer data location handed out by the Server's S3 credentials endpoint.
+	Bucket = "rdatest-bucket"
+	Prefix = "rdatest-account"
+)
+
+// Server is a fake RDA and GBDX API.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	md        rda.Metadata
+	jobPolls  int
+	templates map[string][]byte
+	jobs      map[string]*batchJob
+	nextJob   int
+	objects   map[string]*object
+	faults    []Fault
+	requests  []string
+}
+
+// ServerOption sets options on a Server.
+type ServerOption func(*Server)
+
+// WithMetadata sets the RDA metadata describing every template and
+// 1B image part the Server renders; by default DefaultMetadata is used.
+func WithMetadata(md rda.Metadata) ServerOption {
+	return func(s *Server) {
+		s.md = md
+	}
+}
+
+// WithJobPolls sets how many times a batch materialization job's
+// status must be requested before the job reports it has completed.
+// By default jobs complete on their second status request.
+func WithJobPolls(n int) ServerOption {
+	return func(s *Server) {
+		if n > 0 {
+			s.jobPolls = n
+		}
+	}
+}
+
+// DefaultMetadata returns the metadata the Server uses when none is
+// provided: a 512x512, 3 band BYTE image in UTM zone 15N cut into 4
+// tiles.
+func DefaultMetadata() rda.Metadata {
+	return rda.Metadata{
+		ImageMetadata: rda.ImageMetadata{
+			ImageWidth:  512,
+			ImageHeight: 512,
+			NumBands:    3,
+			DataType:    "BYTE",
+			TileXSize:   256,
+			TileYSize:   256,
+			TileWindow: rda.TileWindow{
+				NumXTiles: 2,
+				NumYTiles: 2,
+				MaxTileX:  1,
+				MaxTileY:  1,
+			},
+			AcquisitionDate: time.Date(2018, 2, 28, 17, 21, 40, 0, time.UTC),
+			ImageID:         "rdatest-image",
+			TileBucketName:  "rdatest-tiles",
+			SatElevation:    72.5,
+		},
+		ImageGeoreferencing: rda.ImageGeoreferencing{
+			SpatialReferenceSystemCode: "EPSG:32615",
+			TranslateX:                 500000,
+			ScaleX:                     2,
+			TranslateY:                 4000000,
+			ScaleY:                     -2,
+		},
+	}
+}
+
+// NewServer starts and returns a new Server.  The caller should call
+// Close when finished, to shut it down.
+func NewServer(options ...ServerOption) *Server {
+	s := NewUnstartedServer(options...)
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a new Server but doesn't start it,
+// letting the caller change its Listener before calling Start.
+func NewUnstartedServer(options ...ServerOption) *Server {
+	s := &Server{
+		md:        DefaultMetadata(),
+		jobPolls:  2,
+		templates: make(map[string][]byte),
+		jobs:      make(map[string]*batchJob),
+		objects:   make(map[string]*object),
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/", s.handleRDA)
+	mux.HandleFunc("/auth/v1/oauth/token", s.handleToken)
+	mux.HandleFunc("/s3creds/v1/prefix", s.handleS3Credentials)
+	mux.HandleFunc("/catalog/v2/search", s.handleCatalogSearch)
+	mux.HandleFunc("/s3/", s.handleS3)
+	s.Server = httptest.NewUnstartedServer(s.injectFaults(mux))
+	return s
+}
+
+// RDAURL returns the base URL of the Server's RDA API, suitable for rda.NewEndpoints.
+func (s *Server) RDAURL() string {
+	return s.URL + "/v1"
+}
+
+// TokenURL returns the Server's GBDX oauth2 token endpoint.
+func (s *Server) TokenURL() string {
+	return s.URL + "/auth/v1/oauth/token"
+}
+
+// S3CredentialsURL returns the Server's GBDX S3 credentials endpoint.
+func (s *Server) S3CredentialsURL() string {
+	return s.URL + "/s3creds/v1/prefix"
+}
+
+// CatalogURL returns the Server's GBDX catalog search endpoint.
+func (s *Server) CatalogURL() string {
+	return s.URL + "/catalog/v2/search"
+}
+
+// S3URL returns the endpoint of the Server's S3 API.
+func (s *Server) S3URL() string {
+	return s.URL + "/s3"
+}
+
+// Requests returns how many requests the Server has received whose
+// path contains route, including those a Fault answered.
+func (s *Server) Requests(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, path := range s.requests {
+		if strings.Contains(path, route) {
+			n++
+		}
+	}
+	return n
+}
+
+// handleToken hands out AccessToken for the password grant when
+// given Username and Password, and for any refresh grant.
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "token requests must be POSTed")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "password":
+		if r.PostForm.Get("username") != Username || r.PostForm.Get("password") != Password {
+			writeError(w, http.StatusUnauthorized, "bad username or password")
+			return
+		}
+	case "refresh_token":
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported grant_type %q", r.PostForm.Get("grant_type")))
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"access_token":  AccessToken,
+		"refresh_token": AccessToken,
+		"token_type":    "Bearer",
+		"expires_in":    3600,
+	})
+}
+
+// handleS3Credentials hands out AWS credentials that the Server's S3 API accepts.
+func (s *Server) handleS3Credentials(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r) {
+		return
+	}
+	writeJSON(w, map[string]string{
+		"bucket":           Bucket,
+		"prefix":           Prefix,
+		"S3_access_key":    "rdatest-access-key",
+		"S3_secret_key":    "rdatest-secret-key",
+		"S3_session_token": "rdatest-session-token",
+	})
+}
+
+// authorized returns true if the request carries the Server's access
+// token, otherwise it writes an error response and returns false.
+func authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Authorization") != "Bearer "+AccessToken {
+		writeError(w, http.StatusUnauthorized, "missing or invalid access token")
+		return false
+	}
+	return true
+}
+
+// writeJSON writes v as the JSON body of a 200 response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response shaped like those RDA sends.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]*object) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
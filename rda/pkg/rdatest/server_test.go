@@ -0,0 +1,496 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rdatest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/gbdx"
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"golang.org/x/oauth2"
+)
+
+// newTestClient returns a client authorized against s the way rda's commands are.
+func newTestClient(t *testing.T, s *Server) (*retryablehttp.Client, *rda.Client) {
+	ctx := context.Background()
+	conf := &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: s.TokenURL()}}
+	tok, err := conf.PasswordCredentialsToken(ctx, Username, Password)
+	if err != nil {
+		t.Fatalf("failed getting a token: %v", err)
+	}
+
+	client := retryablehttp.NewClient()
+	client.HTTPClient = oauth2.NewClient(ctx, conf.TokenSource(ctx, tok))
+	client.Logger = nil
+	client.RetryWaitMin = time.Millisecond
+	client.RetryWaitMax = 10 * time.Millisecond
+
+	endpoints, err := rda.NewEndpoints(s.RDAURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, rda.NewClient(client, endpoints)
+}
+
+func TestTokenRequiresCredentials(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	conf := &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: s.TokenURL()}}
+	if _, err := conf.PasswordCredentialsToken(context.Background(), Username, "wrong"); err == nil {
+		t.Fatal("expected a bad password to be refused a token")
+	}
+
+	res, err := http.Get(s.RDAURL() + "/operator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("got status %d for an unauthorized request, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestOperatorInfo(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	_, api := newTestClient(t, s)
+
+	buf := &bytes.Buffer{}
+	if err := api.OperatorInfo(buf, "IdahoRead", "Crop"); err != nil {
+		t.Fatal(err)
+	}
+	ops := []struct{ Name string }{}
+	if err := json.Unmarshal(buf.Bytes(), &ops); err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 || ops[0].Name != "IdahoRead" || ops[1].Name != "Crop" {
+		t.Fatalf("got operators %+v, want IdahoRead and Crop", ops)
+	}
+
+	if err := api.OperatorInfo(buf, "NotAnOperator"); err == nil {
+		t.Fatal("expected an error describing an unknown operator")
+	}
+}
+
+func TestTemplateRealize(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	_, api := newTestClient(t, s)
+
+	if _, err := api.NewTemplate(DGStripTemplateID).Metadata(); err == nil {
+		t.Fatal("expected an error when the catalogId template parameter is missing")
+	}
+
+	template := api.NewTemplate(DGStripTemplateID, rda.AddParameter("catalogId", "1040010038952900"))
+	md, err := template.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultMetadata()
+	if md.ImageMetadata.ImageWidth != want.ImageMetadata.ImageWidth || md.ImageGeoreferencing != want.ImageGeoreferencing {
+		t.Fatalf("got metadata %+v, want %+v", md, want)
+	}
+
+	tileDir, err := ioutil.TempDir("", "rdatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tileDir)
+
+	template = api.NewTemplate(DGStripTemplateID, rda.AddParameter("catalogId", "1040010038952900"), rda.WithWindow(md.ImageMetadata.TileWindow))
+	tiles, err := template.Realize(context.Background(), tileDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tiles) != 4 {
+		t.Fatalf("realized %d tiles, want 4", len(tiles))
+	}
+
+	// The pixels trail the TIFF header, so the last byte is the last band of the tile's bottom right pixel.
+	b, err := ioutil.ReadFile(filepath.Join(tileDir, "tile_1_1.tif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte{'I', 'I', 42, 0}) {
+		t.Fatalf("tile doesn't start with a TIFF header, got %v", b[:4])
+	}
+	if got, want := b[len(b)-1], byte(Pixel(md, 511, 511, 2)); got != want {
+		t.Fatalf("got last pixel value %d, want %d", got, want)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name       string
+		fault      Fault
+		wantErr    bool
+		wantTiles  int
+		wantTileRq int
+	}{
+		{"retried 503s", Fault{Route: "/tile/", Status: http.StatusServiceUnavailable, Times: 2}, false, 4, 6},
+		{"429 honors Retry-After", Fault{Route: "/tile/", Status: http.StatusTooManyRequests, RetryAfter: time.Second, Times: 1}, false, 4, 5},
+		{"truncated tile", Fault{Route: "/tile/0/0", Truncate: true, Times: 1}, true, 3, 4},
+		{"slow body", Fault{Route: "/tile/", SlowBody: 10 * time.Millisecond}, false, 4, 4},
+		{"slow response", Fault{Route: "/tile/", Delay: 10 * time.Millisecond}, false, 4, 4},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewServer()
+			defer s.Close()
+			_, api := newTestClient(t, s)
+
+			tileDir, err := ioutil.TempDir("", "rdatest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tileDir)
+
+			s.AddFault(tc.fault)
+			md := DefaultMetadata()
+			template := api.NewTemplate(IdahoReadTemplateID,
+				rda.AddParameter("imageId", "image"),
+				rda.AddParameter("bucketName", "bucket"),
+				rda.WithWindow(md.ImageMetadata.TileWindow))
+			tiles, err := template.Realize(context.Background(), tileDir)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, wanted one: %t", err, tc.wantErr)
+			}
+			if len(tiles) != tc.wantTiles {
+				t.Errorf("realized %d tiles, want %d", len(tiles), tc.wantTiles)
+			}
+			if n := s.Requests("/tile/"); n != tc.wantTileRq {
+				t.Errorf("server saw %d tile requests, want %d", n, tc.wantTileRq)
+			}
+
+			// Faults that were used up shouldn't stop a rerun from picking up where we left off.
+			s.ClearFaults()
+			if _, err := template.Realize(context.Background(), tileDir); err != nil {
+				t.Fatalf("rerunning realize failed: %v", err)
+			}
+			files, err := filepath.Glob(filepath.Join(tileDir, "*.tif"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 4 {
+				t.Fatalf("got %d tiles after rerunning realize, want 4", len(files))
+			}
+		})
+	}
+}
+
+func TestTemplateUpload(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	_, api := newTestClient(t, s)
+
+	g, err := api.NewTemplate(DGStripTemplateID).Describe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := api.NewTemplate("").Upload(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.NewTemplate(id).Describe(); err != nil {
+		t.Fatalf("failed describing uploaded template %s: %v", id, err)
+	}
+	if _, err := api.NewTemplate(id, rda.AddParameter("catalogId", "catid")).Metadata(); err != nil {
+		t.Fatalf("failed fetching metadata of uploaded template %s: %v", id, err)
+	}
+}
+
+func TestStripMetadata(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	_, api := newTestClient(t, s)
+
+	parts, err := api.PartSummary("1040010038952900")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts.PanImages) != 2 || len(parts.VNIRImages) != 2 {
+		t.Fatalf("got %d pan and %d vnir parts, want 2 of each", len(parts.PanImages), len(parts.VNIRImages))
+	}
+	if parts.VNIRImages[0].NumBands != 4 {
+		t.Errorf("got %d vnir bands, want 4", parts.VNIRImages[0].NumBands)
+	}
+
+	info, err := api.StripMetadata("1040010038952900")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(info.BandTypes, ","), "PAN,MS,Pansharp"; got != want {
+		t.Errorf("got band types %s, want %s", got, want)
+	}
+	if info.GSD != 0.5 || info.SatElevation != DefaultMetadata().ImageMetadata.SatElevation || info.PartFootprintsWKT == "" {
+		t.Errorf("strip info isn't summarized from the parts: %+v", info)
+	}
+
+	outDir, err := ioutil.TempDir("", "rdatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	rpcs, err := api.PartMetadata("1040010038952900", "MUL_P001", outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rpcs == nil || len(rpcs.LINENUMCOEFList.LINENUMCOEF) != 20 {
+		t.Fatalf("failed parsing RPCs from the factory metadata, got %+v", rpcs)
+	}
+	for _, ext := range []string{".IMD", ".RPB", ".TIL", ".EPH", ".ATT", ".XML"} {
+		if _, err := os.Stat(filepath.Join(outDir, "MUL_P001"+ext)); err != nil {
+			t.Errorf("factory metadata file MUL_P001%s wasn't extracted: %v", ext, err)
+		}
+	}
+
+	fms, err := api.FactoryMetadata("1040010038952900")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fms) != 4 {
+		t.Fatalf("got factory metadata for %d parts, want 4", len(fms))
+	}
+	mul := fms[0]
+	if mul.Prefix != "MUL_P001" || mul.IMD == nil || len(mul.IMD.Bands) != 4 || mul.TIL == nil || mul.Ephemeris == nil || mul.Attitude == nil {
+		t.Fatalf("factory metadata of MUL_P001 is incomplete: %+v", mul)
+	}
+
+	// The key=value files must parse the same as the XML file.
+	f, err := os.Open(filepath.Join(outDir, "MUL_P001.IMD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	imd, err := rda.ParseIMD(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(imd, mul.IMD) {
+		t.Errorf("MUL_P001.IMD parsed as %+v, but the XML has %+v", imd, mul.IMD)
+	}
+	f, err = os.Open(filepath.Join(outDir, "MUL_P001.EPH"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	eph, err := rda.ParseEphemeris(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(eph, mul.Ephemeris) {
+		t.Errorf("MUL_P001.EPH parsed as %+v, but the XML has %+v", eph, mul.Ephemeris)
+	}
+
+	// The IdahoRead template realizes a part's own bands, as DNs.
+	md, err := api.NewTemplate(IdahoReadTemplateID,
+		rda.AddParameter("imageId", parts.VNIRImages[0].ImageID),
+		rda.AddParameter("bucketName", parts.VNIRImages[0].TileBucketName)).Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.ImageMetadata.NumBands != 4 || md.ImageMetadata.DataType != "UNSIGNED_SHORT" {
+		t.Errorf("IdahoRead of a vnir part has %d %s bands, want 4 UNSIGNED_SHORT", md.ImageMetadata.NumBands, md.ImageMetadata.DataType)
+	}
+}
+
+func TestCatalogSearch(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	client, api := newTestClient(t, s)
+	catalog := gbdx.NewCatalog(client, gbdx.WithCatalogEndpoint(s.CatalogURL()))
+	ctx := context.Background()
+
+	ids := func(acqs []gbdx.Acquisition) string {
+		var out []string
+		for _, acq := range acqs {
+			out = append(out, acq.CatalogID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		name    string
+		area    string
+		options []gbdx.SearchOption
+		want    string
+	}{
+		{"all", "POLYGON ((-93.1 36.1, -92.9 36.1, -92.9 36.2, -93.1 36.1))", nil, "1040010000000000," + UnavailableCatalogID + ",1030010000000000"},
+		{"elsewhere", "POLYGON ((10 10, 11 10, 11 11, 10 10))", nil, ""},
+		{"dates", "POLYGON ((-93.1 36.1, -92.9 36.1, -92.9 36.2, -93.1 36.1))", []gbdx.SearchOption{gbdx.AcquiredBetween(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))}, UnavailableCatalogID},
+		{"filters", "POLYGON ((-93.1 36.1, -92.9 36.1, -92.9 36.2, -93.1 36.1))", []gbdx.SearchOption{gbdx.MaxCloudCover(10), gbdx.MaxOffNadir(20)}, "1040010000000000"},
+		{"sensors", "POLYGON ((-93.1 36.1, -92.9 36.1, -92.9 36.2, -93.1 36.1))", []gbdx.SearchOption{gbdx.Sensors("WORLDVIEW02", "GEOEYE01")}, UnavailableCatalogID + ",1030010000000000"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			acqs, err := catalog.Search(ctx, tc.area, tc.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(acqs); got != tc.want {
+				t.Errorf("found %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	for catID, want := range map[string]bool{"1030010000000000": true, UnavailableCatalogID: false} {
+		got, err := api.StripAvailable(ctx, catID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("StripAvailable(%q) = %t, want %t", catID, got, want)
+		}
+	}
+}
+
+func TestBatchMaterialization(t *testing.T) {
+	s := NewServer(WithJobPolls(2))
+	defer s.Close()
+	client, api := newTestClient(t, s)
+	ctx := context.Background()
+
+	template := api.NewTemplate(DGStripTemplateID, rda.AddParameter("catalogId", "1040010038952900"))
+	job, err := template.BatchRealize(ctx, rda.Tif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status.Status != "processing" {
+		t.Fatalf("got job status %q, want processing", job.Status.Status)
+	}
+
+	accessor, err := gbdx.NewS3Accessor(client,
+		gbdx.WithProviderOptions(gbdx.WithCredentialsEndpoint(s.S3CredentialsURL())),
+		gbdx.WithS3Endpoint(s.S3URL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Artifacts show up as the job is polled.
+	for i, want := range []struct {
+		status    string
+		artifacts int
+	}{{"processing", 2}, {"complete", 4}} {
+		jobs, err := api.FetchBatchStatus(ctx, job.JobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := jobs[0].Status.Status; got != want.status {
+			t.Fatalf("poll %d: got job status %q, want %q", i+1, got, want.status)
+		}
+		paths, err := accessor.RDABatchJobObjects(ctx, job.JobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) != want.artifacts {
+			t.Fatalf("poll %d: got artifacts %v, want %d of them", i+1, paths, want.artifacts)
+		}
+	}
+
+	jobIDs, err := accessor.RDABatchJobPrefixes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobIDs) != 1 || jobIDs[0] != job.JobID {
+		t.Fatalf("got job ids %v, want [%s]", jobIDs, job.JobID)
+	}
+
+	outDir, err := ioutil.TempDir("", "rdatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	n, dlFunc, err := accessor.DownloadBatchJobArtifacts(ctx, outDir, job.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Fatalf("got %d artifacts to download, want 4", n)
+	}
+	if err := dlFunc(); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := s.Object(strings.Join([]string{Prefix, "rda", job.JobID, "granule_0_0.tif"}, "/"))
+	got, err := ioutil.ReadFile(filepath.Join(outDir, "granule_0_0.tif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("downloaded artifact doesn't match what was in S3")
+	}
+
+	deleted, err := accessor.RDADeleteBatchJobArtifacts(ctx, job.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 4 || len(s.ObjectKeys(Prefix)) != 0 {
+		t.Fatalf("deleted %d artifacts leaving %v, want all 4 deleted", deleted, s.ObjectKeys(Prefix))
+	}
+}
+
+func TestBatchCallback(t *testing.T) {
+	s := NewServer(WithJobPolls(1))
+	defer s.Close()
+	_, api := newTestClient(t, s)
+	ctx := context.Background()
+
+	callbacks := make(chan *rda.BatchResponse, 1)
+	listener := httptest.NewServer(rda.NewCallbackHandler(func(_ context.Context, resp *rda.BatchResponse) error {
+		callbacks <- resp
+		return nil
+	}, rda.CallbackToken("s3cret")))
+	defer listener.Close()
+
+	template := api.NewTemplate(DGStripTemplateID, rda.AddParameter("catalogId", "1040010038952900"))
+	job, err := template.BatchRealize(ctx, rda.Tif, rda.WithCallbackURL(listener.URL+"/callback?token=s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The job completes when polled, at which point RDA calls back.
+	if _, err := api.FetchBatchStatus(ctx, job.JobID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case resp := <-callbacks:
+		if resp.JobID != job.JobID || resp.Status.Status != "complete" {
+			t.Fatalf("got callback for job %s with status %q, want %s complete", resp.JobID, resp.Status.Status, job.JobID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the job's callback")
+	}
+}
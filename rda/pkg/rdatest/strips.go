@@ -0,0 +1,536 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rdatest
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+)
+
+// part describes a 1B image part of a strip.
+type part struct {
+	Prefix   string // Prefix of the part's factory metadata files, e.g. PAN_P001.
+	ImageID  string
+	BandID   string
+	Bands    []string
+	CatID    string
+	Rows     int
+	Cols     int
+	BitDepth int
+	AcqTime  time.Time
+}
+
+// parts returns the 1B parts the Server reports for every catalog
+// id: two panchromatic and two multispectral parts.
+func (s *Server) parts(catID string) (pan, vnir []part) {
+	s.mu.Lock()
+	im := s.md.ImageMetadata
+	s.mu.Unlock()
+
+	for i := 1; i <= 2; i++ {
+		pan = append(pan, part{
+			Prefix:   fmt.Sprintf("PAN_P%03d", i),
+			ImageID:  fmt.Sprintf("%s-pan-p%03d", catID, i),
+			BandID:   "P",
+			Bands:    []string{"P"},
+			CatID:    catID,
+			Rows:     im.ImageHeight,
+			Cols:     im.ImageWidth,
+			BitDepth: 11,
+			AcqTime:  im.AcquisitionDate,
+		})
+		vnir = append(vnir, part{
+			Prefix:   fmt.Sprintf("MUL_P%03d", i),
+			ImageID:  fmt.Sprintf("%s-mul-p%03d", catID, i),
+			BandID:   "Multi",
+			Bands:    []string{"B", "G", "R", "N"},
+			CatID:    catID,
+			Rows:     im.ImageHeight,
+			Cols:     im.ImageWidth,
+			BitDepth: 11,
+			AcqTime:  im.AcquisitionDate,
+		})
+	}
+	return pan, vnir
+}
+
+// partImage returns md as the IdahoRead template realizes the 1B
+// part imageID, with the part's bands as UNSIGNED_SHORT DNs.  Image
+// ids that aren't one of the Server's parts get md as is.
+func (s *Server) partImage(md rda.Metadata, imageID string) rda.Metadata {
+	pan, vnir := s.parts(strings.SplitN(imageID, "-", 2)[0])
+	for _, p := range append(pan, vnir...) {
+		if p.ImageID == imageID {
+			md.ImageMetadata.NumBands = len(p.Bands)
+			md.ImageMetadata.DataType = "UNSIGNED_SHORT"
+		}
+	}
+	return md
+}
+
+// handleStripMetadata describes the image parts of a strip.
+func (s *Server) handleStripMetadata(w http.ResponseWriter, catID string) {
+	if catID == UnavailableCatalogID {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no strip metadata for %s", catID))
+		return
+	}
+
+	s.mu.Lock()
+	im := s.md.ImageMetadata
+	s.mu.Unlock()
+
+	fp, err := s.footprint()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	images := func(parts []part, gsd float64) []interface{} {
+		out := []interface{}{}
+		for _, p := range parts {
+			pim := im
+			pim.ImageID = p.ImageID
+			pim.NumBands = len(p.Bands)
+			pim.DataType = "UNSIGNED_SHORT"
+			js := imageMetadataJSON(&pim)
+			js["sensorName"] = "WorldView 2"
+			js["sensorPlatformName"] = "WORLDVIEW02"
+			js["groundSampleDistanceMeters"] = gsd
+			js["cloudCover"] = 0
+			js["sunAzimuth"] = 158.3
+			js["sunElevation"] = 41.2
+			js["satAzimuth"] = 212.7
+			js["imageBoundsWGS84"] = fp.WKT()
+			out = append(out, js)
+		}
+		return out
+	}
+
+	pan, vnir := s.parts(catID)
+	writeJSON(w, map[string]interface{}{
+		"catalogIdentifier": catID,
+		"panImages":         images(pan, 0.5),
+		"vnirImages":        images(vnir, 2),
+		"swirImages":        []interface{}{},
+		"cavisImages":       []interface{}{},
+	})
+}
+
+// handleFactoryMetadata returns a zip of the DG factory metadata for
+// each image part of a strip.
+func (s *Server) handleFactoryMetadata(w http.ResponseWriter, catID string) {
+	pan, vnir := s.parts(catID)
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, p := range append(pan, vnir...) {
+		for _, f := range []struct {
+			ext  string
+			tmpl *template.Template
+		}{
+			{".IMD", imdTemplate},
+			{".RPB", rpbTemplate},
+			{".TIL", tilTemplate},
+			{".EPH", ephTemplate},
+			{".ATT", attTemplate},
+			{".XML", xmlTemplate},
+		} {
+			fw, err := zw.Create(p.Prefix + f.ext)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if err := f.tmpl.Execute(fw, p); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Write(buf.Bytes())
+}
+
+// absCalFactors and effectiveBandwidths are the WorldView-2 values
+// for each band, used to fill in factory metadata.
+var (
+	absCalFactors       = map[string]string{"P": "5.678345000000000e-02", "B": "1.260825000000000e-02", "G": "9.713071000000001e-03", "R": "1.103623000000000e-02", "N": "1.224380000000000e-02"}
+	effectiveBandwidths = map[string]string{"P": "2.846000000000000e-01", "B": "5.430000000000000e-02", "G": "6.300000000000000e-02", "R": "5.740000000000000e-02", "N": "9.890000000000000e-02"}
+)
+
+var templateFuncs = template.FuncMap{
+	"absCal":      func(band string) string { return absCalFactors[band] },
+	"bandwidth":   func(band string) string { return effectiveBandwidths[band] },
+	"timestamp":   func(t time.Time) string { return t.UTC().Format("2006-01-02T15:04:05.000000Z") },
+	"lower":       strings.ToLower,
+	"last":        func(n int) int { return n - 1 },
+	"commas":      func(s string) string { return strings.Replace(s, " ", ", ", -1) },
+	"ephemPoints": func() []string { return ephemPoints },
+	"attPoints":   func() []string { return attPoints },
+}
+
+// rpcCoefficients are the 20 RPC coefficients of each polynomial; the
+// line and sample numerators are the only non trivial ones.
+const (
+	rpcLineNum = "+1.0E-03 -2.0E-02 -1.1E+00 +3.0E-03 +1.0E-05 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00"
+	rpcSampNum = "-2.0E-03 +1.0E+00 +1.0E-02 +1.0E-03 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00"
+	rpcDen     = "+1.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00 +0.0E+00"
+)
+
+// ephemPoints and attPoints are the satellite's ephemeris and
+// attitude while collecting every part, one point per half second:
+// the point's index, earth centered, earth fixed position and
+// velocity, or the point's index and attitude quaternion.
+var (
+	ephemPoints = []string{
+		"1 -301578.9638 -5773750.3093 4190844.0216 229.5 4394.3 6028.1",
+		"2 -301464.2252 -5771553.6307 4193858.8681 229.6 4395.6 6027.4",
+		"3 -301349.4037 -5769355.3661 4196872.5760 229.7 4396.9 6026.7",
+	}
+	attPoints = []string{
+		"1 0.16105428 0.22789381 -0.92925924 0.24208097",
+		"2 0.16135292 0.22769244 -0.92915302 0.24247897",
+		"3 0.16165150 0.22749101 -0.92904653 0.24287688",
+	}
+)
+
+var imdTemplate = template.Must(template.New("imd").Funcs(templateFuncs).Parse(`version = "28.3";
+generationTime = 2018-07-21T06:18:47.000000Z;
+productOrderId = "058197559010_01_{{.Prefix}}";
+productCatalogId = "{{.CatID}}";
+imageDescriptor = "Basic1B";
+bandId = "{{.BandID}}";
+panSharpenAlgorithm = "None";
+numRows = {{.Rows}};
+numColumns = {{.Cols}};
+productLevel = "LV1B";
+productType = "Basic";
+numberOfLooks = 1;
+radiometricLevel = "Corrected";
+bitsPerPixel = 16;
+compressionType = "None";
+outputFormat = "GeoTIFF";
+{{- range .Bands}}
+BEGIN_GROUP = BAND_{{.}}
+	ULLon = -93.00000000;
+	ULLat = 36.14000000;
+	ULHAE = 120.00;
+	URLon = -92.98000000;
+	URLat = 36.14000000;
+	URHAE = 120.00;
+	LRLon = -92.98000000;
+	LRLat = 36.12000000;
+	LRHAE = 120.00;
+	LLLon = -93.00000000;
+	LLLat = 36.12000000;
+	LLHAE = 120.00;
+	absCalFactor = {{absCal .}};
+	effectiveBandwidth = {{bandwidth .}};
+	TDILevel = 24;
+END_GROUP = BAND_{{.}}
+{{- end}}
+BEGIN_GROUP = IMAGE_1
+	satId = "WV02";
+	mode = "FullSwath";
+	scanDirection = "Forward";
+	CatId = "{{.CatID}}";
+	firstLineTime = {{timestamp .AcqTime}};
+	avgLineRate = 20000.00;
+	exposureDuration = 0.00005000;
+	minCollectedRowGSD = 1.900;
+	maxCollectedRowGSD = 1.920;
+	meanCollectedRowGSD = 1.910;
+	minCollectedColGSD = 1.880;
+	maxCollectedColGSD = 1.900;
+	meanCollectedColGSD = 1.890;
+	meanCollectedGSD = 1.900;
+	meanProductGSD = 2.000;
+	minSunAz = 150.0;
+	maxSunAz = 150.2;
+	meanSunAz = 150.1;
+	minSunEl = 45.0;
+	maxSunEl = 45.2;
+	meanSunEl = 45.1;
+	minSatAz = 200.0;
+	maxSatAz = 200.4;
+	meanSatAz = 200.2;
+	minSatEl = 70.0;
+	maxSatEl = 70.4;
+	meanSatEl = 70.2;
+	minInTrackViewAngle = -5.0;
+	maxInTrackViewAngle = -4.8;
+	meanInTrackViewAngle = -4.9;
+	minCrossTrackViewAngle = 17.0;
+	maxCrossTrackViewAngle = 17.2;
+	meanCrossTrackViewAngle = 17.1;
+	minOffNadirViewAngle = 17.5;
+	maxOffNadirViewAngle = 17.7;
+	meanOffNadirViewAngle = 17.6;
+	cloudCover = 0.000;
+	revNumber = 30412;
+END_GROUP = IMAGE_1
+END;
+`))
+
+var tilTemplate = template.Must(template.New("til").Funcs(templateFuncs).Parse(`bandId = "{{.BandID}}";
+numTiles = 1;
+tileSizeX = {{.Cols}};
+tileSizeY = {{.Rows}};
+tileUnits = "Pixels";
+tileOverlap = 0;
+BEGIN_GROUP = TILE_1
+	filename = "{{.ImageID}}.TIF";
+	ULColOffset = 0;
+	ULRowOffset = 0;
+	URColOffset = {{last .Cols}};
+	URRowOffset = 0;
+	LRColOffset = {{last .Cols}};
+	LRRowOffset = {{last .Rows}};
+	LLColOffset = 0;
+	LLRowOffset = {{last .Rows}};
+	ULLon = -93.00000000;
+	ULLat = 36.14000000;
+	URLon = -92.98000000;
+	URLat = 36.14000000;
+	LRLon = -92.98000000;
+	LRLat = 36.12000000;
+	LLLon = -93.00000000;
+	LLLat = 36.12000000;
+END_GROUP = TILE_1
+END;
+`))
+
+var ephTemplate = template.Must(template.New("eph").Funcs(templateFuncs).Parse(`satId = "WV02";
+revNumber = 30412;
+stripId = "{{.CatID}}";
+type = "R";
+version = "28";
+generationTime = 2018-07-21T06:18:47.000000Z;
+startTime = {{timestamp .AcqTime}};
+numPoints = 3;
+timeInterval = 0.50;
+ephemList = (
+{{- range $i, $p := ephemPoints}}{{if $i}},{{end}}
+	({{commas $p}})
+{{- end}});
+END;
+`))
+
+var attTemplate = template.Must(template.New("att").Funcs(templateFuncs).Parse(`satId = "WV02";
+revNumber = 30412;
+stripId = "{{.CatID}}";
+type = "R";
+version = "28";
+generationTime = 2018-07-21T06:18:47.000000Z;
+startTime = {{timestamp .AcqTime}};
+numPoints = 3;
+timeInterval = 0.50;
+attList = (
+{{- range $i, $p := attPoints}}{{if $i}},{{end}}
+	({{commas $p}})
+{{- end}});
+END;
+`))
+
+var rpbTemplate = template.Must(template.New("rpb").Funcs(templateFuncs).Parse(`satId = "WV02";
+bandId = "{{.BandID}}";
+SpecId = "RPC00B";
+BEGIN_GROUP = IMAGE
+	errBias = 3.10;
+	errRand = 0.12;
+	lineOffset = {{.Rows}};
+	sampOffset = {{.Cols}};
+	latOffset = 36.1300;
+	longOffset = -92.9900;
+	heightOffset = 120;
+	lineScale = {{.Rows}};
+	sampScale = {{.Cols}};
+	latScale = 0.0100;
+	longScale = 0.0100;
+	heightScale = 500;
+	lineNumCoef = (` + rpcLineNum + `);
+	lineDenCoef = (` + rpcDen + `);
+	sampNumCoef = (` + rpcSampNum + `);
+	sampDenCoef = (` + rpcDen + `);
+END_GROUP = IMAGE
+END;
+`))
+
+var xmlTemplate = template.Must(template.New("xml").Funcs(templateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<isd>
+	<IMD>
+		<VERSION>28.3</VERSION>
+		<GENERATIONTIME>2018-07-21T06:18:47.000000Z</GENERATIONTIME>
+		<PRODUCTORDERID>058197559010_01_{{.Prefix}}</PRODUCTORDERID>
+		<PRODUCTCATALOGID>{{.CatID}}</PRODUCTCATALOGID>
+		<IMAGEDESCRIPTOR>Basic1B</IMAGEDESCRIPTOR>
+		<BANDID>{{.BandID}}</BANDID>
+		<NUMROWS>{{.Rows}}</NUMROWS>
+		<NUMCOLUMNS>{{.Cols}}</NUMCOLUMNS>
+		<PRODUCTLEVEL>LV1B</PRODUCTLEVEL>
+		<PRODUCTTYPE>Basic</PRODUCTTYPE>
+		<RADIOMETRICLEVEL>Corrected</RADIOMETRICLEVEL>
+		<BITSPERPIXEL>16</BITSPERPIXEL>
+		<COMPRESSIONTYPE>None</COMPRESSIONTYPE>
+		<OUTPUTFORMAT>GeoTIFF</OUTPUTFORMAT>
+		{{- range .Bands}}
+		<BAND_{{.}}>
+			<ULLON>-9.300000000000000e+01</ULLON>
+			<ULLAT>3.614000000000000e+01</ULLAT>
+			<ULHAE>1.200000000000000e+02</ULHAE>
+			<URLON>-9.298000000000000e+01</URLON>
+			<URLAT>3.614000000000000e+01</URLAT>
+			<URHAE>1.200000000000000e+02</URHAE>
+			<LRLON>-9.298000000000000e+01</LRLON>
+			<LRLAT>3.612000000000000e+01</LRLAT>
+			<LRHAE>1.200000000000000e+02</LRHAE>
+			<LLLON>-9.300000000000000e+01</LLLON>
+			<LLLAT>3.612000000000000e+01</LLLAT>
+			<LLHAE>1.200000000000000e+02</LLHAE>
+			<ABSCALFACTOR>{{absCal .}}</ABSCALFACTOR>
+			<EFFECTIVEBANDWIDTH>{{bandwidth .}}</EFFECTIVEBANDWIDTH>
+			<TDILEVEL>24</TDILEVEL>
+		</BAND_{{.}}>
+		{{- end}}
+		<IMAGE>
+			<SATID>WV02</SATID>
+			<MODE>FullSwath</MODE>
+			<SCANDIRECTION>Forward</SCANDIRECTION>
+			<CATID>{{.CatID}}</CATID>
+			<FIRSTLINETIME>{{timestamp .AcqTime}}</FIRSTLINETIME>
+			<AVGLINERATE>2.000000000000000e+04</AVGLINERATE>
+			<EXPOSUREDURATION>5.000000000000000e-05</EXPOSUREDURATION>
+			<MEANCOLLECTEDGSD>1.900000000000000e+00</MEANCOLLECTEDGSD>
+			<MEANPRODUCTGSD>2.000000000000000e+00</MEANPRODUCTGSD>
+			<MEANSUNAZ>1.501000000000000e+02</MEANSUNAZ>
+			<MEANSUNEL>4.510000000000000e+01</MEANSUNEL>
+			<MEANSATAZ>2.002000000000000e+02</MEANSATAZ>
+			<MEANSATEL>7.020000000000000e+01</MEANSATEL>
+			<MEANINTRACKVIEWANGLE>-4.900000000000000e+00</MEANINTRACKVIEWANGLE>
+			<MEANCROSSTRACKVIEWANGLE>1.710000000000000e+01</MEANCROSSTRACKVIEWANGLE>
+			<MEANOFFNADIRVIEWANGLE>1.760000000000000e+01</MEANOFFNADIRVIEWANGLE>
+			<CLOUDCOVER>0.000000000000000e+00</CLOUDCOVER>
+			<REVNUMBER>30412</REVNUMBER>
+		</IMAGE>
+	</IMD>
+	<RPB>
+		<SATID>WV02</SATID>
+		<BANDID>{{.BandID}}</BANDID>
+		<SPECID>RPC00B</SPECID>
+		<IMAGE>
+			<ERRBIAS>3.100000000000000e+00</ERRBIAS>
+			<ERRRAND>1.200000000000000e-01</ERRRAND>
+			<LINEOFFSET>{{.Rows}}</LINEOFFSET>
+			<SAMPOFFSET>{{.Cols}}</SAMPOFFSET>
+			<LATOFFSET>3.613000000000000e+01</LATOFFSET>
+			<LONGOFFSET>-9.299000000000000e+01</LONGOFFSET>
+			<HEIGHTOFFSET>120</HEIGHTOFFSET>
+			<LINESCALE>{{.Rows}}</LINESCALE>
+			<SAMPSCALE>{{.Cols}}</SAMPSCALE>
+			<LATSCALE>1.000000000000000e-02</LATSCALE>
+			<LONGSCALE>1.000000000000000e-02</LONGSCALE>
+			<HEIGHTSCALE>500</HEIGHTSCALE>
+			<LINENUMCOEFList>
+				<LINENUMCOEF>` + rpcLineNum + `</LINENUMCOEF>
+			</LINENUMCOEFList>
+			<LINEDENCOEFList>
+				<LINEDENCOEF>` + rpcDen + `</LINEDENCOEF>
+			</LINEDENCOEFList>
+			<SAMPNUMCOEFList>
+				<SAMPNUMCOEF>` + rpcSampNum + `</SAMPNUMCOEF>
+			</SAMPNUMCOEFList>
+			<SAMPDENCOEFList>
+				<SAMPDENCOEF>` + rpcDen + `</SAMPDENCOEF>
+			</SAMPDENCOEFList>
+		</IMAGE>
+	</RPB>
+	<TIL>
+		<BANDID>{{.BandID}}</BANDID>
+		<NUMTILES>1</NUMTILES>
+		<TILESIZEX>{{.Cols}}</TILESIZEX>
+		<TILESIZEY>{{.Rows}}</TILESIZEY>
+		<TILEUNITS>Pixels</TILEUNITS>
+		<TILEOVERLAP>0</TILEOVERLAP>
+		<TILE>
+			<FILENAME>{{.ImageID}}.TIF</FILENAME>
+			<ULCOLOFFSET>0</ULCOLOFFSET>
+			<ULROWOFFSET>0</ULROWOFFSET>
+			<URCOLOFFSET>{{last .Cols}}</URCOLOFFSET>
+			<URROWOFFSET>0</URROWOFFSET>
+			<LRCOLOFFSET>{{last .Cols}}</LRCOLOFFSET>
+			<LRROWOFFSET>{{last .Rows}}</LRROWOFFSET>
+			<LLCOLOFFSET>0</LLCOLOFFSET>
+			<LLROWOFFSET>{{last .Rows}}</LLROWOFFSET>
+			<ULLON>-9.300000000000000e+01</ULLON>
+			<ULLAT>3.614000000000000e+01</ULLAT>
+			<URLON>-9.298000000000000e+01</URLON>
+			<URLAT>3.614000000000000e+01</URLAT>
+			<LRLON>-9.298000000000000e+01</LRLON>
+			<LRLAT>3.612000000000000e+01</LRLAT>
+			<LLLON>-9.300000000000000e+01</LLLON>
+			<LLLAT>3.612000000000000e+01</LLLAT>
+		</TILE>
+	</TIL>
+	<EPH>
+		<SATID>WV02</SATID>
+		<REVNUMBER>30412</REVNUMBER>
+		<STRIPID>{{.CatID}}</STRIPID>
+		<TYPE>R</TYPE>
+		<VERSION>28</VERSION>
+		<GENERATIONTIME>2018-07-21T06:18:47.000000Z</GENERATIONTIME>
+		<STARTTIME>{{timestamp .AcqTime}}</STARTTIME>
+		<NUMPOINTS>3</NUMPOINTS>
+		<TIMEINTERVAL>5.000000000000000e-01</TIMEINTERVAL>
+		<EPHEMLISTList>
+			{{- range ephemPoints}}
+			<EPHEMLIST>{{.}}</EPHEMLIST>
+			{{- end}}
+		</EPHEMLISTList>
+	</EPH>
+	<ATT>
+		<SATID>WV02</SATID>
+		<REVNUMBER>30412</REVNUMBER>
+		<STRIPID>{{.CatID}}</STRIPID>
+		<TYPE>R</TYPE>
+		<VERSION>28</VERSION>
+		<GENERATIONTIME>2018-07-21T06:18:47.000000Z</GENERATIONTIME>
+		<STARTTIME>{{timestamp .AcqTime}}</STARTTIME>
+		<NUMPOINTS>3</NUMPOINTS>
+		<TIMEINTERVAL>5.000000000000000e-01</TIMEINTERVAL>
+		<ATTLISTList>
+			{{- range attPoints}}
+			<ATTLIST>{{.}}</ATTLIST>
+			{{- end}}
+		</ATTLISTList>
+	</ATT>
+</isd>
+`))
@@ -0,0 +1,262 @@
+// Copyright © 2018 DigitalGlobe
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rdatest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/DigitalGlobe/rdatools/rda/pkg/rda"
+	"github.com/pkg/errors"
+)
+
+// Pixel returns the synthetic value the Server uses for the pixel at
+// (col, row) in the given band of the image described by md.  Values
+// are small enough to be represented by any RDA data type.
+func Pixel(md *rda.Metadata, col, row, band int) float64 {
+	return float64((col + 2*row + 7*band) % 251)
+}
+
+// sampleFormat describes how a RDA data type is laid out in a TIFF.
+type sampleFormat struct {
+	bytesPerSample int
+	format         uint16 // 1 = unsigned int, 2 = signed int, 3 = IEEE float.
+}
+
+func rdaSampleFormat(dataType string) (sampleFormat, error) {
+	switch strings.ToLower(dataType) {
+	case "byte":
+		return sampleFormat{1, 1}, nil
+	case "short":
+		return sampleFormat{2, 2}, nil
+	case "unsigned_short":
+		return sampleFormat{2, 1}, nil
+	case "integer":
+		return sampleFormat{4, 2}, nil
+	case "unsigned_integer":
+		return sampleFormat{4, 1}, nil
+	case "float":
+		return sampleFormat{4, 3}, nil
+	case "double":
+		return sampleFormat{8, 3}, nil
+	}
+	return sampleFormat{}, errors.Errorf("RDA type %q is not supported by rdatest", dataType)
+}
+
+func (sf sampleFormat) put(b []byte, v float64) {
+	switch {
+	case sf.format == 3 && sf.bytesPerSample == 4:
+		binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v)))
+	case sf.format == 3:
+		binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+	case sf.bytesPerSample == 1:
+		b[0] = uint8(v)
+	case sf.bytesPerSample == 2:
+		binary.LittleEndian.PutUint16(b, uint16(v))
+	default:
+		binary.LittleEndian.PutUint32(b, uint32(v))
+	}
+}
+
+// encodeTile returns the RDA tile at (xTile, yTile) as an
+// uncompressed, pixel interleaved GeoTIFF, which is what RDA hands
+// back from its tile endpoint.
+func encodeTile(md *rda.Metadata, xTile, yTile int) ([]byte, error) {
+	sf, err := rdaSampleFormat(md.ImageMetadata.DataType)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height, nBands := md.ImageMetadata.TileXSize, md.ImageMetadata.TileYSize, md.ImageMetadata.NumBands
+	col0, row0 := xTile*width, yTile*height
+
+	pixels := make([]byte, width*height*nBands*sf.bytesPerSample)
+	i := 0
+	for row := 0; row < height; row++ {
+		for col := 0; col < width; col++ {
+			for band := 0; band < nBands; band++ {
+				sf.put(pixels[i:], Pixel(md, col0+col, row0+row, band))
+				i += sf.bytesPerSample
+			}
+		}
+	}
+
+	bps := make([]uint16, nBands)
+	sfs := make([]uint16, nBands)
+	for b := range bps {
+		bps[b] = uint16(8 * sf.bytesPerSample)
+		sfs[b] = sf.format
+	}
+
+	ifd := []ifdEntry{
+		{tag: 256, long: []uint32{uint32(width)}},  // ImageWidth
+		{tag: 257, long: []uint32{uint32(height)}}, // ImageLength
+		{tag: 258, short: bps},                     // BitsPerSample
+		{tag: 259, short: []uint16{1}},             // Compression, none
+		{tag: 262, short: []uint16{1}},             // PhotometricInterpretation, min is black
+		{tag: 273, long: []uint32{0}},              // StripOffsets, patched below
+		{tag: 277, short: []uint16{uint16(nBands)}},
+		{tag: 278, long: []uint32{uint32(height)}},      // RowsPerStrip
+		{tag: 279, long: []uint32{uint32(len(pixels))}}, // StripByteCounts
+		{tag: 284, short: []uint16{1}},                  // PlanarConfiguration, chunky
+		{tag: 339, short: sfs},                          // SampleFormat
+	}
+	if nBands > 1 {
+		ifd = append(ifd, ifdEntry{tag: 338, short: make([]uint16, nBands-1)}) // ExtraSamples, unspecified
+	}
+
+	// Georeference the tile if the image is georeferenced.
+	gt := md.ImageGeoreferencing
+	if epsg := epsgCode(gt.SpatialReferenceSystemCode); epsg > 0 {
+		x, y := gt.Apply(float64(col0), float64(row0))
+		ifd = append(ifd,
+			ifdEntry{tag: 33550, double: []float64{gt.ScaleX, -gt.ScaleY, 0}}, // ModelPixelScale
+			ifdEntry{tag: 33922, double: []float64{0, 0, 0, x, y, 0}},         // ModelTiepoint
+			ifdEntry{tag: 34735, short: geoKeys(epsg)},                        // GeoKeyDirectory
+		)
+	}
+
+	return writeTIFF(ifd, pixels), nil
+}
+
+// epsgCode returns the numeric code of srs if it is of the form "EPSG:<code>", otherwise 0.
+func epsgCode(srs string) int {
+	if !strings.HasPrefix(strings.ToUpper(srs), "EPSG:") {
+		return 0
+	}
+	code, err := strconv.Atoi(srs[len("EPSG:"):])
+	if err != nil {
+		return 0
+	}
+	return code
+}
+
+// geoKeys returns a GeoKeyDirectory declaring the EPSG code as either
+// a geographic or projected coordinate system.
+func geoKeys(epsg int) []uint16 {
+	if epsg == 4326 {
+		return []uint16{
+			1, 1, 0, 3,
+			1024, 0, 1, 2, // GTModelTypeGeoKey = geographic
+			1025, 0, 1, 1, // GTRasterTypeGeoKey = pixel is area
+			2048, 0, 1, uint16(epsg), // GeographicTypeGeoKey
+		}
+	}
+	return []uint16{
+		1, 1, 0, 3,
+		1024, 0, 1, 1, // GTModelTypeGeoKey = projected
+		1025, 0, 1, 1, // GTRasterTypeGeoKey = pixel is area
+		3072, 0, 1, uint16(epsg), // ProjectedCSTypeGeoKey
+	}
+}
+
+// ifdEntry is a TIFF tag holding one of the value types we write.
+type ifdEntry struct {
+	tag    uint16
+	short  []uint16
+	long   []uint32
+	double []float64
+}
+
+func (e ifdEntry) typeCountSize() (typ uint16, count, size int) {
+	switch {
+	case e.short != nil:
+		return 3, len(e.short), 2 * len(e.short)
+	case e.long != nil:
+		return 4, len(e.long), 4 * len(e.long)
+	default:
+		return 12, len(e.double), 8 * len(e.double)
+	}
+}
+
+func (e ifdEntry) values() []byte {
+	buf := &bytes.Buffer{}
+	switch {
+	case e.short != nil:
+		binary.Write(buf, binary.LittleEndian, e.short)
+	case e.long != nil:
+		binary.Write(buf, binary.LittleEndian, e.long)
+	default:
+		binary.Write(buf, binary.LittleEndian, e.double)
+	}
+	return buf.Bytes()
+}
+
+// writeTIFF lays out a little endian TIFF as header, IFD, out of line
+// tag values, and then the single strip of pixel data.
+func writeTIFF(ifd []ifdEntry, pixels []byte) []byte {
+	// Tags must be sorted in a TIFF IFD.
+	for i := 1; i < len(ifd); i++ {
+		for j := i; j > 0 && ifd[j].tag < ifd[j-1].tag; j-- {
+			ifd[j], ifd[j-1] = ifd[j-1], ifd[j]
+		}
+	}
+
+	const headerSize = 8
+	ifdSize := 2 + 12*len(ifd) + 4
+	extraOffset := headerSize + ifdSize
+
+	// Figure out where the out of line values and pixels will live.
+	extraSize := 0
+	for _, e := range ifd {
+		if _, _, size := e.typeCountSize(); size > 4 {
+			extraSize += size + size%2
+		}
+	}
+	pixelOffset := extraOffset + extraSize
+	for i, e := range ifd {
+		if e.tag == 273 {
+			ifd[i].long = []uint32{uint32(pixelOffset)}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write([]byte{'I', 'I', 42, 0})
+	binary.Write(buf, binary.LittleEndian, uint32(headerSize))
+
+	binary.Write(buf, binary.LittleEndian, uint16(len(ifd)))
+	extra := &bytes.Buffer{}
+	for _, e := range ifd {
+		typ, count, size := e.typeCountSize()
+		binary.Write(buf, binary.LittleEndian, e.tag)
+		binary.Write(buf, binary.LittleEndian, typ)
+		binary.Write(buf, binary.LittleEndian, uint32(count))
+		if size <= 4 {
+			v := make([]byte, 4)
+			copy(v, e.values())
+			buf.Write(v)
+			continue
+		}
+		binary.Write(buf, binary.LittleEndian, uint32(extraOffset+extra.Len()))
+		extra.Write(e.values())
+		if size%2 == 1 {
+			extra.WriteByte(0)
+		}
+	}
+	binary.Write(buf, binary.LittleEndian, uint32(0)) // No more IFDs.
+
+	buf.Write(extra.Bytes())
+	buf.Write(pixels)
+	return buf.Bytes()
+}